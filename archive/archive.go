@@ -0,0 +1,380 @@
+// Package archive copies everything the chaincode stores to a portable file and
+// back, for year-end copies of the books and for moving to a new network.
+//
+// An archive is newline delimited JSON. The first line is the manifest, naming
+// the format and its version and giving the number of entries and the SHA-256
+// checksum of each section. Every other line is an entry: a document of one of
+// the doctypes, as it was stored, or a marker key. Entries are written in the
+// order they are restored, so the fund of a capital account always comes first.
+//
+// An export is not a point-in-time snapshot. Each page of each section is read by
+// a query of its own, so a transaction committed while exporting can show up in
+// some sections and not others. The manifest says so in its consistency field;
+// export while nothing is being submitted for a consistent copy.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const FORMAT string = "admin-ledger-archive"
+const VERSION int = 1
+
+// Every page of an export is read by a separate query
+const CONSISTENCY_PER_QUERY string = "per-query"
+
+// Documents fetched per query while exporting
+const EXPORT_PAGE_SIZE int32 = 500
+
+const DEFAULT_BATCH_SIZE int = 50
+const MAX_BATCH_SIZE int = 200
+
+var UnsupportedArchiveError = errors.New("not a ledger archive of a supported version")
+var CorruptArchiveError = errors.New("the archive is corrupt")
+
+// Runs a chaincode query or transaction by name
+type Invoker func(name string, args ...string) ([]byte, error)
+
+// The first line of an archive
+type Manifest struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	// How consistent the sections are with each other, CONSISTENCY_PER_QUERY for
+	// every export so far
+	Consistency string    `json:"consistency,omitempty"`
+	Sections    []Section `json:"sections"`
+}
+
+// A section holds the entries of a doctype, or the marker keys. The checksum is
+// over the lines of its entries, newlines included.
+type Section struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// A line of an archive after the manifest
+type entry struct {
+	Section  string                `json:"section"`
+	ID       string                `json:"id,omitempty"`
+	Document json.RawMessage       `json:"document,omitempty"`
+	Marker   *types.ExportedMarker `json:"marker,omitempty"`
+}
+
+// The contents of an archive, by section
+type Archive struct {
+	Manifest  Manifest
+	Documents map[string][]types.ExportedDocument
+	Markers   []types.ExportedMarker
+}
+
+// The sections of every archive in the order they are written and restored
+func sectionNames() []string {
+	return append(append([]string{}, types.EXPORT_DOCTYPES...), types.EXPORT_MARKERS_SECTION)
+}
+
+// Reads every document and marker key through the ExportDocuments and
+// ExportMarkers queries, a page at a time. The pages are separate queries, so
+// writes committed meanwhile may be partly included.
+func Fetch(evaluate Invoker) (*Archive, error) {
+	a := &Archive{Documents: map[string][]types.ExportedDocument{}, Markers: []types.ExportedMarker{}}
+	for _, docType := range types.EXPORT_DOCTYPES {
+		documents := []types.ExportedDocument{}
+		bookmark := ""
+		for {
+			result, err := evaluate("ExportDocuments", docType, strconv.Itoa(int(EXPORT_PAGE_SIZE)), bookmark)
+			if err != nil {
+				return nil, err
+			}
+			var page types.ExportPage
+			err = json.Unmarshal(result, &page)
+			if err != nil {
+				return nil, err
+			}
+			for _, document := range page.Records {
+				documents = append(documents, *document)
+			}
+			if page.Bookmark == "" || page.FetchedRecordsCount < EXPORT_PAGE_SIZE {
+				break
+			}
+			bookmark = page.Bookmark
+		}
+		a.Documents[docType] = documents
+	}
+	for _, objectType := range types.EXPORT_MARKERS {
+		bookmark := ""
+		for {
+			result, err := evaluate("ExportMarkers", objectType, strconv.Itoa(int(EXPORT_PAGE_SIZE)), bookmark)
+			if err != nil {
+				return nil, err
+			}
+			var page types.ExportMarkerPage
+			err = json.Unmarshal(result, &page)
+			if err != nil {
+				return nil, err
+			}
+			for _, marker := range page.Records {
+				a.Markers = append(a.Markers, *marker)
+			}
+			if page.Bookmark == "" || page.FetchedRecordsCount < EXPORT_PAGE_SIZE {
+				break
+			}
+			bookmark = page.Bookmark
+		}
+	}
+	return a, nil
+}
+
+// Fetches everything the chaincode stores and writes it to w as an archive
+func Export(evaluate Invoker, w io.Writer) (*Manifest, error) {
+	a, err := Fetch(evaluate)
+	if err != nil {
+		return nil, err
+	}
+	err = a.Write(w, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &a.Manifest, nil
+}
+
+// Writes the archive with a manifest exported at exportedAt. The manifest of a is
+// replaced by the one written.
+func (a *Archive) Write(w io.Writer, exportedAt time.Time) error {
+	manifest := Manifest{
+		Format:      FORMAT,
+		Version:     VERSION,
+		ExportedAt:  exportedAt,
+		Consistency: CONSISTENCY_PER_QUERY,
+		Sections:    []Section{},
+	}
+	var body bytes.Buffer
+	for _, name := range sectionNames() {
+		entries := []entry{}
+		if name == types.EXPORT_MARKERS_SECTION {
+			for i := range a.Markers {
+				entries = append(entries, entry{Section: name, Marker: &a.Markers[i]})
+			}
+		} else {
+			for _, document := range a.Documents[name] {
+				entries = append(entries, entry{Section: name, ID: document.ID, Document: json.RawMessage(document.Document)})
+			}
+		}
+		sum := sha256.New()
+		for _, e := range entries {
+			line, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			line = append(line, '\n')
+			sum.Write(line)
+			body.Write(line)
+		}
+		manifest.Sections = append(manifest.Sections, Section{Name: name, Count: len(entries), SHA256: hex.EncodeToString(sum.Sum(nil))})
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(manifestJSON, '\n'))
+	if err != nil {
+		return err
+	}
+	_, err = body.WriteTo(w)
+	if err != nil {
+		return err
+	}
+	a.Manifest = manifest
+	return nil
+}
+
+// Reads an archive, checking the count and checksum of every section against
+// the manifest before returning anything
+func Read(r io.Reader) (*Archive, error) {
+	reader := bufio.NewReader(r)
+	line, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var manifest Manifest
+	if json.Unmarshal(line, &manifest) != nil || manifest.Format != FORMAT {
+		return nil, UnsupportedArchiveError
+	}
+	if manifest.Version != VERSION {
+		return nil, fmt.Errorf("%w: version %d", UnsupportedArchiveError, manifest.Version)
+	}
+
+	a := &Archive{Manifest: manifest, Documents: map[string][]types.ExportedDocument{}, Markers: []types.ExportedMarker{}}
+	known := map[string]bool{}
+	for _, name := range sectionNames() {
+		known[name] = true
+	}
+	sums := map[string]*sectionSum{}
+	for _, section := range manifest.Sections {
+		if !known[section.Name] {
+			return nil, fmt.Errorf("%w: unknown section %q", UnsupportedArchiveError, section.Name)
+		}
+		sums[section.Name] = &sectionSum{hash: sha256.New()}
+	}
+	for number := 2; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		//the last line may have lost its newline, which the checksum covers
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			line = append(line, '\n')
+		}
+		var e entry
+		err = json.Unmarshal(line, &e)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %s", CorruptArchiveError, number, err.Error())
+		}
+		sum, ok := sums[e.Section]
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: the manifest has no section %q", CorruptArchiveError, number, e.Section)
+		}
+		sum.hash.Write(line)
+		sum.count++
+		if e.Section == types.EXPORT_MARKERS_SECTION {
+			if e.Marker == nil {
+				return nil, fmt.Errorf("%w: line %d: a marker entry without a marker", CorruptArchiveError, number)
+			}
+			a.Markers = append(a.Markers, *e.Marker)
+		} else {
+			if e.ID == "" || len(e.Document) == 0 {
+				return nil, fmt.Errorf("%w: line %d: a document entry without an id or a document", CorruptArchiveError, number)
+			}
+			a.Documents[e.Section] = append(a.Documents[e.Section], types.ExportedDocument{ID: e.ID, Document: string(e.Document)})
+		}
+	}
+	for _, section := range manifest.Sections {
+		sum := sums[section.Name]
+		if sum.count != section.Count || hex.EncodeToString(sum.hash.Sum(nil)) != section.SHA256 {
+			return nil, fmt.Errorf("%w: the %s section does not match its checksum", CorruptArchiveError, section.Name)
+		}
+	}
+	return a, nil
+}
+
+// The checksum and number of the entries read for a section
+type sectionSum struct {
+	hash  hash.Hash
+	count int
+}
+
+// The outcome of a restore, by section
+type RestoreReport struct {
+	Sections []types.RestoreResult `json:"sections"`
+	Batches  int                   `json:"batches"`
+}
+
+func (r *RestoreReport) add(result types.RestoreResult) {
+	r.Batches += 1
+	for i := range r.Sections {
+		if r.Sections[i].Section == result.Section {
+			r.Sections[i].Restored += result.Restored
+			r.Sections[i].Skipped += result.Skipped
+			return
+		}
+	}
+	r.Sections = append(r.Sections, result)
+}
+
+func (r *RestoreReport) committed() int {
+	count := 0
+	for _, section := range r.Sections {
+		count += section.Restored + section.Skipped
+	}
+	return count
+}
+
+// Writes the archive to the ledger through the RestoreDocuments and
+// RestoreMarkers transactions, batchSize entries per transaction, stopping at the
+// first batch that fails. Entries already on the ledger are skipped, so a failed
+// restore is resumed by running it again. The report covers the batches committed
+// before a failure, and a coded error gets the number of entries they hold as its
+// committedEntries detail.
+func Restore(a *Archive, batchSize int, submit Invoker) (*RestoreReport, error) {
+	if batchSize <= 0 {
+		batchSize = DEFAULT_BATCH_SIZE
+	}
+	if batchSize > MAX_BATCH_SIZE {
+		batchSize = MAX_BATCH_SIZE
+	}
+	report := &RestoreReport{Sections: []types.RestoreResult{}}
+	fail := func(err error) (*RestoreReport, error) {
+		if coded, ok := pkgErrors.From(err); ok {
+			err = coded.WithDetail("committedEntries", strconv.Itoa(report.committed()))
+		}
+		return report, err
+	}
+	for _, docType := range types.EXPORT_DOCTYPES {
+		documents := a.Documents[docType]
+		for start := 0; start < len(documents); start += batchSize {
+			end := start + batchSize
+			if end > len(documents) {
+				end = len(documents)
+			}
+			batch := []string{}
+			for _, document := range documents[start:end] {
+				batch = append(batch, document.Document)
+			}
+			result, err := submitBatch(submit, "RestoreDocuments", docType, batch)
+			if err != nil {
+				return fail(err)
+			}
+			report.add(*result)
+		}
+	}
+	for start := 0; start < len(a.Markers); start += batchSize {
+		end := start + batchSize
+		if end > len(a.Markers) {
+			end = len(a.Markers)
+		}
+		result, err := submitBatch(submit, "RestoreMarkers", "", a.Markers[start:end])
+		if err != nil {
+			return fail(err)
+		}
+		report.add(*result)
+	}
+	return report, nil
+}
+
+func submitBatch(submit Invoker, name string, docType string, batch interface{}) (*types.RestoreResult, error) {
+	batchJSON, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{string(batchJSON)}
+	if docType != "" {
+		args = append([]string{docType}, args...)
+	}
+	result, err := submit(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	var restored types.RestoreResult
+	err = json.Unmarshal(result, &restored)
+	if err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
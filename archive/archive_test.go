@@ -0,0 +1,145 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/archive"
+	"github.com/zacharyfrederick/admin/local"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func submit(t *testing.T, backend *local.Backend, name string, args ...string) {
+	_, err := backend.SubmitTransaction(name, args...)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+}
+
+// A bootstrapped fund with a portfolio and an account opened mid year, which
+// leaves a marker key, and a riskless rate
+func books(t *testing.T) *local.Backend {
+	backend, err := local.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit(t, backend, "CreateFund", "fund", "Test Fund", "01-01-2020")
+	submit(t, backend, "CreateInvestor", "investor", "Investor")
+	submit(t, backend, "CreateInvestor", "late", "Late Investor")
+	submit(t, backend, "CreateCapitalAccount", "account", "fund", "investor", "false", "0")
+	submit(t, backend, "CreateCapitalAccountAction", "deposit", "account", "deposit", "1000", "false", "01-01-2020", "0")
+	submit(t, backend, "CreatePortfolio", "portfolio", "fund", "Main")
+	submit(t, backend, "CreatePortfolioAction", "buy", "portfolio", "buy", "01-01-2020", "0", "Apple", "037833100", "10", "USD")
+	submit(t, backend, "BootstrapFund", "fund")
+	submit(t, backend, "MidYearDeposit", "lateAccount", "fund", "late", "false", "0")
+	submit(t, backend, "SetRisklessRate", "tbill", "T-bill", "01-01-2020", "0.01")
+	submit(t, backend, "SetSecurity", "037833100", "Apple Inc", "equity", "Information Technology", "US", "Apple Inc")
+	return backend
+}
+
+func export(t *testing.T, backend *local.Backend) []byte {
+	var buffer bytes.Buffer
+	manifest, err := archive.Export(backend.EvaluateTransaction, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, manifest.Sections, len(types.EXPORT_DOCTYPES)+1)
+	return buffer.Bytes()
+}
+
+func TestExportAndRestore(t *testing.T) {
+	original := books(t)
+	data := export(t, original)
+	exported, err := archive.Read(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, archive.VERSION, exported.Manifest.Version)
+	assert.Equal(t, archive.CONSISTENCY_PER_QUERY, exported.Manifest.Consistency)
+	assert.Len(t, exported.Documents["fund"], 1)
+	assert.Len(t, exported.Documents["investor"], 2)
+	assert.Len(t, exported.Documents["capitalAccount"], 2)
+	assert.Len(t, exported.Documents["capitalAccountAction"], 1)
+	assert.Len(t, exported.Documents["portfolioAction"], 1)
+	assert.Len(t, exported.Documents["risklessRate"], 1)
+	assert.Len(t, exported.Documents["security"], 1)
+	assert.Len(t, exported.Markers, 1)
+
+	restored, _ := local.Open("")
+	report, err := archive.Restore(exported, 1, restored.SubmitTransaction)
+	assert.Nil(t, err)
+	assert.Equal(t, 11, report.Batches)
+	for _, section := range report.Sections {
+		assert.Zero(t, section.Skipped, section.Section)
+	}
+
+	//the restored ledger exports the same documents, so its index keys were written too
+	again, err := archive.Fetch(restored.EvaluateTransaction)
+	assert.Nil(t, err)
+	assert.Equal(t, exported.Documents, again.Documents)
+	assert.Equal(t, exported.Markers, again.Markers)
+
+	//and it can carry on from where the original left off
+	submit(t, restored, "CreateCapitalAccountAction", "second", "lateAccount", "deposit", "500", "false", "01-15-2020", "1")
+
+	//restoring again skips everything
+	report, err = archive.Restore(exported, 0, restored.SubmitTransaction)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Batches-len(types.EXPORT_DOCTYPES))
+	for _, section := range report.Sections {
+		assert.Zero(t, section.Restored, section.Section)
+	}
+}
+
+func TestRestoreStopsAtConflicts(t *testing.T) {
+	exported, err := archive.Read(bytes.NewReader(export(t, books(t))))
+	assert.Nil(t, err)
+	target, _ := local.Open("")
+	submit(t, target, "CreateInvestor", "late", "Someone Else")
+
+	report, err := archive.Restore(exported, 0, target.SubmitTransaction)
+	assert.True(t, errors.Is(err, pkgErrors.IdAlreadyInUseError))
+	coded, _ := pkgErrors.From(err)
+	assert.Equal(t, "late", coded.Details["id"])
+	assert.Equal(t, "1", coded.Details["committedEntries"])
+	assert.Equal(t, []types.RestoreResult{{Section: "fund", Restored: 1}}, report.Sections)
+}
+
+func TestReadChecksArchives(t *testing.T) {
+	data := export(t, books(t))
+
+	//a changed amount no longer matches the checksum of its section
+	tampered := bytes.Replace(data, []byte(`"amount":"1000"`), []byte(`"amount":"9000"`), 1)
+	assert.NotEqual(t, data, tampered)
+	_, err := archive.Read(bytes.NewReader(tampered))
+	assert.True(t, errors.Is(err, archive.CorruptArchiveError))
+	assert.Contains(t, err.Error(), "capitalAccountAction")
+
+	//so does a missing entry
+	lines := strings.SplitAfter(string(data), "\n")
+	_, err = archive.Read(strings.NewReader(strings.Join(append(lines[:2], lines[3:]...), "")))
+	assert.True(t, errors.Is(err, archive.CorruptArchiveError))
+
+	//the final newline is optional
+	_, err = archive.Read(bytes.NewReader(bytes.TrimSuffix(data, []byte("\n"))))
+	assert.Nil(t, err)
+
+	_, err = archive.Read(strings.NewReader(`{"format":"admin-ledger-archive","version":2}` + "\n"))
+	assert.True(t, errors.Is(err, archive.UnsupportedArchiveError))
+	_, err = archive.Read(strings.NewReader("reference,investorName\n"))
+	assert.True(t, errors.Is(err, archive.UnsupportedArchiveError))
+}
+
+func TestWriteIsDeterministic(t *testing.T) {
+	exported, err := archive.Fetch(books(t).EvaluateTransaction)
+	assert.Nil(t, err)
+	exportedAt := time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC)
+	var first, second bytes.Buffer
+	assert.Nil(t, exported.Write(&first, exportedAt))
+	assert.Nil(t, exported.Write(&second, exportedAt))
+	assert.Equal(t, first.String(), second.String())
+	assert.True(t, strings.HasPrefix(first.String(), `{"format":"admin-ledger-archive","version":1,"exportedAt":"2020-12-31T00:00:00Z"`))
+}
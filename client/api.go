@@ -0,0 +1,497 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const TRANSACTION_STATUS_PENDING string = "pending"
+const TRANSACTION_STATUS_VALID string = "valid"
+
+// The state of a transaction submitted with ?async=true, as reported by
+// GET /transactions/:txid
+type Transaction struct {
+	ID             string           `json:"txId"`
+	Transaction    string           `json:"transaction"`
+	Subject        string           `json:"subject,omitempty"`
+	LedgerId       string           `json:"ledgerId,omitempty"`
+	Status         string           `json:"status"`
+	Attempts       int              `json:"attempts,omitempty"`
+	FabricTxId     string           `json:"fabricTxId,omitempty"`
+	BlockNumber    uint64           `json:"blockNumber,omitempty"`
+	ValidationCode string           `json:"validationCode,omitempty"`
+	Result         string           `json:"result,omitempty"`
+	Error          *pkgErrors.Error `json:"error,omitempty"`
+	SubmittedAt    time.Time        `json:"submittedAt"`
+	CompletedAt    *time.Time       `json:"completedAt,omitempty"`
+}
+
+func (t *Transaction) Pending() bool {
+	return t.Status == TRANSACTION_STATUS_PENDING
+}
+
+// The answer to a request submitted in the background. ID is the id of the created
+// document and is empty for requests that do not create one.
+type Accepted struct {
+	ID     string
+	TxId   string
+	Status string
+}
+
+var asyncQuery = url.Values{"async": []string{"true"}}
+
+func (c *Client) create(ctx context.Context, path string, field string, body interface{}, options []RequestOption) (string, error) {
+	var response map[string]string
+	err := c.do(ctx, call{Method: "POST", Path: path, Body: body}, &response, options...)
+	if err != nil {
+		return "", err
+	}
+	return response[field], nil
+}
+
+func (c *Client) submitAsync(ctx context.Context, path string, field string, body interface{}, options []RequestOption) (*Accepted, error) {
+	var response map[string]string
+	err := c.do(ctx, call{Method: "POST", Path: path, Query: asyncQuery, Body: body}, &response, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Accepted{ID: response[field], TxId: response["txId"], Status: response["status"]}, nil
+}
+
+func (c *Client) CreateFund(ctx context.Context, request types.CreateFundRequest, options ...RequestOption) (string, error) {
+	return c.create(ctx, "/funds", "fundId", request, options)
+}
+
+func (c *Client) CreateFundAsync(ctx context.Context, request types.CreateFundRequest, options ...RequestOption) (*Accepted, error) {
+	return c.submitAsync(ctx, "/funds", "fundId", request, options)
+}
+
+func (c *Client) ListFunds(ctx context.Context, page Page) (*types.FundPage, error) {
+	var result types.FundPage
+	err := c.do(ctx, call{Method: "GET", Path: "/funds", Query: page.query()}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetFund(ctx context.Context, id string) (*types.Fund, error) {
+	var result types.Fund
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListFundInvestors(ctx context.Context, id string, page Page) (*types.InvestorPage, error) {
+	var result types.InvestorPage
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/investors", Params: []string{id}, Query: page.query()}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListFundCapitalAccounts(ctx context.Context, id string, page Page) (*types.CapitalAccountPage, error) {
+	var result types.CapitalAccountPage
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/capitalaccounts", Params: []string{id}, Query: page.query()}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListFundPortfolios(ctx context.Context, id string, page Page) (*types.PortfolioPage, error) {
+	var result types.PortfolioPage
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/portfolios", Params: []string{id}, Query: page.query()}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListFundCapitalAccountActions(ctx context.Context, id string, filter types.ActionFilter, page Page) (*types.CapitalAccountActionPage, error) {
+	var result types.CapitalAccountActionPage
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/capitalaccountactions", Params: []string{id}, Query: actionQuery(filter, page)}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListFundPortfolioActions(ctx context.Context, id string, filter types.ActionFilter, page Page) (*types.PortfolioActionPage, error) {
+	var result types.PortfolioActionPage
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/portfolioactions", Params: []string{id}, Query: actionQuery(filter, page)}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) BootstrapFund(ctx context.Context, id string) error {
+	return c.do(ctx, call{Method: "GET", Path: "/funds/:id/bootstrap", Params: []string{id}}, nil)
+}
+
+func (c *Client) StepFund(ctx context.Context, id string) (*types.FundAndCapitalAccounts, error) {
+	var result types.FundAndCapitalAccounts
+	err := c.do(ctx, call{Method: "POST", Path: "/funds/:id/step", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Sends a CSV file or Excel workbook of contentType to import. A batchSize of
+// zero leaves the server default.
+func (c *Client) ImportCapitalAccounts(ctx context.Context, id string, sheet io.Reader, contentType string, batchSize int) (*types.ImportReport, error) {
+	query := url.Values{}
+	if batchSize > 0 {
+		query.Set("batchSize", strconv.Itoa(batchSize))
+	}
+	var result types.ImportReport
+	err := c.do(ctx, call{Method: "POST", Path: "/funds/:id/import", Params: []string{id}, Query: query, Upload: sheet, ContentType: contentType}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) SetFundRoundingPolicy(ctx context.Context, id string, request types.SetRoundingPolicyRequest) (*types.RoundingPolicySet, error) {
+	var result types.RoundingPolicySet
+	err := c.do(ctx, call{Method: "PUT", Path: "/funds/:id/roundingpolicy", Params: []string{id}, Body: request}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) SetFundChartOfAccounts(ctx context.Context, id string, chart types.ChartOfAccounts) (*types.ChartOfAccountsSet, error) {
+	var result types.ChartOfAccountsSet
+	request := types.SetChartOfAccountsRequest{ChartOfAccounts: chart}
+	err := c.do(ctx, call{Method: "PUT", Path: "/funds/:id/chartofaccounts", Params: []string{id}, Body: request}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// A negative period is left to the server, which takes the last closed one
+func periodQuery(period int) url.Values {
+	query := url.Values{}
+	if period >= 0 {
+		query.Set("period", strconv.Itoa(period))
+	}
+	return query
+}
+
+// A negative period reads the last closed period
+func (c *Client) GetFundJournal(ctx context.Context, id string, period int) (*types.Journal, error) {
+	var result types.Journal
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/journal", Params: []string{id}, Query: periodQuery(period)}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Reads the journal as a csv or iif file
+func (c *Client) DownloadFundJournal(ctx context.Context, id string, period int, format string) ([]byte, error) {
+	query := periodQuery(period)
+	query.Set("format", format)
+	var result []byte
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/journal", Params: []string{id}, Query: query}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// A negative period reads the last closed period
+func (c *Client) GetFundTrialBalance(ctx context.Context, id string, period int) (*types.TrialBalance, error) {
+	var result types.TrialBalance
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/trialbalance", Params: []string{id}, Query: periodQuery(period)}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// A negative period reads the last closed period, and Sharpe ratios are left out
+// without a riskless rate id
+func (c *Client) GetFundPerformance(ctx context.Context, id string, period int, risklessRateId string) (*types.PerformanceReport, error) {
+	var result types.PerformanceReport
+	query := periodQuery(period)
+	if risklessRateId != "" {
+		query.Set("risklessRate", risklessRateId)
+	}
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/performance", Params: []string{id}, Query: query}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) SetFundPeriodsPerYear(ctx context.Context, id string, periodsPerYear int) (*types.PeriodsPerYearSet, error) {
+	var result types.PeriodsPerYearSet
+	request := types.SetPeriodsPerYearRequest{PeriodsPerYear: periodsPerYear}
+	err := c.do(ctx, call{Method: "PUT", Path: "/funds/:id/periodsperyear", Params: []string{id}, Body: request}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) SetFundConcentrationLimits(ctx context.Context, id string, limits types.ConcentrationLimits) (*types.ConcentrationLimitsSet, error) {
+	var result types.ConcentrationLimitsSet
+	request := types.SetConcentrationLimitsRequest{Limits: limits}
+	err := c.do(ctx, call{Method: "PUT", Path: "/funds/:id/concentrationlimits", Params: []string{id}, Body: request}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// An empty date reads the latest valuations, and a top that is not positive ranks
+// the server's default number of positions
+func (c *Client) GetFundExposure(ctx context.Context, id string, date string, top int) (*types.ExposureReport, error) {
+	var result types.ExposureReport
+	query := url.Values{}
+	if date != "" {
+		query.Set("date", date)
+	}
+	if top > 0 {
+		query.Set("top", strconv.Itoa(top))
+	}
+	err := c.do(ctx, call{Method: "GET", Path: "/funds/:id/exposure", Params: []string{id}, Query: query}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreateInvestor(ctx context.Context, request types.CreateInvestorRequest, options ...RequestOption) (string, error) {
+	return c.create(ctx, "/investors", "investorId", request, options)
+}
+
+func (c *Client) CreateInvestorAsync(ctx context.Context, request types.CreateInvestorRequest, options ...RequestOption) (*Accepted, error) {
+	return c.submitAsync(ctx, "/investors", "investorId", request, options)
+}
+
+func (c *Client) ListInvestors(ctx context.Context, page Page) (*types.InvestorPage, error) {
+	var result types.InvestorPage
+	err := c.do(ctx, call{Method: "GET", Path: "/investors", Query: page.query()}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetInvestor(ctx context.Context, id string) (*types.Investor, error) {
+	var result types.Investor
+	err := c.do(ctx, call{Method: "GET", Path: "/investors/:id", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreateCapitalAccount(ctx context.Context, request types.CreateCapitalAccountRequest, options ...RequestOption) (string, error) {
+	return c.create(ctx, "/capitalaccounts", "capitalAccountId", request, options)
+}
+
+func (c *Client) CreateCapitalAccountAsync(ctx context.Context, request types.CreateCapitalAccountRequest, options ...RequestOption) (*Accepted, error) {
+	return c.submitAsync(ctx, "/capitalaccounts", "capitalAccountId", request, options)
+}
+
+func (c *Client) ListCapitalAccounts(ctx context.Context, fund string, page Page) (*types.CapitalAccountPage, error) {
+	query := page.query()
+	query.Set("fund", fund)
+	var result types.CapitalAccountPage
+	err := c.do(ctx, call{Method: "GET", Path: "/capitalaccounts", Query: query}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetCapitalAccount(ctx context.Context, id string) (*types.CapitalAccount, error) {
+	var result types.CapitalAccount
+	err := c.do(ctx, call{Method: "GET", Path: "/capitalaccounts/:id", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreatePortfolio(ctx context.Context, request types.CreatePortfolioRequest, options ...RequestOption) (string, error) {
+	return c.create(ctx, "/portfolios", "portfolioId", request, options)
+}
+
+func (c *Client) CreatePortfolioAsync(ctx context.Context, request types.CreatePortfolioRequest, options ...RequestOption) (*Accepted, error) {
+	return c.submitAsync(ctx, "/portfolios", "portfolioId", request, options)
+}
+
+func (c *Client) ListPortfolios(ctx context.Context, fund string, page Page) (*types.PortfolioPage, error) {
+	query := page.query()
+	query.Set("fund", fund)
+	var result types.PortfolioPage
+	err := c.do(ctx, call{Method: "GET", Path: "/portfolios", Query: query}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetPortfolio(ctx context.Context, id string) (*types.Portfolio, error) {
+	var result types.Portfolio
+	err := c.do(ctx, call{Method: "GET", Path: "/portfolios/:id", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreateCapitalAccountAction(ctx context.Context, request types.CreateCapitalAccountActionRequest, options ...RequestOption) (string, error) {
+	return c.create(ctx, "/capitalaccountactions", "transactionId", request, options)
+}
+
+func (c *Client) CreateCapitalAccountActionAsync(ctx context.Context, request types.CreateCapitalAccountActionRequest, options ...RequestOption) (*Accepted, error) {
+	return c.submitAsync(ctx, "/capitalaccountactions", "transactionId", request, options)
+}
+
+func (c *Client) ListCapitalAccountActions(ctx context.Context, capitalAccount string, page Page) (*types.CapitalAccountActionPage, error) {
+	query := page.query()
+	query.Set("capitalAccount", capitalAccount)
+	var result types.CapitalAccountActionPage
+	err := c.do(ctx, call{Method: "GET", Path: "/capitalaccountactions", Query: query}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetCapitalAccountAction(ctx context.Context, id string) (*types.CapitalAccountAction, error) {
+	var result types.CapitalAccountAction
+	err := c.do(ctx, call{Method: "GET", Path: "/capitalaccountactions/:id", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreatePortfolioAction(ctx context.Context, request types.CreatePortfolioActionRequest, options ...RequestOption) (string, error) {
+	return c.create(ctx, "/portfolioactions", "transactionId", request, options)
+}
+
+func (c *Client) CreatePortfolioActionAsync(ctx context.Context, request types.CreatePortfolioActionRequest, options ...RequestOption) (*Accepted, error) {
+	return c.submitAsync(ctx, "/portfolioactions", "transactionId", request, options)
+}
+
+func (c *Client) ListPortfolioActions(ctx context.Context, portfolio string, page Page) (*types.PortfolioActionPage, error) {
+	query := page.query()
+	query.Set("portfolio", portfolio)
+	var result types.PortfolioActionPage
+	err := c.do(ctx, call{Method: "GET", Path: "/portfolioactions", Query: query}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetPortfolioAction(ctx context.Context, id string) (*types.PortfolioAction, error) {
+	var result types.PortfolioAction
+	err := c.do(ctx, call{Method: "GET", Path: "/portfolioactions/:id", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ValuePortfolio(ctx context.Context, request types.ValuePortfolioRequest) error {
+	return c.do(ctx, call{Method: "POST", Path: "/valueportfolio", Body: request}, nil)
+}
+
+func (c *Client) ValuePortfolioAsync(ctx context.Context, request types.ValuePortfolioRequest) (*Accepted, error) {
+	return c.submitAsync(ctx, "/valueportfolio", "", request, nil)
+}
+
+func (c *Client) SetRisklessRate(ctx context.Context, id string, request types.SetRisklessRateRequest) (*types.RisklessRate, error) {
+	var result types.RisklessRate
+	err := c.do(ctx, call{Method: "PUT", Path: "/risklessrates/:id", Params: []string{id}, Body: request}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetRisklessRate(ctx context.Context, id string) (*types.RisklessRate, error) {
+	var result types.RisklessRate
+	err := c.do(ctx, call{Method: "GET", Path: "/risklessrates/:id", Params: []string{id}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) SetSecurity(ctx context.Context, cusip string, request types.SetSecurityRequest) (*types.Security, error) {
+	var result types.Security
+	err := c.do(ctx, call{Method: "PUT", Path: "/securities/:cusip", Params: []string{cusip}, Body: request}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetSecurity(ctx context.Context, cusip string) (*types.Security, error) {
+	var result types.Security
+	err := c.do(ctx, call{Method: "GET", Path: "/securities/:cusip", Params: []string{cusip}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetTransaction(ctx context.Context, txId string) (*Transaction, error) {
+	var result Transaction
+	err := c.do(ctx, call{Method: "GET", Path: "/transactions/:txid", Params: []string{txId}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Polls the transaction every interval until it is no longer pending. A
+// transaction that completed without being valid is returned without an error,
+// its Error field holds the reason.
+func (c *Client) WaitForTransaction(ctx context.Context, txId string, interval time.Duration) (*Transaction, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		transaction, err := c.GetTransaction(ctx, txId)
+		if err != nil || !transaction.Pending() {
+			return transaction, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Returns the OpenAPI document of the server
+func (c *Client) OpenAPI(ctx context.Context) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := c.do(ctx, call{Method: "GET", Path: "/openapi.json"}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
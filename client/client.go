@@ -0,0 +1,233 @@
+// Package client is a typed client of the admin REST API for other Go services.
+// Its methods mirror the routes of the server, which publishes the same contract
+// as an OpenAPI document at /openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const API_KEY_HEADER string = "X-API-Key"
+const IDEMPOTENCY_KEY_HEADER string = "Idempotency-Key"
+
+const DEFAULT_TIMEOUT time.Duration = 30 * time.Second
+
+// A client of one server. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+}
+
+type Option func(*Client)
+
+// Authenticates every request with an API key of the form id.secret
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.header.Set(API_KEY_HEADER, key)
+	}
+}
+
+// Authenticates every request with a JWT
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// Sends the requests with the given client instead of one with DEFAULT_TIMEOUT,
+// e.g. to trust a private CA
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Creates a client of the server at baseURL, e.g. https://admin.internal:8443
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DEFAULT_TIMEOUT},
+		header:     http.Header{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+type RequestOption func(*http.Request)
+
+// Makes a create request safe to retry: the server returns the document the first
+// request created instead of creating another
+func WithIdempotencyKey(key string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(IDEMPOTENCY_KEY_HEADER, key)
+	}
+}
+
+// An error response of the server. Unwrap returns the coded error, so errors.Is
+// matches the sentinels of the errors package.
+type APIError struct {
+	StatusCode int
+	Err        *pkgErrors.Error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Err.Code, e.Err.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// The pageSize and bookmark of a list request. The zero value requests the first
+// page of the default size.
+type Page struct {
+	Size     int
+	Bookmark string
+}
+
+func (p Page) query() url.Values {
+	query := url.Values{}
+	if p.Size > 0 {
+		query.Set("pageSize", strconv.Itoa(p.Size))
+	}
+	if p.Bookmark != "" {
+		query.Set("bookmark", p.Bookmark)
+	}
+	return query
+}
+
+func actionQuery(filter types.ActionFilter, page Page) url.Values {
+	query := page.query()
+	if filter.Period != nil {
+		query.Set("period", strconv.Itoa(*filter.Period))
+	}
+	for name, value := range map[string]string{
+		"type":      filter.Type,
+		"status":    filter.Status,
+		"startDate": filter.StartDate,
+		"endDate":   filter.EndDate,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	return query
+}
+
+// A call of a route. Path is the route's template, e.g. /funds/:id, whose
+// parameters are filled in order from Params.
+type call struct {
+	Method string
+	Path   string
+	Params []string
+	Query  url.Values
+	Body   interface{}
+	// A file sent as the body instead of JSON
+	Upload      io.Reader
+	ContentType string
+}
+
+func (r call) url(baseURL string) string {
+	segments := strings.Split(r.Path, "/")
+	params := r.Params
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") && len(params) > 0 {
+			segments[i] = url.PathEscape(params[0])
+			params = params[1:]
+		}
+	}
+	target := baseURL + strings.Join(segments, "/")
+	if len(r.Query) > 0 {
+		target += "?" + r.Query.Encode()
+	}
+	return target
+}
+
+// Sends the call and decodes a successful response into out, which may be nil. An
+// out of *[]byte receives the body as it is, for files the server sends.
+func (c *Client) do(ctx context.Context, r call, out interface{}, options ...RequestOption) error {
+	var body io.Reader
+	if r.Body != nil {
+		data, err := json.Marshal(r.Body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	if r.Upload != nil {
+		body = r.Upload
+	}
+	request, err := http.NewRequestWithContext(ctx, r.Method, r.url(c.baseURL), body)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Accept", "application/json")
+	if r.Body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if r.Upload != nil {
+		request.Header.Set("Content-Type", r.ContentType)
+	}
+	for _, option := range options {
+		option(request)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= 400 {
+		return decodeError(response.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		return fmt.Errorf("decoding the response of %s %s: %v", r.Method, r.Path, err)
+	}
+	return nil
+}
+
+// Responses that are not the server's error document, e.g. from a proxy, are
+// reported as INTERNAL with the body as the message
+func decodeError(statusCode int, data []byte) error {
+	var body struct {
+		Error *pkgErrors.Error `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error == nil || body.Error.Code == "" {
+		message := strings.TrimSpace(string(data))
+		if message == "" {
+			message = http.StatusText(statusCode)
+		}
+		return &APIError{StatusCode: statusCode, Err: pkgErrors.New(pkgErrors.CODE_INTERNAL, message)}
+	}
+	return &APIError{StatusCode: statusCode, Err: body.Error}
+}
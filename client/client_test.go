@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/endpoints"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+// A server with the routes of the real one whose handlers record the route that was
+// called and the request, and answer with an empty document of the right shape
+type fakeServer struct {
+	mu       sync.Mutex
+	called   map[string]bool
+	requests []*http.Request
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *Client) {
+	gin.SetMode(gin.TestMode)
+	fake := &fakeServer{called: map[string]bool{}}
+	router := gin.New()
+	for _, route := range (&endpoints.EndpointWrapper{}).Routes() {
+		route := route
+		router.Handle(route.Method, route.Path, func(c *gin.Context) {
+			fake.mu.Lock()
+			fake.called[route.Method+" "+route.Path] = true
+			fake.requests = append(fake.requests, c.Request)
+			fake.mu.Unlock()
+			switch {
+			case route.CreatedField != "":
+				c.JSON(http.StatusOK, gin.H{route.CreatedField: "created", "txId": "tx", "status": "pending"})
+			case route.Response == "":
+				c.JSON(http.StatusOK, "success")
+			default:
+				c.JSON(http.StatusOK, gin.H{})
+			}
+		})
+	}
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return fake, New(server.URL, WithAPIKey("ci.secret"))
+}
+
+func (f *fakeServer) last() *http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func TestClientCallsEveryRoute(t *testing.T) {
+	fake, c := newFakeServer(t)
+	ctx := context.Background()
+	period := 2
+	filter := types.ActionFilter{Period: &period, Type: "deposit"}
+	calls := []func() error{
+		func() error { _, err := c.CreateFund(ctx, types.CreateFundRequest{}); return err },
+		func() error { _, err := c.ListFunds(ctx, Page{}); return err },
+		func() error { _, err := c.GetFund(ctx, "fund"); return err },
+		func() error { _, err := c.ListFundInvestors(ctx, "fund", Page{}); return err },
+		func() error { _, err := c.ListFundCapitalAccounts(ctx, "fund", Page{}); return err },
+		func() error { _, err := c.ListFundPortfolios(ctx, "fund", Page{}); return err },
+		func() error { _, err := c.ListFundCapitalAccountActions(ctx, "fund", filter, Page{}); return err },
+		func() error { _, err := c.ListFundPortfolioActions(ctx, "fund", filter, Page{}); return err },
+		func() error { return c.BootstrapFund(ctx, "fund") },
+		func() error { _, err := c.StepFund(ctx, "fund"); return err },
+		func() error {
+			_, err := c.ImportCapitalAccounts(ctx, "fund", strings.NewReader("reference\n"), "text/csv", 10)
+			return err
+		},
+		func() error {
+			_, err := c.SetFundRoundingPolicy(ctx, "fund", types.SetRoundingPolicyRequest{})
+			return err
+		},
+		func() error {
+			_, err := c.SetFundChartOfAccounts(ctx, "fund", types.DefaultChartOfAccounts())
+			return err
+		},
+		func() error { _, err := c.GetFundJournal(ctx, "fund", -1); return err },
+		func() error { _, err := c.DownloadFundJournal(ctx, "fund", 1, "iif"); return err },
+		func() error { _, err := c.GetFundTrialBalance(ctx, "fund", 1); return err },
+		func() error { _, err := c.GetFundPerformance(ctx, "fund", -1, "tbill"); return err },
+		func() error { _, err := c.SetFundPeriodsPerYear(ctx, "fund", 4); return err },
+		func() error {
+			_, err := c.SetFundConcentrationLimits(ctx, "fund", types.ConcentrationLimits{})
+			return err
+		},
+		func() error { _, err := c.GetFundExposure(ctx, "fund", "03-31-2020", 5); return err },
+		func() error { _, err := c.CreateInvestor(ctx, types.CreateInvestorRequest{}); return err },
+		func() error { _, err := c.ListInvestors(ctx, Page{}); return err },
+		func() error { _, err := c.GetInvestor(ctx, "investor"); return err },
+		func() error {
+			_, err := c.CreateCapitalAccount(ctx, types.CreateCapitalAccountRequest{})
+			return err
+		},
+		func() error { _, err := c.ListCapitalAccounts(ctx, "fund", Page{}); return err },
+		func() error { _, err := c.GetCapitalAccount(ctx, "account"); return err },
+		func() error { _, err := c.CreatePortfolio(ctx, types.CreatePortfolioRequest{}); return err },
+		func() error { _, err := c.ListPortfolios(ctx, "fund", Page{}); return err },
+		func() error { _, err := c.GetPortfolio(ctx, "portfolio"); return err },
+		func() error {
+			_, err := c.CreateCapitalAccountAction(ctx, types.CreateCapitalAccountActionRequest{})
+			return err
+		},
+		func() error { _, err := c.ListCapitalAccountActions(ctx, "account", Page{}); return err },
+		func() error { _, err := c.GetCapitalAccountAction(ctx, "action"); return err },
+		func() error {
+			_, err := c.CreatePortfolioAction(ctx, types.CreatePortfolioActionRequest{})
+			return err
+		},
+		func() error { _, err := c.ListPortfolioActions(ctx, "portfolio", Page{}); return err },
+		func() error { _, err := c.GetPortfolioAction(ctx, "action"); return err },
+		func() error { return c.ValuePortfolio(ctx, types.ValuePortfolioRequest{}) },
+		func() error {
+			_, err := c.SetRisklessRate(ctx, "tbill", types.SetRisklessRateRequest{})
+			return err
+		},
+		func() error { _, err := c.GetRisklessRate(ctx, "tbill"); return err },
+		func() error {
+			_, err := c.SetSecurity(ctx, "037833100", types.SetSecurityRequest{})
+			return err
+		},
+		func() error { _, err := c.GetSecurity(ctx, "037833100"); return err },
+		func() error { _, err := c.GetTransaction(ctx, "tx"); return err },
+		func() error { _, err := c.OpenAPI(ctx); return err },
+	}
+	for i, call := range calls {
+		assert.Nil(t, call(), i)
+	}
+
+	routes := []string{}
+	for _, route := range (&endpoints.EndpointWrapper{}).Routes() {
+		routes = append(routes, route.Method+" "+route.Path)
+	}
+	called := []string{}
+	for route := range fake.called {
+		called = append(called, route)
+	}
+	sort.Strings(routes)
+	sort.Strings(called)
+	assert.Equal(t, routes, called)
+}
+
+func TestClientSendsParametersAndCredentials(t *testing.T) {
+	fake, c := newFakeServer(t)
+	ctx := context.Background()
+
+	period := 3
+	_, err := c.ListFundCapitalAccountActions(ctx, "fund 1", types.ActionFilter{Period: &period, StartDate: "01-01-2021"}, Page{Size: 50, Bookmark: "next"})
+	assert.Nil(t, err)
+	request := fake.last()
+	assert.Equal(t, "/funds/fund%201/capitalaccountactions", request.URL.EscapedPath())
+	assert.Equal(t, "3", request.URL.Query().Get("period"))
+	assert.Equal(t, "01-01-2021", request.URL.Query().Get("startDate"))
+	assert.Equal(t, "50", request.URL.Query().Get("pageSize"))
+	assert.Equal(t, "next", request.URL.Query().Get("bookmark"))
+	assert.Equal(t, "ci.secret", request.Header.Get(API_KEY_HEADER))
+
+	id, err := c.CreateFund(ctx, types.CreateFundRequest{Name: "fund"}, WithIdempotencyKey("fund-2021"))
+	assert.Nil(t, err)
+	assert.Equal(t, "created", id)
+	assert.Equal(t, "fund-2021", fake.last().Header.Get(IDEMPOTENCY_KEY_HEADER))
+
+	accepted, err := c.CreateInvestorAsync(ctx, types.CreateInvestorRequest{Name: "investor"})
+	assert.Nil(t, err)
+	assert.Equal(t, &Accepted{ID: "created", TxId: "tx", Status: "pending"}, accepted)
+	assert.Equal(t, "true", fake.last().URL.Query().Get("async"))
+
+	_, err = c.ImportCapitalAccounts(ctx, "fund", strings.NewReader("reference\n"), "text/csv", 25)
+	assert.Nil(t, err)
+	assert.Equal(t, "text/csv", fake.last().Header.Get("Content-Type"))
+	assert.Equal(t, "25", fake.last().URL.Query().Get("batchSize"))
+}
+
+func TestClientReturnsCodedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/funds/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": pkgErrors.FundNotFoundError.WithDetail("fundId", "missing")})
+			return
+		}
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+	c := New(server.URL, WithBearerToken("token"))
+
+	_, err := c.GetFund(context.Background(), "missing")
+	assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+	var apiError *APIError
+	assert.True(t, errors.As(err, &apiError))
+	assert.Equal(t, http.StatusNotFound, apiError.StatusCode)
+	assert.Equal(t, "missing", apiError.Err.Details["fundId"])
+
+	_, err = c.GetFund(context.Background(), "other")
+	assert.True(t, errors.As(err, &apiError))
+	assert.Equal(t, http.StatusBadGateway, apiError.StatusCode)
+	assert.Equal(t, pkgErrors.CODE_INTERNAL, apiError.Err.Code)
+	assert.Equal(t, "bad gateway", apiError.Err.Message)
+}
+
+// The client cannot import web, so its copy of the transaction record is checked
+// against the server's
+func TestTransactionMirrorsServerRecord(t *testing.T) {
+	fields := func(v interface{}) map[string]string {
+		result := map[string]string{}
+		value := reflect.TypeOf(v)
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Field(i)
+			result[field.Name] = field.Type.String() + " " + field.Tag.Get("json")
+		}
+		return result
+	}
+	assert.Equal(t, fields(web.TransactionRecord{}), fields(Transaction{}))
+	assert.Equal(t, web.TRANSACTION_STATUS_PENDING, TRANSACTION_STATUS_PENDING)
+	assert.Equal(t, web.TRANSACTION_STATUS_VALID, TRANSACTION_STATUS_VALID)
+	assert.Equal(t, web.API_KEY_HEADER, API_KEY_HEADER)
+	assert.Equal(t, endpoints.IDEMPOTENCY_KEY_HEADER, IDEMPOTENCY_KEY_HEADER)
+}
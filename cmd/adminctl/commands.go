@@ -0,0 +1,890 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/zacharyfrederick/admin/archive"
+	"github.com/zacharyfrederick/admin/journal"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const DEFAULT_CURRENCY string = "USD"
+
+// Prints the id of a created record under the field the REST server uses for it
+func printCreated(env *environment, field string, id string) error {
+	return env.print(report{
+		Headers: []string{field},
+		Rows:    [][]string{{id}},
+		Value:   map[string]string{field: id},
+	})
+}
+
+func createFund(env *environment, args []string) error {
+	set := commandFlags(env, "create-fund")
+	var request types.CreateFundRequest
+	set.StringVar(&request.Name, "name", "", "name of the fund")
+	set.StringVar(&request.InceptionDate, "inception-date", "", "inception date, MM-DD-YYYY")
+	_, err := parseArgs(set, args, 0)
+	if err != nil {
+		return err
+	}
+	id, err := env.ledger.CreateFund(request)
+	if err != nil {
+		return err
+	}
+	return printCreated(env, "fundId", id)
+}
+
+func createInvestor(env *environment, args []string) error {
+	set := commandFlags(env, "create-investor")
+	var request types.CreateInvestorRequest
+	set.StringVar(&request.Name, "name", "", "name of the investor")
+	_, err := parseArgs(set, args, 0)
+	if err != nil {
+		return err
+	}
+	id, err := env.ledger.CreateInvestor(request)
+	if err != nil {
+		return err
+	}
+	return printCreated(env, "investorId", id)
+}
+
+func createAccount(env *environment, args []string) error {
+	set := commandFlags(env, "create-account")
+	var request types.CreateCapitalAccountRequest
+	set.StringVar(&request.Fund, "fund", "", "id of the fund")
+	set.StringVar(&request.Investor, "investor", "", "id of the investor")
+	set.BoolVar(&request.HasPerformanceFees, "performance-fees", false, "charge the account performance fees")
+	set.StringVar(&request.PerformanceRate, "performance-rate", "0", "performance fee rate, e.g. 0.2")
+	_, err := parseArgs(set, args, 0)
+	if err != nil {
+		return err
+	}
+	id, err := env.ledger.CreateCapitalAccount(request)
+	if err != nil {
+		return err
+	}
+	return printCreated(env, "capitalAccountId", id)
+}
+
+func createPortfolio(env *environment, args []string) error {
+	set := commandFlags(env, "create-portfolio")
+	var request types.CreatePortfolioRequest
+	set.StringVar(&request.Fund, "fund", "", "id of the fund")
+	set.StringVar(&request.Name, "name", "", "name of the portfolio")
+	_, err := parseArgs(set, args, 0)
+	if err != nil {
+		return err
+	}
+	id, err := env.ledger.CreatePortfolio(request)
+	if err != nil {
+		return err
+	}
+	return printCreated(env, "portfolioId", id)
+}
+
+func deposit(env *environment, args []string) error {
+	return capitalAccountAction(env, "deposit", types.CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT, args)
+}
+
+func withdraw(env *environment, args []string) error {
+	return capitalAccountAction(env, "withdraw", types.CAPITAL_ACCOUNT_ACTION_TYPE_WITHDRAWAL, args)
+}
+
+func capitalAccountAction(env *environment, name string, actionType string, args []string) error {
+	set := commandFlags(env, name)
+	request := types.CreateCapitalAccountActionRequest{Type: actionType}
+	set.StringVar(&request.CapitalAccount, "account", "", "id of the capital account")
+	set.StringVar(&request.Amount, "amount", "", "amount of cash")
+	set.StringVar(&request.Date, "date", "", "date of the action, MM-DD-YYYY")
+	set.IntVar(&request.Period, "period", 0, "period of the action")
+	if actionType == types.CAPITAL_ACCOUNT_ACTION_TYPE_WITHDRAWAL {
+		set.BoolVar(&request.Full, "full", false, "withdraw the whole account")
+	}
+	_, err := parseArgs(set, args, 0)
+	if err != nil {
+		return err
+	}
+	id, err := env.ledger.CreateCapitalAccountAction(request)
+	if err != nil {
+		return err
+	}
+	return printCreated(env, "transactionId", id)
+}
+
+func buy(env *environment, args []string) error {
+	return portfolioAction(env, "buy", types.PORTFOLIO_ACTION_TYPE_BUY, args)
+}
+
+func sell(env *environment, args []string) error {
+	return portfolioAction(env, "sell", types.PORTFOLIO_ACTION_TYPE_SELL, args)
+}
+
+func portfolioAction(env *environment, name string, actionType string, args []string) error {
+	set := commandFlags(env, name)
+	request := types.CreatePortfolioActionRequest{Type: actionType}
+	set.StringVar(&request.Portfolio, "portfolio", "", "id of the portfolio")
+	set.StringVar(&request.Name, "name", "", "name of the security")
+	set.StringVar(&request.CUSIP, "cusip", "", "CUSIP of the security")
+	set.StringVar(&request.Amount, "amount", "", "number of units")
+	set.StringVar(&request.Currency, "currency", DEFAULT_CURRENCY, "currency of the security")
+	set.StringVar(&request.Date, "date", "", "date of the trade, MM-DD-YYYY")
+	set.IntVar(&request.Period, "period", 0, "period of the trade")
+	_, err := parseArgs(set, args, 0)
+	if err != nil {
+		return err
+	}
+	id, err := env.ledger.CreatePortfolioAction(request)
+	if err != nil {
+		return err
+	}
+	return printCreated(env, "transactionId", id)
+}
+
+// The result of valuing a portfolio from one row of a price file
+type priceResult struct {
+	Line      int    `json:"line"`
+	Portfolio string `json:"portfolio"`
+	Name      string `json:"name"`
+	Date      string `json:"date"`
+	Price     string `json:"price"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Values the portfolios from each row of the file in turn. Every row is tried and
+// reported, and the command fails when any row did.
+func loadPrices(env *environment, args []string) error {
+	set := commandFlags(env, "load-prices")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filepath.Clean(positional[0]))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	requests, lines, err := readPrices(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", positional[0], err)
+	}
+
+	results := []priceResult{}
+	rows := [][]string{}
+	failed := 0
+	for i, request := range requests {
+		result := priceResult{Line: lines[i], Portfolio: request.Portfolio, Name: request.Name, Date: request.Date, Price: request.Price}
+		status := "ok"
+		err := env.ledger.ValuePortfolio(request)
+		if err != nil {
+			result.Error = err.Error()
+			status = result.Error
+			failed++
+		}
+		results = append(results, result)
+		rows = append(rows, []string{strconv.Itoa(result.Line), result.Portfolio, result.Name, result.Date, result.Price, status})
+	}
+	err = env.print(report{
+		Headers: []string{"LINE", "PORTFOLIO", "NAME", "DATE", "PRICE", "STATUS"},
+		Rows:    rows,
+		Value:   results,
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		fmt.Fprintf(env.stderr, "adminctl: %d of %d prices failed\n", failed, len(requests))
+		return errCommandFailed
+	}
+	return nil
+}
+
+// Reads the requests from a CSV file whose header names the columns, in any order,
+// returning the line of each request
+func readPrices(r io.Reader) ([]types.ValuePortfolioRequest, []int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading the header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"portfolio", "name", "date", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("missing the %s column", name)
+		}
+	}
+	requests := []types.ValuePortfolioRequest{}
+	lines := []int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return requests, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		requests = append(requests, types.ValuePortfolioRequest{
+			Portfolio: record[columns["portfolio"]],
+			Name:      record[columns["name"]],
+			Date:      record[columns["date"]],
+			Price:     record[columns["price"]],
+		})
+		lines = append(lines, line)
+	}
+}
+
+// Imports the sheet, printing every row's problem when the sheet is rejected.
+// Running the command again after a failure resumes the import.
+func importAccounts(env *environment, args []string) error {
+	set := commandFlags(env, "import")
+	batchSize := set.Int("batch-size", types.DEFAULT_IMPORT_BATCH_SIZE, "rows submitted per transaction")
+	positional, err := parseArgs(set, args, 2)
+	if err != nil {
+		return err
+	}
+	imported, err := env.ledger.ImportCapitalAccounts(positional[0], positional[1], *batchSize)
+	if err != nil {
+		coded, ok := pkgErrors.From(err)
+		if !ok || coded.Code != pkgErrors.CODE_VALIDATION_FAILED {
+			return err
+		}
+		fmt.Fprintf(env.stderr, "adminctl: %s, nothing was imported:\n", coded.Message)
+		for _, key := range sortedImportKeys(coded.Details) {
+			fmt.Fprintf(env.stderr, "  %s: %s\n", key, coded.Details[key])
+		}
+		return errCommandFailed
+	}
+	rows := [][]string{}
+	for _, row := range imported.Rows {
+		rows = append(rows, []string{strconv.Itoa(row.Line), row.Reference, row.InvestorId, row.CapitalAccountId, row.Status})
+	}
+	err = env.print(report{
+		Headers: []string{"LINE", "REFERENCE", "INVESTOR", "ACCOUNT", "STATUS"},
+		Rows:    rows,
+		Value:   imported,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stderr, "%d rows imported and %d already imported in %d batches\n", imported.Imported, imported.Skipped, imported.Batches)
+	return nil
+}
+
+// Orders the details of a rejected sheet by line, then by field
+func sortedImportKeys(details map[string]string) []string {
+	line := func(key string) int {
+		number := strings.TrimPrefix(strings.SplitN(key, ":", 2)[0], "line ")
+		parsed, _ := strconv.Atoi(number)
+		return parsed
+	}
+	keys := []string{}
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if line(keys[i]) != line(keys[j]) {
+			return line(keys[i]) < line(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func exportLedger(env *environment, args []string) error {
+	set := commandFlags(env, "export")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	//written next to the file and renamed over it, so a failed export leaves any earlier archive alone
+	path := filepath.Clean(positional[0])
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	manifest, err := env.ledger.Export(temp)
+	closeErr := temp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	rows := [][]string{}
+	for _, section := range manifest.Sections {
+		rows = append(rows, []string{section.Name, strconv.Itoa(section.Count), section.SHA256})
+	}
+	return env.print(report{Headers: []string{"SECTION", "COUNT", "SHA256"}, Rows: rows, Value: manifest})
+}
+
+func restoreLedger(env *environment, args []string) error {
+	set := commandFlags(env, "restore")
+	batchSize := set.Int("batch-size", archive.DEFAULT_BATCH_SIZE, "documents submitted per transaction")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filepath.Clean(positional[0]))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	a, err := archive.Read(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", positional[0], err)
+	}
+	restored, err := env.ledger.Restore(a, *batchSize)
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	for _, section := range restored.Sections {
+		rows = append(rows, []string{section.Section, strconv.Itoa(section.Restored), strconv.Itoa(section.Skipped)})
+	}
+	err = env.print(report{Headers: []string{"SECTION", "RESTORED", "SKIPPED"}, Rows: rows, Value: restored})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stderr, "restored the archive exported at %s in %d batches\n", a.Manifest.ExportedAt.Format(time.RFC3339), restored.Batches)
+	return nil
+}
+
+func bootstrap(env *environment, args []string) error {
+	set := commandFlags(env, "bootstrap")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	err = env.ledger.BootstrapFund(positional[0])
+	if err != nil {
+		return err
+	}
+	fund, err := env.ledger.GetFund(positional[0])
+	if err != nil {
+		return err
+	}
+	return env.print(fundReport([]*types.Fund{fund}))
+}
+
+func step(env *environment, args []string) error {
+	set := commandFlags(env, "step")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	stepped, err := env.ledger.StepFund(positional[0])
+	if err != nil {
+		return err
+	}
+	//the accounts were stepped into the fund's current period
+	r := balanceReport(stepped.Accounts, stepped.Fund.PreviousPeriod())
+	r.Value = stepped
+	return env.print(r)
+}
+
+func getFund(env *environment, args []string) error {
+	set := commandFlags(env, "get-fund")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	fund, err := env.ledger.GetFund(positional[0])
+	if err != nil {
+		return err
+	}
+	r := fundReport([]*types.Fund{fund})
+	r.Value = fund
+	return env.print(r)
+}
+
+func listFunds(env *environment, args []string) error {
+	set := commandFlags(env, "list-funds")
+	_, err := parseArgs(set, args, 0)
+	if err != nil {
+		return err
+	}
+	funds, err := env.ledger.ListFunds()
+	if err != nil {
+		return err
+	}
+	return env.print(fundReport(funds))
+}
+
+func fundReport(funds []*types.Fund) report {
+	rows := [][]string{}
+	for _, fund := range funds {
+		period := fund.PreviousPeriod()
+		rows = append(rows, []string{fund.ID, fund.Name, fund.InceptionDate, strconv.Itoa(fund.CurrentPeriod),
+			fund.OpeningValues[period], fund.ClosingValues[period]})
+	}
+	return report{
+		Headers: []string{"ID", "NAME", "INCEPTION", "PERIOD", "OPENING", "CLOSING"},
+		Rows:    rows,
+		Value:   funds,
+	}
+}
+
+// One period of a capital account statement
+type statementLine struct {
+	Period          int    `json:"period"`
+	OpeningValue    string `json:"openingValue"`
+	Deposits        string `json:"deposits"`
+	FixedFees       string `json:"fixedFees"`
+	PerformanceFees string `json:"performanceFees"`
+	ClosingValue    string `json:"closingValue"`
+	Ownership       string `json:"ownershipPercentage"`
+}
+
+func statement(env *environment, args []string) error {
+	set := commandFlags(env, "statement")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	account, err := env.ledger.GetCapitalAccount(positional[0])
+	if err != nil {
+		return err
+	}
+	lines := []statementLine{}
+	rows := [][]string{}
+	for _, period := range accountPeriods(account) {
+		line := statementLine{
+			Period:          period,
+			OpeningValue:    account.OpeningValue[period],
+			Deposits:        account.Deposits[period],
+			FixedFees:       account.FixedFees[period],
+			PerformanceFees: account.PerformanceFees[period],
+			ClosingValue:    account.ClosingValue[period],
+			Ownership:       account.OwnershipPercentage[period],
+		}
+		lines = append(lines, line)
+		rows = append(rows, []string{strconv.Itoa(period), line.OpeningValue, line.Deposits, line.FixedFees,
+			line.PerformanceFees, line.ClosingValue, line.Ownership})
+	}
+	return env.print(report{
+		Headers: []string{"PERIOD", "OPENING", "DEPOSITS", "FIXED FEES", "PERFORMANCE FEES", "CLOSING", "OWNERSHIP"},
+		Rows:    rows,
+		Value:   lines,
+	})
+}
+
+// The periods the account has any value for, in order
+func accountPeriods(account *types.CapitalAccount) []int {
+	seen := map[int]bool{}
+	for _, values := range []map[int]string{account.OpeningValue, account.Deposits, account.FixedFees,
+		account.PerformanceFees, account.ClosingValue, account.OwnershipPercentage} {
+		for period := range values {
+			seen[period] = true
+		}
+	}
+	periods := []int{}
+	for period := range seen {
+		periods = append(periods, period)
+	}
+	sort.Ints(periods)
+	return periods
+}
+
+func balances(env *environment, args []string) error {
+	set := commandFlags(env, "balances")
+	period := set.Int("period", -1, "period to show, the last closed period by default")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	if *period < 0 {
+		fund, err := env.ledger.GetFund(positional[0])
+		if err != nil {
+			return err
+		}
+		*period = fund.PreviousPeriod()
+	}
+	accounts, err := env.ledger.ListFundCapitalAccounts(positional[0])
+	if err != nil {
+		return err
+	}
+	return env.print(balanceReport(accounts, *period))
+}
+
+// The balance of one capital account in a period
+type balance struct {
+	CapitalAccount string `json:"capitalAccount"`
+	Investor       string `json:"investor"`
+	Number         int    `json:"number"`
+	Period         int    `json:"period"`
+	OpeningValue   string `json:"openingValue"`
+	Deposits       string `json:"deposits"`
+	ClosingValue   string `json:"closingValue"`
+	Ownership      string `json:"ownershipPercentage"`
+}
+
+// Lists the balances of the accounts in the period by investor number, with a
+// row totalling the values
+func balanceReport(accounts []*types.CapitalAccount, period int) report {
+	sorted := append([]*types.CapitalAccount{}, accounts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+
+	result := []balance{}
+	rows := [][]string{}
+	opening, deposits, closing := decimal.Zero, decimal.Zero, decimal.Zero
+	for _, account := range sorted {
+		b := balance{
+			CapitalAccount: account.ID,
+			Investor:       account.Investor,
+			Number:         account.Number,
+			Period:         period,
+			OpeningValue:   account.OpeningValue[period],
+			Deposits:       account.Deposits[period],
+			ClosingValue:   account.ClosingValue[period],
+			Ownership:      account.OwnershipPercentage[period],
+		}
+		opening = opening.Add(parseDecimal(b.OpeningValue))
+		deposits = deposits.Add(parseDecimal(b.Deposits))
+		closing = closing.Add(parseDecimal(b.ClosingValue))
+		result = append(result, b)
+		rows = append(rows, []string{strconv.Itoa(b.Number), b.CapitalAccount, b.Investor, b.OpeningValue, b.Deposits, b.ClosingValue, b.Ownership})
+	}
+	rows = append(rows, []string{"", "TOTAL", "", opening.String(), deposits.String(), closing.String(), ""})
+	return report{
+		Headers: []string{"NUMBER", "ACCOUNT", "INVESTOR", "OPENING", "DEPOSITS", "CLOSING", "OWNERSHIP"},
+		Rows:    rows,
+		Value:   result,
+	}
+}
+
+// Values missing from a period count as zero in the totals
+func parseDecimal(value string) decimal.Decimal {
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero
+	}
+	return parsed
+}
+
+func setChart(env *environment, args []string) error {
+	set := commandFlags(env, "set-chart")
+	positional, err := parseArgs(set, args, 2)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(positional[1]))
+	if err != nil {
+		return err
+	}
+	var chart types.ChartOfAccounts
+	err = json.Unmarshal(data, &chart)
+	if err != nil {
+		return fmt.Errorf("%s: %v", positional[1], err)
+	}
+	err = env.ledger.SetFundChartOfAccounts(positional[0], chart)
+	if err != nil {
+		return err
+	}
+	return env.print(chartReport(chart))
+}
+
+// The completed chart by account number, with the role of each account
+func chartReport(chart types.ChartOfAccounts) report {
+	complete := chart.Complete()
+	roles := []string{}
+	for role := range complete {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return complete[roles[i]].Number < complete[roles[j]].Number })
+	rows := [][]string{}
+	for _, role := range roles {
+		account := complete[role]
+		rows = append(rows, []string{account.Number, account.Name, account.Type, role})
+	}
+	return report{Headers: []string{"ACCOUNT", "NAME", "TYPE", "ROLE"}, Rows: rows, Value: complete}
+}
+
+// Prints a line of the journal per row, or with -format writes the file a general
+// ledger imports to -out or the standard output
+func showJournal(env *environment, args []string) error {
+	set := commandFlags(env, "journal")
+	period := set.Int("period", -1, "period to show, the last closed period by default")
+	format := set.String("format", "", "write the journal as csv or iif instead of showing it")
+	out := set.String("out", "", "file to write the journal to, the standard output by default")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	if *format != "" {
+		_, err = journal.MediaType(*format)
+		if err != nil {
+			return err
+		}
+	}
+	fundJournal, err := env.ledger.GetFundJournal(positional[0], *period)
+	if err != nil {
+		return err
+	}
+	if len(fundJournal.Unpriced) > 0 {
+		fmt.Fprintf(env.stderr, "not journaled, without a price on their date: %s\n", strings.Join(fundJournal.Unpriced, ", "))
+	}
+	if *format == "" {
+		return env.print(journalReport(fundJournal))
+	}
+	if *out == "" {
+		return journal.Write(env.stdout, *format, fundJournal)
+	}
+	file, err := os.Create(filepath.Clean(*out))
+	if err != nil {
+		return err
+	}
+	err = journal.Write(file, *format, fundJournal)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func journalReport(fundJournal *types.Journal) report {
+	rows := [][]string{}
+	for _, entry := range fundJournal.Entries {
+		for _, line := range entry.Lines {
+			rows = append(rows, []string{entry.ID, entry.Date, line.Account, line.AccountName, line.CapitalAccount,
+				line.Debit, line.Credit, entry.Description})
+		}
+	}
+	return report{
+		Headers: []string{"ENTRY", "DATE", "ACCOUNT", "NAME", "CAPITAL ACCOUNT", "DEBIT", "CREDIT", "DESCRIPTION"},
+		Rows:    rows,
+		Value:   fundJournal,
+	}
+}
+
+func trialBalance(env *environment, args []string) error {
+	set := commandFlags(env, "trial-balance")
+	period := set.Int("period", -1, "period to show, the last closed period by default")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	balance, err := env.ledger.GetFundTrialBalance(positional[0], *period)
+	if err != nil {
+		return err
+	}
+	if len(balance.Unpriced) > 0 {
+		fmt.Fprintf(env.stderr, "not journaled, without a price on their date: %s\n", strings.Join(balance.Unpriced, ", "))
+	}
+	rows := [][]string{}
+	for _, line := range balance.Lines {
+		rows = append(rows, []string{line.Account, line.Name, line.Type, line.Debit, line.Credit})
+	}
+	rows = append(rows, []string{"", "TOTAL", "", balance.TotalDebit, balance.TotalCredit})
+	return env.print(report{
+		Headers: []string{"ACCOUNT", "NAME", "TYPE", "DEBIT", "CREDIT"},
+		Rows:    rows,
+		Value:   balance,
+	})
+}
+
+func setRisklessRate(env *environment, args []string) error {
+	set := commandFlags(env, "set-riskless-rate")
+	name := set.String("name", "", "name of the riskless rate, required when it is new")
+	date := set.String("date", "", "date the rate takes effect")
+	rate := set.String("rate", "", "annual rate as a fraction, 0.015 for 1.5%")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	risklessRate, err := env.ledger.SetRisklessRate(positional[0], types.SetRisklessRateRequest{Name: *name, Date: *date, Rate: *rate})
+	if err != nil {
+		return err
+	}
+	dates := []string{}
+	for date := range risklessRate.Values {
+		dates = append(dates, date)
+	}
+	//dates are MM-DD-YYYY, so they sort by their parsed value
+	sort.Slice(dates, func(i, j int) bool {
+		first, _ := types.ParseDate(dates[i])
+		second, _ := types.ParseDate(dates[j])
+		return first.Before(second)
+	})
+	rows := [][]string{}
+	for _, date := range dates {
+		rows = append(rows, []string{risklessRate.ID, risklessRate.Name, date, risklessRate.Values[date]})
+	}
+	return env.print(report{Headers: []string{"ID", "NAME", "DATE", "RATE"}, Rows: rows, Value: risklessRate})
+}
+
+// Prints a row for the fund, each class and each capital account
+func performance(env *environment, args []string) error {
+	set := commandFlags(env, "performance")
+	period := set.Int("period", -1, "period to show, the last closed period by default")
+	risklessRateId := set.String("riskless-rate", "", "riskless rate for Sharpe ratios")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	performanceReport, err := env.ledger.GetFundPerformance(positional[0], *period, *risklessRateId)
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	levels := append([]*types.Performance{performanceReport.FundPerformance}, performanceReport.Classes...)
+	for _, performance := range append(levels, performanceReport.Accounts...) {
+		rows = append(rows, []string{performance.Level, performance.ID, performance.YearToDate, performance.InceptionToDate,
+			performance.IRR, performance.Volatility, performance.SharpeRatio, performance.MaxDrawdown,
+			strconv.Itoa(performance.MaxDrawdownDuration)})
+	}
+	return env.print(report{
+		Headers: []string{"LEVEL", "ID", "YTD", "ITD", "IRR", "VOLATILITY", "SHARPE", "MAX DRAWDOWN", "DURATION"},
+		Rows:    rows,
+		Value:   performanceReport,
+	})
+}
+
+func setSecurity(env *environment, args []string) error {
+	set := commandFlags(env, "set-security")
+	var request types.SetSecurityRequest
+	set.StringVar(&request.Name, "name", "", "name of the security")
+	set.StringVar(&request.AssetClass, "asset-class", "", "asset class: "+strings.Join(types.ASSET_CLASSES, ", "))
+	set.StringVar(&request.Sector, "sector", "", "sector of the issuer")
+	set.StringVar(&request.Country, "country", "", "ISO 3166 alpha-2 country code, e.g. US")
+	set.StringVar(&request.Issuer, "issuer", "", "issuer of the security")
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	security, err := env.ledger.SetSecurity(positional[0], request)
+	if err != nil {
+		return err
+	}
+	return env.print(report{
+		Headers: []string{"CUSIP", "NAME", "ASSET CLASS", "SECTOR", "COUNTRY", "ISSUER"},
+		Rows:    [][]string{{security.ID, security.Name, security.AssetClass, security.Sector, security.Country, security.Issuer}},
+		Value:   security,
+	})
+}
+
+// Replaces the concentration limits of a fund with the JSON list of limits in a file
+func setLimits(env *environment, args []string) error {
+	set := commandFlags(env, "set-limits")
+	positional, err := parseArgs(set, args, 2)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(positional[1]))
+	if err != nil {
+		return err
+	}
+	var limits types.ConcentrationLimits
+	err = json.Unmarshal(data, &limits)
+	if err != nil {
+		return fmt.Errorf("%s: %v", positional[1], err)
+	}
+	err = env.ledger.SetFundConcentrationLimits(positional[0], limits)
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	for _, limit := range limits {
+		count := ""
+		if limit.Count > 0 {
+			count = strconv.Itoa(limit.Count)
+		}
+		rows = append(rows, []string{limit.Dimension, limit.Value, count, limit.Max})
+	}
+	return env.print(report{Headers: []string{"DIMENSION", "VALUE", "COUNT", "MAX"}, Rows: rows, Value: limits})
+}
+
+// The column heading of each exposure dimension
+var dimensionHeaders = map[string]string{
+	types.EXPOSURE_DIMENSION_SECURITY:    "CUSIP",
+	types.EXPOSURE_DIMENSION_ASSET_CLASS: "ASSET CLASS",
+	types.EXPOSURE_DIMENSION_SECTOR:      "SECTOR",
+	types.EXPOSURE_DIMENSION_COUNTRY:     "COUNTRY",
+	types.EXPOSURE_DIMENSION_ISSUER:      "ISSUER",
+}
+
+// Prints the top positions, or with -by the buckets of a dimension, and writes the
+// concentration limits that are breached and the positions left out to the
+// standard error
+func exposure(env *environment, args []string) error {
+	set := commandFlags(env, "exposure")
+	date := set.String("date", "", "date to show, the latest valuations by default")
+	top := set.Int("top", 0, fmt.Sprintf("number of positions to rank, %d by default", types.DEFAULT_TOP_POSITIONS))
+	by := set.String("by", "", "dimension to show instead of the top positions: "+strings.Join(types.EXPOSURE_DIMENSIONS, ", "))
+	positional, err := parseArgs(set, args, 1)
+	if err != nil {
+		return err
+	}
+	if *by != "" {
+		errs := types.FieldErrors{}
+		errs.Check("by", types.CheckOneOf(*by, types.EXPOSURE_DIMENSIONS...))
+		err = errs.Err()
+		if err != nil {
+			return err
+		}
+	}
+	exposureReport, err := env.ledger.GetFundExposure(positional[0], *date, *top)
+	if err != nil {
+		return err
+	}
+	if len(exposureReport.Unpriced) > 0 {
+		fmt.Fprintf(env.stderr, "left out, without a price on their date: %s\n", strings.Join(exposureReport.Unpriced, ", "))
+	}
+	for _, check := range exposureReport.Limits {
+		if !check.Breached {
+			continue
+		}
+		name := check.Dimension
+		if check.Value != "" {
+			name += " " + check.Value
+		}
+		if check.Count > 0 {
+			name += " " + strconv.Itoa(check.Count)
+		}
+		fmt.Fprintf(env.stderr, "limit breached: %s at %s over %s\n", name, check.Actual, check.Max)
+	}
+	rows := [][]string{}
+	if *by == "" {
+		for _, position := range exposureReport.TopPositions {
+			rows = append(rows, []string{strconv.Itoa(position.Rank), position.CUSIP, position.Name, position.Amount,
+				position.Value, position.Weight, position.CumulativeGrossWeight})
+		}
+		return env.print(report{
+			Headers: []string{"RANK", "CUSIP", "NAME", "AMOUNT", "VALUE", "WEIGHT", "CUMULATIVE"},
+			Rows:    rows,
+			Value:   exposureReport,
+		})
+	}
+	for _, bucket := range exposureReport.Buckets(*by) {
+		rows = append(rows, []string{bucket.Value, bucket.Long, bucket.Short, bucket.Gross, bucket.Net,
+			bucket.GrossWeight, bucket.NetWeight})
+	}
+	rows = append(rows, []string{"TOTAL", exposureReport.Long, exposureReport.Short, exposureReport.Gross, exposureReport.Net,
+		exposureReport.GrossWeight, exposureReport.NetWeight})
+	return env.print(report{
+		Headers: []string{dimensionHeaders[*by], "LONG", "SHORT", "GROSS", "NET", "GROSS WEIGHT", "NET WEIGHT"},
+		Rows:    rows,
+		Value:   exposureReport,
+	})
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zacharyfrederick/admin/archive"
+	"github.com/zacharyfrederick/admin/client"
+	"github.com/zacharyfrederick/admin/importer"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+// Number of records fetched per page when a command lists everything
+const LIST_PAGE_SIZE int = 200
+
+// The operations of the commands, over the REST api or on the ledger directly
+type ledger interface {
+	CreateFund(request types.CreateFundRequest) (string, error)
+	CreateInvestor(request types.CreateInvestorRequest) (string, error)
+	CreateCapitalAccount(request types.CreateCapitalAccountRequest) (string, error)
+	CreatePortfolio(request types.CreatePortfolioRequest) (string, error)
+	CreateCapitalAccountAction(request types.CreateCapitalAccountActionRequest) (string, error)
+	CreatePortfolioAction(request types.CreatePortfolioActionRequest) (string, error)
+	ValuePortfolio(request types.ValuePortfolioRequest) error
+	BootstrapFund(fundId string) error
+	StepFund(fundId string) (*types.FundAndCapitalAccounts, error)
+	GetFund(fundId string) (*types.Fund, error)
+	ListFunds() ([]*types.Fund, error)
+	GetCapitalAccount(capitalAccountId string) (*types.CapitalAccount, error)
+	ListFundCapitalAccounts(fundId string) ([]*types.CapitalAccount, error)
+	ImportCapitalAccounts(fundId string, path string, batchSize int) (*types.ImportReport, error)
+	SetFundChartOfAccounts(fundId string, chart types.ChartOfAccounts) error
+	GetFundJournal(fundId string, period int) (*types.Journal, error)
+	GetFundTrialBalance(fundId string, period int) (*types.TrialBalance, error)
+	SetRisklessRate(risklessRateId string, request types.SetRisklessRateRequest) (*types.RisklessRate, error)
+	GetFundPerformance(fundId string, period int, risklessRateId string) (*types.PerformanceReport, error)
+	SetSecurity(cusip string, request types.SetSecurityRequest) (*types.Security, error)
+	SetFundConcentrationLimits(fundId string, limits types.ConcentrationLimits) error
+	GetFundExposure(fundId string, date string, top int) (*types.ExposureReport, error)
+	Export(w io.Writer) (*archive.Manifest, error)
+	Restore(a *archive.Archive, batchSize int) (*archive.RestoreReport, error)
+}
+
+// Export and restore read and write every document, which the REST api does not offer
+var errNeedsLedger = errors.New("export and restore run against the ledger directly, use -config instead of -server")
+
+// Calls the REST server
+type restLedger struct {
+	client *client.Client
+}
+
+func (l *restLedger) CreateFund(request types.CreateFundRequest) (string, error) {
+	return l.client.CreateFund(context.Background(), request)
+}
+
+func (l *restLedger) CreateInvestor(request types.CreateInvestorRequest) (string, error) {
+	return l.client.CreateInvestor(context.Background(), request)
+}
+
+func (l *restLedger) CreateCapitalAccount(request types.CreateCapitalAccountRequest) (string, error) {
+	return l.client.CreateCapitalAccount(context.Background(), request)
+}
+
+func (l *restLedger) CreatePortfolio(request types.CreatePortfolioRequest) (string, error) {
+	return l.client.CreatePortfolio(context.Background(), request)
+}
+
+func (l *restLedger) CreateCapitalAccountAction(request types.CreateCapitalAccountActionRequest) (string, error) {
+	return l.client.CreateCapitalAccountAction(context.Background(), request)
+}
+
+func (l *restLedger) CreatePortfolioAction(request types.CreatePortfolioActionRequest) (string, error) {
+	return l.client.CreatePortfolioAction(context.Background(), request)
+}
+
+func (l *restLedger) ValuePortfolio(request types.ValuePortfolioRequest) error {
+	return l.client.ValuePortfolio(context.Background(), request)
+}
+
+func (l *restLedger) BootstrapFund(fundId string) error {
+	return l.client.BootstrapFund(context.Background(), fundId)
+}
+
+func (l *restLedger) StepFund(fundId string) (*types.FundAndCapitalAccounts, error) {
+	return l.client.StepFund(context.Background(), fundId)
+}
+
+func (l *restLedger) GetFund(fundId string) (*types.Fund, error) {
+	return l.client.GetFund(context.Background(), fundId)
+}
+
+func (l *restLedger) ListFunds() ([]*types.Fund, error) {
+	funds := []*types.Fund{}
+	page := client.Page{Size: LIST_PAGE_SIZE}
+	for {
+		result, err := l.client.ListFunds(context.Background(), page)
+		if err != nil {
+			return nil, err
+		}
+		funds = append(funds, result.Records...)
+		if int(result.FetchedRecordsCount) < page.Size || result.Bookmark == "" {
+			return funds, nil
+		}
+		page.Bookmark = result.Bookmark
+	}
+}
+
+func (l *restLedger) GetCapitalAccount(capitalAccountId string) (*types.CapitalAccount, error) {
+	return l.client.GetCapitalAccount(context.Background(), capitalAccountId)
+}
+
+func (l *restLedger) ListFundCapitalAccounts(fundId string) ([]*types.CapitalAccount, error) {
+	accounts := []*types.CapitalAccount{}
+	page := client.Page{Size: LIST_PAGE_SIZE}
+	for {
+		result, err := l.client.ListFundCapitalAccounts(context.Background(), fundId, page)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, result.Records...)
+		if int(result.FetchedRecordsCount) < page.Size || result.Bookmark == "" {
+			return accounts, nil
+		}
+		page.Bookmark = result.Bookmark
+	}
+}
+
+func (l *restLedger) ImportCapitalAccounts(fundId string, path string, batchSize int) (*types.ImportReport, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	contentType := importer.CSV_MEDIA_TYPE
+	if strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		contentType = importer.XLSX_MEDIA_TYPE
+	}
+	return l.client.ImportCapitalAccounts(context.Background(), fundId, file, contentType, batchSize)
+}
+
+func (l *restLedger) SetFundChartOfAccounts(fundId string, chart types.ChartOfAccounts) error {
+	_, err := l.client.SetFundChartOfAccounts(context.Background(), fundId, chart)
+	return err
+}
+
+func (l *restLedger) GetFundJournal(fundId string, period int) (*types.Journal, error) {
+	return l.client.GetFundJournal(context.Background(), fundId, period)
+}
+
+func (l *restLedger) GetFundTrialBalance(fundId string, period int) (*types.TrialBalance, error) {
+	return l.client.GetFundTrialBalance(context.Background(), fundId, period)
+}
+
+func (l *restLedger) SetRisklessRate(risklessRateId string, request types.SetRisklessRateRequest) (*types.RisklessRate, error) {
+	return l.client.SetRisklessRate(context.Background(), risklessRateId, request)
+}
+
+func (l *restLedger) GetFundPerformance(fundId string, period int, risklessRateId string) (*types.PerformanceReport, error) {
+	return l.client.GetFundPerformance(context.Background(), fundId, period, risklessRateId)
+}
+
+func (l *restLedger) SetSecurity(cusip string, request types.SetSecurityRequest) (*types.Security, error) {
+	return l.client.SetSecurity(context.Background(), cusip, request)
+}
+
+func (l *restLedger) SetFundConcentrationLimits(fundId string, limits types.ConcentrationLimits) error {
+	_, err := l.client.SetFundConcentrationLimits(context.Background(), fundId, limits)
+	return err
+}
+
+func (l *restLedger) GetFundExposure(fundId string, date string, top int) (*types.ExposureReport, error) {
+	return l.client.GetFundExposure(context.Background(), fundId, date, top)
+}
+
+func (l *restLedger) Export(w io.Writer) (*archive.Manifest, error) {
+	return nil, errNeedsLedger
+}
+
+func (l *restLedger) Restore(a *archive.Archive, batchSize int) (*archive.RestoreReport, error) {
+	return nil, errNeedsLedger
+}
+
+// Submits to the chaincode through the gateway, or to the local backend, with the
+// server configuration's identity. Requests are validated as the REST server
+// validates them.
+type directLedger struct {
+	server *web.AdminServer
+}
+
+func (l *directLedger) create(transaction string, args ...string) (string, error) {
+	id := uuid.NewV4().String()
+	_, err := l.server.Submit("", transaction, append([]string{id}, args...)...)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Evaluates the query into v, returning notFound when there is no document
+func (l *directLedger) query(v interface{}, notFound error, name string, args ...string) error {
+	result, err := l.server.Evaluate("", name, args...)
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		return notFound
+	}
+	return json.Unmarshal(result, v)
+}
+
+func (l *directLedger) CreateFund(request types.CreateFundRequest) (string, error) {
+	err := types.ValidateCreateFundRequest(&request)
+	if err != nil {
+		return "", err
+	}
+	return l.create("CreateFund", request.Name, request.InceptionDate)
+}
+
+func (l *directLedger) CreateInvestor(request types.CreateInvestorRequest) (string, error) {
+	err := types.ValidateCreateInvestorRequest(&request)
+	if err != nil {
+		return "", err
+	}
+	return l.create("CreateInvestor", request.Name)
+}
+
+func (l *directLedger) CreateCapitalAccount(request types.CreateCapitalAccountRequest) (string, error) {
+	err := types.ValidateCreateCapitalAccountRequest(&request)
+	if err != nil {
+		return "", err
+	}
+	return l.create("CreateCapitalAccount", request.Fund, request.Investor, fmt.Sprintf("%t", request.HasPerformanceFees), request.PerformanceRate)
+}
+
+func (l *directLedger) CreatePortfolio(request types.CreatePortfolioRequest) (string, error) {
+	err := types.ValidateCreatePortfolioRequest(&request)
+	if err != nil {
+		return "", err
+	}
+	return l.create("CreatePortfolio", request.Fund, request.Name)
+}
+
+func (l *directLedger) CreateCapitalAccountAction(request types.CreateCapitalAccountActionRequest) (string, error) {
+	err := types.ValidateCreateCapitalAccountActionRequest(&request)
+	if err != nil {
+		return "", err
+	}
+	return l.create("CreateCapitalAccountAction", request.CapitalAccount, request.Type, request.Amount,
+		fmt.Sprintf("%t", request.Full), request.Date, fmt.Sprintf("%d", request.Period))
+}
+
+func (l *directLedger) CreatePortfolioAction(request types.CreatePortfolioActionRequest) (string, error) {
+	err := types.ValidateCreatePortfolioActionRequest(&request)
+	if err != nil {
+		return "", err
+	}
+	return l.create("CreatePortfolioAction", request.Portfolio, request.Type, request.Date, fmt.Sprintf("%d", request.Period),
+		request.Name, request.CUSIP, request.Amount, request.Currency)
+}
+
+func (l *directLedger) ValuePortfolio(request types.ValuePortfolioRequest) error {
+	err := types.ValidateValuePortfolioRequest(&request)
+	if err != nil {
+		return err
+	}
+	_, err = l.server.Submit("", "UpdatePortfolioValuation", request.Portfolio, request.Date, request.Name, request.Price)
+	return err
+}
+
+func (l *directLedger) BootstrapFund(fundId string) error {
+	_, err := l.server.Submit("", "BootstrapFund", fundId)
+	return err
+}
+
+func (l *directLedger) StepFund(fundId string) (*types.FundAndCapitalAccounts, error) {
+	result, err := l.server.Submit("", "StepFund", fundId)
+	if err != nil {
+		return nil, err
+	}
+	var stepped types.FundAndCapitalAccounts
+	err = json.Unmarshal(result, &stepped)
+	if err != nil {
+		return nil, err
+	}
+	return &stepped, nil
+}
+
+func (l *directLedger) GetFund(fundId string) (*types.Fund, error) {
+	var fund types.Fund
+	err := l.query(&fund, pkgErrors.FundNotFoundError.WithDetail("fund", fundId), "QueryFundById", fundId)
+	if err != nil {
+		return nil, err
+	}
+	return &fund, nil
+}
+
+func (l *directLedger) ListFunds() ([]*types.Fund, error) {
+	funds := []*types.Fund{}
+	bookmark := ""
+	for {
+		var page types.FundPage
+		err := l.query(&page, nil, "QueryFunds", fmt.Sprintf("%d", LIST_PAGE_SIZE), bookmark)
+		if err != nil {
+			return nil, err
+		}
+		funds = append(funds, page.Records...)
+		if int(page.FetchedRecordsCount) < LIST_PAGE_SIZE || page.Bookmark == "" {
+			return funds, nil
+		}
+		bookmark = page.Bookmark
+	}
+}
+
+func (l *directLedger) GetCapitalAccount(capitalAccountId string) (*types.CapitalAccount, error) {
+	var account types.CapitalAccount
+	err := l.query(&account, pkgErrors.CapitalAccountNotFoundError.WithDetail("capitalAccount", capitalAccountId), "QueryCapitalAccountById", capitalAccountId)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (l *directLedger) ListFundCapitalAccounts(fundId string) ([]*types.CapitalAccount, error) {
+	accounts := []*types.CapitalAccount{}
+	bookmark := ""
+	for {
+		var page types.CapitalAccountPage
+		err := l.query(&page, nil, "QueryCapitalAccountsByFundWithPagination", fundId, fmt.Sprintf("%d", LIST_PAGE_SIZE), bookmark)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, page.Records...)
+		if int(page.FetchedRecordsCount) < LIST_PAGE_SIZE || page.Bookmark == "" {
+			return accounts, nil
+		}
+		bookmark = page.Bookmark
+	}
+}
+
+// Reads and checks the sheet here, as the REST server would, before submitting
+func (l *directLedger) ImportCapitalAccounts(fundId string, path string, batchSize int) (*types.ImportReport, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := importer.ReadSheet(file)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := importer.ParseRows(fundId, records)
+	if err != nil {
+		return nil, err
+	}
+	return importer.Import(fundId, rows, batchSize, importer.ChaincodeSubmitter(func(name string, args ...string) ([]byte, error) {
+		return l.server.Submit("", name, args...)
+	}))
+}
+
+func (l *directLedger) SetFundChartOfAccounts(fundId string, chart types.ChartOfAccounts) error {
+	err := chart.Validate()
+	if err != nil {
+		return err
+	}
+	chartJSON, err := json.Marshal(chart)
+	if err != nil {
+		return err
+	}
+	_, err = l.server.Submit("", "SetFundChartOfAccounts", fundId, string(chartJSON))
+	return err
+}
+
+func (l *directLedger) GetFundJournal(fundId string, period int) (*types.Journal, error) {
+	var journal types.Journal
+	err := l.query(&journal, nil, "QueryJournal", fundId, strconv.Itoa(period))
+	if err != nil {
+		return nil, err
+	}
+	return &journal, nil
+}
+
+func (l *directLedger) GetFundTrialBalance(fundId string, period int) (*types.TrialBalance, error) {
+	var trialBalance types.TrialBalance
+	err := l.query(&trialBalance, nil, "QueryTrialBalance", fundId, strconv.Itoa(period))
+	if err != nil {
+		return nil, err
+	}
+	return &trialBalance, nil
+}
+
+func (l *directLedger) SetRisklessRate(risklessRateId string, request types.SetRisklessRateRequest) (*types.RisklessRate, error) {
+	err := types.ValidateSetRisklessRateRequest(&request)
+	if err != nil {
+		return nil, err
+	}
+	_, err = l.server.Submit("", "SetRisklessRate", risklessRateId, request.Name, request.Date, request.Rate)
+	if err != nil {
+		return nil, err
+	}
+	var risklessRate types.RisklessRate
+	err = l.query(&risklessRate, pkgErrors.RisklessRateNotFoundError.WithDetail("risklessRate", risklessRateId), "QueryRisklessRateById", risklessRateId)
+	if err != nil {
+		return nil, err
+	}
+	return &risklessRate, nil
+}
+
+func (l *directLedger) GetFundPerformance(fundId string, period int, risklessRateId string) (*types.PerformanceReport, error) {
+	var performance types.PerformanceReport
+	err := l.query(&performance, nil, "QueryPerformance", fundId, strconv.Itoa(period), risklessRateId)
+	if err != nil {
+		return nil, err
+	}
+	return &performance, nil
+}
+
+func (l *directLedger) SetSecurity(cusip string, request types.SetSecurityRequest) (*types.Security, error) {
+	err := types.ValidateSetSecurityRequest(cusip, &request)
+	if err != nil {
+		return nil, err
+	}
+	_, err = l.server.Submit("", "SetSecurity", cusip, request.Name, request.AssetClass, request.Sector, request.Country,
+		request.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	security := types.CreateSecurity(cusip, &request)
+	return &security, nil
+}
+
+func (l *directLedger) SetFundConcentrationLimits(fundId string, limits types.ConcentrationLimits) error {
+	if limits == nil {
+		limits = types.ConcentrationLimits{}
+	}
+	err := limits.Validate()
+	if err != nil {
+		return err
+	}
+	limitsJSON, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+	_, err = l.server.Submit("", "SetFundConcentrationLimits", fundId, string(limitsJSON))
+	return err
+}
+
+func (l *directLedger) GetFundExposure(fundId string, date string, top int) (*types.ExposureReport, error) {
+	var exposure types.ExposureReport
+	err := l.query(&exposure, nil, "QueryExposure", fundId, date, strconv.Itoa(top))
+	if err != nil {
+		return nil, err
+	}
+	return &exposure, nil
+}
+
+func (l *directLedger) Export(w io.Writer) (*archive.Manifest, error) {
+	return archive.Export(func(name string, args ...string) ([]byte, error) {
+		return l.server.Evaluate("", name, args...)
+	}, w)
+}
+
+func (l *directLedger) Restore(a *archive.Archive, batchSize int) (*archive.RestoreReport, error) {
+	return archive.Restore(a, batchSize, func(name string, args ...string) ([]byte, error) {
+		return l.server.Submit("", name, args...)
+	})
+}
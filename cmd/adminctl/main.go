@@ -0,0 +1,201 @@
+// Command adminctl administers funds from the command line. It calls the REST
+// server when -server is given and otherwise submits to the ledger directly with
+// the identity of a server config file, which can be the local backend.
+//
+//	adminctl -server https://admin.example.com -api-key $KEY list-funds
+//	adminctl -config config.yaml -output csv balances FUND
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/zacharyfrederick/admin/client"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+const ENV_SERVER string = "ADMINCTL_SERVER"
+const ENV_API_KEY string = "ADMIN_API_KEY"
+const ENV_TOKEN string = "ADMIN_TOKEN"
+
+// Returned after a command has printed why it failed, e.g. the rows of a price
+// file that were rejected
+var errCommandFailed = errors.New("command failed")
+
+// What a command runs against and where it prints
+type environment struct {
+	ledger ledger
+	output string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (e *environment) print(r report) error {
+	return writeReport(e.stdout, e.output, r)
+}
+
+type command struct {
+	Usage   string
+	Summary string
+	Run     func(env *environment, args []string) error
+}
+
+// Set in init, since the commands look up their own usage
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"create-fund":       {"-name NAME -inception-date MM-DD-YYYY", "create a fund", createFund},
+		"create-investor":   {"-name NAME", "create an investor", createInvestor},
+		"create-account":    {"-fund FUND -investor INVESTOR [-performance-fees -performance-rate RATE]", "open a capital account for an investor in a fund", createAccount},
+		"create-portfolio":  {"-fund FUND -name NAME", "create a portfolio of a fund", createPortfolio},
+		"deposit":           {"-account ACCOUNT -amount AMOUNT -date MM-DD-YYYY [-period N]", "deposit into a capital account", deposit},
+		"withdraw":          {"-account ACCOUNT -amount AMOUNT -date MM-DD-YYYY [-period N] [-full]", "withdraw from a capital account", withdraw},
+		"buy":               {"-portfolio PORTFOLIO -name NAME -cusip CUSIP -amount AMOUNT -date MM-DD-YYYY [-period N] [-currency CODE]", "buy a security for a portfolio", buy},
+		"sell":              {"-portfolio PORTFOLIO -name NAME -cusip CUSIP -amount AMOUNT -date MM-DD-YYYY [-period N] [-currency CODE]", "sell a security from a portfolio", sell},
+		"import":            {"FUND FILE [-batch-size N]", "import investors, capital accounts and inception deposits from a CSV or Excel sheet", importAccounts},
+		"export":            {"FILE", "write every document on the ledger to an archive file", exportLedger},
+		"restore":           {"FILE [-batch-size N]", "write the documents of an archive file to the ledger", restoreLedger},
+		"load-prices":       {"FILE", "value portfolios from a CSV file with portfolio, name, date and price columns", loadPrices},
+		"bootstrap":         {"FUND", "open the first period of a fund", bootstrap},
+		"step":              {"FUND", "close the current period of a fund and open the next", step},
+		"get-fund":          {"FUND", "show a fund", getFund},
+		"list-funds":        {"", "list the funds", listFunds},
+		"statement":         {"ACCOUNT", "show the values of a capital account in each period", statement},
+		"balances":          {"FUND [-period N]", "show the balances of every capital account of a fund in a period", balances},
+		"set-chart":         {"FUND FILE", "set the general ledger accounts of a fund from a JSON file of accounts by role", setChart},
+		"journal":           {"FUND [-period N] [-format csv|iif] [-out FILE]", "show the journal entries of a period, or write them for a general ledger", showJournal},
+		"trial-balance":     {"FUND [-period N]", "show the balance of every general ledger account of a fund after a period", trialBalance},
+		"set-riskless-rate": {"ID -date MM-DD-YYYY -rate RATE [-name NAME]", "set an annual riskless rate from a date on, naming it when it is new", setRisklessRate},
+		"performance":       {"FUND [-period N] [-riskless-rate ID]", "show the returns, volatility and drawdowns of a fund, its classes and its capital accounts", performance},
+		"set-security":      {"CUSIP -name NAME -asset-class CLASS -country CODE -issuer ISSUER [-sector SECTOR]", "set the security master record that classifies positions in a CUSIP", setSecurity},
+		"set-limits":        {"FUND FILE", "set the concentration limits of a fund from a JSON file of limits", setLimits},
+		"exposure":          {"FUND [-date MM-DD-YYYY] [-top N] [-by DIMENSION]", "show the largest positions of a fund, or its exposure by a dimension, against its concentration limits", exposure},
+	}
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		if err != errCommandFailed {
+			fmt.Fprintf(os.Stderr, "adminctl: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) error {
+	global := flag.NewFlagSet("adminctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	server := global.String("server", os.Getenv(ENV_SERVER), "URL of the REST server, the ledger is used directly when empty")
+	apiKey := global.String("api-key", os.Getenv(ENV_API_KEY), "API key sent to the REST server")
+	token := global.String("token", os.Getenv(ENV_TOKEN), "bearer token sent to the REST server")
+	configPath := global.String("config", os.Getenv(web.CONFIG_PATH_ENV), "server config file used to reach the ledger directly")
+	output := global.String("output", OUTPUT_TABLE, "output format: table, json or csv")
+	global.Usage = func() { usage(global) }
+	err := global.Parse(args)
+	if err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return flag.ErrHelp
+	}
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, run adminctl -h for the list", name)
+	}
+	//fail before anything is submitted
+	err = writeReport(io.Discard, *output, report{})
+	if err != nil {
+		return err
+	}
+
+	env := &environment{output: *output, stdout: stdout, stderr: stderr}
+	if *server != "" {
+		options := []client.Option{}
+		if *apiKey != "" {
+			options = append(options, client.WithAPIKey(*apiKey))
+		}
+		if *token != "" {
+			options = append(options, client.WithBearerToken(*token))
+		}
+		env.ledger = &restLedger{client: client.New(*server, options...)}
+	} else {
+		adminServer, err := openLedger(*configPath)
+		if err != nil {
+			return err
+		}
+		defer adminServer.Close()
+		env.ledger = &directLedger{server: adminServer}
+	}
+	return cmd.Run(env, global.Args()[1:])
+}
+
+func openLedger(configPath string) (*web.AdminServer, error) {
+	config, err := web.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the config: %w", err)
+	}
+	if config.Backend == web.BACKEND_LOCAL {
+		return web.OpenLocal(config)
+	}
+	return web.ConnectToNetwork(config)
+}
+
+func usage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintln(out, "usage: adminctl [flags] COMMAND [arguments]")
+	fmt.Fprintln(out, "\nflags:")
+	global.PrintDefaults()
+	fmt.Fprintln(out, "\ncommands:")
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s %s\n      %s\n", name, commands[name].Usage, commands[name].Summary)
+	}
+}
+
+// Returns a flag set for the command that reports its own usage on errors
+func commandFlags(env *environment, name string) *flag.FlagSet {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.SetOutput(env.stderr)
+	set.Usage = func() {
+		fmt.Fprintf(env.stderr, "usage: adminctl %s %s\n", name, commands[name].Usage)
+		set.PrintDefaults()
+	}
+	return set
+}
+
+// Parses the flags wherever they appear among the positional arguments and
+// returns the positional arguments, which must number exactly want
+func parseArgs(set *flag.FlagSet, args []string, want int) ([]string, error) {
+	positional := []string{}
+	for {
+		err := set.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		args = set.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != want {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", set.Name(), want, len(positional))
+	}
+	return positional, nil
+}
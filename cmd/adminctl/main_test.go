@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/archive"
+	"github.com/zacharyfrederick/admin/journal"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Runs the commands against a local backend saved in a temporary directory
+type cli struct {
+	t      *testing.T
+	config string
+	dir    string
+}
+
+func newCLI(t *testing.T) *cli {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	data := "backend: local\nlocal:\n  dataFile: " + filepath.Join(dir, "ledger.json") + "\naudit:\n  path: " + filepath.Join(dir, "audit.log") + "\n"
+	err := ioutil.WriteFile(config, []byte(data), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &cli{t: t, config: config, dir: dir}
+}
+
+func (c *cli) run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(append([]string{"-config", c.config}, args...), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+// Runs the command with JSON output and returns the created id in field
+func (c *cli) create(field string, args ...string) string {
+	out, err := c.run(append([]string{"-output", "json"}, args...)...)
+	if err != nil {
+		c.t.Fatal(args[0], err)
+	}
+	var created map[string]string
+	err = json.Unmarshal([]byte(out), &created)
+	if err != nil {
+		c.t.Fatal(args[0], out, err)
+	}
+	return created[field]
+}
+
+func TestAdministerAFund(t *testing.T) {
+	c := newCLI(t)
+	fund := c.create("fundId", "create-fund", "-name", "Test Fund", "-inception-date", "01-01-2020")
+	investor := c.create("investorId", "create-investor", "-name", "Investor")
+	account := c.create("capitalAccountId", "create-account", "-fund", fund, "-investor", investor)
+	portfolio := c.create("portfolioId", "create-portfolio", "-fund", fund, "-name", "Main")
+	c.create("transactionId", "deposit", "-account", account, "-amount", "1000", "-date", "01-01-2020")
+	c.create("transactionId", "buy", "-portfolio", portfolio, "-name", "Apple", "-cusip", "037833100", "-amount", "10", "-date", "01-01-2020")
+
+	_, err := c.run("bootstrap", fund)
+	assert.Nil(t, err)
+
+	prices := filepath.Join(c.dir, "prices.csv")
+	err = ioutil.WriteFile(prices, []byte("portfolio,name,date,price\n"+portfolio+",Apple,01-01-2020,110\n"), 0600)
+	assert.Nil(t, err)
+	_, err = c.run("load-prices", prices)
+	assert.Nil(t, err)
+
+	out, err := c.run("-output", "csv", "step", fund)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "NUMBER,ACCOUNT,INVESTOR,OPENING,DEPOSITS,CLOSING,OWNERSHIP\n")
+	assert.Contains(t, out, ","+account+","+investor+",")
+
+	//the values are those of the closed period
+	out, err = c.run("-output", "csv", "balances", fund)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, ",TOTAL,,1100,0,1100,", lines[2])
+
+	out, err = c.run("statement", account)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(out, "PERIOD  OPENING"), out)
+
+	out, err = c.run("-output", "json", "list-funds")
+	assert.Nil(t, err)
+	assert.Contains(t, out, `"name": "Test Fund"`)
+}
+
+func TestLoadPricesReportsEveryRow(t *testing.T) {
+	c := newCLI(t)
+	prices := filepath.Join(c.dir, "prices.csv")
+	err := ioutil.WriteFile(prices, []byte("date,price,name,portfolio\n01-31-2020,110,Apple,missing\n31-01-2020,-1,Apple,missing\n"), 0600)
+	assert.Nil(t, err)
+
+	out, err := c.run("-output", "json", "load-prices", prices)
+	assert.Equal(t, errCommandFailed, err)
+	var results []priceResult
+	assert.Nil(t, json.Unmarshal([]byte(out), &results))
+	assert.Len(t, results, 2)
+	assert.Equal(t, 2, results[0].Line)
+	assert.NotEmpty(t, results[0].Error)
+	assert.Contains(t, results[1].Error, pkgErrors.CODE_VALIDATION_FAILED)
+
+	err = ioutil.WriteFile(prices, []byte("portfolio,name,price\n"), 0600)
+	assert.Nil(t, err)
+	_, err = c.run("load-prices", prices)
+	assert.EqualError(t, err, prices+": missing the date column")
+}
+
+func TestCommandErrors(t *testing.T) {
+	c := newCLI(t)
+	_, err := c.run("get-fund", "missing")
+	assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+
+	_, err = c.run("create-fund", "-name", "Test Fund")
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+
+	_, err = c.run("step")
+	assert.EqualError(t, err, "step takes 1 argument(s), got 0")
+	_, err = c.run("rebalance")
+	assert.EqualError(t, err, `unknown command "rebalance", run adminctl -h for the list`)
+	_, err = c.run("-output", "xml", "list-funds")
+	assert.EqualError(t, err, `unknown output format "xml", use table, json or csv`)
+}
+
+func TestImportAccounts(t *testing.T) {
+	c := newCLI(t)
+	fund := c.create("fundId", "create-fund", "-name", "Test Fund", "-inception-date", "01-01-2020")
+	sheet := filepath.Join(c.dir, "investors.csv")
+	err := ioutil.WriteFile(sheet, []byte("reference,investorName,depositAmount,depositDate\nLP-1,Alice,1000,01-01-2020\nLP-2,Bob,,\n"), 0600)
+	assert.Nil(t, err)
+
+	out, err := c.run("-output", "csv", "import", fund, sheet, "-batch-size", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strings.Count(out, ",imported\n"), out)
+
+	//running it again skips the rows that are already on the ledger
+	out, err = c.run("-output", "csv", "import", fund, sheet)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strings.Count(out, ",skipped\n"), out)
+	out, err = c.run("-output", "csv", "balances", fund)
+	assert.Nil(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 4)
+
+	err = ioutil.WriteFile(sheet, []byte("reference,investorName\nLP-3,\n"), 0600)
+	assert.Nil(t, err)
+	_, err = c.run("import", fund, sheet)
+	assert.Equal(t, errCommandFailed, err)
+}
+
+func TestExportAndRestore(t *testing.T) {
+	c := newCLI(t)
+	fund := c.create("fundId", "create-fund", "-name", "Test Fund", "-inception-date", "01-01-2020")
+	investor := c.create("investorId", "create-investor", "-name", "Investor")
+	c.create("capitalAccountId", "create-account", "-fund", fund, "-investor", investor)
+
+	path := filepath.Join(c.dir, "books.ndjson")
+	out, err := c.run("-output", "csv", "export", path)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "SECTION,COUNT,SHA256\nfund,1,")
+	assert.Contains(t, out, "\ncapitalAccount,1,")
+
+	fresh := newCLI(t)
+	out, err = fresh.run("-output", "csv", "restore", path, "-batch-size", "10")
+	assert.Nil(t, err)
+	assert.Equal(t, "SECTION,RESTORED,SKIPPED\nfund,1,0\ninvestor,1,0\ncapitalAccount,1,0\n", out)
+	out, err = fresh.run("-output", "json", "get-fund", fund)
+	assert.Nil(t, err)
+	assert.Contains(t, out, `"name": "Test Fund"`)
+
+	_, err = c.run("-server", "http://localhost:1", "export", path)
+	assert.Equal(t, errNeedsLedger, err)
+	err = ioutil.WriteFile(path, []byte("not an archive\n"), 0600)
+	assert.Nil(t, err)
+	_, err = fresh.run("restore", path)
+	assert.True(t, errors.Is(err, archive.UnsupportedArchiveError))
+}
+
+func TestJournalAndTrialBalance(t *testing.T) {
+	c := newCLI(t)
+	fund := c.create("fundId", "create-fund", "-name", "Test Fund", "-inception-date", "01-01-2020")
+	investor := c.create("investorId", "create-investor", "-name", "Investor")
+	account := c.create("capitalAccountId", "create-account", "-fund", fund, "-investor", investor)
+	c.create("transactionId", "deposit", "-account", account, "-amount", "1000", "-date", "01-01-2020")
+
+	chart := filepath.Join(c.dir, "chart.json")
+	err := ioutil.WriteFile(chart, []byte(`{"cash":{"number":"1010","name":"Bank","type":"asset"}}`), 0600)
+	assert.Nil(t, err)
+	out, err := c.run("-output", "csv", "set-chart", fund, chart)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "1010,Bank,asset,cash\n")
+	_, err = c.run("bootstrap", fund)
+	assert.Nil(t, err)
+
+	out, err = c.run("-output", "csv", "trial-balance", fund)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, "ACCOUNT,NAME,TYPE,DEBIT,CREDIT", lines[0])
+	assert.Equal(t, ",TOTAL,,1000.00,1000.00", lines[len(lines)-1])
+
+	out, err = c.run("-output", "csv", "journal", fund, "-period", "0")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "1010,Bank,,1000.00,0.00,Deposit to capital account")
+
+	iif := filepath.Join(c.dir, "journal.iif")
+	_, err = c.run("journal", fund, "-format", "iif", "-out", iif)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadFile(iif)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "!ACCNT\tNAME\tACCNTTYPE\tACCNUM\r\n"), string(data))
+	_, err = c.run("journal", fund, "-format", "qif")
+	assert.True(t, errors.Is(err, journal.UnsupportedFormatError))
+}
+
+func TestPerformance(t *testing.T) {
+	c := newCLI(t)
+	fund := c.create("fundId", "create-fund", "-name", "Test Fund", "-inception-date", "01-01-2020")
+	investor := c.create("investorId", "create-investor", "-name", "Investor")
+	account := c.create("capitalAccountId", "create-account", "-fund", fund, "-investor", investor)
+	c.create("transactionId", "deposit", "-account", account, "-amount", "1000", "-date", "01-01-2020")
+	_, err := c.run("bootstrap", fund)
+	assert.Nil(t, err)
+
+	_, err = c.run("set-riskless-rate", "tbill", "-date", "01-01-2020", "-rate", "0.01")
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	_, err = c.run("set-riskless-rate", "tbill", "-name", "T-bill", "-date", "07-01-2020", "-rate", "0.005")
+	assert.Nil(t, err)
+	out, err := c.run("-output", "csv", "set-riskless-rate", "tbill", "-date", "01-01-2020", "-rate", "0.01")
+	assert.Nil(t, err)
+	assert.Equal(t, "ID,NAME,DATE,RATE\ntbill,T-bill,01-01-2020,0.01\ntbill,T-bill,07-01-2020,0.005\n", out)
+
+	out, err = c.run("-output", "csv", "performance", fund, "-riskless-rate", "tbill")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, "LEVEL,ID,YTD,ITD,IRR,VOLATILITY,SHARPE,MAX DRAWDOWN,DURATION", lines[0])
+	//nothing has been returned yet, the deposit is just held to the bootstrap
+	assert.Equal(t, []string{
+		"fund," + fund + ",,,0.000000,,,,0",
+		"class,0.02/0,,,0.000000,,,,0",
+		"capitalAccount," + account + ",,,0.000000,,,,0",
+	}, lines[1:])
+	_, err = c.run("performance", fund, "-riskless-rate", "missing")
+	assert.True(t, errors.Is(err, pkgErrors.RisklessRateNotFoundError))
+}
+
+func TestExposure(t *testing.T) {
+	c := newCLI(t)
+	fund := c.create("fundId", "create-fund", "-name", "Test Fund", "-inception-date", "01-01-2020")
+	portfolio := c.create("portfolioId", "create-portfolio", "-fund", fund, "-name", "Main")
+	c.create("transactionId", "buy", "-portfolio", portfolio, "-name", "Apple", "-cusip", "037833100", "-amount", "10", "-date", "01-31-2020")
+	c.create("transactionId", "buy", "-portfolio", portfolio, "-name", "Microsoft", "-cusip", "594918104", "-amount", "5", "-date", "01-31-2020")
+	prices := filepath.Join(c.dir, "prices.csv")
+	err := ioutil.WriteFile(prices, []byte("portfolio,name,date,price\n"+portfolio+",Apple,01-31-2020,150\n"+portfolio+",Microsoft,01-31-2020,200\n"), 0600)
+	assert.Nil(t, err)
+	_, err = c.run("load-prices", prices)
+	assert.Nil(t, err)
+
+	_, err = c.run("set-security", "037833100", "-name", "Apple Inc", "-asset-class", "stock", "-country", "US", "-issuer", "Apple Inc")
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	out, err := c.run("-output", "csv", "set-security", "037833100", "-name", "Apple Inc", "-asset-class", "equity",
+		"-sector", "Information Technology", "-country", "US", "-issuer", "Apple Inc")
+	assert.Nil(t, err)
+	assert.Equal(t, "CUSIP,NAME,ASSET CLASS,SECTOR,COUNTRY,ISSUER\n037833100,Apple Inc,equity,Information Technology,US,Apple Inc\n", out)
+
+	limits := filepath.Join(c.dir, "limits.json")
+	err = ioutil.WriteFile(limits, []byte(`[{"dimension":"security","max":"0.5"},{"dimension":"top","max":"1"}]`), 0600)
+	assert.Nil(t, err)
+	_, err = c.run("set-limits", fund, limits)
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	err = ioutil.WriteFile(limits, []byte(`[{"dimension":"security","max":"0.5"}]`), 0600)
+	assert.Nil(t, err)
+	out, err = c.run("-output", "csv", "set-limits", fund, limits)
+	assert.Nil(t, err)
+	assert.Equal(t, "DIMENSION,VALUE,COUNT,MAX\nsecurity,,,0.5\n", out)
+
+	out, err = c.run("-output", "csv", "exposure", fund, "-top", "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "RANK,CUSIP,NAME,AMOUNT,VALUE,WEIGHT,CUMULATIVE\n1,037833100,Apple Inc,10,1500.00,0.600000,0.600000\n", out)
+	out, err = c.run("-output", "csv", "exposure", fund, "-by", "assetClass")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, []string{
+		"ASSET CLASS,LONG,SHORT,GROSS,NET,GROSS WEIGHT,NET WEIGHT",
+		"equity,1500.00,0.00,1500.00,1500.00,0.600000,0.600000",
+		"unclassified,1000.00,0.00,1000.00,1000.00,0.400000,0.400000",
+		"TOTAL,2500.00,0.00,2500.00,2500.00,1.000000,1.000000",
+	}, lines)
+	out, err = c.run("-output", "json", "exposure", fund)
+	assert.Nil(t, err)
+	assert.Contains(t, out, `"breached": true`)
+	_, err = c.run("exposure", fund, "-by", "region")
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+}
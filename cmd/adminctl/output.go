@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const OUTPUT_TABLE string = "table"
+const OUTPUT_JSON string = "json"
+const OUTPUT_CSV string = "csv"
+
+// The result of a command. Tables and CSV show the rows, JSON shows the value.
+type report struct {
+	Headers []string
+	Rows    [][]string
+	Value   interface{}
+}
+
+func writeReport(w io.Writer, format string, r report) error {
+	switch format {
+	case OUTPUT_JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r.Value)
+	case OUTPUT_CSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write(r.Headers)
+		if err != nil {
+			return err
+		}
+		err = writer.WriteAll(r.Rows)
+		if err != nil {
+			return err
+		}
+		return writer.Error()
+	case OUTPUT_TABLE:
+		writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.Join(r.Headers, "\t"))
+		for _, row := range r.Rows {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, use %s, %s or %s", format, OUTPUT_TABLE, OUTPUT_JSON, OUTPUT_CSV)
+	}
+}
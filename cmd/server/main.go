@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -12,38 +13,51 @@ import (
 )
 
 func main() {
-	path, err := os.Getwd()
-	if err == nil {
-		fmt.Println(path)
+	configPath := flag.String("config", os.Getenv(web.CONFIG_PATH_ENV), "path to the server config file")
+	apiKeyId := flag.String("generate-api-key", "", "print a new API key with this id and the hash to add to auth.apiKeys, then exit")
+	flag.Parse()
+
+	if *apiKeyId != "" {
+		key, hash, err := web.GenerateAPIKey(*apiKeyId)
+		if err != nil {
+			log.Fatalf("Could not generate the API key: %v", err)
+		}
+		fmt.Printf("key:  %s\nhash: %s\n", key, hash)
+		return
 	}
-	adminServer, err := web.ConnectToNetwork()
+
+	config, err := web.LoadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Could not connect to the network: %v", err)
+		log.Fatalf("Could not load the config: %v", err)
+	}
+	if !config.Auth.Enabled() {
+		log.Println("WARNING: authentication is not configured, every route is open to anyone who can reach the server")
 	}
 
-	endpointWrapper := &endpoints.EndpointWrapper{AdminServer: adminServer}
-
-	router := gin.Default()
-	router.POST("/funds", endpointWrapper.PostFundEndpoint)
-	router.GET("/funds/:id", endpointWrapper.GetFundByIdEndpoint)
-	router.GET("/funds/:id/*action", endpointWrapper.GetFundActionEndpoint)
-
-	router.POST("/investors", endpointWrapper.PostInvestorEndpoint)
-	router.GET("/investors/:id", endpointWrapper.GetInvestorByIdEndpoint)
-
-	router.POST("/capitalaccounts", endpointWrapper.PostCapitalAccountEndpoint)
-	router.GET("/capitalaccounts/:id", endpointWrapper.GetCapitalAccountByIdEndpoint)
-
-	router.POST("/portfolios", endpointWrapper.PostPortfoliosEndpoint)
-	router.GET("/portfolios/:id", endpointWrapper.GetPortfolioByIdEndpoint)
-
-	router.POST("/capitalaccountactions", endpointWrapper.PostCapitalAccountActionEndpoint)
-	router.GET("/capitalaccountactions/:id", endpointWrapper.GetCapitalAccountActionByIdEndpoint)
+	var adminServer *web.AdminServer
+	if config.Backend == web.BACKEND_LOCAL {
+		log.Println("Running the chaincode in-process, transactions are not endorsed or ordered")
+		adminServer, err = web.OpenLocal(config)
+	} else {
+		adminServer, err = web.ConnectToNetwork(config)
+	}
+	if err != nil {
+		log.Fatalf("Could not connect to the %s backend: %v", config.Backend, err)
+	}
 
-	router.POST("/portfolioactions", endpointWrapper.PostPortfolioActionEndpoint)
-	router.GET("/portfolioactions/:id", endpointWrapper.GetPortfolioActionByIdEndpoint)
+	defer adminServer.Close()
 
-	router.POST("/valueportfolio", endpointWrapper.PostValuePortfolioEndpoint)
+	w := &endpoints.EndpointWrapper{AdminServer: adminServer}
+	router := gin.Default()
+	router.Use(w.Audit)
+	w.RegisterRoutes(router)
 
-	router.Run()
+	if config.Server.TLSEnabled() {
+		err = router.RunTLS(config.Server.ListenAddress, config.Server.TLSCert, config.Server.TLSKey)
+	} else {
+		err = router.Run(config.Server.ListenAddress)
+	}
+	if err != nil {
+		log.Fatalf("Could not start the server: %v", err)
+	}
 }
@@ -0,0 +1,121 @@
+package endpoints
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+const PRINCIPAL_CONTEXT_KEY string = "principal"
+const ERROR_CODE_CONTEXT_KEY string = "errorCode"
+
+var missingCredentialsError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the request has no Authorization or "+web.API_KEY_HEADER+" header")
+
+// Authenticates the request with the first authenticator that recognizes its
+// credentials and resolves the wallet identity of the caller. Does nothing when
+// the server has no authenticators, in which case every request is submitted
+// with the server's own identity.
+func (w *EndpointWrapper) Authenticate(c *gin.Context) {
+	if len(w.Authenticators) == 0 {
+		c.Next()
+		return
+	}
+	for _, authenticator := range w.Authenticators {
+		principal, ok, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			abortUnauthenticated(c, err)
+			return
+		}
+		if !ok {
+			continue
+		}
+		principal.Identity, err = w.IdentityFor(principal.Subject)
+		if err != nil {
+			respondWithError(c, err)
+			c.Abort()
+			return
+		}
+		c.Set(PRINCIPAL_CONTEXT_KEY, principal)
+		c.Next()
+		return
+	}
+	abortUnauthenticated(c, missingCredentialsError)
+}
+
+func abortUnauthenticated(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+	respondWithError(c, err)
+	c.Abort()
+}
+
+// Rejects requests whose caller was not granted the scope. Requests are not
+// checked when the server does not authenticate them.
+func (w *EndpointWrapper) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := principal(c)
+		if principal == nil {
+			c.Next()
+			return
+		}
+		if !principal.HasScope(scope) {
+			respondWithError(c, pkgErrors.MissingScopeError.WithDetail("scope", scope))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Writes every request to the audit log once it has been answered, with the
+// principal that made it
+func (w *EndpointWrapper) Audit(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	entry := web.AuditEntry{
+		Time:      start.UTC(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Route:     c.FullPath(),
+		Status:    c.Writer.Status(),
+		ErrorCode: c.GetString(ERROR_CODE_CONTEXT_KEY),
+		ClientIP:  c.ClientIP(),
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if principal := principal(c); principal != nil {
+		entry.Subject = principal.Subject
+		entry.AuthMethod = principal.Method
+		entry.Identity = principal.Identity
+	}
+	if err := w.AuditLog.Record(entry); err != nil {
+		log.Printf("Could not write the audit log: %v", err)
+	}
+}
+
+func principal(c *gin.Context) *web.Principal {
+	value, ok := c.Get(PRINCIPAL_CONTEXT_KEY)
+	if !ok {
+		return nil
+	}
+	return value.(*web.Principal)
+}
+
+// Returns the wallet identity the request's transactions are signed with, which
+// is empty for the server's own identity
+func identity(c *gin.Context) string {
+	if principal := principal(c); principal != nil {
+		return principal.Identity
+	}
+	return ""
+}
+
+// Returns the subject the request authenticated as, which is empty when
+// authentication is disabled
+func subject(c *gin.Context) string {
+	if principal := principal(c); principal != nil {
+		return principal.Subject
+	}
+	return ""
+}
@@ -0,0 +1,246 @@
+package endpoints
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/stretchr/testify/assert"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+func encodeJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signToken(t *testing.T, key *ecdsa.PrivateKey, subject string, scope string) string {
+	claims := map[string]interface{}{"sub": subject, "exp": time.Now().Add(time.Hour).Unix(), "scope": scope}
+	input := encodeJSON(t, map[string]string{"alg": "ES256", "kid": "test"}) + "." + encodeJSON(t, claims)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// A server trusting a freshly generated token signing key and the API key
+// returned, with alice enrolled in the wallet under her own subject and bob under
+// the label bob-admin. The API key belongs to bob.
+func authServer(t *testing.T, audit *bytes.Buffer) (*EndpointWrapper, *ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "EC",
+		"kid": "test",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}}})
+	verifier, err := web.NewJWTVerifier(jwks, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := gateway.NewFileSystemWallet(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"alice", "bob-admin"} {
+		err = wallet.Put(label, gateway.NewX509Identity("Org1MSP", "cert", "key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	apiKey, hash, err := web.GenerateAPIKey("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKeys, err := web.NewAPIKeyAuthenticator([]web.APIKey{
+		{ID: "ci", Subject: "bob", Scopes: []string{web.SCOPE_VALUATIONS_WRITE}, Hash: hash},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &EndpointWrapper{AdminServer: &web.AdminServer{
+		Wallet:         wallet,
+		Authenticators: []web.Authenticator{&web.JWTAuthenticator{Verifier: verifier}, apiKeys},
+		AuditLog:       web.NewAuditLog(audit),
+		Identities:     map[string]string{"bob": "bob-admin"},
+	}}, key, apiKey
+}
+
+// Serves one request through the middleware of the real server and returns the
+// response and the identity the handler saw
+func serve(w *EndpointWrapper, method string, path string, headers map[string]string) (*httptest.ResponseRecorder, string) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	router := gin.New()
+	router.Use(w.Audit, w.Authenticate)
+	var label string
+	handler := func(c *gin.Context) {
+		label = identity(c)
+		c.Status(http.StatusOK)
+	}
+	router.GET("/funds", w.RequireScope(web.SCOPE_REPORTS_READ), handler)
+	router.GET("/funds/:id/capitalaccounts", w.RequireScope(web.SCOPE_REPORTS_READ), handler)
+	router.GET("/funds/:id/bootstrap", w.RequireScope(web.SCOPE_FUNDS_WRITE), handler)
+	router.POST("/valueportfolio", w.RequireScope(web.SCOPE_VALUATIONS_WRITE), handler)
+	request := httptest.NewRequest(method, path, nil)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	router.ServeHTTP(recorder, request)
+	return recorder, label
+}
+
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func errorCode(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	var body struct {
+		Error pkgErrors.Error `json:"error"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body.Error.Code
+}
+
+func TestAuthenticateResolvesCallerIdentity(t *testing.T) {
+	w, key, apiKey := authServer(t, &bytes.Buffer{})
+
+	recorder, label := serve(w, "GET", "/funds", bearer(signToken(t, key, "alice", web.SCOPE_REPORTS_READ)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "alice", label)
+
+	recorder, label = serve(w, "POST", "/valueportfolio", map[string]string{web.API_KEY_HEADER: apiKey})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "bob-admin", label)
+}
+
+func TestAuthenticateRejectsMissingCredentials(t *testing.T) {
+	w, key, apiKey := authServer(t, &bytes.Buffer{})
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for _, headers := range []map[string]string{
+		{},
+		{"Authorization": "Basic YWxpY2U6c2VjcmV0"},
+		{"Authorization": "Bearer "},
+		bearer(signToken(t, other, "alice", web.SCOPE_REPORTS_READ)),
+		{web.API_KEY_HEADER: apiKey + "x"},
+		{web.API_KEY_HEADER: "unknown." + apiKey},
+	} {
+		recorder, _ := serve(w, "GET", "/funds", headers)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, headers)
+		assert.Equal(t, pkgErrors.CODE_UNAUTHENTICATED, errorCode(t, recorder))
+		assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+	}
+
+	//a valid token for a subject with no enrolled identity
+	for _, subject := range []string{"carol", "../alice"} {
+		recorder, _ := serve(w, "GET", "/funds", bearer(signToken(t, key, subject, web.SCOPE_REPORTS_READ)))
+		assert.Equal(t, http.StatusForbidden, recorder.Code, subject)
+		assert.Equal(t, pkgErrors.CODE_NO_FABRIC_IDENTITY, errorCode(t, recorder))
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	w, key, apiKey := authServer(t, &bytes.Buffer{})
+	reader := bearer(signToken(t, key, "alice", web.SCOPE_REPORTS_READ))
+
+	recorder, _ := serve(w, "GET", "/funds/fund/capitalaccounts", reader)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder, _ = serve(w, "GET", "/funds/fund/bootstrap", reader)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, pkgErrors.CODE_MISSING_SCOPE, errorCode(t, recorder))
+
+	recorder, _ = serve(w, "POST", "/valueportfolio", reader)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	writer := bearer(signToken(t, key, "alice", web.SCOPE_REPORTS_READ+" "+web.SCOPE_FUNDS_WRITE))
+	recorder, _ = serve(w, "GET", "/funds/fund/bootstrap", writer)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	//the API key may only value portfolios
+	recorder, _ = serve(w, "GET", "/funds", map[string]string{web.API_KEY_HEADER: apiKey})
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestAuditLogsPrincipal(t *testing.T) {
+	audit := &bytes.Buffer{}
+	w, key, _ := authServer(t, audit)
+	serve(w, "GET", "/funds/fund/bootstrap", bearer(signToken(t, key, "alice", web.SCOPE_REPORTS_READ)))
+	serve(w, "GET", "/funds", map[string]string{})
+
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	assert.Len(t, lines, 2)
+	var denied, anonymous web.AuditEntry
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &denied))
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &anonymous))
+
+	assert.Equal(t, "GET", denied.Method)
+	assert.Equal(t, "/funds/fund/bootstrap", denied.Path)
+	assert.Equal(t, "/funds/:id/bootstrap", denied.Route)
+	assert.Equal(t, http.StatusForbidden, denied.Status)
+	assert.Equal(t, pkgErrors.CODE_MISSING_SCOPE, denied.ErrorCode)
+	assert.Equal(t, "alice", denied.Subject)
+	assert.Equal(t, web.AUTH_METHOD_JWT, denied.AuthMethod)
+	assert.Equal(t, "alice", denied.Identity)
+
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Status)
+	assert.Equal(t, "", anonymous.Subject)
+}
+
+func TestAuthenticateIsDisabledWithoutAuthenticators(t *testing.T) {
+	w := &EndpointWrapper{AdminServer: &web.AdminServer{AuditLog: web.NewAuditLog(&bytes.Buffer{})}}
+	recorder, label := serve(w, "POST", "/valueportfolio", map[string]string{})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "", label)
+}
+
+func TestTransactionRecordsAreOnlyVisibleToTheSubmitter(t *testing.T) {
+	w, key, _ := authServer(t, &bytes.Buffer{})
+	w.Transactions = web.NewTransactionTracker(web.DEFAULT_TRANSACTION_HISTORY)
+	record, _ := w.Transactions.Start("alice", "CreateFund", "testFundId")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(w.Authenticate)
+	router.GET("/transactions/:txid", w.GetTransactionByIdEndpoint)
+	get := func(subject string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/transactions/"+record.ID, nil)
+		request.Header.Set("Authorization", "Bearer "+signToken(t, key, subject, web.SCOPE_REPORTS_READ))
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	assert.Equal(t, http.StatusOK, get("alice").Code)
+	recorder := get("bob")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, pkgErrors.CODE_TRANSACTION_NOT_FOUND, errorCode(t, recorder))
+}
@@ -6,51 +6,55 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	uuid "github.com/satori/go.uuid"
 	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
 )
 
 func (w *EndpointWrapper) PostCapitalAccountEndpoint(c *gin.Context) {
 	var createCapitalAccountRequest types.CreateCapitalAccountRequest
 
-	err := c.BindJSON(&createCapitalAccountRequest)
+	err := c.ShouldBindJSON(&createCapitalAccountRequest)
 	if err != nil {
 		fmt.Printf("%v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing required parameters"})
+		respondWithError(c, missingParametersError)
 		return
 	}
 
-	validRequest := types.ValidateCreateCapitalAccountRequest(&createCapitalAccountRequest)
-	if !validRequest {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "improperly formatted request"})
-		return
-	}
-
-	capitalAccountId := uuid.NewV4().String()
-	hasPerformanceFees := fmt.Sprintf("%t", createCapitalAccountRequest.HasPerformanceFees)
-	result, err := w.Contract.SubmitTransaction("CreateCapitalAccount", capitalAccountId, createCapitalAccountRequest.Fund, createCapitalAccountRequest.Investor, hasPerformanceFees, createCapitalAccountRequest.PerformanceRate)
+	err = types.ValidateCreateCapitalAccountRequest(&createCapitalAccountRequest)
 	if err != nil {
-		errorString := fmt.Sprintf("error submitting request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"capitalAccountId": capitalAccountId})
+	hasPerformanceFees := fmt.Sprintf("%t", createCapitalAccountRequest.HasPerformanceFees)
+	w.submitCreate(c, idempotentCreate{
+		Kind:        "capitalAccount",
+		Transaction: "CreateCapitalAccount",
+		Args:        []string{createCapitalAccountRequest.Fund, createCapitalAccountRequest.Investor, hasPerformanceFees, createCapitalAccountRequest.PerformanceRate},
+		Query:       "QueryCapitalAccountById",
+		Matches: func(document []byte) bool {
+			var capitalAccount types.CapitalAccount
+			return decodeDocument(document, &capitalAccount) &&
+				capitalAccount.Fund == createCapitalAccountRequest.Fund &&
+				capitalAccount.Investor == createCapitalAccountRequest.Investor &&
+				capitalAccount.HasPerformanceFees == createCapitalAccountRequest.HasPerformanceFees &&
+				types.SameDecimal(capitalAccount.PerformanceFeeRate, createCapitalAccountRequest.PerformanceRate)
+		},
+		IdField: "capitalAccountId",
+	})
 }
 
 func (w *EndpointWrapper) GetCapitalAccountByIdEndpoint(c *gin.Context) {
 	capitalAccountId := c.Param("id")
-	result, err := w.Contract.EvaluateTransaction("QueryCapitalAccountById", capitalAccountId)
+	result, err := w.Evaluate(identity(c), "QueryCapitalAccountById", capitalAccountId)
 
 	if err != nil {
-		errorString := fmt.Sprintf("error evaluating request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
+		return
 	}
 
 	if len(result) == 0 {
-		c.JSON(http.StatusOK, "")
+		respondWithError(c, pkgErrors.CapitalAccountNotFoundError.WithDetail("capitalAccount", capitalAccountId))
 		return
 	}
 
@@ -58,7 +62,7 @@ func (w *EndpointWrapper) GetCapitalAccountByIdEndpoint(c *gin.Context) {
 	jsonErr := json.Unmarshal(result, &capitalAccount)
 
 	if jsonErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error unmarshaling json"})
+		respondWithError(c, unmarshalResponseError)
 		return
 	}
 	c.JSON(http.StatusOK, capitalAccount)
@@ -67,46 +71,52 @@ func (w *EndpointWrapper) GetCapitalAccountByIdEndpoint(c *gin.Context) {
 func (w *EndpointWrapper) PostCapitalAccountActionEndpoint(c *gin.Context) {
 	var createCapitalAccountActionRequest types.CreateCapitalAccountActionRequest
 
-	err := c.BindJSON(&createCapitalAccountActionRequest)
+	err := c.ShouldBindJSON(&createCapitalAccountActionRequest)
 	if err != nil {
 		fmt.Printf("%v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing required parameters"})
+		respondWithError(c, missingParametersError)
 		return
 	}
 
-	validRequest := types.ValidateCreateCapitalAccountActionRequest(&createCapitalAccountActionRequest)
-	if !validRequest {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "improperly formatted request"})
+	err = types.ValidateCreateCapitalAccountActionRequest(&createCapitalAccountActionRequest)
+	if err != nil {
+		respondWithError(c, err)
 		return
 	}
 
-	transactionId := uuid.NewV4().String()
 	full := fmt.Sprintf("%t", createCapitalAccountActionRequest.Full)
 	period := fmt.Sprintf("%d", createCapitalAccountActionRequest.Period)
 
-	result, err := w.Contract.SubmitTransaction("CreateCapitalAccountAction", transactionId, createCapitalAccountActionRequest.CapitalAccount, createCapitalAccountActionRequest.Type, createCapitalAccountActionRequest.Amount, full, createCapitalAccountActionRequest.Date, period)
-	if err != nil {
-		errorString := fmt.Sprintf("error submitting request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"transactionId": transactionId})
+	w.submitCreate(c, idempotentCreate{
+		Kind:        "capitalAccountAction",
+		Transaction: "CreateCapitalAccountAction",
+		Args:        []string{createCapitalAccountActionRequest.CapitalAccount, createCapitalAccountActionRequest.Type, createCapitalAccountActionRequest.Amount, full, createCapitalAccountActionRequest.Date, period},
+		Query:       "QueryCapitalAccountActionById",
+		Matches: func(document []byte) bool {
+			var action types.CapitalAccountAction
+			return decodeDocument(document, &action) &&
+				action.CapitalAccount == createCapitalAccountActionRequest.CapitalAccount &&
+				action.Type == createCapitalAccountActionRequest.Type &&
+				types.SameDecimal(action.Amount, createCapitalAccountActionRequest.Amount) &&
+				action.Full == createCapitalAccountActionRequest.Full &&
+				action.Date == createCapitalAccountActionRequest.Date &&
+				action.Period == createCapitalAccountActionRequest.Period
+		},
+		IdField: "transactionId",
+	})
 }
 
 func (w *EndpointWrapper) GetCapitalAccountActionByIdEndpoint(c *gin.Context) {
 	transactionId := c.Param("id")
-	result, err := w.Contract.EvaluateTransaction("QueryCapitalAccountActionById", transactionId)
+	result, err := w.Evaluate(identity(c), "QueryCapitalAccountActionById", transactionId)
 
 	if err != nil {
-		errorString := fmt.Sprintf("error evaluating request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
+		return
 	}
 
 	if len(result) == 0 {
-		c.JSON(http.StatusOK, "")
+		respondWithError(c, pkgErrors.CapitalAccountActionNotFoundError.WithDetail("capitalAccountAction", transactionId))
 		return
 	}
 
@@ -114,8 +124,58 @@ func (w *EndpointWrapper) GetCapitalAccountActionByIdEndpoint(c *gin.Context) {
 	jsonErr := json.Unmarshal(result, &capitalAccountAction)
 
 	if jsonErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error unmarshaling json"})
+		respondWithError(c, unmarshalResponseError)
 		return
 	}
 	c.JSON(http.StatusOK, capitalAccountAction)
 }
+
+func (w *EndpointWrapper) GetCapitalAccountsEndpoint(c *gin.Context) {
+	fundId := c.Query("fund")
+	if fundId == "" {
+		respondWithInvalidRequest(c, "missing required parameter fund")
+		return
+	}
+	pageSize, bookmark, err := parsePagination(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	result, err := w.Evaluate(identity(c), "QueryCapitalAccountsByFundWithPagination", fundId, pageSize, bookmark)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var page types.CapitalAccountPage
+	jsonErr := json.Unmarshal(result, &page)
+	if jsonErr != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (w *EndpointWrapper) GetCapitalAccountActionsEndpoint(c *gin.Context) {
+	capitalAccountId := c.Query("capitalAccount")
+	if capitalAccountId == "" {
+		respondWithInvalidRequest(c, "missing required parameter capitalAccount")
+		return
+	}
+	pageSize, bookmark, err := parsePagination(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	result, err := w.Evaluate(identity(c), "QueryCapitalAccountActionsByAccountWithPagination", capitalAccountId, pageSize, bookmark)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var page types.CapitalAccountActionPage
+	jsonErr := json.Unmarshal(result, &page)
+	if jsonErr != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,86 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+var missingParametersError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "the request body is not valid JSON for this request")
+var unmarshalResponseError = pkgErrors.New(pkgErrors.CODE_INTERNAL, "error unmarshaling json")
+
+// HTTP statuses of the error codes: 400 for malformed requests, 401 and 403 for
+// callers without credentials or a Fabric identity, 404 for missing documents, 409
+// for operations the current state of a document does not allow and 422 for
+// requests the fund accounting rejects. Anything else is a 500.
+var errorStatuses = map[string]int{
+	pkgErrors.CODE_INVALID_REQUEST:                     http.StatusBadRequest,
+	pkgErrors.CODE_VALIDATION_FAILED:                   http.StatusBadRequest,
+	pkgErrors.CODE_INVALID_PORTFOLIO_ACTION_TYPE:       http.StatusBadRequest,
+	pkgErrors.CODE_INVALID_CAPITAL_ACCOUNT_ACTION_TYPE: http.StatusBadRequest,
+	pkgErrors.CODE_INVALID_DOC_TYPE:                    http.StatusBadRequest,
+	pkgErrors.CODE_INVALID_ROUNDING_POLICY:             http.StatusBadRequest,
+	pkgErrors.CODE_DECIMAL_CONVERSION:                  http.StatusBadRequest,
+	pkgErrors.CODE_UNAUTHENTICATED:                     http.StatusUnauthorized,
+	pkgErrors.CODE_NO_FABRIC_IDENTITY:                  http.StatusForbidden,
+	pkgErrors.CODE_MISSING_SCOPE:                       http.StatusForbidden,
+	pkgErrors.CODE_FUND_NOT_FOUND:                      http.StatusNotFound,
+	pkgErrors.CODE_PORTFOLIO_NOT_FOUND:                 http.StatusNotFound,
+	pkgErrors.CODE_INVESTOR_NOT_FOUND:                  http.StatusNotFound,
+	pkgErrors.CODE_CAPITAL_ACCOUNT_NOT_FOUND:           http.StatusNotFound,
+	pkgErrors.CODE_CAPITAL_ACCOUNT_ACTION_NOT_FOUND:    http.StatusNotFound,
+	pkgErrors.CODE_PORTFOLIO_ACTION_NOT_FOUND:          http.StatusNotFound,
+	pkgErrors.CODE_RISKLESS_RATE_NOT_FOUND:             http.StatusNotFound,
+	pkgErrors.CODE_SECURITY_NOT_FOUND:                  http.StatusNotFound,
+	pkgErrors.CODE_VALUATION_DATE_NOT_FOUND:            http.StatusNotFound,
+	pkgErrors.CODE_ASSET_NOT_FOUND:                     http.StatusNotFound,
+	pkgErrors.CODE_TRANSACTION_NOT_FOUND:               http.StatusNotFound,
+	pkgErrors.CODE_ID_ALREADY_IN_USE:                   http.StatusConflict,
+	pkgErrors.CODE_CANNOT_BOOTSTRAP_CAPITAL_ACCOUNT:    http.StatusConflict,
+	pkgErrors.CODE_CANNOT_BOOTSTRAP_FUND:               http.StatusConflict,
+	pkgErrors.CODE_CANNOT_STEP_FUND:                    http.StatusConflict,
+	pkgErrors.CODE_CANNOT_IMPORT_CAPITAL_ACCOUNTS:      http.StatusConflict,
+	pkgErrors.CODE_CANNOT_CHANGE_ROUNDING_POLICY:       http.StatusConflict,
+	pkgErrors.CODE_IDEMPOTENCY_KEY_REUSED:              http.StatusConflict,
+	pkgErrors.CODE_NEGATIVE_BALANCE:                    http.StatusUnprocessableEntity,
+	pkgErrors.CODE_NO_PORTFOLIOS:                       http.StatusUnprocessableEntity,
+	pkgErrors.CODE_NO_MOST_RECENT_DATE:                 http.StatusUnprocessableEntity,
+	pkgErrors.CODE_NO_VALUATIONS_FOR_DATE:              http.StatusUnprocessableEntity,
+	pkgErrors.CODE_NO_CAPITAL_ACCOUNTS:                 http.StatusUnprocessableEntity,
+	pkgErrors.CODE_GENERAL_PARTNER_NOT_FOUND:           http.StatusUnprocessableEntity,
+	pkgErrors.CODE_MID_YEAR_DEPOSIT:                    http.StatusUnprocessableEntity,
+	pkgErrors.CODE_ZERO_FUND_OPENING_VALUE:             http.StatusUnprocessableEntity,
+	pkgErrors.CODE_NEGATIVE_SECURITY_AMOUNT:            http.StatusUnprocessableEntity,
+	pkgErrors.CODE_EMPTY_PORTFOLIO:                     http.StatusUnprocessableEntity,
+}
+
+// Converts any error into a coded one. Errors that carry no code, e.g. a gateway
+// that cannot reach the peers, become INTERNAL errors with the original message.
+func toCodedError(err error) *pkgErrors.Error {
+	coded, ok := pkgErrors.From(err)
+	if !ok {
+		return pkgErrors.New(pkgErrors.CODE_INTERNAL, err.Error())
+	}
+	return coded
+}
+
+func errorStatus(coded *pkgErrors.Error) int {
+	status, ok := errorStatuses[coded.Code]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// Writes err as {"error": {"code": ..., "message": ..., "details": ...}} with the
+// status of its code
+func respondWithError(c *gin.Context, err error) {
+	coded := toCodedError(err)
+	c.Set(ERROR_CODE_CONTEXT_KEY, coded.Code)
+	c.JSON(errorStatus(coded), gin.H{"error": coded})
+}
+
+func respondWithInvalidRequest(c *gin.Context, message string) {
+	respondWithError(c, pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, message))
+}
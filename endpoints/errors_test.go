@@ -0,0 +1,69 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func respond(err error) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	respondWithError(c, err)
+	return recorder
+}
+
+func TestRespondWithErrorParsesGatewayMessage(t *testing.T) {
+	chaincodeErr := pkgErrors.NegativeCapitalAccountBalanceError.WithDetail("capitalAccount", "testAccountId")
+	gatewayErr := fmt.Errorf(
+		"Failed to submit: Multiple errors occurred: - Transaction processing for endorser [localhost:7051]: Chaincode status Code: (500) UNKNOWN. Description: %s",
+		chaincodeErr,
+	)
+	recorder := respond(gatewayErr)
+	assert.Equal(t, recorder.Code, http.StatusUnprocessableEntity)
+	var body struct {
+		Error pkgErrors.Error `json:"error"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	assert.Nil(t, err)
+	assert.Equal(t, body.Error.Code, pkgErrors.CODE_NEGATIVE_BALANCE)
+	assert.Equal(t, body.Error.Message, pkgErrors.NegativeCapitalAccountBalanceError.Message)
+	assert.Equal(t, body.Error.Details, map[string]string{"capitalAccount": "testAccountId"})
+}
+
+func TestRespondWithErrorStatuses(t *testing.T) {
+	for _, test := range []struct {
+		err    error
+		status int
+	}{
+		{invalidPageSizeError, http.StatusBadRequest},
+		{pkgErrors.FundNotFoundError.WithDetail("fund", "testFundId"), http.StatusNotFound},
+		{pkgErrors.CannotStepFundError, http.StatusConflict},
+		{pkgErrors.MidYearDepositError, http.StatusUnprocessableEntity},
+		{pkgErrors.WealthConservationFunctionError, http.StatusInternalServerError},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	} {
+		assert.Equal(t, respond(test.err).Code, test.status, "%v", test.err)
+	}
+}
+
+func TestErrorsMatchOnCode(t *testing.T) {
+	detailed := pkgErrors.FundNotFoundError.WithDetail("fund", "testFundId")
+	assert.True(t, errors.Is(detailed, pkgErrors.FundNotFoundError))
+	assert.False(t, errors.Is(detailed, pkgErrors.InvestorNotFoundError))
+	assert.Nil(t, pkgErrors.FundNotFoundError.Details)
+	parsed, ok := pkgErrors.Parse("peer said: " + detailed.Error() + " (retrying)")
+	assert.True(t, ok)
+	assert.True(t, errors.Is(parsed, pkgErrors.FundNotFoundError))
+	assert.Equal(t, parsed.Details["fund"], "testFundId")
+	_, ok = pkgErrors.Parse("chaincode panicked")
+	assert.False(t, ok)
+}
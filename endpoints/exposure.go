@@ -0,0 +1,116 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+var invalidTopError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "top must be an integer")
+
+func (a *EndpointWrapper) PutSecurityEndpoint(c *gin.Context) {
+	cusip := c.Param("cusip")
+	var setSecurityRequest types.SetSecurityRequest
+
+	err := c.ShouldBindJSON(&setSecurityRequest)
+	if err != nil {
+		respondWithError(c, missingParametersError)
+		return
+	}
+	err = types.ValidateSetSecurityRequest(cusip, &setSecurityRequest)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	_, err = a.Submit(identity(c), "SetSecurity", cusip, setSecurityRequest.Name, setSecurityRequest.AssetClass,
+		setSecurityRequest.Sector, setSecurityRequest.Country, setSecurityRequest.Issuer)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, types.CreateSecurity(cusip, &setSecurityRequest))
+}
+
+func (a *EndpointWrapper) GetSecurityByIdEndpoint(c *gin.Context) {
+	cusip := c.Param("cusip")
+	result, err := a.Evaluate(identity(c), "QuerySecurityById", cusip)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	if len(result) == 0 {
+		respondWithError(c, pkgErrors.SecurityNotFoundError.WithDetail("cusip", cusip))
+		return
+	}
+	var security types.Security
+	err = json.Unmarshal(result, &security)
+	if err != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, security)
+}
+
+func (a *EndpointWrapper) PutFundConcentrationLimitsEndpoint(c *gin.Context) {
+	fundId := c.Param("id")
+	var setLimitsRequest types.SetConcentrationLimitsRequest
+
+	err := c.ShouldBindJSON(&setLimitsRequest)
+	if err != nil {
+		respondWithError(c, missingParametersError)
+		return
+	}
+	limits := setLimitsRequest.Limits
+	if limits == nil {
+		limits = types.ConcentrationLimits{}
+	}
+	err = limits.Validate()
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	limitsJSON, err := json.Marshal(limits)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	_, err = a.Submit(identity(c), "SetFundConcentrationLimits", fundId, string(limitsJSON))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, types.ConcentrationLimitsSet{FundId: fundId, Limits: limits})
+}
+
+// Answers with the exposure on the date query parameter, the latest valuations
+// when it is missing, ranking the number of positions the top query parameter asks
+// for
+func (a *EndpointWrapper) GetFundExposureEndpoint(c *gin.Context) {
+	top := "0"
+	if raw := c.Query("top"); raw != "" {
+		_, err := strconv.Atoi(raw)
+		if err != nil {
+			respondWithError(c, invalidTopError)
+			return
+		}
+		top = raw
+	}
+	result, err := a.Evaluate(identity(c), "QueryExposure", c.Param("id"), c.Query("date"), top)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var report types.ExposureReport
+	err = json.Unmarshal(result, &report)
+	if err != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,44 @@
+package endpoints
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+var invalidPeriodError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "period must be an integer")
+var invalidDateError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "startDate and endDate must use the MM-DD-YYYY format")
+
+// Reads the period, type, status, startDate and endDate query parameters into the
+// JSON filter accepted by the chaincode action queries
+func parseActionFilter(c *gin.Context) (string, error) {
+	var filter types.ActionFilter
+	rawPeriod := c.Query("period")
+	if rawPeriod != "" {
+		period, err := strconv.Atoi(rawPeriod)
+		if err != nil {
+			return "", invalidPeriodError
+		}
+		filter.Period = &period
+	}
+	filter.Type = c.Query("type")
+	filter.Status = c.Query("status")
+	filter.StartDate = c.Query("startDate")
+	filter.EndDate = c.Query("endDate")
+	for _, date := range []string{filter.StartDate, filter.EndDate} {
+		if date == "" {
+			continue
+		}
+		_, err := types.ParseDate(date)
+		if err != nil {
+			return "", invalidDateError
+		}
+	}
+	filterJSON, err := filter.ToJSON()
+	if err != nil {
+		return "", err
+	}
+	return string(filterJSON), nil
+}
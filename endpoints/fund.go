@@ -4,90 +4,199 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	uuid "github.com/satori/go.uuid"
 	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
 )
 
 func (a *EndpointWrapper) PostFundEndpoint(c *gin.Context) {
 	var createFundRequest types.CreateFundRequest
 
-	err := c.BindJSON(&createFundRequest)
+	err := c.ShouldBindJSON(&createFundRequest)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing required parameter"})
+		respondWithError(c, missingParametersError)
 		return
 	}
 
-	validRequest := types.ValidateCreateFundRequest(&createFundRequest)
-	if !validRequest {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "improperly formatted inceptionDate"})
+	err = types.ValidateCreateFundRequest(&createFundRequest)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	a.submitCreate(c, idempotentCreate{
+		Kind:        "fund",
+		Transaction: "CreateFund",
+		Args:        []string{createFundRequest.Name, createFundRequest.InceptionDate},
+		Query:       "QueryFundById",
+		Matches: func(document []byte) bool {
+			var fund types.Fund
+			return decodeDocument(document, &fund) &&
+				fund.Name == createFundRequest.Name &&
+				fund.InceptionDate == createFundRequest.InceptionDate
+		},
+		IdField: "fundId",
+	})
+}
+
+func (a *EndpointWrapper) PutFundRoundingPolicyEndpoint(c *gin.Context) {
+	fundId := c.Param("id")
+	var setRoundingPolicyRequest types.SetRoundingPolicyRequest
+
+	err := c.ShouldBindJSON(&setRoundingPolicyRequest)
+	if err != nil {
+		respondWithError(c, missingParametersError)
+		return
 	}
 
-	fundId := uuid.NewV4().String()
+	policy := types.RoundingPolicy{
+		CurrencyPrecision:  *setRoundingPolicyRequest.CurrencyPrecision,
+		OwnershipPrecision: *setRoundingPolicyRequest.OwnershipPrecision,
+		RoundingMode:       setRoundingPolicyRequest.RoundingMode,
+	}
+	err = policy.Validate()
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
 
-	result, err := a.Contract.SubmitTransaction("CreateFund", fundId, createFundRequest.Name, createFundRequest.InceptionDate)
+	_, err = a.Submit(
+		identity(c),
+		"SetFundRoundingPolicy",
+		fundId,
+		strconv.Itoa(int(policy.CurrencyPrecision)),
+		strconv.Itoa(int(policy.OwnershipPrecision)),
+		policy.RoundingMode,
+	)
 	if err != nil {
-		errorString := fmt.Sprintf("error submitting request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"fundId": fundId})
+	c.JSON(http.StatusOK, types.RoundingPolicySet{FundId: fundId, RoundingPolicy: policy})
 }
 
 func (a *EndpointWrapper) GetFundByIdEndpoint(c *gin.Context) {
 	fundId := c.Param("id")
-	result, err := a.Contract.EvaluateTransaction("QueryFundById", fundId)
+	result, err := a.Evaluate(identity(c), "QueryFundById", fundId)
 	if err != nil {
-		errorString := fmt.Sprintf("error evaluating request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
 		return
 	}
 	if len(result) == 0 {
-		c.JSON(http.StatusOK, "")
+		respondWithError(c, pkgErrors.FundNotFoundError.WithDetail("fund", fundId))
 		return
 	}
 	var fund types.Fund
 	jsonErr := json.Unmarshal(result, &fund)
 	if jsonErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error unmarshaling json"})
+		respondWithError(c, unmarshalResponseError)
 		fmt.Println(jsonErr)
 		return
 	}
 	c.JSON(http.StatusOK, fund)
 }
 
-func (a *EndpointWrapper) GetFundActionEndpoint(c *gin.Context) {
-	fundId := c.Param("id")
-	action := c.Param("action")
-	switch action {
-	case "/investors":
-		fmt.Println("investors")
-	case "/capitalaccounts":
-		fmt.Println("capitalaccounts")
-	case "/portfolios":
-		fmt.Println("portfolios")
-	case "/capitalaccountactions":
-		fmt.Println("capitalaccountactions")
-	case "/portfolioactions":
-		fmt.Println("portfolioactions")
-	case "/bootstrap":
-		result, err := a.Contract.SubmitTransaction("BootstrapFund", fundId)
-		if err != nil {
-			fmt.Println(result)
-			errorString := fmt.Sprintf("error evaluating request: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-			return
-		}
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid action supplied"})
+func (a *EndpointWrapper) GetFundsEndpoint(c *gin.Context) {
+	pageSize, bookmark, err := parsePagination(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	result, err := a.Evaluate(identity(c), "QueryFunds", pageSize, bookmark)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var page types.FundPage
+	jsonErr := json.Unmarshal(result, &page)
+	if jsonErr != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (a *EndpointWrapper) GetFundInvestorsEndpoint(c *gin.Context) {
+	a.listFundResource(c, "QueryInvestorsByFundWithPagination", c.Param("id"), false, &types.InvestorPage{})
+}
+
+func (a *EndpointWrapper) GetFundCapitalAccountsEndpoint(c *gin.Context) {
+	a.listFundResource(c, "QueryCapitalAccountsByFundWithPagination", c.Param("id"), false, &types.CapitalAccountPage{})
+}
+
+func (a *EndpointWrapper) GetFundPortfoliosEndpoint(c *gin.Context) {
+	a.listFundResource(c, "QueryPortfoliosByFundWithPagination", c.Param("id"), false, &types.PortfolioPage{})
+}
+
+func (a *EndpointWrapper) GetFundCapitalAccountActionsEndpoint(c *gin.Context) {
+	a.listFundResource(c, "QueryCapitalAccountActionsByFundWithPagination", c.Param("id"), true, &types.CapitalAccountActionPage{})
+}
+
+func (a *EndpointWrapper) GetFundPortfolioActionsEndpoint(c *gin.Context) {
+	a.listFundResource(c, "QueryPortfolioActionsByFundWithPagination", c.Param("id"), true, &types.PortfolioActionPage{})
+}
+
+func (a *EndpointWrapper) GetFundBootstrapEndpoint(c *gin.Context) {
+	_, err := a.Submit(identity(c), "BootstrapFund", c.Param("id"))
+	if err != nil {
+		respondWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, "success")
 }
 
-func (w *EndpointWrapper) bootstrapFund(fundId string) error {
-	return nil
+// Closes the current period of the fund and opens the next one
+func (a *EndpointWrapper) PostFundStepEndpoint(c *gin.Context) {
+	result, err := a.Submit(identity(c), "StepFund", c.Param("id"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var stepped types.FundAndCapitalAccounts
+	err = json.Unmarshal(result, &stepped)
+	if err != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, stepped)
+}
+
+// Evaluates a paginated fund listing and writes the decoded page to the response.
+// Action listings additionally accept the filters parsed by parseActionFilter.
+func (a *EndpointWrapper) listFundResource(
+	c *gin.Context,
+	queryName string,
+	fundId string,
+	withActionFilter bool,
+	page interface{},
+) {
+	pageSize, bookmark, err := parsePagination(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	args := []string{fundId}
+	if withActionFilter {
+		filterJSON, err := parseActionFilter(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		args = append(args, filterJSON)
+	}
+	args = append(args, pageSize, bookmark)
+	result, err := a.Evaluate(identity(c), queryName, args...)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	jsonErr := json.Unmarshal(result, page)
+	if jsonErr != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
@@ -0,0 +1,130 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const IDEMPOTENCY_KEY_HEADER string = "Idempotency-Key"
+const IDEMPOTENT_REPLAYED_HEADER string = "Idempotent-Replayed"
+const MAX_IDEMPOTENCY_KEY_LENGTH int = 255
+
+// Namespace of the name based UUIDs derived from idempotency keys
+var idempotencyNamespace = uuid.NewV5(uuid.NamespaceURL, "https://github.com/zacharyfrederick/admin/idempotency")
+
+var invalidIdempotencyKeyError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "the Idempotency-Key header must be 1 to 255 printable characters")
+var idempotencyKeyReusedError = pkgErrors.New(pkgErrors.CODE_IDEMPOTENCY_KEY_REUSED, "the Idempotency-Key was already used for a different request")
+
+// A create transaction whose ledger id is derived from the Idempotency-Key header
+// of the request when it has one. Retrying the request then resolves to the same
+// id, so the retry finds the document the first attempt created instead of
+// creating another one.
+type idempotentCreate struct {
+	// Scopes the key, so the same key sent to two endpoints creates two documents
+	Kind        string
+	Transaction string
+	// The arguments of the transaction after the id
+	Args []string
+	// Reads the document with the id for a replayed request
+	Query string
+	// Reports whether the existing document was created by the same request. It
+	// receives the raw JSON of the document.
+	Matches func(document []byte) bool
+	// The response field holding the id, e.g. fundId
+	IdField string
+}
+
+// Returns the ledger id for a new document of the kind: a UUIDv5 of the kind, the
+// caller's subject and the Idempotency-Key when the request has one, and a random
+// UUID otherwise. The subject is quoted so it cannot run into the key, and two
+// callers sending the same key get different ids.
+func ledgerId(c *gin.Context, kind string) (string, bool, error) {
+	key := c.GetHeader(IDEMPOTENCY_KEY_HEADER)
+	if key == "" {
+		return uuid.NewV4().String(), false, nil
+	}
+	if len(key) > MAX_IDEMPOTENCY_KEY_LENGTH || strings.TrimSpace(key) != key {
+		return "", false, invalidIdempotencyKeyError
+	}
+	for _, r := range key {
+		if r < ' ' || r > '~' {
+			return "", false, invalidIdempotencyKeyError
+		}
+	}
+	return uuid.NewV5(idempotencyNamespace, kind+":"+strconv.Quote(subject(c))+":"+key).String(), true, nil
+}
+
+// Submits the create transaction and responds with the id of the document, or
+// with the pending transaction when the request is asynchronous. When a keyed
+// request is replayed the existing document is checked against the request and
+// its id is returned with the Idempotent-Replayed header set.
+func (w *EndpointWrapper) submitCreate(c *gin.Context, create idempotentCreate) {
+	id, keyed, err := ledgerId(c, create.Kind)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	async, err := asyncRequested(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	args := append([]string{id}, create.Args...)
+	if async {
+		if keyed {
+			document, err := w.Evaluate(identity(c), create.Query, id)
+			if err != nil {
+				respondWithError(c, err)
+				return
+			}
+			if len(document) != 0 {
+				w.respondWithReplay(c, create, id, document)
+				return
+			}
+		}
+		record, err := w.SubmitAsync(identity(c), subject(c), id, create.Transaction, args...)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		respondAccepted(c, record, gin.H{create.IdField: id})
+		return
+	}
+	_, err = w.Submit(identity(c), create.Transaction, args...)
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{create.IdField: id})
+		return
+	}
+	if !keyed || !errors.Is(toCodedError(err), pkgErrors.IdAlreadyInUseError) {
+		respondWithError(c, err)
+		return
+	}
+	document, err := w.Evaluate(identity(c), create.Query, id)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	w.respondWithReplay(c, create, id, document)
+}
+
+func (w *EndpointWrapper) respondWithReplay(c *gin.Context, create idempotentCreate, id string, document []byte) {
+	if len(document) == 0 || !create.Matches(document) {
+		respondWithError(c, idempotencyKeyReusedError.WithDetail("id", id))
+		return
+	}
+	c.Header(IDEMPOTENT_REPLAYED_HEADER, "true")
+	c.JSON(http.StatusOK, gin.H{create.IdField: id})
+}
+
+// Decodes the document for a Matches function, treating a document that cannot be
+// decoded as a mismatch
+func decodeDocument(document []byte, v interface{}) bool {
+	return json.Unmarshal(document, v) == nil
+}
@@ -0,0 +1,81 @@
+package endpoints
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/types"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+func keyedContext(key string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/capitalaccountactions", nil)
+	if key != "" {
+		c.Request.Header.Set(IDEMPOTENCY_KEY_HEADER, key)
+	}
+	return c
+}
+
+func TestLedgerIdIsDerivedFromIdempotencyKey(t *testing.T) {
+	first, keyed, err := ledgerId(keyedContext("subscription-2020-01"), "capitalAccountAction")
+	assert.Nil(t, err)
+	assert.True(t, keyed)
+	retry, _, err := ledgerId(keyedContext("subscription-2020-01"), "capitalAccountAction")
+	assert.Nil(t, err)
+	assert.Equal(t, first, retry)
+
+	other, _, err := ledgerId(keyedContext("subscription-2020-02"), "capitalAccountAction")
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, other)
+
+	otherKind, _, err := ledgerId(keyedContext("subscription-2020-01"), "fund")
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, otherKind)
+}
+
+func TestLedgerIdIsScopedToTheCaller(t *testing.T) {
+	ids := map[string]string{}
+	for _, name := range []string{"alice", "bob"} {
+		c := keyedContext("subscription-2020-01")
+		c.Set(PRINCIPAL_CONTEXT_KEY, &web.Principal{Subject: name})
+		id, keyed, err := ledgerId(c, "capitalAccountAction")
+		assert.Nil(t, err)
+		assert.True(t, keyed)
+		ids[name] = id
+	}
+	assert.NotEqual(t, ids["alice"], ids["bob"])
+
+	c := keyedContext("subscription-2020-01")
+	c.Set(PRINCIPAL_CONTEXT_KEY, &web.Principal{Subject: "alice"})
+	retry, _, err := ledgerId(c, "capitalAccountAction")
+	assert.Nil(t, err)
+	assert.Equal(t, ids["alice"], retry)
+}
+
+func TestLedgerIdWithoutIdempotencyKeyIsRandom(t *testing.T) {
+	first, keyed, err := ledgerId(keyedContext(""), "fund")
+	assert.Nil(t, err)
+	assert.False(t, keyed)
+	second, _, err := ledgerId(keyedContext(""), "fund")
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestLedgerIdRejectsInvalidIdempotencyKeys(t *testing.T) {
+	for _, key := range []string{" padded", "tab\tkey", strings.Repeat("k", MAX_IDEMPOTENCY_KEY_LENGTH+1), "clé"} {
+		_, _, err := ledgerId(keyedContext(key), "fund")
+		assert.ErrorIs(t, err, invalidIdempotencyKeyError, key)
+	}
+}
+
+func TestSameDecimal(t *testing.T) {
+	assert.True(t, types.SameDecimal("100", "100.00"))
+	assert.False(t, types.SameDecimal("100", "100.01"))
+	assert.False(t, types.SameDecimal("100", "abc"))
+	assert.False(t, types.SameDecimal("abc", "abc"))
+}
@@ -0,0 +1,66 @@
+package endpoints
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zacharyfrederick/admin/importer"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Largest sheet accepted, far more than a fund's investors need
+const MAX_IMPORT_FILE_SIZE int64 = 10 << 20
+
+var invalidBatchSizeError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "batchSize must be a positive integer")
+var importFileTooLargeError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "the sheet is larger than 10MB")
+var unreadableSheetError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "the body is not a CSV file or an Excel workbook")
+
+// Takes the sheet as the request body. Nothing is submitted unless every row is
+// valid, and a failed import is resumed by sending the same sheet again.
+func (w *EndpointWrapper) PostFundImportEndpoint(c *gin.Context) {
+	fundId := c.Param("id")
+	batchSize := types.DEFAULT_IMPORT_BATCH_SIZE
+	if raw := c.Query("batchSize"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			respondWithError(c, invalidBatchSizeError)
+			return
+		}
+		batchSize = parsed
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, MAX_IMPORT_FILE_SIZE+1))
+	if err != nil {
+		respondWithError(c, unreadableSheetError.WithDetail("reason", err.Error()))
+		return
+	}
+	if int64(len(data)) > MAX_IMPORT_FILE_SIZE {
+		respondWithError(c, importFileTooLargeError)
+		return
+	}
+	records, err := importer.ReadSheet(bytes.NewReader(data))
+	if err != nil {
+		respondWithError(c, unreadableSheetError.WithDetail("reason", err.Error()))
+		return
+	}
+	rows, err := importer.ParseRows(fundId, records)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	caller := identity(c)
+	report, err := importer.Import(fundId, rows, batchSize, importer.ChaincodeSubmitter(func(name string, args ...string) ([]byte, error) {
+		return w.Submit(caller, name, args...)
+	}))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -2,55 +2,52 @@ package endpoints
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	uuid "github.com/satori/go.uuid"
 	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
 )
 
 func (w *EndpointWrapper) PostInvestorEndpoint(c *gin.Context) {
 	var createInvestorRequest types.CreateInvestorRequest
 
-	err := c.BindJSON(&createInvestorRequest)
+	err := c.ShouldBindJSON(&createInvestorRequest)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing required parameters"})
+		respondWithError(c, missingParametersError)
 		return
 	}
 
-	validRequest := types.ValidateCreateInvestorRequest(&createInvestorRequest)
-	if !validRequest {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "improperly formatted request"})
-		return
-	}
-
-	investorId := uuid.NewV4().String()
-
-	result, err := w.Contract.SubmitTransaction("CreateInvestor", investorId, createInvestorRequest.Name)
+	err = types.ValidateCreateInvestorRequest(&createInvestorRequest)
 	if err != nil {
-		errorString := fmt.Sprintf("error submitting request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"investorId": investorId})
-
+	w.submitCreate(c, idempotentCreate{
+		Kind:        "investor",
+		Transaction: "CreateInvestor",
+		Args:        []string{createInvestorRequest.Name},
+		Query:       "QueryInvestorById",
+		Matches: func(document []byte) bool {
+			var investor types.Investor
+			return decodeDocument(document, &investor) && investor.Name == createInvestorRequest.Name
+		},
+		IdField: "investorId",
+	})
 }
 
 func (w *EndpointWrapper) GetInvestorByIdEndpoint(c *gin.Context) {
 	investorId := c.Param("id")
-	result, err := w.Contract.EvaluateTransaction("QueryInvestorById", investorId)
+	result, err := w.Evaluate(identity(c), "QueryInvestorById", investorId)
 
 	if err != nil {
-		errorString := fmt.Sprintf("error evaluating request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
+		return
 	}
 
 	if len(result) == 0 {
-		c.JSON(http.StatusOK, "")
+		respondWithError(c, pkgErrors.InvestorNotFoundError.WithDetail("investor", investorId))
 		return
 	}
 
@@ -58,8 +55,28 @@ func (w *EndpointWrapper) GetInvestorByIdEndpoint(c *gin.Context) {
 	jsonErr := json.Unmarshal(result, &investor)
 
 	if jsonErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error unmarshaling json"})
+		respondWithError(c, unmarshalResponseError)
 		return
 	}
 	c.JSON(http.StatusOK, investor)
 }
+
+func (w *EndpointWrapper) GetInvestorsEndpoint(c *gin.Context) {
+	pageSize, bookmark, err := parsePagination(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	result, err := w.Evaluate(identity(c), "QueryInvestors", pageSize, bookmark)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var page types.InvestorPage
+	jsonErr := json.Unmarshal(result, &page)
+	if jsonErr != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,105 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zacharyfrederick/admin/journal"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+var invalidJournalFormatError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "format must be json, csv or iif")
+
+func (a *EndpointWrapper) PutFundChartOfAccountsEndpoint(c *gin.Context) {
+	fundId := c.Param("id")
+	var setChartRequest types.SetChartOfAccountsRequest
+
+	err := c.ShouldBindJSON(&setChartRequest)
+	if err != nil {
+		respondWithError(c, missingParametersError)
+		return
+	}
+	chart := setChartRequest.ChartOfAccounts
+	err = chart.Validate()
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	chartJSON, err := json.Marshal(chart)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	_, err = a.Submit(identity(c), "SetFundChartOfAccounts", fundId, string(chartJSON))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, types.ChartOfAccountsSet{FundId: fundId, ChartOfAccounts: chart})
+}
+
+// Answers with the journal as JSON, or as a file to import into a general ledger
+// with ?format=csv or ?format=iif
+func (a *EndpointWrapper) GetFundJournalEndpoint(c *gin.Context) {
+	format := c.DefaultQuery("format", journal.FORMAT_JSON)
+	mediaType, err := journal.MediaType(format)
+	if err != nil && format != journal.FORMAT_JSON {
+		respondWithError(c, invalidJournalFormatError)
+		return
+	}
+	var fundJournal types.Journal
+	if !a.evaluatePeriodReport(c, "QueryJournal", &fundJournal) {
+		return
+	}
+	if format == journal.FORMAT_JSON {
+		c.JSON(http.StatusOK, fundJournal)
+		return
+	}
+	var buffer bytes.Buffer
+	err = journal.Write(&buffer, format, &fundJournal)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	filename := fundJournal.Fund + "-journal-" + strconv.Itoa(fundJournal.Period) + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, mediaType, buffer.Bytes())
+}
+
+func (a *EndpointWrapper) GetFundTrialBalanceEndpoint(c *gin.Context) {
+	var trialBalance types.TrialBalance
+	if a.evaluatePeriodReport(c, "QueryTrialBalance", &trialBalance) {
+		c.JSON(http.StatusOK, trialBalance)
+	}
+}
+
+// Evaluates a report of the fund for the period query parameter, the last closed
+// period when it is missing, and decodes it. Any args follow the period. Returns
+// false once it has responded with an error.
+func (a *EndpointWrapper) evaluatePeriodReport(c *gin.Context, name string, report interface{}, args ...string) bool {
+	period := "-1"
+	if raw := c.Query("period"); raw != "" {
+		_, err := strconv.Atoi(raw)
+		if err != nil {
+			respondWithError(c, invalidPeriodError)
+			return false
+		}
+		period = raw
+	}
+	result, err := a.Evaluate(identity(c), name, append([]string{c.Param("id"), period}, args...)...)
+	if err != nil {
+		respondWithError(c, err)
+		return false
+	}
+	err = json.Unmarshal(result, report)
+	if err != nil {
+		respondWithError(c, unmarshalResponseError)
+		return false
+	}
+	return true
+}
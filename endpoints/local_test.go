@@ -0,0 +1,234 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/client"
+	"github.com/zacharyfrederick/admin/importer"
+	"github.com/zacharyfrederick/admin/local"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+// The whole api served from the chaincode running in-process
+func localServer(t *testing.T) *client.Client {
+	backend, err := local.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &EndpointWrapper{AdminServer: &web.AdminServer{
+		Contract:     backend,
+		AuditLog:     web.NewAuditLog(&bytes.Buffer{}),
+		Transactions: web.NewTransactionTracker(web.DEFAULT_TRANSACTION_HISTORY),
+		Retry:        web.DEFAULT_RETRY_POLICY,
+	}}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(w.Audit)
+	w.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return client.New(server.URL)
+}
+
+func TestLocalBackendServesTheAPI(t *testing.T) {
+	c := localServer(t)
+	ctx := context.Background()
+
+	fundId, err := c.CreateFund(ctx, types.CreateFundRequest{Name: "Test Fund", InceptionDate: "01-01-2020"})
+	assert.Nil(t, err)
+	investorId, err := c.CreateInvestor(ctx, types.CreateInvestorRequest{Name: "Investor"})
+	assert.Nil(t, err)
+	accountId, err := c.CreateCapitalAccount(ctx, types.CreateCapitalAccountRequest{Fund: fundId, Investor: investorId, PerformanceRate: "0"},
+		client.WithIdempotencyKey("account"))
+	assert.Nil(t, err)
+	replayed, err := c.CreateCapitalAccount(ctx, types.CreateCapitalAccountRequest{Fund: fundId, Investor: investorId, PerformanceRate: "0"},
+		client.WithIdempotencyKey("account"))
+	assert.Nil(t, err)
+	assert.Equal(t, accountId, replayed)
+
+	accepted, err := c.CreateCapitalAccountActionAsync(ctx, types.CreateCapitalAccountActionRequest{
+		CapitalAccount: accountId, Type: "deposit", Amount: "1000", Date: "01-01-2020",
+	})
+	assert.Nil(t, err)
+	transaction, err := c.WaitForTransaction(ctx, accepted.TxId, 10*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, client.TRANSACTION_STATUS_VALID, transaction.Status)
+
+	sheet := "reference,investorName,depositAmount,depositDate\nLP-1,Alice,500,01-01-2020\n"
+	report, err := c.ImportCapitalAccounts(ctx, fundId, strings.NewReader(sheet), importer.CSV_MEDIA_TYPE, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Imported)
+	_, err = c.ImportCapitalAccounts(ctx, fundId, strings.NewReader("reference\nLP-2\n"), importer.CSV_MEDIA_TYPE, 0)
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	report, err = c.ImportCapitalAccounts(ctx, fundId, strings.NewReader(sheet), importer.CSV_MEDIA_TYPE, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Skipped)
+
+	assert.Nil(t, c.BootstrapFund(ctx, fundId))
+	fund, err := c.GetFund(ctx, fundId)
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Fund", fund.Name)
+	assert.Equal(t, 1, fund.CurrentPeriod)
+
+	page, err := c.ListFundCapitalAccounts(ctx, fundId, client.Page{})
+	assert.Nil(t, err)
+	assert.Len(t, page.Records, 2)
+	_, err = c.ImportCapitalAccounts(ctx, fundId, strings.NewReader(sheet), importer.CSV_MEDIA_TYPE, 0)
+	assert.True(t, errors.Is(err, pkgErrors.CannotImportCapitalAccountsError))
+
+	_, err = c.GetFund(ctx, "missing")
+	assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+}
+
+func TestLocalBackendServesTheJournal(t *testing.T) {
+	c := localServer(t)
+	ctx := context.Background()
+
+	fundId, err := c.CreateFund(ctx, types.CreateFundRequest{Name: "Test Fund", InceptionDate: "01-01-2020"})
+	assert.Nil(t, err)
+	investorId, err := c.CreateInvestor(ctx, types.CreateInvestorRequest{Name: "Investor"})
+	assert.Nil(t, err)
+	accountId, err := c.CreateCapitalAccount(ctx, types.CreateCapitalAccountRequest{Fund: fundId, Investor: investorId, PerformanceRate: "0"})
+	assert.Nil(t, err)
+	_, err = c.CreateCapitalAccountAction(ctx, types.CreateCapitalAccountActionRequest{
+		CapitalAccount: accountId, Type: "deposit", Amount: "1000", Date: "01-01-2020",
+	})
+	assert.Nil(t, err)
+
+	_, err = c.SetFundChartOfAccounts(ctx, fundId, types.ChartOfAccounts{types.GL_ROLE_CASH: {Number: "1100", Name: "Bank", Type: types.GL_TYPE_ASSET}})
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	set, err := c.SetFundChartOfAccounts(ctx, fundId, types.ChartOfAccounts{types.GL_ROLE_CASH: {Number: "1010", Name: "Bank", Type: types.GL_TYPE_ASSET}})
+	assert.Nil(t, err)
+	assert.Equal(t, fundId, set.FundId)
+	assert.Nil(t, c.BootstrapFund(ctx, fundId))
+
+	journal, err := c.GetFundJournal(ctx, fundId, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, journal.Period)
+	assert.Len(t, journal.Entries, 3)
+	assert.Equal(t, "1010", journal.Entries[0].Lines[0].Account)
+	csv, err := c.DownloadFundJournal(ctx, fundId, 0, "csv")
+	assert.Nil(t, err)
+	assert.Contains(t, string(csv), "Bank,,1000.00,0.00")
+	iif, err := c.DownloadFundJournal(ctx, fundId, 0, "iif")
+	assert.Nil(t, err)
+	assert.Contains(t, string(iif), "ACCNT\tBank\tOCASSET\t1010")
+	_, err = c.DownloadFundJournal(ctx, fundId, 0, "pdf")
+	var apiErr *client.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, pkgErrors.CODE_INVALID_REQUEST, apiErr.Err.Code)
+
+	trialBalance, err := c.GetFundTrialBalance(ctx, fundId, 0)
+	assert.Nil(t, err)
+	assert.True(t, trialBalance.Balanced)
+	assert.Equal(t, "1000.00", trialBalance.TotalDebit)
+}
+
+func TestLocalBackendServesPerformance(t *testing.T) {
+	c := localServer(t)
+	ctx := context.Background()
+
+	_, err := c.GetRisklessRate(ctx, "tbill")
+	assert.True(t, errors.Is(err, pkgErrors.RisklessRateNotFoundError))
+	_, err = c.SetRisklessRate(ctx, "tbill", types.SetRisklessRateRequest{Date: "01-01-2020", Rate: "0.01"})
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	risklessRate, err := c.SetRisklessRate(ctx, "tbill", types.SetRisklessRateRequest{Name: "T-bill", Date: "01-01-2020", Rate: "0.01"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"01-01-2020": "0.01"}, risklessRate.Values)
+	risklessRate, err = c.GetRisklessRate(ctx, "tbill")
+	assert.Nil(t, err)
+	assert.Equal(t, "T-bill", risklessRate.Name)
+
+	fundId, err := c.CreateFund(ctx, types.CreateFundRequest{Name: "Test Fund", InceptionDate: "01-01-2020"})
+	assert.Nil(t, err)
+	investorId, err := c.CreateInvestor(ctx, types.CreateInvestorRequest{Name: "Investor"})
+	assert.Nil(t, err)
+	accountId, err := c.CreateCapitalAccount(ctx, types.CreateCapitalAccountRequest{Fund: fundId, Investor: investorId, PerformanceRate: "0"})
+	assert.Nil(t, err)
+	_, err = c.CreateCapitalAccountAction(ctx, types.CreateCapitalAccountActionRequest{
+		CapitalAccount: accountId, Type: "deposit", Amount: "1000", Date: "01-01-2020",
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, c.BootstrapFund(ctx, fundId))
+
+	report, err := c.GetFundPerformance(ctx, fundId, -1, "tbill")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.Period)
+	assert.Equal(t, "tbill", report.RisklessRate)
+	assert.Empty(t, report.FundPerformance.Periods)
+	assert.Len(t, report.Accounts, 1)
+	assert.Equal(t, accountId, report.Accounts[0].ID)
+	_, err = c.GetFundPerformance(ctx, fundId, 1, "")
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	_, err = c.GetFundPerformance(ctx, fundId, -1, "missing")
+	assert.True(t, errors.Is(err, pkgErrors.RisklessRateNotFoundError))
+
+	_, err = c.SetFundPeriodsPerYear(ctx, fundId, 0)
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	_, err = c.SetFundPeriodsPerYear(ctx, "missing", 4)
+	assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+	set, err := c.SetFundPeriodsPerYear(ctx, fundId, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, set.PeriodsPerYear)
+	report, err = c.GetFundPerformance(ctx, fundId, -1, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, report.PeriodsPerYear)
+}
+
+func TestLocalBackendServesExposure(t *testing.T) {
+	c := localServer(t)
+	ctx := context.Background()
+
+	_, err := c.GetSecurity(ctx, "037833100")
+	assert.True(t, errors.Is(err, pkgErrors.SecurityNotFoundError))
+	_, err = c.SetSecurity(ctx, "037833100", types.SetSecurityRequest{Name: "Apple Inc", AssetClass: "stock", Country: "US", Issuer: "Apple Inc"})
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	security, err := c.SetSecurity(ctx, "037833100", types.SetSecurityRequest{
+		Name: "Apple Inc", AssetClass: types.ASSET_CLASS_EQUITY, Sector: "Information Technology", Country: "US", Issuer: "Apple Inc",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "037833100", security.ID)
+	security, err = c.GetSecurity(ctx, "037833100")
+	assert.Nil(t, err)
+	assert.Equal(t, "Information Technology", security.Sector)
+
+	fundId, err := c.CreateFund(ctx, types.CreateFundRequest{Name: "Test Fund", InceptionDate: "01-01-2020"})
+	assert.Nil(t, err)
+	_, err = c.SetFundConcentrationLimits(ctx, fundId, types.ConcentrationLimits{{Dimension: "top", Max: "0.5"}})
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	limits := types.ConcentrationLimits{{Dimension: types.EXPOSURE_DIMENSION_ISSUER, Max: "0.25"}}
+	set, err := c.SetFundConcentrationLimits(ctx, fundId, limits)
+	assert.Nil(t, err)
+	assert.Equal(t, fundId, set.FundId)
+	assert.Equal(t, limits, set.Limits)
+
+	portfolioId, err := c.CreatePortfolio(ctx, types.CreatePortfolioRequest{Fund: fundId, Name: "Main"})
+	assert.Nil(t, err)
+	_, err = c.CreatePortfolioAction(ctx, types.CreatePortfolioActionRequest{
+		Portfolio: portfolioId, Type: "buy", Date: "01-31-2020", Period: 1, Name: "Apple", CUSIP: "037833100", Amount: "100", Currency: "USD",
+	})
+	assert.Nil(t, err)
+
+	//without a valuation the position is reported but left out of the book
+	report, err := c.GetFundExposure(ctx, fundId, "", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, fundId, report.Fund)
+	assert.Equal(t, "0.00", report.NAV)
+	assert.Len(t, report.Unpriced, 1)
+	assert.Len(t, report.Limits, 1)
+	assert.True(t, report.Breached)
+	_, err = c.GetFundExposure(ctx, fundId, "2020-01-31", 0)
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	_, err = c.GetFundExposure(ctx, "missing", "", 0)
+	assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+}
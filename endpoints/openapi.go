@@ -0,0 +1,258 @@
+package endpoints
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const OPENAPI_VERSION string = "3.0.3"
+const API_VERSION string = "1.0.0"
+
+type openAPIObject = map[string]interface{}
+
+// Builds the OpenAPI document of the routes. Schemas are generated from the Go
+// types the handlers bind and return, so they follow the json tags.
+func (w *EndpointWrapper) OpenAPI() openAPIObject {
+	schemas := &schemaRegistry{components: openAPIObject{}}
+	errorSchema := openAPIObject{
+		"type":       "object",
+		"properties": openAPIObject{"error": schemas.schemaFor(reflect.TypeOf(pkgErrors.Error{}))},
+	}
+	schemas.components["ErrorResponse"] = errorSchema
+
+	paths := openAPIObject{}
+	for _, route := range w.Routes() {
+		path := openAPIPath(route.Path)
+		item, ok := paths[path].(openAPIObject)
+		if !ok {
+			item = openAPIObject{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = route.operation(schemas)
+	}
+
+	return openAPIObject{
+		"openapi": OPENAPI_VERSION,
+		"info": openAPIObject{
+			"title":   "Fund administration API",
+			"version": API_VERSION,
+		},
+		"paths": paths,
+		"components": openAPIObject{
+			"schemas": schemas.components,
+			"securitySchemes": openAPIObject{
+				"bearerAuth": openAPIObject{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     openAPIObject{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+func (w *EndpointWrapper) GetOpenAPIEndpoint(c *gin.Context) {
+	c.JSON(http.StatusOK, w.OpenAPI())
+}
+
+// Converts the gin path parameters of a route to OpenAPI templates, e.g.
+// /funds/:id to /funds/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (r Route) operation(schemas *schemaRegistry) openAPIObject {
+	parameters := []openAPIObject{}
+	for _, segment := range strings.Split(r.Path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			parameters = append(parameters, openAPIObject{
+				"name": segment[1:], "in": "path", "required": true, "schema": openAPIObject{"type": "string"},
+			})
+		}
+	}
+	query := r.Query
+	if r.Async {
+		query = append(append([]Parameter{}, query...), asyncParameter)
+	}
+	for _, parameter := range query {
+		parameterType := parameter.Type
+		if parameterType == "" {
+			parameterType = "string"
+		}
+		parameters = append(parameters, openAPIObject{
+			"name":        parameter.Name,
+			"in":          "query",
+			"required":    parameter.Required,
+			"description": parameter.Description,
+			"schema":      openAPIObject{"type": parameterType},
+		})
+	}
+	if r.CreatedField != "" {
+		parameters = append(parameters, openAPIObject{
+			"name":        IDEMPOTENCY_KEY_HEADER,
+			"in":          "header",
+			"description": "Retries with the same key return the document the first request created",
+			"schema":      openAPIObject{"type": "string", "maxLength": MAX_IDEMPOTENCY_KEY_LENGTH},
+		})
+	}
+
+	operation := openAPIObject{
+		"operationId": r.OperationId,
+		"summary":     r.Summary,
+		"parameters":  parameters,
+		"responses":   r.responses(schemas),
+	}
+	if r.Body != nil {
+		operation["requestBody"] = openAPIObject{
+			"required": true,
+			"content":  jsonContent(schemas.schemaFor(reflect.TypeOf(r.Body))),
+		}
+	}
+	if len(r.Upload) > 0 {
+		content := openAPIObject{}
+		for _, mediaType := range r.Upload {
+			content[mediaType] = openAPIObject{"schema": openAPIObject{"type": "string", "format": "binary"}}
+		}
+		operation["requestBody"] = openAPIObject{"required": true, "content": content}
+	}
+	if r.Scope == "" {
+		operation["security"] = []openAPIObject{}
+	} else {
+		operation["security"] = []openAPIObject{{"bearerAuth": []string{}}, {"apiKey": []string{}}}
+		operation["x-required-scope"] = r.Scope
+	}
+	return operation
+}
+
+func (r Route) responses(schemas *schemaRegistry) openAPIObject {
+	responses := openAPIObject{
+		"default": openAPIObject{
+			"description": "An error",
+			"content":     jsonContent(openAPIObject{"$ref": "#/components/schemas/ErrorResponse"}),
+		},
+	}
+	success := openAPIObject{"description": "Success"}
+	switch {
+	case r.CreatedField != "":
+		success["content"] = jsonContent(idSchema(r.CreatedField))
+	case r.Response != nil:
+		success["content"] = jsonContent(schemas.schemaFor(reflect.TypeOf(r.Response)))
+	}
+	for _, mediaType := range r.Download {
+		success["content"].(openAPIObject)[mediaType] = openAPIObject{"schema": openAPIObject{"type": "string", "format": "binary"}}
+	}
+	responses["200"] = success
+	if r.Async {
+		accepted := idSchema("txId", "status")
+		if r.CreatedField != "" {
+			accepted = idSchema(r.CreatedField, "txId", "status")
+		}
+		responses["202"] = openAPIObject{
+			"description": "Accepted with ?async=true, poll the Location header for the outcome",
+			"content":     jsonContent(accepted),
+		}
+	}
+	return responses
+}
+
+func jsonContent(schema openAPIObject) openAPIObject {
+	return openAPIObject{"application/json": openAPIObject{"schema": schema}}
+}
+
+// An object of required string fields
+func idSchema(fields ...string) openAPIObject {
+	properties := openAPIObject{}
+	for _, field := range fields {
+		properties[field] = openAPIObject{"type": "string"}
+	}
+	return openAPIObject{"type": "object", "properties": properties, "required": fields}
+}
+
+// Collects the schemas of named structs as components so that each is described
+// once and referenced everywhere else
+type schemaRegistry struct {
+	components openAPIObject
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (s *schemaRegistry) schemaFor(t reflect.Type) openAPIObject {
+	if t.Kind() == reflect.Ptr {
+		return s.schemaFor(t.Elem())
+	}
+	if t == timeType {
+		return openAPIObject{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return openAPIObject{"type": "string"}
+	case reflect.Bool:
+		return openAPIObject{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return openAPIObject{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return openAPIObject{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return openAPIObject{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return openAPIObject{"type": "array", "items": s.schemaFor(t.Elem())}
+	case reflect.Map:
+		//JSON object keys are strings whatever the key type of the map
+		return openAPIObject{"type": "object", "additionalProperties": s.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		if _, ok := s.components[t.Name()]; !ok {
+			//registered before the fields are described so recursive types terminate
+			s.components[t.Name()] = openAPIObject{}
+			s.components[t.Name()] = s.structSchema(t)
+		}
+		return openAPIObject{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return openAPIObject{}
+}
+
+func (s *schemaRegistry) structSchema(t reflect.Type) openAPIObject {
+	properties := openAPIObject{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, omitted := jsonFieldName(field)
+		if omitted {
+			continue
+		}
+		properties[name] = s.schemaFor(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			required = append(required, name)
+		}
+	}
+	schema := openAPIObject{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, false
+}
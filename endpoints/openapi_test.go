@@ -0,0 +1,154 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+// Serves the document through the router the server builds and decodes it the way
+// a client would
+func openAPIDocument(t *testing.T) map[string]interface{} {
+	gin.SetMode(gin.TestMode)
+	w := &EndpointWrapper{AdminServer: &web.AdminServer{}}
+	router := gin.New()
+	w.RegisterRoutes(router)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var document map[string]interface{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	document := openAPIDocument(t)
+	assert.Equal(t, OPENAPI_VERSION, document["openapi"])
+	paths := document["paths"].(map[string]interface{})
+
+	gin.SetMode(gin.TestMode)
+	w := &EndpointWrapper{AdminServer: &web.AdminServer{}}
+	router := gin.New()
+	w.RegisterRoutes(router)
+
+	operationIds := map[string]bool{}
+	for _, route := range router.Routes() {
+		item, ok := paths[openAPIPath(route.Path)].(map[string]interface{})
+		if !assert.True(t, ok, route.Path) {
+			continue
+		}
+		operation, ok := item[strings.ToLower(route.Method)].(map[string]interface{})
+		if !assert.True(t, ok, route.Method+" "+route.Path) {
+			continue
+		}
+		operationId := operation["operationId"].(string)
+		assert.NotEmpty(t, operationId)
+		assert.False(t, operationIds[operationId], operationId)
+		operationIds[operationId] = true
+	}
+	assert.Len(t, operationIds, len(w.Routes()))
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/funds/{id}/roundingpolicy", openAPIPath("/funds/:id/roundingpolicy"))
+	assert.Equal(t, "/transactions/{txid}", openAPIPath("/transactions/:txid"))
+	assert.Equal(t, "/funds", openAPIPath("/funds"))
+}
+
+// Collects every $ref in the document
+func collectRefs(value interface{}, refs *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				*refs = append(*refs, ref)
+			}
+			collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectRefs(child, refs)
+		}
+	}
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	document := openAPIDocument(t)
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	refs := []string{}
+	collectRefs(document, &refs)
+	assert.NotEmpty(t, refs)
+	for _, ref := range refs {
+		assert.True(t, strings.HasPrefix(ref, "#/components/schemas/"), ref)
+		_, ok := schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+		assert.True(t, ok, ref)
+	}
+}
+
+func TestOpenAPIRequestBodiesFollowJSONTags(t *testing.T) {
+	document := openAPIDocument(t)
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	w := &EndpointWrapper{AdminServer: &web.AdminServer{}}
+	for _, route := range w.Routes() {
+		if route.Body == nil {
+			continue
+		}
+		bodyType := reflect.TypeOf(route.Body)
+		expected := []string{}
+		for i := 0; i < bodyType.NumField(); i++ {
+			name, omitted := jsonFieldName(bodyType.Field(i))
+			if !omitted {
+				expected = append(expected, name)
+			}
+		}
+		schema := schemas[bodyType.Name()].(map[string]interface{})
+		actual := []string{}
+		for name := range schema["properties"].(map[string]interface{}) {
+			actual = append(actual, name)
+		}
+		sort.Strings(expected)
+		sort.Strings(actual)
+		assert.Equal(t, expected, actual, route.OperationId)
+	}
+}
+
+func TestOpenAPIOperations(t *testing.T) {
+	paths := openAPIDocument(t)["paths"].(map[string]interface{})
+
+	create := paths["/funds"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, web.SCOPE_FUNDS_WRITE, create["x-required-scope"])
+	parameters := []string{}
+	for _, parameter := range create["parameters"].([]interface{}) {
+		parameters = append(parameters, parameter.(map[string]interface{})["name"].(string))
+	}
+	assert.Equal(t, []string{"async", IDEMPOTENCY_KEY_HEADER}, parameters)
+	responses := create["responses"].(map[string]interface{})
+	for _, status := range []string{"200", "202", "default"} {
+		assert.Contains(t, responses, status)
+	}
+
+	document := paths["/openapi.json"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.NotContains(t, document, "x-required-scope")
+	assert.Empty(t, document["security"])
+
+	//every route that needs a scope declares it
+	for path, item := range paths {
+		for method, operation := range item.(map[string]interface{}) {
+			if path == "/openapi.json" {
+				continue
+			}
+			assert.NotEmpty(t, operation.(map[string]interface{})["x-required-scope"], method+" "+path)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package endpoints
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+var invalidPageSizeError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "pageSize must be a positive integer")
+
+// Reads the pageSize and bookmark query parameters and returns them in the form the chaincode expects
+func parsePagination(c *gin.Context) (string, string, error) {
+	pageSize := types.DEFAULT_PAGE_SIZE
+	rawPageSize := c.Query("pageSize")
+	if rawPageSize != "" {
+		parsed, err := strconv.ParseInt(rawPageSize, 10, 32)
+		if err != nil || parsed <= 0 {
+			return "", "", invalidPageSizeError
+		}
+		pageSize = types.NormalizePageSize(int32(parsed))
+	}
+	return fmt.Sprintf("%d", pageSize), c.Query("bookmark"), nil
+}
@@ -0,0 +1,89 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func (a *EndpointWrapper) PutRisklessRateEndpoint(c *gin.Context) {
+	risklessRateId := c.Param("id")
+	var setRisklessRateRequest types.SetRisklessRateRequest
+
+	err := c.ShouldBindJSON(&setRisklessRateRequest)
+	if err != nil {
+		respondWithError(c, missingParametersError)
+		return
+	}
+	err = types.ValidateSetRisklessRateRequest(&setRisklessRateRequest)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	_, err = a.Submit(identity(c), "SetRisklessRate", risklessRateId, setRisklessRateRequest.Name, setRisklessRateRequest.Date, setRisklessRateRequest.Rate)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	a.respondWithRisklessRate(c, risklessRateId)
+}
+
+func (a *EndpointWrapper) GetRisklessRateByIdEndpoint(c *gin.Context) {
+	a.respondWithRisklessRate(c, c.Param("id"))
+}
+
+func (a *EndpointWrapper) respondWithRisklessRate(c *gin.Context, risklessRateId string) {
+	result, err := a.Evaluate(identity(c), "QueryRisklessRateById", risklessRateId)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	if len(result) == 0 {
+		respondWithError(c, pkgErrors.RisklessRateNotFoundError.WithDetail("risklessRate", risklessRateId))
+		return
+	}
+	var risklessRate types.RisklessRate
+	err = json.Unmarshal(result, &risklessRate)
+	if err != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, risklessRate)
+}
+
+func (a *EndpointWrapper) PutFundPeriodsPerYearEndpoint(c *gin.Context) {
+	fundId := c.Param("id")
+	var setPeriodsPerYearRequest types.SetPeriodsPerYearRequest
+
+	err := c.ShouldBindJSON(&setPeriodsPerYearRequest)
+	if err != nil {
+		respondWithError(c, missingParametersError)
+		return
+	}
+	err = types.ValidatePeriodsPerYear(setPeriodsPerYearRequest.PeriodsPerYear)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	_, err = a.Submit(identity(c), "SetFundPeriodsPerYear", fundId, strconv.Itoa(setPeriodsPerYearRequest.PeriodsPerYear))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, types.PeriodsPerYearSet{FundId: fundId, PeriodsPerYear: setPeriodsPerYearRequest.PeriodsPerYear})
+}
+
+// Answers with the performance through the period, with Sharpe ratios when the
+// riskless rate query parameter names a riskless rate
+func (a *EndpointWrapper) GetFundPerformanceEndpoint(c *gin.Context) {
+	var report types.PerformanceReport
+	if a.evaluatePeriodReport(c, "QueryPerformance", &report, c.Query("risklessRate")) {
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -6,50 +6,51 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	uuid "github.com/satori/go.uuid"
 	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
 )
 
 func (w *EndpointWrapper) PostPortfoliosEndpoint(c *gin.Context) {
 	var createPortfolioRequest types.CreatePortfolioRequest
 
-	err := c.BindJSON(&createPortfolioRequest)
+	err := c.ShouldBindJSON(&createPortfolioRequest)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing required parameters"})
+		respondWithError(c, missingParametersError)
 		return
 	}
 
-	validRequest := types.ValidateCreatePortfolioRequest(&createPortfolioRequest)
-	if !validRequest {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "improperly formatted request"})
-		return
-	}
-
-	porfolioId := uuid.NewV4().String()
-
-	result, err := w.Contract.SubmitTransaction("CreatePortfolio", porfolioId, createPortfolioRequest.Fund, createPortfolioRequest.Name)
+	err = types.ValidateCreatePortfolioRequest(&createPortfolioRequest)
 	if err != nil {
-		errorString := fmt.Sprintf("error submitting request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"portfolioId": porfolioId})
+	w.submitCreate(c, idempotentCreate{
+		Kind:        "portfolio",
+		Transaction: "CreatePortfolio",
+		Args:        []string{createPortfolioRequest.Fund, createPortfolioRequest.Name},
+		Query:       "QueryPortfolioById",
+		Matches: func(document []byte) bool {
+			var portfolio types.Portfolio
+			return decodeDocument(document, &portfolio) &&
+				portfolio.Fund == createPortfolioRequest.Fund &&
+				portfolio.Name == createPortfolioRequest.Name
+		},
+		IdField: "portfolioId",
+	})
 }
 
 func (w *EndpointWrapper) GetPortfolioByIdEndpoint(c *gin.Context) {
 	porfolioId := c.Param("id")
-	result, err := w.Contract.EvaluateTransaction("QueryInvestorById", porfolioId)
+	result, err := w.Evaluate(identity(c), "QueryPortfolioById", porfolioId)
 
 	if err != nil {
-		errorString := fmt.Sprintf("error evaluating request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
+		return
 	}
 
 	if len(result) == 0 {
-		c.JSON(http.StatusOK, "")
+		respondWithError(c, pkgErrors.PortfolioNotFoundError.WithDetail("portfolio", porfolioId))
 		return
 	}
 
@@ -57,7 +58,7 @@ func (w *EndpointWrapper) GetPortfolioByIdEndpoint(c *gin.Context) {
 	jsonErr := json.Unmarshal(result, &portfolio)
 
 	if jsonErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error unmarshaling json"})
+		respondWithError(c, unmarshalResponseError)
 		return
 	}
 	c.JSON(http.StatusOK, portfolio)
@@ -66,45 +67,53 @@ func (w *EndpointWrapper) GetPortfolioByIdEndpoint(c *gin.Context) {
 func (w *EndpointWrapper) PostPortfolioActionEndpoint(c *gin.Context) {
 	var createPortfolioActionRequest types.CreatePortfolioActionRequest
 
-	err := c.BindJSON(&createPortfolioActionRequest)
+	err := c.ShouldBindJSON(&createPortfolioActionRequest)
 	if err != nil {
 		fmt.Println(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing required parameters"})
+		respondWithError(c, missingParametersError)
 		return
 	}
 
-	validRequest := types.ValidateCreatePortfolioActionRequest(&createPortfolioActionRequest)
-	if !validRequest {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "improperly formatted request"})
+	err = types.ValidateCreatePortfolioActionRequest(&createPortfolioActionRequest)
+	if err != nil {
+		respondWithError(c, err)
 		return
 	}
 
-	transactionId := uuid.NewV4().String()
 	period := fmt.Sprintf("%d", createPortfolioActionRequest.Period)
 
-	result, err := w.Contract.SubmitTransaction("CreatePortfolioAction", transactionId, createPortfolioActionRequest.Portfolio, createPortfolioActionRequest.Type, createPortfolioActionRequest.Date, period, createPortfolioActionRequest.Name, createPortfolioActionRequest.CUSIP, createPortfolioActionRequest.Amount, createPortfolioActionRequest.Currency)
-	if err != nil {
-		errorString := fmt.Sprintf("error submitting request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"transactionId": transactionId})
+	w.submitCreate(c, idempotentCreate{
+		Kind:        "portfolioAction",
+		Transaction: "CreatePortfolioAction",
+		Args:        []string{createPortfolioActionRequest.Portfolio, createPortfolioActionRequest.Type, createPortfolioActionRequest.Date, period, createPortfolioActionRequest.Name, createPortfolioActionRequest.CUSIP, createPortfolioActionRequest.Amount, createPortfolioActionRequest.Currency},
+		Query:       "QueryPortfolioActionById",
+		Matches: func(document []byte) bool {
+			var action types.PortfolioAction
+			return decodeDocument(document, &action) &&
+				action.Portfolio == createPortfolioActionRequest.Portfolio &&
+				action.Type == createPortfolioActionRequest.Type &&
+				action.Date == createPortfolioActionRequest.Date &&
+				action.Period == createPortfolioActionRequest.Period &&
+				action.Asset.Name == createPortfolioActionRequest.Name &&
+				action.Asset.CUSIP == createPortfolioActionRequest.CUSIP &&
+				types.SameDecimal(action.Asset.Amount, createPortfolioActionRequest.Amount) &&
+				action.Asset.Currency == createPortfolioActionRequest.Currency
+		},
+		IdField: "transactionId",
+	})
 }
 
 func (w *EndpointWrapper) GetPortfolioActionByIdEndpoint(c *gin.Context) {
 	transactionId := c.Param("id")
-	result, err := w.Contract.EvaluateTransaction("QueryPortfolioActionById", transactionId)
+	result, err := w.Evaluate(identity(c), "QueryPortfolioActionById", transactionId)
 
 	if err != nil {
-		errorString := fmt.Sprintf("error evaluating request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
+		return
 	}
 
 	if len(result) == 0 {
-		c.JSON(http.StatusOK, "")
+		respondWithError(c, pkgErrors.PortfolioActionNotFoundError.WithDetail("portfolioAction", transactionId))
 		return
 	}
 
@@ -112,7 +121,7 @@ func (w *EndpointWrapper) GetPortfolioActionByIdEndpoint(c *gin.Context) {
 	jsonErr := json.Unmarshal(result, &portfolioAction)
 
 	if jsonErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error unmarshaling json"})
+		respondWithError(c, unmarshalResponseError)
 		return
 	}
 	c.JSON(http.StatusOK, portfolioAction)
@@ -120,23 +129,86 @@ func (w *EndpointWrapper) GetPortfolioActionByIdEndpoint(c *gin.Context) {
 
 func (w *EndpointWrapper) PostValuePortfolioEndpoint(c *gin.Context) {
 	var valuePortfolioRequest types.ValuePortfolioRequest
-	err := c.BindJSON(&valuePortfolioRequest)
+	err := c.ShouldBindJSON(&valuePortfolioRequest)
 	if err != nil {
 		fmt.Println(err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing required parameters"})
+		respondWithError(c, missingParametersError)
 		return
 	}
-	validRequest := types.ValidateValuePortfolioRequest(&valuePortfolioRequest)
-	if !validRequest {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "improperly formatted request"})
+	err = types.ValidateValuePortfolioRequest(&valuePortfolioRequest)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	async, err := asyncRequested(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	args := []string{valuePortfolioRequest.Portfolio, valuePortfolioRequest.Date, valuePortfolioRequest.Name, valuePortfolioRequest.Price}
+	if async {
+		record, err := w.SubmitAsync(identity(c), subject(c), "", "UpdatePortfolioValuation", args...)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		respondAccepted(c, record, gin.H{})
 		return
 	}
-	result, err := w.Contract.SubmitTransaction("UpdatePortfolioValuation", valuePortfolioRequest.Portfolio, valuePortfolioRequest.Date, valuePortfolioRequest.Name, valuePortfolioRequest.Price)
+	_, err = w.Submit(identity(c), "UpdatePortfolioValuation", args...)
 	if err != nil {
-		errorString := fmt.Sprintf("error submitting request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorString})
-		fmt.Println(result)
+		respondWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, types.ValuePortfolioResponse{Status: "success"})
+}
+
+func (w *EndpointWrapper) GetPortfoliosEndpoint(c *gin.Context) {
+	fundId := c.Query("fund")
+	if fundId == "" {
+		respondWithInvalidRequest(c, "missing required parameter fund")
+		return
+	}
+	pageSize, bookmark, err := parsePagination(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	result, err := w.Evaluate(identity(c), "QueryPortfoliosByFundWithPagination", fundId, pageSize, bookmark)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var page types.PortfolioPage
+	jsonErr := json.Unmarshal(result, &page)
+	if jsonErr != nil {
+		respondWithError(c, unmarshalResponseError)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (w *EndpointWrapper) GetPortfolioActionsEndpoint(c *gin.Context) {
+	portfolioId := c.Query("portfolio")
+	if portfolioId == "" {
+		respondWithInvalidRequest(c, "missing required parameter portfolio")
+		return
+	}
+	pageSize, bookmark, err := parsePagination(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	result, err := w.Evaluate(identity(c), "QueryPortfolioActionsByPortfolioWithPagination", portfolioId, pageSize, bookmark)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	var page types.PortfolioActionPage
+	jsonErr := json.Unmarshal(result, &page)
+	if jsonErr != nil {
+		respondWithError(c, unmarshalResponseError)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	c.JSON(http.StatusOK, page)
 }
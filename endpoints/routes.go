@@ -0,0 +1,308 @@
+package endpoints
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zacharyfrederick/admin/importer"
+	"github.com/zacharyfrederick/admin/journal"
+	"github.com/zacharyfrederick/admin/types"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+// A query string parameter of a route
+type Parameter struct {
+	Name        string
+	Description string
+	Required    bool
+	// The OpenAPI type of the value, string when empty
+	Type string
+}
+
+// A route of the REST api. The router is built from these and so is the OpenAPI
+// document, so a route cannot be served without being documented.
+type Route struct {
+	Method      string
+	Path        string
+	OperationId string
+	Summary     string
+	// The scope the caller needs. Routes without one are public.
+	Scope   string
+	Handler gin.HandlerFunc
+	Query   []Parameter
+	// The JSON body of the request, nil when it has none
+	Body interface{}
+	// The media types of a file sent as the whole request body instead of JSON
+	Upload []string
+	// The JSON body of a successful response
+	Response interface{}
+	// The media types a successful response can be sent as instead of JSON,
+	// chosen with ?format
+	Download []string
+	// For routes creating a document, the response field holding its id. These
+	// routes accept an Idempotency-Key.
+	CreatedField string
+	// Whether the route accepts ?async=true
+	Async bool
+}
+
+var paginationParameters = []Parameter{
+	{Name: "pageSize", Type: "integer", Description: "Number of records per page, at most 1000"},
+	{Name: "bookmark", Description: "The bookmark of the previous page"},
+}
+
+var actionFilterParameters = []Parameter{
+	{Name: "period", Type: "integer", Description: "Only actions in this period"},
+	{Name: "type", Description: "Only actions of this type"},
+	{Name: "status", Description: "Only actions with this status"},
+	{Name: "startDate", Description: "Only actions on or after this date, MM-DD-YYYY"},
+	{Name: "endDate", Description: "Only actions on or before this date, MM-DD-YYYY"},
+}
+
+var periodParameter = Parameter{Name: "period", Type: "integer", Description: "The period, the last closed one by default"}
+
+var asyncParameter = Parameter{Name: "async", Type: "boolean", Description: "Submit in the background and answer 202 with a transaction id"}
+
+func requiredParameter(name string, description string) Parameter {
+	return Parameter{Name: name, Description: description, Required: true}
+}
+
+func withPagination(parameters ...Parameter) []Parameter {
+	return append(parameters, paginationParameters...)
+}
+
+func (w *EndpointWrapper) Routes() []Route {
+	return []Route{
+		{
+			Method: "POST", Path: "/funds", OperationId: "createFund", Summary: "Create a fund",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostFundEndpoint,
+			Body: types.CreateFundRequest{}, CreatedField: "fundId", Async: true,
+		},
+		{
+			Method: "GET", Path: "/funds", OperationId: "listFunds", Summary: "List funds",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundsEndpoint,
+			Query: withPagination(), Response: types.FundPage{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id", OperationId: "getFund", Summary: "Read a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundByIdEndpoint,
+			Response: types.Fund{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/investors", OperationId: "listFundInvestors", Summary: "List the investors of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundInvestorsEndpoint,
+			Query: withPagination(), Response: types.InvestorPage{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/capitalaccounts", OperationId: "listFundCapitalAccounts", Summary: "List the capital accounts of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundCapitalAccountsEndpoint,
+			Query: withPagination(), Response: types.CapitalAccountPage{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/portfolios", OperationId: "listFundPortfolios", Summary: "List the portfolios of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundPortfoliosEndpoint,
+			Query: withPagination(), Response: types.PortfolioPage{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/capitalaccountactions", OperationId: "listFundCapitalAccountActions", Summary: "List the capital account actions of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundCapitalAccountActionsEndpoint,
+			Query: withPagination(actionFilterParameters...), Response: types.CapitalAccountActionPage{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/portfolioactions", OperationId: "listFundPortfolioActions", Summary: "List the portfolio actions of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundPortfolioActionsEndpoint,
+			Query: withPagination(actionFilterParameters...), Response: types.PortfolioActionPage{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/bootstrap", OperationId: "bootstrapFund", Summary: "Bootstrap a fund from its initial deposits",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.GetFundBootstrapEndpoint,
+			Response: "",
+		},
+		{
+			Method: "POST", Path: "/funds/:id/step", OperationId: "stepFund", Summary: "Close the current period of a fund and open the next",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostFundStepEndpoint,
+			Response: types.FundAndCapitalAccounts{},
+		},
+		{
+			Method: "POST", Path: "/funds/:id/import", OperationId: "importCapitalAccounts", Summary: "Import investors, capital accounts and inception deposits from a CSV or Excel sheet",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostFundImportEndpoint,
+			Query:    []Parameter{{Name: "batchSize", Type: "integer", Description: "Rows submitted per transaction, at most 200"}},
+			Upload:   []string{importer.CSV_MEDIA_TYPE, importer.XLSX_MEDIA_TYPE},
+			Response: types.ImportReport{},
+		},
+		{
+			Method: "PUT", Path: "/funds/:id/roundingpolicy", OperationId: "setFundRoundingPolicy", Summary: "Set the rounding policy of a fund before it is bootstrapped",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PutFundRoundingPolicyEndpoint,
+			Body: types.SetRoundingPolicyRequest{}, Response: types.RoundingPolicySet{},
+		},
+		{
+			Method: "PUT", Path: "/funds/:id/chartofaccounts", OperationId: "setFundChartOfAccounts", Summary: "Set the general ledger accounts of a fund's journal by role",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PutFundChartOfAccountsEndpoint,
+			Body: types.SetChartOfAccountsRequest{}, Response: types.ChartOfAccountsSet{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/journal", OperationId: "getFundJournal", Summary: "Read the journal entries of a period of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundJournalEndpoint,
+			Query:    []Parameter{periodParameter, {Name: "format", Description: "json, csv or iif, json by default"}},
+			Response: types.Journal{}, Download: []string{journal.CSV_MEDIA_TYPE, journal.IIF_MEDIA_TYPE},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/trialbalance", OperationId: "getFundTrialBalance", Summary: "Read the trial balance of a fund after a period",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundTrialBalanceEndpoint,
+			Query: []Parameter{periodParameter}, Response: types.TrialBalance{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/performance", OperationId: "getFundPerformance", Summary: "Read the returns, volatility and drawdowns of a fund, its classes and its capital accounts",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundPerformanceEndpoint,
+			Query:    []Parameter{periodParameter, {Name: "risklessRate", Description: "The id of the riskless rate for Sharpe ratios"}},
+			Response: types.PerformanceReport{},
+		},
+		{
+			Method: "PUT", Path: "/funds/:id/periodsperyear", OperationId: "setFundPeriodsPerYear", Summary: "Set how many periods a year a fund closes, which annualizes its performance",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PutFundPeriodsPerYearEndpoint,
+			Body: types.SetPeriodsPerYearRequest{}, Response: types.PeriodsPerYearSet{},
+		},
+		{
+			Method: "PUT", Path: "/funds/:id/concentrationlimits", OperationId: "setFundConcentrationLimits", Summary: "Set the concentration limits a fund's exposure is checked against",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PutFundConcentrationLimitsEndpoint,
+			Body: types.SetConcentrationLimitsRequest{}, Response: types.ConcentrationLimitsSet{},
+		},
+		{
+			Method: "GET", Path: "/funds/:id/exposure", OperationId: "getFundExposure", Summary: "Read the exposure of a fund by security master dimension, its top positions and its concentration limits",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetFundExposureEndpoint,
+			Query: []Parameter{
+				{Name: "date", Description: "The valuation date, MM-DD-YYYY, the latest valuations by default"},
+				{Name: "top", Type: "integer", Description: "The number of largest positions to list, 10 by default"},
+			},
+			Response: types.ExposureReport{},
+		},
+
+		{
+			Method: "POST", Path: "/investors", OperationId: "createInvestor", Summary: "Create an investor",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostInvestorEndpoint,
+			Body: types.CreateInvestorRequest{}, CreatedField: "investorId", Async: true,
+		},
+		{
+			Method: "GET", Path: "/investors", OperationId: "listInvestors", Summary: "List investors",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetInvestorsEndpoint,
+			Query: withPagination(), Response: types.InvestorPage{},
+		},
+		{
+			Method: "GET", Path: "/investors/:id", OperationId: "getInvestor", Summary: "Read an investor",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetInvestorByIdEndpoint,
+			Response: types.Investor{},
+		},
+
+		{
+			Method: "POST", Path: "/capitalaccounts", OperationId: "createCapitalAccount", Summary: "Open a capital account for an investor in a fund",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostCapitalAccountEndpoint,
+			Body: types.CreateCapitalAccountRequest{}, CreatedField: "capitalAccountId", Async: true,
+		},
+		{
+			Method: "GET", Path: "/capitalaccounts", OperationId: "listCapitalAccounts", Summary: "List the capital accounts of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetCapitalAccountsEndpoint,
+			Query: withPagination(requiredParameter("fund", "The id of the fund")), Response: types.CapitalAccountPage{},
+		},
+		{
+			Method: "GET", Path: "/capitalaccounts/:id", OperationId: "getCapitalAccount", Summary: "Read a capital account",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetCapitalAccountByIdEndpoint,
+			Response: types.CapitalAccount{},
+		},
+
+		{
+			Method: "POST", Path: "/portfolios", OperationId: "createPortfolio", Summary: "Create a portfolio for a fund",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostPortfoliosEndpoint,
+			Body: types.CreatePortfolioRequest{}, CreatedField: "portfolioId", Async: true,
+		},
+		{
+			Method: "GET", Path: "/portfolios", OperationId: "listPortfolios", Summary: "List the portfolios of a fund",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetPortfoliosEndpoint,
+			Query: withPagination(requiredParameter("fund", "The id of the fund")), Response: types.PortfolioPage{},
+		},
+		{
+			Method: "GET", Path: "/portfolios/:id", OperationId: "getPortfolio", Summary: "Read a portfolio",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetPortfolioByIdEndpoint,
+			Response: types.Portfolio{},
+		},
+
+		{
+			Method: "POST", Path: "/capitalaccountactions", OperationId: "createCapitalAccountAction", Summary: "Record a deposit or withdrawal",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostCapitalAccountActionEndpoint,
+			Body: types.CreateCapitalAccountActionRequest{}, CreatedField: "transactionId", Async: true,
+		},
+		{
+			Method: "GET", Path: "/capitalaccountactions", OperationId: "listCapitalAccountActions", Summary: "List the actions of a capital account",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetCapitalAccountActionsEndpoint,
+			Query: withPagination(requiredParameter("capitalAccount", "The id of the capital account")), Response: types.CapitalAccountActionPage{},
+		},
+		{
+			Method: "GET", Path: "/capitalaccountactions/:id", OperationId: "getCapitalAccountAction", Summary: "Read a capital account action",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetCapitalAccountActionByIdEndpoint,
+			Response: types.CapitalAccountAction{},
+		},
+
+		{
+			Method: "POST", Path: "/portfolioactions", OperationId: "createPortfolioAction", Summary: "Record a purchase or sale of a security",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PostPortfolioActionEndpoint,
+			Body: types.CreatePortfolioActionRequest{}, CreatedField: "transactionId", Async: true,
+		},
+		{
+			Method: "GET", Path: "/portfolioactions", OperationId: "listPortfolioActions", Summary: "List the actions of a portfolio",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetPortfolioActionsEndpoint,
+			Query: withPagination(requiredParameter("portfolio", "The id of the portfolio")), Response: types.PortfolioActionPage{},
+		},
+		{
+			Method: "GET", Path: "/portfolioactions/:id", OperationId: "getPortfolioAction", Summary: "Read a portfolio action",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetPortfolioActionByIdEndpoint,
+			Response: types.PortfolioAction{},
+		},
+
+		{
+			Method: "POST", Path: "/valueportfolio", OperationId: "valuePortfolio", Summary: "Record the price of a security in a portfolio",
+			Scope: web.SCOPE_VALUATIONS_WRITE, Handler: w.PostValuePortfolioEndpoint,
+			Body: types.ValuePortfolioRequest{}, Response: types.ValuePortfolioResponse{}, Async: true,
+		},
+
+		{
+			Method: "PUT", Path: "/risklessrates/:id", OperationId: "setRisklessRate", Summary: "Set a riskless rate from a date on",
+			Scope: web.SCOPE_VALUATIONS_WRITE, Handler: w.PutRisklessRateEndpoint,
+			Body: types.SetRisklessRateRequest{}, Response: types.RisklessRate{},
+		},
+		{
+			Method: "GET", Path: "/risklessrates/:id", OperationId: "getRisklessRate", Summary: "Read a riskless rate",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetRisklessRateByIdEndpoint,
+			Response: types.RisklessRate{},
+		},
+		{
+			Method: "PUT", Path: "/securities/:cusip", OperationId: "setSecurity", Summary: "Set the security master record of a CUSIP",
+			Scope: web.SCOPE_FUNDS_WRITE, Handler: w.PutSecurityEndpoint,
+			Body: types.SetSecurityRequest{}, Response: types.Security{},
+		},
+		{
+			Method: "GET", Path: "/securities/:cusip", OperationId: "getSecurity", Summary: "Read the security master record of a CUSIP",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetSecurityByIdEndpoint,
+			Response: types.Security{},
+		},
+
+		{
+			Method: "GET", Path: "/transactions/:txid", OperationId: "getTransaction", Summary: "Report on a transaction submitted with ?async=true",
+			Scope: web.SCOPE_REPORTS_READ, Handler: w.GetTransactionByIdEndpoint,
+			Response: web.TransactionRecord{},
+		},
+
+		{
+			Method: "GET", Path: "/openapi.json", OperationId: "getOpenAPI", Summary: "This document",
+			Handler: w.GetOpenAPIEndpoint,
+		},
+	}
+}
+
+// Registers every route with its authentication and scope check
+func (w *EndpointWrapper) RegisterRoutes(router gin.IRoutes) {
+	for _, route := range w.Routes() {
+		handlers := []gin.HandlerFunc{}
+		if route.Scope != "" {
+			handlers = append(handlers, w.Authenticate, w.RequireScope(route.Scope))
+		}
+		handlers = append(handlers, route.Handler)
+		router.Handle(route.Method, route.Path, handlers...)
+	}
+}
@@ -0,0 +1,45 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+	"github.com/zacharyfrederick/admin/web"
+)
+
+var invalidAsyncError = pkgErrors.New(pkgErrors.CODE_INVALID_REQUEST, "async must be true or false")
+
+// Requests with ?async=true are submitted in the background and answered with 202
+// and the id of a transaction that GET /transactions/:txid reports on
+func asyncRequested(c *gin.Context) (bool, error) {
+	value := c.Query("async")
+	if value == "" {
+		return false, nil
+	}
+	async, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidAsyncError
+	}
+	return async, nil
+}
+
+func respondAccepted(c *gin.Context, record web.TransactionRecord, body gin.H) {
+	body["txId"] = record.ID
+	body["status"] = record.Status
+	c.Header("Location", "/transactions/"+record.ID)
+	c.JSON(http.StatusAccepted, body)
+}
+
+func (w *EndpointWrapper) GetTransactionByIdEndpoint(c *gin.Context) {
+	txId := c.Param("txid")
+	record, ok := w.Transactions.Get(txId)
+	//another caller's transaction is reported as missing rather than forbidden, so
+	//transaction ids cannot be probed
+	if !ok || record.Subject != subject(c) {
+		respondWithError(c, pkgErrors.TransactionNotFoundError.WithDetail("txId", txId))
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
@@ -0,0 +1,251 @@
+// Package importer migrates the investors of a fund onto the ledger from a
+// spreadsheet with one row per investor: their capital account, its fee terms and
+// the inception deposit. Every row is checked before anything is submitted, and
+// the rows are then submitted in batches of one transaction each. The fee terms
+// are the performance fee flag and rate and the fixedFee management fee rate,
+// which is types.DEFAULT_FIXED_FEE for a row that leaves it empty.
+//
+// The ids of the documents are derived from the fund and the reference of each
+// row, so an import that failed partway through is resumed by running it again:
+// the rows that were already imported are skipped.
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const COLUMN_REFERENCE string = "reference"
+const COLUMN_INVESTOR_ID string = "investorId"
+const COLUMN_INVESTOR_NAME string = "investorName"
+const COLUMN_HAS_PERFORMANCE_FEES string = "hasPerformanceFees"
+const COLUMN_PERFORMANCE_RATE string = "performanceRate"
+const COLUMN_FIXED_FEE string = "fixedFee"
+const COLUMN_DEPOSIT_AMOUNT string = "depositAmount"
+const COLUMN_DEPOSIT_DATE string = "depositDate"
+
+const CSV_MEDIA_TYPE string = "text/csv"
+const XLSX_MEDIA_TYPE string = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+var COLUMNS = []string{
+	COLUMN_REFERENCE,
+	COLUMN_INVESTOR_ID,
+	COLUMN_INVESTOR_NAME,
+	COLUMN_HAS_PERFORMANCE_FEES,
+	COLUMN_PERFORMANCE_RATE,
+	COLUMN_FIXED_FEE,
+	COLUMN_DEPOSIT_AMOUNT,
+	COLUMN_DEPOSIT_DATE,
+}
+
+var importNamespace = uuid.NewV5(uuid.NamespaceURL, "https://github.com/zacharyfrederick/admin/import")
+
+// Day zero of the serial numbers spreadsheets store dates as
+var spreadsheetEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// The serial number of 12-31-9999, the last date a spreadsheet can hold
+const MAX_SPREADSHEET_SERIAL int = 2958465
+
+// Submits one batch of rows as a single transaction
+type Submitter func(fundId string, rows []types.ImportRow) ([]types.ImportRowResult, error)
+
+// Submits each batch as an ImportCapitalAccounts transaction through submit
+func ChaincodeSubmitter(submit func(name string, args ...string) ([]byte, error)) Submitter {
+	return func(fundId string, rows []types.ImportRow) ([]types.ImportRowResult, error) {
+		rowsJSON, err := json.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		result, err := submit("ImportCapitalAccounts", fundId, string(rowsJSON))
+		if err != nil {
+			return nil, err
+		}
+		var results []types.ImportRowResult
+		err = json.Unmarshal(result, &results)
+		if err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+}
+
+// Converts the records of a sheet whose first record is the header into rows of
+// the fund. Column names ignore case, spaces, underscores and hyphens, and the columns may
+// come in any order. Problems are returned together as a ValidationError with a
+// detail per field of each row, keyed by types.ImportFieldKey.
+func ParseRows(fundId string, records []Record) ([]types.ImportRow, error) {
+	if len(records) == 0 {
+		return nil, types.FieldErrors{"header": "the sheet is empty"}.Err()
+	}
+	columns, err := parseHeader(records[0])
+	if err != nil {
+		return nil, err
+	}
+	rows := []types.ImportRow{}
+	errs := types.FieldErrors{}
+	references := map[string]int{}
+	investors := map[string]types.ImportRow{}
+	for _, record := range records[1:] {
+		value := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(record.Fields) {
+				return ""
+			}
+			return strings.TrimSpace(record.Fields[index])
+		}
+		check := func(field string, message string) {
+			errs.Check(types.ImportFieldKey(record.Line, field), message)
+		}
+
+		row := types.ImportRow{
+			Line:            record.Line,
+			Reference:       value(COLUMN_REFERENCE),
+			InvestorId:      value(COLUMN_INVESTOR_ID),
+			InvestorName:    value(COLUMN_INVESTOR_NAME),
+			PerformanceRate: value(COLUMN_PERFORMANCE_RATE),
+			FixedFee:        value(COLUMN_FIXED_FEE),
+			DepositAmount:   value(COLUMN_DEPOSIT_AMOUNT),
+			DepositDate:     spreadsheetDate(value(COLUMN_DEPOSIT_DATE)),
+		}
+		if row.PerformanceRate == "" {
+			row.PerformanceRate = "0"
+		}
+		//rows without a management fee rate get the rate of accounts created one by one
+		if row.FixedFee == "" {
+			row.FixedFee = types.DEFAULT_FIXED_FEE
+		}
+		hasPerformanceFees, ok := parseBool(value(COLUMN_HAS_PERFORMANCE_FEES))
+		if !ok {
+			check(COLUMN_HAS_PERFORMANCE_FEES, "must be true or false")
+		}
+		row.HasPerformanceFees = hasPerformanceFees
+		for field, message := range types.ValidateImportRow(&row, row.InvestorId != "") {
+			check(field, message)
+		}
+		if first, ok := references[row.Reference]; ok && row.Reference != "" {
+			check(COLUMN_REFERENCE, fmt.Sprintf("duplicates line %d", first))
+		} else {
+			references[row.Reference] = row.Line
+		}
+
+		//rows naming the same investor must agree on the name
+		if row.InvestorId != "" && row.InvestorName != "" {
+			if first, ok := investors[row.InvestorId]; !ok {
+				investors[row.InvestorId] = row
+			} else if first.InvestorName != row.InvestorName {
+				check(COLUMN_INVESTOR_NAME, fmt.Sprintf("differs from the name of investor %s on line %d", row.InvestorId, first.Line))
+			}
+		}
+
+		if row.InvestorId == "" {
+			row.InvestorId = deriveId(fundId, "investor", row.Reference)
+		}
+		row.CapitalAccountId = deriveId(fundId, "capitalAccount", row.Reference)
+		if row.DepositAmount != "" {
+			row.DepositId = deriveId(fundId, "deposit", row.Reference)
+		}
+		rows = append(rows, row)
+	}
+	err = errs.Err()
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Maps each known column to its index, failing on unknown or repeated columns
+func parseHeader(header Record) (map[string]int, error) {
+	known := map[string]string{}
+	for _, column := range COLUMNS {
+		known[normalizeColumn(column)] = column
+	}
+	columns := map[string]int{}
+	errs := types.FieldErrors{}
+	for i, name := range header.Fields {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		column, ok := known[normalizeColumn(name)]
+		if !ok {
+			errs.Check(types.ImportFieldKey(header.Line, name), "unknown column, the columns are "+strings.Join(COLUMNS, ", "))
+			continue
+		}
+		if _, repeated := columns[column]; repeated {
+			errs.Check(types.ImportFieldKey(header.Line, name), "repeats the "+column+" column")
+			continue
+		}
+		columns[column] = i
+	}
+	if _, ok := columns[COLUMN_REFERENCE]; !ok {
+		errs.Check(types.ImportFieldKey(header.Line, COLUMN_REFERENCE), "the column is required")
+	}
+	_, hasId := columns[COLUMN_INVESTOR_ID]
+	_, hasName := columns[COLUMN_INVESTOR_NAME]
+	if !hasId && !hasName {
+		errs.Check(types.ImportFieldKey(header.Line, COLUMN_INVESTOR_NAME), "the column is required without an investorId column")
+	}
+	return columns, errs.Err()
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.TrimSpace(name)))
+}
+
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "", "false", "no", "n", "0":
+		return false, true
+	case "true", "yes", "y", "1":
+		return true, true
+	}
+	return false, false
+}
+
+// Converts a date stored as a spreadsheet serial number, leaving anything else to
+// the date check
+func spreadsheetDate(value string) string {
+	serial, err := strconv.Atoi(value)
+	if err != nil || serial <= 0 || serial > MAX_SPREADSHEET_SERIAL {
+		return value
+	}
+	return spreadsheetEpoch.AddDate(0, 0, serial).Format(types.DATE_FORMAT)
+}
+
+func deriveId(fundId string, kind string, reference string) string {
+	return uuid.NewV5(importNamespace, fundId+":"+kind+":"+reference).String()
+}
+
+// Submits the rows in batches of batchSize, stopping at the first batch that
+// fails. The report covers the batches that were committed before the failure,
+// and a coded error gets the number of rows they hold as its committedRows detail.
+func Import(fundId string, rows []types.ImportRow, batchSize int, submit Submitter) (*types.ImportReport, error) {
+	if batchSize <= 0 {
+		batchSize = types.DEFAULT_IMPORT_BATCH_SIZE
+	}
+	if batchSize > types.MAX_IMPORT_BATCH_SIZE {
+		batchSize = types.MAX_IMPORT_BATCH_SIZE
+	}
+	report := &types.ImportReport{Fund: fundId, Rows: []types.ImportRowResult{}}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		results, err := submit(fundId, rows[start:end])
+		if err != nil {
+			if coded, ok := pkgErrors.From(err); ok {
+				err = coded.WithDetail("committedRows", strconv.Itoa(len(report.Rows)))
+			}
+			return report, err
+		}
+		report.Add(results)
+	}
+	return report, nil
+}
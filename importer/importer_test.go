@@ -0,0 +1,299 @@
+package importer_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/importer"
+	"github.com/zacharyfrederick/admin/local"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const SHEET string = `Reference,Investor Name,has_performance_fees,performanceRate,depositAmount,depositDate
+LP-1,Alice,yes,0.2,1000,01-01-2020
+LP-2,Bob,no,,2500.50,01-01-2020
+
+LP-3,Carol,,,,
+`
+
+func parse(t *testing.T, sheet string) []types.ImportRow {
+	records, err := importer.ReadSheet(strings.NewReader(sheet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := importer.ParseRows("fund", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestParseRows(t *testing.T) {
+	rows := parse(t, SHEET)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, 2, rows[0].Line)
+	assert.True(t, rows[0].HasPerformanceFees)
+	assert.Equal(t, "0.2", rows[0].PerformanceRate)
+	assert.Equal(t, 5, rows[2].Line)
+	assert.Equal(t, "0", rows[2].PerformanceRate)
+	assert.Equal(t, types.DEFAULT_FIXED_FEE, rows[2].FixedFee)
+	assert.Empty(t, rows[2].DepositId)
+
+	//the ids depend only on the fund and the reference
+	again := parse(t, SHEET)
+	assert.Equal(t, rows[0].InvestorId, again[0].InvestorId)
+	assert.Equal(t, rows[0].CapitalAccountId, again[0].CapitalAccountId)
+	assert.Equal(t, rows[0].DepositId, again[0].DepositId)
+	assert.NotEqual(t, rows[0].CapitalAccountId, rows[1].CapitalAccountId)
+}
+
+func TestParseRowsReportsEveryProblem(t *testing.T) {
+	records, err := importer.ReadSheet(strings.NewReader(`reference,investorName,hasPerformanceFees,depositAmount,depositDate
+LP-1,,maybe,-5,01-01-2020
+LP-1,Bob,,100,
+`))
+	assert.Nil(t, err)
+	_, err = importer.ParseRows("fund", records)
+	assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+	coded, _ := pkgErrors.From(err)
+	assert.Equal(t, map[string]string{
+		"line 2: investorName":       "is required",
+		"line 2: hasPerformanceFees": "must be true or false",
+		"line 2: depositAmount":      "must be greater than zero",
+		"line 3: reference":          "duplicates line 2",
+		"line 3: depositDate":        "must use the MM-DD-YYYY format",
+	}, coded.Details)
+
+	records, err = importer.ReadSheet(strings.NewReader("ref,investorName\n"))
+	assert.Nil(t, err)
+	_, err = importer.ParseRows("fund", records)
+	coded, _ = pkgErrors.From(err)
+	assert.Contains(t, coded.Details, "line 1: ref")
+	assert.Contains(t, coded.Details, "line 1: reference")
+}
+
+// A workbook as Excel writes it: shared strings, an inline string, a number and a
+// date stored as a serial number, with an empty cell skipped
+func workbook(t *testing.T) []byte {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="LPs" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>reference</t></si><si><t>investorName</t></si><si><t>depositAmount</t></si><si><t>depositDate</t></si>
+<si><r><t>Alice </t></r><r><t>Smith</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>
+<row r="3"><c r="A3" t="inlineStr"><is><t>LP-1</t></is></c><c r="B3" t="s"><v>4</v></c><c r="C3"><v>1000</v></c><c r="D3" s="1"><v>43831</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>LP-2</t></is></c><c r="C4"><v>5</v></c></row>
+</sheetData></worksheet>`,
+	}
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(content))
+	}
+	archive.Close()
+	return buffer.Bytes()
+}
+
+func TestReadWorkbook(t *testing.T) {
+	records, err := importer.ReadSheet(bytes.NewReader(workbook(t)))
+	assert.Nil(t, err)
+	assert.Equal(t, []importer.Record{
+		{Line: 1, Fields: []string{"reference", "investorName", "depositAmount", "depositDate"}},
+		{Line: 3, Fields: []string{"LP-1", "Alice Smith", "1000", "43831"}},
+		{Line: 4, Fields: []string{"LP-2", "", "5"}},
+	}, records)
+
+	rows, err := importer.ParseRows("fund", records[:2])
+	assert.Nil(t, err)
+	assert.Equal(t, "01-01-2020", rows[0].DepositDate)
+}
+
+func TestImportIsResumable(t *testing.T) {
+	backend, err := local.Open("")
+	assert.Nil(t, err)
+	_, err = backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Nil(t, err)
+	rows := parse(t, SHEET)
+
+	//the second batch fails and the first stays committed
+	calls := 0
+	flaky := func(fundId string, batch []types.ImportRow) ([]types.ImportRowResult, error) {
+		calls++
+		if calls == 2 {
+			return nil, pkgErrors.ReadingWorldStateError
+		}
+		return importer.ChaincodeSubmitter(backend.SubmitTransaction)(fundId, batch)
+	}
+	report, err := importer.Import("fund", rows, 2, flaky)
+	assert.True(t, errors.Is(err, pkgErrors.ReadingWorldStateError))
+	coded, _ := pkgErrors.From(err)
+	assert.Equal(t, "2", coded.Details["committedRows"])
+	assert.Equal(t, 2, report.Imported)
+
+	report, err = importer.Import("fund", rows, 2, importer.ChaincodeSubmitter(backend.SubmitTransaction))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Batches)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, 1, report.Imported)
+	assert.Equal(t, types.IMPORT_STATUS_IMPORTED, report.Rows[2].Status)
+
+	result, err := backend.EvaluateTransaction("QueryCapitalAccountsByFundWithPagination", "fund", "10", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, strings.Count(string(result), `"docType":"capitalAccount"`))
+	result, err = backend.EvaluateTransaction("QueryCapitalAccountActionById", rows[1].DepositId)
+	assert.Nil(t, err)
+	assert.Contains(t, string(result), `"amount":"2500.50"`)
+}
+
+func TestImportRejectsChangedRows(t *testing.T) {
+	backend, _ := local.Open("")
+	_, err := backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Nil(t, err)
+	submit := importer.ChaincodeSubmitter(backend.SubmitTransaction)
+	_, err = importer.Import("fund", parse(t, SHEET), 0, submit)
+	assert.Nil(t, err)
+
+	//the deposit of LP-2 changed since it was imported
+	changed := parse(t, strings.Replace(SHEET, "2500.50", "2600", 1))
+	_, err = importer.Import("fund", changed, 0, submit)
+	assert.True(t, errors.Is(err, pkgErrors.IdAlreadyInUseError))
+	coded, _ := pkgErrors.From(err)
+	assert.Equal(t, "3", coded.Details["line"])
+	assert.Equal(t, "0", coded.Details["committedRows"])
+
+	_, err = importer.Import("missing", parse(t, SHEET), 0, submit)
+	assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+}
+
+func TestImportNumbersAccountsInSheetOrder(t *testing.T) {
+	backend, _ := local.Open("")
+	_, err := backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Nil(t, err)
+	sheet := "reference,investorName,depositAmount,depositDate\n"
+	for _, name := range []string{"Gina", "Hal", "Ida", "Jon", "Kim", "Lea"} {
+		sheet += "LP-" + name + "," + name + ",1000,01-01-2020\n"
+	}
+	rows := parse(t, sheet)
+	_, err = importer.Import("fund", rows, 0, importer.ChaincodeSubmitter(backend.SubmitTransaction))
+	assert.Nil(t, err)
+	_, err = backend.SubmitTransaction("BootstrapFund", "fund")
+	assert.Nil(t, err)
+
+	//every row is created in the same transaction, so only the sheet orders them
+	for i, row := range rows {
+		result, err := backend.EvaluateTransaction("QueryCapitalAccountById", row.CapitalAccountId)
+		assert.Nil(t, err)
+		var account types.CapitalAccount
+		assert.Nil(t, json.Unmarshal(result, &account))
+		assert.Equal(t, i, account.Number, row.Reference)
+	}
+}
+
+func TestParseRowsRejectsConflictingInvestorNames(t *testing.T) {
+	records, err := importer.ReadSheet(strings.NewReader(`reference,investorId,investorName
+LP-1,alice,Alice
+LP-2,alice,Alice
+LP-3,alice,
+LP-4,alice,Alicia
+`))
+	assert.Nil(t, err)
+	_, err = importer.ParseRows("fund", records)
+	coded, _ := pkgErrors.From(err)
+	assert.Equal(t, map[string]string{
+		"line 5: investorName": "differs from the name of investor alice on line 2",
+	}, coded.Details)
+}
+
+func TestImportRejectsConflictingInvestorNamesInABatch(t *testing.T) {
+	backend, _ := local.Open("")
+	_, err := backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Nil(t, err)
+	submit := importer.ChaincodeSubmitter(backend.SubmitTransaction)
+
+	//rows that skipped ParseRows still cannot rename an investor the batch created
+	rows := parse(t, "reference,investorId,investorName\nLP-1,alice,Alice\nLP-2,alice,Alice\n")
+	conflicting := append([]types.ImportRow{}, rows...)
+	conflicting[1].InvestorName = "Alicia"
+	_, err = submit("fund", conflicting)
+	assert.True(t, errors.Is(err, pkgErrors.IdAlreadyInUseError))
+	coded, _ := pkgErrors.From(err)
+	assert.Equal(t, "3", coded.Details["line"])
+
+	results, err := submit("fund", rows)
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	result, err := backend.EvaluateTransaction("QueryInvestorById", "alice")
+	assert.Nil(t, err)
+	assert.Contains(t, string(result), `"name":"Alice"`)
+}
+
+func TestImportRejectsABootstrappedFund(t *testing.T) {
+	backend, _ := local.Open("")
+	_, err := backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Nil(t, err)
+	submit := importer.ChaincodeSubmitter(backend.SubmitTransaction)
+	_, err = importer.Import("fund", parse(t, SHEET), 0, submit)
+	assert.Nil(t, err)
+	_, err = backend.SubmitTransaction("BootstrapFund", "fund")
+	assert.Nil(t, err)
+
+	//an account imported now would have no ownership for the period being closed
+	late := parse(t, "reference,investorName,depositAmount,depositDate\nLP-4,Dan,1000,01-31-2020\n")
+	_, err = importer.Import("fund", late, 0, submit)
+	assert.True(t, errors.Is(err, pkgErrors.CannotImportCapitalAccountsError))
+	result, err := backend.EvaluateTransaction("QueryCapitalAccountById", late[0].CapitalAccountId)
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+
+	_, err = backend.SubmitTransaction("CreatePortfolio", "portfolio", "fund", "Main")
+	assert.Nil(t, err)
+	_, err = backend.SubmitTransaction("CreatePortfolioAction", "buy", "portfolio", "buy", "01-31-2020", "1", "ACME", "000000000", "100", "USD")
+	assert.Nil(t, err)
+	_, err = backend.SubmitTransaction("UpdatePortfolioValuation", "portfolio", "01-31-2020", "ACME", "40")
+	assert.Nil(t, err)
+	_, err = backend.SubmitTransaction("StepFund", "fund")
+	assert.Nil(t, err)
+}
+
+func TestImportSetsFixedFees(t *testing.T) {
+	backend, _ := local.Open("")
+	_, err := backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Nil(t, err)
+	submit := importer.ChaincodeSubmitter(backend.SubmitTransaction)
+	sheet := "reference,investorName,fixedFee\nLP-1,Alice,0.015\nLP-2,Bob,\n"
+	rows := parse(t, sheet)
+	_, err = importer.Import("fund", rows, 0, submit)
+	assert.Nil(t, err)
+	for i, expected := range []string{"0.015", types.DEFAULT_FIXED_FEE} {
+		result, err := backend.EvaluateTransaction("QueryCapitalAccountById", rows[i].CapitalAccountId)
+		assert.Nil(t, err)
+		var account types.CapitalAccount
+		assert.Nil(t, json.Unmarshal(result, &account))
+		assert.Equal(t, expected, account.FixedFee)
+	}
+
+	_, err = importer.Import("fund", parse(t, strings.Replace(sheet, "0.015", "0.0150", 1)), 0, submit)
+	assert.Nil(t, err)
+	_, err = importer.Import("fund", parse(t, strings.Replace(sheet, "0.015", "0.01", 1)), 0, submit)
+	assert.True(t, errors.Is(err, pkgErrors.IdAlreadyInUseError))
+
+	records, err := importer.ReadSheet(strings.NewReader("reference,investorName,fixedFee\nLP-1,Alice,1.5\n"))
+	assert.Nil(t, err)
+	_, err = importer.ParseRows("fund", records)
+	coded, _ := pkgErrors.From(err)
+	assert.Equal(t, map[string]string{"line 2: fixedFee": "must be between 0 and 1"}, coded.Details)
+}
@@ -0,0 +1,232 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var NoWorksheetError = errors.New("the workbook has no worksheet")
+
+// A row of a spreadsheet and the line or row number it was read from
+type Record struct {
+	Line   int
+	Fields []string
+}
+
+// Reads a CSV file or the first worksheet of an Excel workbook (.xlsx), told
+// apart by the zip signature of the workbook. Blank rows are skipped.
+func ReadSheet(r io.Reader) ([]Record, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readWorkbook(data)
+	}
+	return readCSV(data)
+}
+
+func readCSV(data []byte) ([]Record, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records := []Record{}
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if !blank(fields) {
+			records = append(records, Record{Line: line, Fields: fields})
+		}
+	}
+}
+
+func blank(fields []string) bool {
+	for _, field := range fields {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		Id   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// A shared string is either plain text or runs of formatted text
+type sharedString struct {
+	Text string   `xml:"t"`
+	Runs []string `xml:"r>t"`
+}
+
+func (s sharedString) String() string {
+	if len(s.Runs) > 0 {
+		return strings.Join(s.Runs, "")
+	}
+	return s.Text
+}
+
+type worksheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string       `xml:"r,attr"`
+			Type   string       `xml:"t,attr"`
+			Value  string       `xml:"v"`
+			Inline sharedString `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Reads the cell values of the first worksheet as text. Numbers are kept as
+// written in the file, so dates formatted as dates arrive as serial numbers.
+func readWorkbook(data []byte) ([]Record, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var book workbook
+	err = decodeXML(files, "xl/workbook.xml", &book)
+	if err != nil {
+		return nil, err
+	}
+	if len(book.Sheets) == 0 {
+		return nil, NoWorksheetError
+	}
+	var rels relationships
+	err = decodeXML(files, "xl/_rels/workbook.xml.rels", &rels)
+	if err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.Id == book.Sheets[0].Id {
+			sheetPath = rel.Target
+		}
+	}
+	if sheetPath == "" {
+		return nil, NoWorksheetError
+	}
+	//targets are relative to xl/ unless they start at the root of the package
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	sharedStrings := []string{}
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		var table struct {
+			Items []sharedString `xml:"si"`
+		}
+		err = decodeXML(files, "xl/sharedStrings.xml", &table)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range table.Items {
+			sharedStrings = append(sharedStrings, item.String())
+		}
+	}
+
+	var sheet worksheet
+	err = decodeXML(files, sheetPath, &sheet)
+	if err != nil {
+		return nil, err
+	}
+	records := []Record{}
+	for i, row := range sheet.Rows {
+		number := row.Number
+		if number == 0 {
+			number = i + 1
+		}
+		fields := []string{}
+		for j, cell := range row.Cells {
+			column := j
+			if cell.Ref != "" {
+				column, err = columnIndex(cell.Ref)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for len(fields) <= column {
+				fields = append(fields, "")
+			}
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(sharedStrings) {
+					return nil, fmt.Errorf("cell %s refers to a missing shared string", cell.Ref)
+				}
+				fields[column] = sharedStrings[index]
+			case "inlineStr":
+				fields[column] = cell.Inline.String()
+			default:
+				fields[column] = cell.Value
+			}
+		}
+		if !blank(fields) {
+			records = append(records, Record{Line: number, Fields: fields})
+		}
+	}
+	return records, nil
+}
+
+func decodeXML(files map[string]*zip.File, name string, v interface{}) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("the workbook has no %s", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return xml.NewDecoder(reader).Decode(v)
+}
+
+// The zero based column of a cell reference such as AB12
+func columnIndex(ref string) (int, error) {
+	column := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A') + 1
+		letters++
+	}
+	if letters == 0 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return column - 1, nil
+}
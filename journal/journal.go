@@ -0,0 +1,167 @@
+// Package journal writes the journal of a fund period in the formats general
+// ledger software imports: a CSV with a row per line, and an IIF file of general
+// journal transactions with the accounts of the chart ahead of them.
+package journal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/zacharyfrederick/admin/types"
+)
+
+const FORMAT_JSON string = "json"
+const FORMAT_CSV string = "csv"
+const FORMAT_IIF string = "iif"
+
+const CSV_MEDIA_TYPE string = "text/csv"
+const IIF_MEDIA_TYPE string = "application/x-iif"
+
+const IIF_DATE_FORMAT string = "01/02/2006"
+const IIF_TRANSACTION_TYPE string = "GENERAL JOURNAL"
+
+var UnsupportedFormatError = errors.New("the journal format is not supported")
+
+var CSV_COLUMNS = []string{"entry", "date", "period", "source", "reference", "description", "account", "accountName", "capitalAccount", "debit", "credit"}
+
+// The IIF account type of each type of account
+var IIF_ACCOUNT_TYPES = map[string]string{
+	types.GL_TYPE_ASSET:     "OCASSET",
+	types.GL_TYPE_LIABILITY: "OCLIAB",
+	types.GL_TYPE_EQUITY:    "EQUITY",
+	types.GL_TYPE_INCOME:    "INC",
+	types.GL_TYPE_EXPENSE:   "EXP",
+}
+
+// The media type of an export format
+func MediaType(format string) (string, error) {
+	switch format {
+	case FORMAT_CSV:
+		return CSV_MEDIA_TYPE, nil
+	case FORMAT_IIF:
+		return IIF_MEDIA_TYPE, nil
+	}
+	return "", fmt.Errorf("%w: %q", UnsupportedFormatError, format)
+}
+
+func Write(w io.Writer, format string, journal *types.Journal) error {
+	switch format {
+	case FORMAT_CSV:
+		return WriteCSV(w, journal)
+	case FORMAT_IIF:
+		return WriteIIF(w, journal)
+	}
+	return fmt.Errorf("%w: %q", UnsupportedFormatError, format)
+}
+
+func WriteCSV(w io.Writer, journal *types.Journal) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(CSV_COLUMNS)
+	if err != nil {
+		return err
+	}
+	for _, entry := range journal.Entries {
+		for _, line := range entry.Lines {
+			err = writer.Write([]string{
+				entry.ID,
+				entry.Date,
+				strconv.Itoa(entry.Period),
+				entry.Source,
+				entry.Reference,
+				entry.Description,
+				line.Account,
+				line.AccountName,
+				line.CapitalAccount,
+				line.Debit,
+				line.Credit,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the accounts of the journal and a general journal transaction per entry.
+// Amounts are signed, debits positive, and the memo of an investor capital line
+// names its capital account.
+func WriteIIF(w io.Writer, journal *types.Journal) error {
+	rows := [][]string{{"!ACCNT", "NAME", "ACCNTTYPE", "ACCNUM"}}
+	for _, account := range journal.Accounts {
+		rows = append(rows, []string{"ACCNT", account.Name, IIF_ACCOUNT_TYPES[account.Type], account.Number})
+	}
+	rows = append(rows,
+		[]string{"!TRNS", "TRNSID", "TRNSTYPE", "DATE", "ACCNT", "AMOUNT", "DOCNUM", "MEMO"},
+		[]string{"!SPL", "SPLID", "TRNSTYPE", "DATE", "ACCNT", "AMOUNT", "DOCNUM", "MEMO"},
+		[]string{"!ENDTRNS"},
+	)
+	for _, entry := range journal.Entries {
+		date, err := time.Parse(types.DATE_FORMAT, entry.Date)
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", entry.ID, err)
+		}
+		for i, line := range entry.Lines {
+			amount, err := lineAmount(line)
+			if err != nil {
+				return fmt.Errorf("entry %s: %w", entry.ID, err)
+			}
+			memo := entry.Description
+			if line.CapitalAccount != "" {
+				memo = fmt.Sprintf("%s (%s)", memo, line.CapitalAccount)
+			}
+			kind := "SPL"
+			if i == 0 {
+				kind = "TRNS"
+			}
+			rows = append(rows, []string{kind, "", IIF_TRANSACTION_TYPE, date.Format(IIF_DATE_FORMAT), line.AccountName, amount, entry.ID, memo})
+		}
+		rows = append(rows, []string{"ENDTRNS"})
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = iifField(row[i])
+		}
+		_, err := io.WriteString(w, strings.Join(row, "\t")+"\r\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lineAmount(line *types.JournalLine) (string, error) {
+	debit, err := decimal.NewFromString(line.Debit)
+	if err != nil {
+		return "", fmt.Errorf("debit of account %s: %w", line.Account, err)
+	}
+	credit, err := decimal.NewFromString(line.Credit)
+	if err != nil {
+		return "", fmt.Errorf("credit of account %s: %w", line.Account, err)
+	}
+	amount := debit.Sub(credit)
+	return amount.StringFixed(int32(decimalPlaces(line.Debit, line.Credit))), nil
+}
+
+// The decimal places of the wider amount, so amounts keep the fund's precision
+func decimalPlaces(amounts ...string) int {
+	places := 0
+	for _, amount := range amounts {
+		if i := strings.IndexByte(amount, '.'); i >= 0 && len(amount)-i-1 > places {
+			places = len(amount) - i - 1
+		}
+	}
+	return places
+}
+
+// Fields are tab separated and rows end at a newline, and quotes confuse importers
+func iifField(field string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ", "\"", "'").Replace(field)
+}
@@ -0,0 +1,65 @@
+package journal_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/journal"
+	"github.com/zacharyfrederick/admin/types"
+)
+
+func period() *types.Journal {
+	return &types.Journal{
+		Fund:     "fund",
+		Period:   0,
+		Closed:   true,
+		Accounts: types.ChartOfAccounts{}.Accounts(),
+		Entries: []*types.JournalEntry{{
+			ID:          "deposit",
+			Fund:        "fund",
+			Date:        "01-31-2020",
+			Source:      types.JOURNAL_SOURCE_CAPITAL_ACCOUNT_ACTION,
+			Reference:   "deposit",
+			Description: "Deposit to capital account\tLP-1",
+			Lines: []*types.JournalLine{
+				{Account: "1000", AccountName: "Cash", Debit: "1000.00", Credit: "0.00"},
+				{Account: "2100", AccountName: "Capital received in advance", Debit: "0.00", Credit: "1000.00", CapitalAccount: "LP-1"},
+			},
+		}},
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, journal.Write(&buffer, journal.FORMAT_CSV, period()))
+	rows, err := csv.NewReader(&buffer).ReadAll()
+	assert.Nil(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, journal.CSV_COLUMNS, rows[0])
+	assert.Equal(t, []string{"deposit", "01-31-2020", "0", "capitalAccountAction", "deposit", "Deposit to capital account\tLP-1", "2100", "Capital received in advance", "LP-1", "0.00", "1000.00"}, rows[2])
+}
+
+func TestWriteIIF(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, journal.Write(&buffer, journal.FORMAT_IIF, period()))
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\r\n"), "\r\n")
+	accounts := len(types.DefaultChartOfAccounts())
+	assert.Len(t, lines, 1+accounts+3+3)
+	assert.Equal(t, "!ACCNT\tNAME\tACCNTTYPE\tACCNUM", lines[0])
+	assert.Equal(t, "ACCNT\tCash\tOCASSET\t1000", lines[1])
+	assert.Equal(t, "ACCNT\tManagement fees\tEXP\t5100", lines[accounts])
+	assert.Equal(t, "TRNS\t\tGENERAL JOURNAL\t01/31/2020\tCash\t1000.00\tdeposit\tDeposit to capital account LP-1", lines[accounts+4])
+	assert.Equal(t, "SPL\t\tGENERAL JOURNAL\t01/31/2020\tCapital received in advance\t-1000.00\tdeposit\tDeposit to capital account LP-1 (LP-1)", lines[accounts+5])
+	assert.Equal(t, "ENDTRNS", lines[accounts+6])
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	err := journal.Write(&bytes.Buffer{}, "qif", period())
+	assert.True(t, errors.Is(err, journal.UnsupportedFormatError))
+	_, err = journal.MediaType(journal.FORMAT_JSON)
+	assert.True(t, errors.Is(err, journal.UnsupportedFormatError))
+}
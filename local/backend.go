@@ -0,0 +1,221 @@
+// Package local runs AdminContract in-process against the memstub world state,
+// so the REST server can serve the full API without a Fabric network. The state
+// can be saved to a file after every transaction and reloaded on start.
+//
+// Transactions run one at a time, so there are no read conflicts, and nothing is
+// endorsed or signed: every caller submits as the same anonymous client. Only the
+// world state is saved, not the key history or events.
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/serializer"
+	uuid "github.com/satori/go.uuid"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+)
+
+const SNAPSHOT_VERSION int = 1
+
+const CHANNEL_ID string = "local"
+
+var UnsupportedSnapshotError = errors.New("unsupported local state file version")
+
+var contextType = reflect.TypeOf((*contractapi.TransactionContext)(nil))
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// The file the world state is saved to
+type snapshot struct {
+	Version int               `json:"version"`
+	SavedAt time.Time         `json:"savedAt"`
+	State   map[string][]byte `json:"state"`
+}
+
+// Calls the transactions of AdminContract by name with string arguments, as a
+// peer does. Safe for concurrent use.
+type Backend struct {
+	mu           sync.Mutex
+	stub         *memstub.Stub
+	contract     reflect.Value
+	transactions map[string]reflect.Method
+	serializer   serializer.JSONSerializer
+	path         string
+}
+
+// Opens a backend saving its state to path, starting from the state already saved
+// there if the file exists. The state is only kept in memory when path is empty.
+func Open(path string) (*Backend, error) {
+	stub := memstub.New()
+	stub.ChannelID = CHANNEL_ID
+	if path != "" {
+		state, err := readSnapshot(path)
+		if err != nil {
+			return nil, err
+		}
+		stub.LoadState(state)
+	}
+	contract := &smartcontract.AdminContract{}
+	return &Backend{
+		stub:         stub,
+		contract:     reflect.ValueOf(contract),
+		transactions: transactions(reflect.TypeOf(contract)),
+		path:         path,
+	}, nil
+}
+
+// The methods of the contract that take a transaction context, which are the ones
+// contractapi exposes as transactions
+func transactions(contractType reflect.Type) map[string]reflect.Method {
+	methods := map[string]reflect.Method{}
+	for i := 0; i < contractType.NumMethod(); i++ {
+		method := contractType.Method(i)
+		//the first input is the receiver
+		if method.Type.NumIn() < 2 || method.Type.In(1).Kind() != reflect.Interface || !contextType.Implements(method.Type.In(1)) {
+			continue
+		}
+		methods[method.Name] = method
+	}
+	return methods
+}
+
+// Runs the transaction without keeping its writes
+func (b *Backend) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return b.invoke(false, name, args)
+}
+
+// Runs the transaction and saves the state with its writes applied. The writes are
+// only applied once the state is saved.
+func (b *Backend) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	return b.invoke(true, name, args)
+}
+
+func (b *Backend) invoke(commit bool, name string, args []string) ([]byte, error) {
+	method, ok := b.transactions[name]
+	if !ok {
+		return nil, fmt.Errorf("function %s not found in contract AdminContract", name)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	invokeArgs := [][]byte{[]byte(name)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+	b.stub.SetTime(time.Now().UTC())
+	err := b.stub.Begin(uuid.NewV4().String(), invokeArgs)
+	if err != nil {
+		return nil, err
+	}
+	result, err := b.call(method, args)
+	if err != nil || !commit {
+		b.stub.Rollback()
+		return result, err
+	}
+	//the snapshot is written first, so a transaction reported as failed is never
+	//left committed in memory
+	if b.path != "" {
+		err = writeSnapshot(b.path, b.stub.PendingState())
+		if err != nil {
+			b.stub.Rollback()
+			return nil, err
+		}
+	}
+	err = b.stub.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Converts the arguments and the result the way the contractapi serializer does,
+// so the endpoints see the same payloads as from a peer. A transaction that panics
+// fails with an error, so its writes are rolled back like those of any failure.
+func (b *Backend) call(method reflect.Method, args []string) (result []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("transaction %s panicked: %v", method.Name, r)
+		}
+	}()
+	parameters := method.Type.NumIn() - 2
+	if len(args) != parameters {
+		return nil, fmt.Errorf("incorrect number of params. Expected %d, received %d", parameters, len(args))
+	}
+	in := []reflect.Value{b.contract, reflect.ValueOf(memstub.NewTransactionContext(b.stub))}
+	for i, arg := range args {
+		value, err := b.serializer.FromString(arg, method.Type.In(i+2), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error managing parameter param%d. %s", i, err.Error())
+		}
+		in = append(in, value)
+	}
+	out := method.Func.Call(in)
+	if len(out) > 0 && method.Type.Out(len(out)-1) == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	serialized, err := b.serializer.ToString(out[0], method.Type.Out(0), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(serialized), nil
+}
+
+func readSnapshot(path string) (map[string][]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return map[string][]byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved snapshot
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("reading local state %s: %w", path, err)
+	}
+	if saved.Version != SNAPSHOT_VERSION {
+		return nil, fmt.Errorf("%w %d in %s", UnsupportedSnapshotError, saved.Version, path)
+	}
+	return saved.State, nil
+}
+
+// Writes the state to a temporary file renamed over the previous one, so a crash
+// never leaves a partly written file behind
+func writeSnapshot(path string, state map[string][]byte) error {
+	data, err := json.Marshal(snapshot{Version: SNAPSHOT_VERSION, SavedAt: time.Now().UTC(), State: state})
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	closeErr := temp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
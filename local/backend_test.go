@@ -0,0 +1,114 @@
+package local_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/local"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func submit(t *testing.T, backend *local.Backend, name string, args ...string) []byte {
+	result, err := backend.SubmitTransaction(name, args...)
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	return result
+}
+
+func TestSubmitAndEvaluate(t *testing.T) {
+	backend, err := local.Open("")
+	assert.Nil(t, err)
+
+	submit(t, backend, "CreateFund", "fund", "Test Fund", "01-01-2020")
+	submit(t, backend, "CreateInvestor", "investor", "Investor")
+	//bool and int arguments are converted as the contractapi does
+	submit(t, backend, "CreateCapitalAccount", "account", "fund", "investor", "true", "0.2")
+	submit(t, backend, "CreateCapitalAccountAction", "deposit", "account", "deposit", "1000", "false", "01-01-2020", "0")
+
+	result, err := backend.EvaluateTransaction("QueryFundById", "fund")
+	assert.Nil(t, err)
+	var fund types.Fund
+	assert.Nil(t, json.Unmarshal(result, &fund))
+	assert.Equal(t, "Test Fund", fund.Name)
+
+	result, err = backend.EvaluateTransaction("QueryCapitalAccountsByFundWithPagination", "fund", "10", "")
+	assert.Nil(t, err)
+	var page types.CapitalAccountPage
+	assert.Nil(t, json.Unmarshal(result, &page))
+	assert.Len(t, page.Records, 1)
+
+	//a missing document is an empty payload, as from a peer
+	result, err = backend.EvaluateTransaction("QueryFundById", "missing")
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+func TestEvaluateDiscardsWrites(t *testing.T) {
+	backend, _ := local.Open("")
+	_, err := backend.EvaluateTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Nil(t, err)
+	result, err := backend.EvaluateTransaction("QueryFundById", "fund")
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+func TestErrorsKeepTheirCodes(t *testing.T) {
+	backend, _ := local.Open("")
+	_, err := backend.SubmitTransaction("BootstrapFund", "missing")
+	assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+
+	submit(t, backend, "CreateFund", "fund", "Test Fund", "01-01-2020")
+	_, err = backend.SubmitTransaction("CreateFund", "fund", "Other Fund", "01-01-2020")
+	assert.True(t, errors.Is(err, pkgErrors.IdAlreadyInUseError))
+
+	_, err = backend.SubmitTransaction("DeleteEverything")
+	assert.EqualError(t, err, "function DeleteEverything not found in contract AdminContract")
+	_, err = backend.SubmitTransaction("CreateFund", "fund")
+	assert.Error(t, err)
+	_, err = backend.SubmitTransaction("QueryFunds", "many", "")
+	assert.Error(t, err)
+	//methods of the embedded contractapi.Contract are not transactions
+	_, err = backend.EvaluateTransaction("GetName")
+	assert.Error(t, err)
+}
+
+func TestStateIsSavedAfterEachTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	backend, err := local.Open(path)
+	assert.Nil(t, err)
+	submit(t, backend, "CreateFund", "fund", "Test Fund", "01-01-2020")
+	_, err = backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Error(t, err)
+
+	reopened, err := local.Open(path)
+	assert.Nil(t, err)
+	result, err := reopened.EvaluateTransaction("QueryFundById", "fund")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, result)
+
+	err = ioutil.WriteFile(path, []byte(`{"version":2,"state":{}}`), 0600)
+	assert.Nil(t, err)
+	_, err = local.Open(path)
+	assert.True(t, errors.Is(err, local.UnsupportedSnapshotError))
+}
+
+func TestFailedSaveDiscardsTheTransaction(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	assert.Nil(t, os.Mkdir(dir, 0700))
+	backend, err := local.Open(filepath.Join(dir, "ledger.json"))
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(dir))
+
+	_, err = backend.SubmitTransaction("CreateFund", "fund", "Test Fund", "01-01-2020")
+	assert.Error(t, err)
+	result, err := backend.EvaluateTransaction("QueryFundById", "fund")
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
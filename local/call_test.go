@@ -0,0 +1,51 @@
+package local
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+)
+
+// Writes its key and then panics when the value is "panic"
+type panickingContract struct{}
+
+func (c *panickingContract) Put(ctx contractapi.TransactionContextInterface, key string, value string) error {
+	err := ctx.GetStub().PutState(key, []byte(value))
+	if err != nil {
+		return err
+	}
+	if value == "panic" {
+		panic("invalid value")
+	}
+	return nil
+}
+
+func (c *panickingContract) Get(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	value, err := ctx.GetStub().GetState(key)
+	return string(value), err
+}
+
+func TestPanicsRollBackTheTransaction(t *testing.T) {
+	contract := &panickingContract{}
+	backend := &Backend{
+		stub:         memstub.New(),
+		contract:     reflect.ValueOf(contract),
+		transactions: transactions(reflect.TypeOf(contract)),
+	}
+
+	_, err := backend.SubmitTransaction("Put", "staged", "panic")
+	assert.EqualError(t, err, "transaction Put panicked: invalid value")
+
+	//the next transaction commits only its own writes
+	_, err = backend.SubmitTransaction("Put", "other", "value")
+	assert.Nil(t, err)
+	result, err := backend.EvaluateTransaction("Get", "staged")
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+	result, err = backend.EvaluateTransaction("Get", "other")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", string(result))
+}
@@ -3,6 +3,7 @@ import json
 
 from .fund import Fund
 from .investor import Investor
+from .utils import print_error_msg, auth_headers
 
 class CapitalAccount:
     url = "http://localhost:8080/capitalaccounts"
@@ -22,14 +23,14 @@ class CapitalAccount:
             "performanceRate": performanceRate,
         }
 
-        r = requests.post(url=cls.url, data=json.dumps(data), headers=cls.headers)
+        r = requests.post(url=cls.url, data=json.dumps(data), headers=auth_headers(cls.headers))
 
         if r.status_code == 200:
             new_account = CapitalAccount(r.json()['capitalAccountId'], fund, investor)
             print("new capital account created: ", json.dumps(new_account.__dict__, indent=4))
             return new_account
         else:
-            print(r.text)
+            print_error_msg(r)
             return None
         
 if __name__ == '__main__':
@@ -4,6 +4,7 @@ import json
 from .fund import Fund
 from .investor import Investor
 from .capital_account import CapitalAccount
+from .utils import print_error_msg, idempotent_headers, auth_headers
 
 
 class CapitalAccountAction:
@@ -20,7 +21,7 @@ class CapitalAccountAction:
         self.period = period
 
     @classmethod
-    def create_capital_account_action(cls, capital_account, type_, amount, full, date, period):
+    def create_capital_account_action(cls, capital_account, type_, amount, full, date, period, idempotency_key=None):
         data = {
             "capitalAccount": capital_account,
             "type": type_,
@@ -30,13 +31,13 @@ class CapitalAccountAction:
             "period": period
         }
         r = requests.post(url=cls.url, data=json.dumps(data),
-                          headers=cls.headers)
+                          headers=auth_headers(idempotent_headers(cls.headers, idempotency_key)))
         if r.status_code == 200:
             new_action = CapitalAccountAction(r.json()['transactionId'], capital_account, type_, amount, full, date, period)
             print("created capital account action: ", json.dumps(new_action.__dict__, indent=4))
             return new_action
         else:
-            print(r.text)
+            print_error_msg(r)
             return None
 
 
@@ -2,7 +2,7 @@ import requests
 import json
 from termcolor import colored
 
-from .utils import print_creation_msg
+from .utils import print_creation_msg, print_error_msg, auth_headers
 
 class Fund:
     url = "http://localhost:8080/funds"
@@ -15,19 +15,19 @@ class Fund:
 
     def read(self): 
         endpoint = Fund.url + "/" + self.id
-        r = requests.get(url=endpoint)
+        r = requests.get(url=endpoint, headers=auth_headers())
         if r.status_code == 200:
             print("fund bootstrap successful: ", json.dumps(r.json(), indent=4))
         else:
-            print(r.text)
+            print_error_msg(r)
 
     def bootstrap(self):
         endpoint = Fund.url + "/" + self.id + "/bootstrap"
-        r = requests.get(url=endpoint)
+        r = requests.get(url=endpoint, headers=auth_headers())
         if r.status_code == 200:
             self.read()
         else:
-            print(r.text)
+            print_error_msg(r)
 
     @classmethod
     def create(cls, name, inceptionDate):
@@ -35,13 +35,13 @@ class Fund:
             "name": name,
             "inceptionDate": inceptionDate
         }
-        r = requests.post(url=cls.url, data=json.dumps(data), headers=cls.headers)
+        r = requests.post(url=cls.url, data=json.dumps(data), headers=auth_headers(cls.headers))
         if r.status_code == 200:
             new_fund = Fund(r.json()['fundId'], name, inceptionDate)
             print_creation_msg(new_fund, "fund")
             return new_fund
         else:
-            print(r.text)
+            print_error_msg(r)
             return None
         
 if __name__ == '__main__':
@@ -1,5 +1,6 @@
 import requests
 import json
+from .utils import print_error_msg, auth_headers
 
 class Investor:
     url = "http://localhost:8080/investors"
@@ -15,13 +16,13 @@ class Investor:
             "name": name
         }
         r = requests.post(url=Investor.url, data=json.dumps(
-            data), headers=cls.headers)
+            data), headers=auth_headers(cls.headers))
         if r.status_code == 200:
             new_investor = Investor(r.json()['investorId'], name)
             print("created new investor: ", json.dumps(new_investor.__dict__, indent=4))
             return new_investor
         else:
-            print(r.text)
+            print_error_msg(r)
             return None
 
 
@@ -3,6 +3,7 @@ import json
 
 from .fund import Fund
 from .investor import Investor
+from .utils import print_error_msg, auth_headers
 
 class Portfolio:
     url = "http://localhost:8080/portfolios"
@@ -15,11 +16,11 @@ class Portfolio:
 
     def read(self): 
         endpoint = Portfolio.url + "/" + self.id
-        r = requests.get(url=endpoint)
+        r = requests.get(url=endpoint, headers=auth_headers())
         if r.status_code == 200:
             print("portfolio read successful: ", json.dumps(r.json(), indent=4))
         else:
-            print(r.text)
+            print_error_msg(r)
 
     @classmethod
     def create_portfolio(cls, fund, name):
@@ -27,13 +28,13 @@ class Portfolio:
             "fund": fund,
             "name": name
         }
-        r = requests.post(url=cls.url, data=json.dumps(data), headers=cls.headers)
+        r = requests.post(url=cls.url, data=json.dumps(data), headers=auth_headers(cls.headers))
         if r.status_code == 200:
             new_portfolio = Portfolio(r.json()['portfolioId'], fund, name)
             print("new portfolio created: ", json.dumps(new_portfolio.__dict__, indent=4))
             return new_portfolio
         else:
-            print(r.text)
+            print_error_msg(r)
             return None
         
 if __name__ == '__main__':
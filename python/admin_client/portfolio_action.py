@@ -4,6 +4,7 @@ import json
 from .fund import Fund
 from .investor import Investor
 from .portfolio import Portfolio
+from .utils import print_error_msg, idempotent_headers, auth_headers
 
 class PortfolioAction:
     url = "http://localhost:8080/portfolioactions"
@@ -21,7 +22,7 @@ class PortfolioAction:
         self.currency = currency
 
     @classmethod
-    def create_portfolio_action(cls, portfolio, type_, date, period, name, cusip, amount, currency):
+    def create_portfolio_action(cls, portfolio, type_, date, period, name, cusip, amount, currency, idempotency_key=None):
         data = {
             "portfolio": portfolio,
             "type": type_,
@@ -32,13 +33,13 @@ class PortfolioAction:
             "amount": amount,
             "currency": currency
         }
-        r = requests.post(url=cls.url, data=json.dumps(data), headers=cls.headers)
+        r = requests.post(url=cls.url, data=json.dumps(data), headers=auth_headers(idempotent_headers(cls.headers, idempotency_key)))
         if r.status_code == 200:
             new_action = PortfolioAction(r.json()['transactionId'], portfolio, type_, date, period, name, cusip, amount, currency)
             print("new portfolio action created", json.dumps(new_action.__dict__, indent=4))
             return new_action
         else:
-            print(r.text)
+            print_error_msg(r)
             return None
         
 if __name__ == '__main__':
@@ -51,9 +52,9 @@ if __name__ == '__main__':
     portfolio = Portfolio.create_portfolio(fund.id, "test_portfolio1")
     assert portfolio != None, "Portfolio could not be created"
     
-    buy = PortfolioAction.create_portfolio_action(portfolio.id, type_="buy", date="12-27-1996", period=0, name="AAPL", cusip="100", amount="100", currency="USD")
+    buy = PortfolioAction.create_portfolio_action(portfolio.id, type_="buy", date="12-27-1996", period=0, name="AAPL", cusip="037833100", amount="100", currency="USD")
     assert buy != None, "could not create buy action"
 
-    sell = PortfolioAction.create_portfolio_action(portfolio.id, type_="buy", date="12-28-1996", period=0, name="AMZN", cusip="100", amount="50", currency="USD")
+    sell = PortfolioAction.create_portfolio_action(portfolio.id, type_="buy", date="12-28-1996", period=0, name="AMZN", cusip="023135106", amount="50", currency="USD")
     assert sell != None, "could not create sell action"
     
\ No newline at end of file
@@ -0,0 +1,26 @@
+import requests
+import time
+from .utils import print_error_msg, auth_headers
+
+class Transaction:
+    url = "http://localhost:8080/transactions"
+
+    @classmethod
+    def get_transaction(cls, tx_id):
+        r = requests.get(url="{}/{}".format(cls.url, tx_id), headers=auth_headers())
+        if r.status_code == 200:
+            return r.json()
+        else:
+            print_error_msg(r)
+            return None
+
+    @classmethod
+    def wait_for_transaction(cls, tx_id, interval=0.5, timeout=60):
+        # polls a transaction submitted with ?async=true until it leaves the pending status
+        deadline = time.time() + timeout
+        while time.time() < deadline:
+            transaction = cls.get_transaction(tx_id)
+            if transaction is None or transaction["status"] != "pending":
+                return transaction
+            time.sleep(interval)
+        return None
@@ -1,6 +1,32 @@
 from termcolor import colored
 import json
+import os
 
 def print_creation_msg(x, name):
     msg = "new {} created: ".format(name)
-    print(colored(msg, "green"), json.dumps(x.__dict__, indent=4))
\ No newline at end of file
+    print(colored(msg, "green"), json.dumps(x.__dict__, indent=4))
+
+def idempotent_headers(headers, idempotency_key):
+    # a retry with the same key returns the original result instead of creating a duplicate
+    if idempotency_key is None:
+        return headers
+    return dict(headers, **{"Idempotency-Key": idempotency_key})
+
+def auth_headers(headers=None):
+    # the server takes an API key from ADMIN_API_KEY or a bearer token from ADMIN_TOKEN
+    headers = dict(headers or {})
+    if os.environ.get("ADMIN_API_KEY"):
+        headers["X-API-Key"] = os.environ["ADMIN_API_KEY"]
+    elif os.environ.get("ADMIN_TOKEN"):
+        headers["Authorization"] = "Bearer " + os.environ["ADMIN_TOKEN"]
+    return headers
+
+def print_error_msg(r):
+    # errors are returned as {"error": {"code": ..., "message": ..., "details": ...}}
+    try:
+        error = r.json()["error"]
+    except (ValueError, KeyError, TypeError):
+        print(colored("error {}: ".format(r.status_code), "red"), r.text)
+        return
+    msg = "error {} {}: ".format(r.status_code, error.get("code"))
+    print(colored(msg, "red"), error.get("message"), error.get("details", ""))
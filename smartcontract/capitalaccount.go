@@ -1,9 +1,6 @@
 package smartcontract
 
 import (
-	"encoding/json"
-	"fmt"
-
 	"github.com/zacharyfrederick/admin/types"
 	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
 	"github.com/zacharyfrederick/admin/utils"
@@ -17,42 +14,38 @@ func (s *AdminContract) CreateCapitalAccount(
 	hasPerformanceFees bool,
 	performanceFeeRate string,
 ) error {
+	err := types.ValidateCreateCapitalAccountRequest(&types.CreateCapitalAccountRequest{
+		Fund:               fundId,
+		Investor:           investorId,
+		HasPerformanceFees: hasPerformanceFees,
+		PerformanceRate:    performanceFeeRate,
+	})
+	if err != nil {
+		return err
+	}
 	idInUse, err := utils.AssetExists(ctx, capitalAccountId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if idInUse {
-		return smartcontracterrors.IdAlreadyInUseError
+		return smartcontracterrors.IdAlreadyInUseError.WithDetail("id", capitalAccountId)
 	}
 	fund, err := s.QueryFundById(ctx, fundId)
 	if err != nil {
 		return err
 	}
 	if fund == nil {
-		return smartcontracterrors.FundNotFoundError
+		return smartcontracterrors.FundNotFoundError.WithDetail("fund", fundId)
 	}
-	fund.IncrementInvestorNumber()
 	investor, err := s.QueryInvestorById(ctx, investorId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if investor == nil {
-		return smartcontracterrors.InvestorNotFoundError
-	}
-	err = SaveState(ctx, fund)
-	if err != nil {
-		return err
+		return smartcontracterrors.InvestorNotFoundError.WithDetail("investor", investorId)
 	}
-	capitalAccount := types.CreateDefaultCapitalAccount(
-		fund.NextInvestorNumber-1,
-		fund.CurrentPeriod,
-		capitalAccountId,
-		fundId,
-		investorId,
-		hasPerformanceFees,
-		performanceFeeRate,
-	)
-	return SaveState(ctx, &capitalAccount)
+	_, err = createCapitalAccount(ctx, fund, capitalAccountId, investorId, hasPerformanceFees, performanceFeeRate, types.DEFAULT_FIXED_FEE, 0)
+	return err
 }
 
 func (s *AdminContract) MidYearDeposit(
@@ -63,43 +56,74 @@ func (s *AdminContract) MidYearDeposit(
 	hasPerformanceFees bool,
 	performanceFeeRate string,
 ) error {
+	err := types.ValidateCreateCapitalAccountRequest(&types.CreateCapitalAccountRequest{
+		Fund:               fundId,
+		Investor:           investorId,
+		HasPerformanceFees: hasPerformanceFees,
+		PerformanceRate:    performanceFeeRate,
+	})
+	if err != nil {
+		return err
+	}
 	idInUse, err := utils.AssetExists(ctx, capitalAccountId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if idInUse {
-		return smartcontracterrors.IdAlreadyInUseError
+		return smartcontracterrors.IdAlreadyInUseError.WithDetail("id", capitalAccountId)
 	}
 	fund, err := s.QueryFundById(ctx, fundId)
 	if err != nil {
 		return err
 	}
 	if fund == nil {
-		return smartcontracterrors.FundNotFoundError
+		return smartcontracterrors.FundNotFoundError.WithDetail("fund", fundId)
 	}
-	fund.IncrementInvestorNumber()
 	investor, err := s.QueryInvestorById(ctx, investorId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if investor == nil {
-		return smartcontracterrors.InvestorNotFoundError
+		return smartcontracterrors.InvestorNotFoundError.WithDetail("investor", investorId)
 	}
-	fund.MidYearDeposits = append(fund.MidYearDeposits, capitalAccountId)
-	err = SaveState(ctx, fund)
+	attributes := []string{fundId, types.FormatPeriodKey(fund.CurrentPeriod), capitalAccountId}
+	err = saveMarker(ctx, types.MARKER_MIDYEARDEPOSIT, attributes, capitalAccountId)
 	if err != nil {
 		return err
 	}
+	_, err = createCapitalAccount(ctx, fund, capitalAccountId, investorId, hasPerformanceFees, performanceFeeRate, types.DEFAULT_FIXED_FEE, 0)
+	return err
+}
+
+// The fund is only read, and the account is numbered when the fund is next
+// bootstrapped or stepped, so accounts can be created for a fund concurrently
+func createCapitalAccount(
+	ctx SmartContractContext,
+	fund *types.Fund,
+	capitalAccountId string,
+	investorId string,
+	hasPerformanceFees bool,
+	performanceFeeRate string,
+	fixedFee string,
+	createdOrder int,
+) (*types.CapitalAccount, error) {
+	createdAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
 	capitalAccount := types.CreateDefaultCapitalAccount(
-		fund.NextInvestorNumber-1,
+		types.UNASSIGNED_INVESTOR_NUMBER,
 		fund.CurrentPeriod,
 		capitalAccountId,
-		fundId,
+		fund.ID,
 		investorId,
 		hasPerformanceFees,
 		performanceFeeRate,
 	)
-	return SaveState(ctx, &capitalAccount)
+	capitalAccount.FixedFee = fixedFee
+	capitalAccount.CreatedAt = createdAt
+	capitalAccount.CreatedOrder = createdOrder
+	return &capitalAccount, SaveState(ctx, &capitalAccount)
 }
 
 func (s *AdminContract) CreateCapitalAccountAction(
@@ -112,24 +136,61 @@ func (s *AdminContract) CreateCapitalAccountAction(
 	date string,
 	period int,
 ) error {
-	if type_ != "deposit" && type_ != "withdrawal" {
+	if type_ != types.CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT && type_ != types.CAPITAL_ACCOUNT_ACTION_TYPE_WITHDRAWAL {
 		return smartcontracterrors.InvalidCapitalAccountActionTypeError
 	}
+	err := types.ValidateCreateCapitalAccountActionRequest(&types.CreateCapitalAccountActionRequest{
+		CapitalAccount: capitalAccountId,
+		Type:           type_,
+		Amount:         amount,
+		Full:           full,
+		Date:           date,
+		Period:         period,
+	})
+	if err != nil {
+		return err
+	}
+	idInUse, err := utils.AssetExists(ctx, transactionId)
+	if err != nil {
+		return smartcontracterrors.ReadingWorldStateError
+	}
+	if idInUse {
+		return smartcontracterrors.IdAlreadyInUseError.WithDetail("id", transactionId)
+	}
 	capitalAccount, err := s.QueryCapitalAccountById(ctx, capitalAccountId)
 	if err != nil {
 		return err
 	}
 	if capitalAccount == nil {
-		return smartcontracterrors.CapitalAccountNotFoundError
+		return smartcontracterrors.CapitalAccountNotFoundError.WithDetail("capitalAccount", capitalAccountId)
 	}
+	var fund *types.Fund
 	if capitalAccount.HasPerformanceFees {
-		fund, err := s.QueryFundById(ctx, capitalAccount.Fund)
+		fund, err = s.QueryFundById(ctx, capitalAccount.Fund)
 		if err != nil {
 			return err
 		}
 		if fund == nil {
-			return smartcontracterrors.FundNotFoundError
+			return smartcontracterrors.FundNotFoundError.WithDetail("fund", capitalAccount.Fund)
 		}
+	}
+	return createCapitalAccountAction(ctx, fund, capitalAccount, transactionId, type_, amount, full, date, period)
+}
+
+// Saves the action on the account. The fund is only needed, and only read, when
+// the account has performance fees.
+func createCapitalAccountAction(
+	ctx SmartContractContext,
+	fund *types.Fund,
+	capitalAccount *types.CapitalAccount,
+	transactionId string,
+	type_ string,
+	amount string,
+	full bool,
+	date string,
+	period int,
+) error {
+	if capitalAccount.HasPerformanceFees {
 		if type_ == "deposit" {
 			if period%fund.PerformanceFeePeriod != 0 {
 				return smartcontracterrors.MidYearDepositError
@@ -137,8 +198,8 @@ func (s *AdminContract) CreateCapitalAccountAction(
 		}
 		if type_ == "withdrawal" {
 			if period%fund.PerformanceFeePeriod != 0 {
-				fund.MidYearWithdrawals = append(fund.MidYearWithdrawals, capitalAccountId)
-				err := SaveState(ctx, fund)
+				attributes := []string{fund.ID, capitalAccount.ID}
+				err := saveMarker(ctx, types.MARKER_MIDYEARWITHDRAWAL, attributes, capitalAccount.ID)
 				if err != nil {
 					return err
 				}
@@ -147,14 +208,15 @@ func (s *AdminContract) CreateCapitalAccountAction(
 	}
 	capitalAccountAction := types.CreateDefaultCapitalAccountAction(
 		transactionId,
-		capitalAccountId,
+		capitalAccount.Fund,
+		capitalAccount.ID,
 		type_,
 		amount,
 		full,
 		date,
 		period,
 	)
-	return capitalAccountAction.SaveState(ctx)
+	return SaveState(ctx, &capitalAccountAction)
 }
 
 func (s *AdminContract) QueryCapitalAccountById(
@@ -169,7 +231,7 @@ func (s *AdminContract) QueryCapitalAccountById(
 		return nil, nil
 	}
 	var capitalAccount types.CapitalAccount
-	err = json.Unmarshal(data, &capitalAccount)
+	err = LoadState(ctx, data, &capitalAccount)
 	if err != nil {
 		return nil, err
 	}
@@ -181,12 +243,17 @@ func (s *AdminContract) QueryCapitalAccountsByInvestor(
 	fundId string,
 	investorId string,
 ) ([]*types.CapitalAccount, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccount", "fund": "%s", "investor": "%s"}}`,
-		fundId,
-		investorId,
-	)
-	return executeCapitalAccountQuery(ctx, queryString)
+	capitalAccounts, err := queryCapitalAccountsByFundIndex(ctx, fundId)
+	if err != nil {
+		return nil, err
+	}
+	var investorAccounts []*types.CapitalAccount
+	for _, capitalAccount := range capitalAccounts {
+		if capitalAccount.Investor == investorId {
+			investorAccounts = append(investorAccounts, capitalAccount)
+		}
+	}
+	return investorAccounts, nil
 }
 
 func (s *AdminContract) QueryCapitalAccountsByFund(
@@ -196,23 +263,46 @@ func (s *AdminContract) QueryCapitalAccountsByFund(
 	return queryCapitalAccountsByFund(ctx, fundId)
 }
 
+// Lists the capital accounts of a fund in id order
+func (s *AdminContract) QueryCapitalAccountsByFundWithPagination(
+	ctx SmartContractContext,
+	fundId string,
+	pageSize int32,
+	bookmark string,
+) (*types.CapitalAccountPage, error) {
+	capitalAccounts := []*types.CapitalAccount{}
+	bookmark, err := queryIndexPage(ctx, types.INDEX_CAPITALACCOUNT, []string{fundId}, pageSize, bookmark, func(data []byte) (bool, error) {
+		var capitalAccount types.CapitalAccount
+		err := LoadState(ctx, data, &capitalAccount)
+		if err != nil {
+			return false, err
+		}
+		capitalAccounts = append(capitalAccounts, &capitalAccount)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.CapitalAccountPage{
+		Records:             capitalAccounts,
+		FetchedRecordsCount: int32(len(capitalAccounts)),
+		Bookmark:            bookmark,
+	}
+	return page, nil
+}
+
 func queryCapitalAccountsByFund(
 	ctx SmartContractContext,
 	fundId string,
 ) ([]*types.CapitalAccount, error) {
-	queryString := fmt.Sprintf(`{"selector":{"docType":"capitalAccount", "fund": "%s"}}`, fundId)
-	return executeCapitalAccountQuery(ctx, queryString)
+	return queryCapitalAccountsByFundIndex(ctx, fundId)
 }
 
 func (s *AdminContract) QueryCapitalAccountActionsByFund(
 	ctx SmartContractContext,
 	fundId string,
 ) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "fund": "%s"}}`,
-		fundId,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
+	return queryCapitalAccountActionsByFundIndex(ctx, fundId)
 }
 
 func (s *AdminContract) QueryCapitalAccountActionsByFundPeriod(
@@ -220,12 +310,7 @@ func (s *AdminContract) QueryCapitalAccountActionsByFundPeriod(
 	fundId string,
 	period int,
 ) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "fund": "%s", "period": "%d"}}`,
-		fundId,
-		period,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
+	return queryCapitalAccountActionsByFundPeriodIndex(ctx, fundId, period)
 }
 
 func (s *AdminContract) QueryCapitalAccountActionsByAccountPeriod(
@@ -234,13 +319,74 @@ func (s *AdminContract) QueryCapitalAccountActionsByAccountPeriod(
 	capitalAccountId string,
 	period int,
 ) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "fund": "%s", "capitalAccount": "%s", "period": "%d"}}`,
-		fundId,
-		capitalAccountId,
-		period,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
+	return queryCapitalAccountActionsByAccountPeriodIndex(ctx, fundId, capitalAccountId, period)
+}
+
+// Lists the actions of a capital account in period order. The index is keyed by
+// fund first, so the account is loaded for its fund, and an account that does not
+// exist has no actions.
+func (s *AdminContract) QueryCapitalAccountActionsByAccountWithPagination(
+	ctx SmartContractContext,
+	capitalAccountId string,
+	pageSize int32,
+	bookmark string,
+) (*types.CapitalAccountActionPage, error) {
+	capitalAccount, err := s.QueryCapitalAccountById(ctx, capitalAccountId)
+	if err != nil {
+		return nil, err
+	}
+	if capitalAccount == nil {
+		return &types.CapitalAccountActionPage{Records: []*types.CapitalAccountAction{}}, nil
+	}
+	attributes := []string{capitalAccount.Fund, capitalAccountId}
+	return queryCapitalAccountActionPage(ctx, attributes, &types.ActionFilter{}, pageSize, bookmark)
+}
+
+func (s *AdminContract) QueryCapitalAccountActionsByFundWithPagination(
+	ctx SmartContractContext,
+	fundId string,
+	filterJSON string,
+	pageSize int32,
+	bookmark string,
+) (*types.CapitalAccountActionPage, error) {
+	filter, err := parseActionFilter(filterJSON)
+	if err != nil {
+		return nil, err
+	}
+	return queryCapitalAccountActionPage(ctx, []string{fundId}, filter, pageSize, bookmark)
+}
+
+// Loads a page of the actions matching the filter under the partial index key,
+// ordered by capital account and then period
+func queryCapitalAccountActionPage(
+	ctx SmartContractContext,
+	attributes []string,
+	filter *types.ActionFilter,
+	pageSize int32,
+	bookmark string,
+) (*types.CapitalAccountActionPage, error) {
+	capitalAccountActions := []*types.CapitalAccountAction{}
+	bookmark, err := queryIndexPage(ctx, types.INDEX_CAPITALACCOUNTACTION, attributes, pageSize, bookmark, func(data []byte) (bool, error) {
+		var capitalAccountAction types.CapitalAccountAction
+		err := LoadState(ctx, data, &capitalAccountAction)
+		if err != nil {
+			return false, err
+		}
+		if !filter.Matches(capitalAccountAction.Period, capitalAccountAction.Type, capitalAccountAction.Status, capitalAccountAction.Date) {
+			return false, nil
+		}
+		capitalAccountActions = append(capitalAccountActions, &capitalAccountAction)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.CapitalAccountActionPage{
+		Records:             capitalAccountActions,
+		FetchedRecordsCount: int32(len(capitalAccountActions)),
+		Bookmark:            bookmark,
+	}
+	return page, nil
 }
 
 func (s *AdminContract) QueryCapitalAccountActionById(
@@ -255,7 +401,7 @@ func (s *AdminContract) QueryCapitalAccountActionById(
 		return nil, nil
 	}
 	var capitalAccountAction types.CapitalAccountAction
-	err = json.Unmarshal(data, &capitalAccountAction)
+	err = LoadState(ctx, data, &capitalAccountAction)
 	if err != nil {
 		return nil, err
 	}
@@ -267,12 +413,7 @@ func QueryCapitalAccountActionsByFundPeriod(
 	fundId string,
 	period int,
 ) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "fund": "%s", "period": "%d"}}`,
-		fundId,
-		period,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
+	return queryCapitalAccountActionsByFundPeriodIndex(ctx, fundId, period)
 }
 
 func QueryDepositsByFundPeriod(
@@ -280,12 +421,11 @@ func QueryDepositsByFundPeriod(
 	fundId string,
 	period int,
 ) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "fund": "%s", "period": "%d", "type": "deposit"}}`,
-		fundId,
-		period,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
+	actions, err := queryCapitalAccountActionsByFundPeriodIndex(ctx, fundId, period)
+	if err != nil {
+		return nil, err
+	}
+	return filterCapitalAccountActionsByType(actions, "deposit"), nil
 }
 
 func QueryWithdrawalsByFundPeriod(
@@ -293,90 +433,35 @@ func QueryWithdrawalsByFundPeriod(
 	fundId string,
 	period int,
 ) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "fund": "%s", "period": "%d", "type": "withdrawal"}}`,
-		fundId,
-		period,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
+	actions, err := queryCapitalAccountActionsByFundPeriodIndex(ctx, fundId, period)
+	if err != nil {
+		return nil, err
+	}
+	return filterCapitalAccountActionsByType(actions, "withdrawal"), nil
 }
 
 func QueryDepositsByFundAccountPeriod(
 	ctx SmartContractContext,
+	fundId string,
 	capitalAccountId string,
 	period int,
 ) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "period": %d, "type": "deposit", "capitalAccount": "%s"}}`,
-		period,
-		capitalAccountId,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
-}
-
-func QueryWithdrawalsByFundAccountPeriod(
-	ctx SmartContractContext,
-	capitalAccountId string,
-	period int,
-) ([]*types.CapitalAccountAction, error) {
-	queryString := fmt.Sprintf(
-		`{"selector":{"docType":"capitalAccountAction", "period": %d, "type": "withdrawal", "capitalAccount": "%s"}}`,
-		period,
-		capitalAccountId,
-	)
-	return executeCapitalAccountActionQuery(ctx, queryString)
-}
-
-func executeCapitalAccountQuery(
-	ctx SmartContractContext,
-	queryString string,
-) ([]*types.CapitalAccount, error) {
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	actions, err := queryCapitalAccountActionsByAccountPeriodIndex(ctx, fundId, capitalAccountId, period)
 	if err != nil {
 		return nil, err
 	}
-
-	defer resultsIterator.Close()
-
-	var capitalAccounts []*types.CapitalAccount
-	for resultsIterator.HasNext() {
-		queryResult, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var capitalAccount types.CapitalAccount
-		err = json.Unmarshal(queryResult.Value, &capitalAccount)
-		if err != nil {
-			return nil, err
-		}
-		capitalAccounts = append(capitalAccounts, &capitalAccount)
-	}
-
-	return capitalAccounts, nil
+	return filterCapitalAccountActionsByType(actions, "deposit"), nil
 }
 
-func executeCapitalAccountActionQuery(
+func QueryWithdrawalsByFundAccountPeriod(
 	ctx SmartContractContext,
-	queryString string,
+	fundId string,
+	capitalAccountId string,
+	period int,
 ) ([]*types.CapitalAccountAction, error) {
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	actions, err := queryCapitalAccountActionsByAccountPeriodIndex(ctx, fundId, capitalAccountId, period)
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-	var capitalAccountActions []*types.CapitalAccountAction
-	for resultsIterator.HasNext() {
-		queryResult, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-		var capitalAccountAction types.CapitalAccountAction
-		err = json.Unmarshal(queryResult.Value, &capitalAccountAction)
-		if err != nil {
-			return nil, err
-		}
-		capitalAccountActions = append(capitalAccountActions, &capitalAccountAction)
-	}
-	return capitalAccountActions, nil
+	return filterCapitalAccountActionsByType(actions, "withdrawal"), nil
 }
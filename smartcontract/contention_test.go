@@ -0,0 +1,131 @@
+package smartcontract_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+)
+
+// Onboarding transactions must not write the fund, or concurrent onboarding
+// fails with MVCC read conflicts on it
+func TestOnboardingDoesNotWriteTheFund(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "investor", "Investor")
+		},
+	)
+	fundJSON := stub.State()["fund"]
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "account", "fund", "investor", true, "0.2")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.MidYearDeposit(ctx, "midYearAccount", "fund", "investor", true, "0.2")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "deposit", "account", "deposit", "1000", false, "01-01-2020", 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "withdrawal", "account", "withdrawal", "100", false, "03-31-2020", 3)
+		},
+	)
+	assert.Equal(t, string(fundJSON), string(stub.State()["fund"]))
+}
+
+// The general partner is the first account created, whatever its id
+func TestBootstrapFundNumbersAccountsInCreationOrder(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	steps := []func(ctx contractapi.TransactionContextInterface) error{
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+	}
+	for _, id := range []string{"zz", "mm", "aa"} {
+		id := id
+		steps = append(steps,
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateInvestor(ctx, id, id)
+			},
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccount(ctx, id+"Account", "fund", id, false, "0")
+			},
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccountAction(ctx, id+"Deposit", id+"Account", "deposit", "1000", false, "01-01-2020", 0)
+			},
+		)
+	}
+	steps = append(steps, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := admin.BootstrapFund(ctx, "fund")
+		return err
+	})
+	transact(t, stub, steps...)
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		for number, id := range []string{"zzAccount", "mmAccount", "aaAccount"} {
+			account, err := admin.QueryCapitalAccountById(ctx, id)
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, number, account.Number, id)
+		}
+		fund, err := admin.QueryFundById(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, 3, fund.NextInvestorNumber)
+		return nil
+	})
+}
+
+func TestStepFundNumbersAccountsCreatedAfterBootstrap(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	createThirdsFund(t, stub, &admin)
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.BootstrapFund(ctx, "fund")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, "buy", "portfolio", "buy", "01-31-2020", 1, "ACME", "000000000", "1", "USD")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.UpdatePortfolioValuation(ctx, "portfolio", "01-31-2020", "ACME", "3000")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "lp3", "lp3")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "lp3Account", "fund", "lp3", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			account, err := admin.QueryCapitalAccountById(ctx, "lp3Account")
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, types.UNASSIGNED_INVESTOR_NUMBER, account.Number)
+			return nil
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.StepFund(ctx, "fund")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			account, err := admin.QueryCapitalAccountById(ctx, "lp3Account")
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, 3, account.Number)
+			return nil
+		},
+	)
+}
@@ -0,0 +1,247 @@
+package smartcontract
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/zacharyfrederick/admin/types"
+	"github.com/zacharyfrederick/admin/types/doctypes"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// The index every document of a doctype is written under. A partial key query
+// on the object type alone lists all of them.
+var exportIndexes = map[string]string{
+	doctypes.DOCTYPE_FUND:                 types.INDEX_FUND,
+	doctypes.DOCTYPE_INVESTOR:             types.INDEX_INVESTOR,
+	doctypes.DOCTYPE_CAPITALACCOUNT:       types.INDEX_CAPITALACCOUNT,
+	doctypes.DOCTYPE_CAPITALACCOUNTACTION: types.INDEX_CAPITALACCOUNTACTION,
+	doctypes.DOCTYPE_PORTFOLIO:            types.INDEX_PORTFOLIO,
+	doctypes.DOCTYPE_PORTFOLIOACTION:      types.INDEX_PORTFOLIOACTION,
+	doctypes.DOCTYPE_RISKLESSRATE:         types.INDEX_RISKLESSRATE,
+	doctypes.DOCTYPE_SECURITY:             types.INDEX_SECURITY,
+}
+
+// Returns a page of every document of docType exactly as stored, in index key
+// order. It relies on the composite index keys, so documents stored before they
+// existed need MigrateIndexKeys first. Partial key queries with pagination are
+// only allowed in queries, so this has to be evaluated rather than submitted.
+func (s *AdminContract) ExportDocuments(
+	ctx SmartContractContext,
+	docType string,
+	pageSize int32,
+	bookmark string,
+) (*types.ExportPage, error) {
+	objectType, ok := exportIndexes[docType]
+	if !ok {
+		return nil, smartcontracterrors.InvalidDocTypeError.WithDetail("docType", docType)
+	}
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		objectType,
+		[]string{},
+		types.NormalizePageSize(pageSize),
+		bookmark,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	documents := []*types.ExportedDocument{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		id := string(queryResult.Value)
+		data, err := ctx.GetStub().GetState(id)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			return nil, smartcontracterrors.ReadingWorldStateError.WithDetail("id", id)
+		}
+		documents = append(documents, &types.ExportedDocument{ID: id, Document: string(data)})
+	}
+	page := &types.ExportPage{
+		Records:             documents,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}
+	return page, nil
+}
+
+// Returns a page of the marker keys of objectType, one of types.EXPORT_MARKERS, in
+// key order. Markers are kept for every period a fund has closed, so there are too
+// many to return at once.
+func (s *AdminContract) ExportMarkers(
+	ctx SmartContractContext,
+	objectType string,
+	pageSize int32,
+	bookmark string,
+) (*types.ExportMarkerPage, error) {
+	if !contains(types.EXPORT_MARKERS, objectType) {
+		return nil, smartcontracterrors.InvalidDocTypeError.WithDetail("objectType", objectType)
+	}
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		objectType,
+		[]string{},
+		types.NormalizePageSize(pageSize),
+		bookmark,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	markers := []*types.ExportedMarker{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		markers = append(markers, &types.ExportedMarker{
+			ObjectType: objectType,
+			Attributes: attributes,
+			Value:      string(queryResult.Value),
+		})
+	}
+	page := &types.ExportMarkerPage{
+		Records:             markers,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}
+	return page, nil
+}
+
+// Writes exported documents of docType together with their index keys. Documents
+// are upgraded to the current schema version first, so an archive of an older
+// ledger restores into a newer one. A document that is already stored as it
+// would be restored is skipped, so a failed restore can be run again from the
+// start; any other document with the same id fails the batch.
+func (s *AdminContract) RestoreDocuments(
+	ctx SmartContractContext,
+	docType string,
+	documents []string,
+) (*types.RestoreResult, error) {
+	if _, ok := exportIndexes[docType]; !ok {
+		return nil, smartcontracterrors.InvalidDocTypeError.WithDetail("docType", docType)
+	}
+	result := &types.RestoreResult{Section: docType}
+	//reads do not see the writes of the same transaction, so repeated ids are caught here
+	restored := map[string]bool{}
+	for _, document := range documents {
+		model, err := restoredModel(ctx, docType, []byte(document))
+		if err != nil {
+			return nil, err
+		}
+		id := model.GetID()
+		if restored[id] {
+			return nil, smartcontracterrors.IdAlreadyInUseError.WithDetail("id", id)
+		}
+		restored[id] = true
+
+		stored, err := ctx.GetStub().GetState(id)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			same, err := sameDocument(ctx, docType, model, stored)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				return nil, smartcontracterrors.IdAlreadyInUseError.WithDetail("id", id)
+			}
+			result.Skipped += 1
+			continue
+		}
+		err = SaveState(ctx, model)
+		if err != nil {
+			return nil, err
+		}
+		result.Restored += 1
+	}
+	return result, nil
+}
+
+// Decodes an exported document, checking it is of docType and has an id
+func restoredModel(ctx SmartContractContext, docType string, data []byte) (Modeler, error) {
+	var header struct {
+		DocType string `json:"docType"`
+		ID      string `json:"id"`
+	}
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return nil, smartcontracterrors.LoadStateError
+	}
+	if header.DocType != docType {
+		return nil, smartcontracterrors.InvalidDocTypeError.WithDetail("id", header.ID).WithDetail("docType", header.DocType)
+	}
+	if header.ID == "" {
+		return nil, smartcontracterrors.LoadStateError.WithDetail("docType", docType)
+	}
+	model, err := newModelForDocType(docType)
+	if err != nil {
+		return nil, err
+	}
+	err = LoadState(ctx, data, model)
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// Compares a restored document with the stored one at the current schema version
+func sameDocument(ctx SmartContractContext, docType string, model Modeler, stored []byte) (bool, error) {
+	existing, err := newModelForDocType(docType)
+	if err != nil {
+		return false, err
+	}
+	err = LoadState(ctx, stored, existing)
+	if err != nil {
+		return false, err
+	}
+	modelJSON, err := model.ToJSON()
+	if err != nil {
+		return false, smartcontracterrors.SaveStateError
+	}
+	existingJSON, err := existing.ToJSON()
+	if err != nil {
+		return false, smartcontracterrors.SaveStateError
+	}
+	return bytes.Equal(modelJSON, existingJSON), nil
+}
+
+// Writes exported marker keys, skipping those already stored
+func (s *AdminContract) RestoreMarkers(
+	ctx SmartContractContext,
+	markers []types.ExportedMarker,
+) (*types.RestoreResult, error) {
+	result := &types.RestoreResult{Section: types.EXPORT_MARKERS_SECTION}
+	for _, marker := range markers {
+		if !contains(types.EXPORT_MARKERS, marker.ObjectType) {
+			return nil, smartcontracterrors.InvalidDocTypeError.WithDetail("objectType", marker.ObjectType)
+		}
+		key, err := ctx.GetStub().CreateCompositeKey(marker.ObjectType, marker.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return nil, err
+		}
+		if string(stored) == marker.Value {
+			result.Skipped += 1
+			continue
+		}
+		err = ctx.GetStub().PutState(key, []byte(marker.Value))
+		if err != nil {
+			return nil, err
+		}
+		result.Restored += 1
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package smartcontract_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func TestExportMarkersIsPaginated(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "investor", "Investor")
+		},
+	)
+	for _, id := range []string{"account1", "account2", "account3"} {
+		accountId := id
+		transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.MidYearDeposit(ctx, accountId, "fund", "investor", false, "0")
+		})
+	}
+
+	var first, second *types.ExportMarkerPage
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		first, err = admin.ExportMarkers(ctx, types.MARKER_MIDYEARDEPOSIT, 2, "")
+		if err != nil {
+			return err
+		}
+		second, err = admin.ExportMarkers(ctx, types.MARKER_MIDYEARDEPOSIT, 2, first.Bookmark)
+		return err
+	})
+	assert.Len(t, first.Records, 2)
+	assert.NotEmpty(t, first.Bookmark)
+	assert.Len(t, second.Records, 1)
+	values := []string{}
+	for _, marker := range append(first.Records, second.Records...) {
+		assert.Equal(t, types.MARKER_MIDYEARDEPOSIT, marker.ObjectType)
+		values = append(values, marker.Value)
+	}
+	assert.ElementsMatch(t, []string{"account1", "account2", "account3"}, values)
+
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		_, err := admin.ExportMarkers(ctx, types.INDEX_FUND, 2, "")
+		return err
+	})
+	assert.ErrorIs(t, err, smartcontracterrors.InvalidDocTypeError)
+}
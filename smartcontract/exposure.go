@@ -0,0 +1,370 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Sets the concentration limits the fund's exposure is checked against, replacing
+// the earlier ones. An empty list removes them.
+func (s *AdminContract) SetFundConcentrationLimits(
+	ctx SmartContractContext,
+	fundId string,
+	limitsJSON string,
+) error {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return err
+	}
+	if fund == nil {
+		return pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	limits := types.ConcentrationLimits{}
+	if limitsJSON != "" {
+		err = json.Unmarshal([]byte(limitsJSON), &limits)
+		if err != nil {
+			return pkgErrors.ValidationError.WithDetail("limits", "must be a list of concentration limits")
+		}
+	}
+	err = limits.Validate()
+	if err != nil {
+		return err
+	}
+	fund.ConcentrationLimits = limits
+	return SaveState(ctx, fund)
+}
+
+// Returns the exposure of the fund's portfolios by security and by each dimension
+// of the security master, its top positions and its concentration limits checked.
+// Each portfolio is taken as held and priced on its last valuation date on or
+// before date, or its most recent one when date is empty. Top is the number of
+// positions to rank, DEFAULT_TOP_POSITIONS when it is not positive.
+func (s *AdminContract) QueryExposure(
+	ctx SmartContractContext,
+	fundId string,
+	date string,
+	top int,
+) (*types.ExposureReport, error) {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return nil, err
+	}
+	if fund == nil {
+		return nil, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	var asOf time.Time
+	if date != "" {
+		asOf, err = types.ParseDate(date)
+		if err != nil {
+			return nil, pkgErrors.ValidationError.WithDetail("date", types.CheckDate(date))
+		}
+	}
+	if top <= 0 {
+		top = types.DEFAULT_TOP_POSITIONS
+	}
+	portfolios, err := queryPortfoliosByFund(ctx, fund.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(portfolios, func(i, j int) bool { return portfolios[i].ID < portfolios[j].ID })
+
+	report := &types.ExposureReport{
+		Fund:         fund.ID,
+		Date:         date,
+		Unpriced:     []string{},
+		Unclassified: []string{},
+	}
+	var latest time.Time
+	positions := map[string]*exposurePosition{}
+	cusips := []string{}
+	for _, portfolio := range portfolios {
+		held, ok := portfolioValuationDate(portfolio, date, asOf)
+		if !ok {
+			continue
+		}
+		if heldOn, err := types.ParseDate(held); err == nil && heldOn.After(latest) {
+			latest = heldOn
+		}
+		names := []string{}
+		for name := range portfolio.Assets[held] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			asset := portfolio.Assets[held][name]
+			amount, err := decimal.NewFromString(asset.Amount)
+			if err != nil {
+				return nil, pkgErrors.DecimalConversionError.WithDetail("value", asset.Amount).WithDetail("asset", name)
+			}
+			if amount.IsZero() {
+				continue
+			}
+			valued, ok := portfolio.Valuations[held][name]
+			if !ok {
+				report.Unpriced = append(report.Unpriced, portfolio.ID+"/"+name)
+				continue
+			}
+			price, err := decimal.NewFromString(valued.Price)
+			if err != nil {
+				return nil, pkgErrors.DecimalConversionError.WithDetail("value", valued.Price).WithDetail("asset", name)
+			}
+			position, ok := positions[asset.CUSIP]
+			if !ok {
+				position = &exposurePosition{cusip: asset.CUSIP, name: name}
+				positions[asset.CUSIP] = position
+				cusips = append(cusips, asset.CUSIP)
+			}
+			position.amount = position.amount.Add(amount)
+			position.value = position.value.Add(amount.Mul(price))
+		}
+	}
+	if date == "" && !latest.IsZero() {
+		report.Date = latest.Format(types.DATE_FORMAT)
+	}
+
+	securities, err := querySecurities(ctx, cusips)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(cusips)
+	ranked := []*exposurePosition{}
+	book := &exposureBucket{value: fund.ID}
+	for _, cusip := range cusips {
+		position := positions[cusip]
+		security := securities[cusip]
+		if security == nil {
+			report.Unclassified = append(report.Unclassified, cusip)
+		} else {
+			position.name = security.Name
+		}
+		position.security = security
+		book.add(position.value)
+		ranked = append(ranked, position)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].value.Abs().GreaterThan(ranked[j].value.Abs())
+	})
+
+	nav := book.net()
+	exposure := exposureWeights{policy: fund.RoundingPolicy, nav: nav}
+	report.NAV = fund.RoundingPolicy.FormatCurrency(nav)
+	report.Long = fund.RoundingPolicy.FormatCurrency(book.long)
+	report.Short = fund.RoundingPolicy.FormatCurrency(book.short)
+	report.Gross = fund.RoundingPolicy.FormatCurrency(book.gross())
+	report.Net = fund.RoundingPolicy.FormatCurrency(nav)
+	report.GrossWeight = exposure.weight(book.gross())
+	report.NetWeight = exposure.weight(nav)
+
+	dimensions := map[string][]*exposureBucket{}
+	for _, dimension := range types.EXPOSURE_DIMENSIONS {
+		dimensions[dimension] = bucketPositions(ranked, dimension)
+	}
+	report.BySecurity = exposure.buckets(dimensions[types.EXPOSURE_DIMENSION_SECURITY])
+	report.ByAssetClass = exposure.buckets(dimensions[types.EXPOSURE_DIMENSION_ASSET_CLASS])
+	report.BySector = exposure.buckets(dimensions[types.EXPOSURE_DIMENSION_SECTOR])
+	report.ByCountry = exposure.buckets(dimensions[types.EXPOSURE_DIMENSION_COUNTRY])
+	report.ByIssuer = exposure.buckets(dimensions[types.EXPOSURE_DIMENSION_ISSUER])
+
+	report.TopPositions = []*types.ExposurePosition{}
+	cumulative := decimal.Zero
+	for i, position := range ranked {
+		if i == top {
+			break
+		}
+		cumulative = cumulative.Add(position.value.Abs())
+		report.TopPositions = append(report.TopPositions, &types.ExposurePosition{
+			Rank:                  i + 1,
+			CUSIP:                 position.cusip,
+			Name:                  position.name,
+			Amount:                position.amount.String(),
+			Value:                 fund.RoundingPolicy.FormatCurrency(position.value),
+			Weight:                exposure.weight(position.value),
+			CumulativeGrossWeight: exposure.weight(cumulative),
+		})
+	}
+
+	report.Limits = []*types.ConcentrationCheck{}
+	for _, limit := range fund.ConcentrationLimits {
+		check := exposure.check(limit, book, ranked, dimensions)
+		report.Breached = report.Breached || check.Breached
+		report.Limits = append(report.Limits, check)
+	}
+	return report, nil
+}
+
+// The valuation date a portfolio is held and priced on: its latest holdings on or
+// before asOf, or its most recent ones without a date
+func portfolioValuationDate(portfolio *types.Portfolio, date string, asOf time.Time) (string, bool) {
+	if portfolio.MostRecentDate == "" {
+		return "", false
+	}
+	if date == "" {
+		return portfolio.MostRecentDate, true
+	}
+	held, heldOn := "", time.Time{}
+	for key := range portfolio.Assets {
+		on, err := types.ParseDate(key)
+		if err != nil || on.After(asOf) {
+			continue
+		}
+		if held == "" || on.After(heldOn) {
+			held, heldOn = key, on
+		}
+	}
+	return held, held != ""
+}
+
+// A CUSIP held across the fund's portfolios. The value is the market value,
+// negative for a short position.
+type exposurePosition struct {
+	cusip    string
+	name     string
+	amount   decimal.Decimal
+	value    decimal.Decimal
+	security *types.Security
+}
+
+// The value of a position in a dimension, unclassified without a security master
+// record or a value for the dimension
+func (p *exposurePosition) classification(dimension string) string {
+	if dimension == types.EXPOSURE_DIMENSION_SECURITY {
+		return p.cusip
+	}
+	value := ""
+	if p.security != nil {
+		switch dimension {
+		case types.EXPOSURE_DIMENSION_ASSET_CLASS:
+			value = p.security.AssetClass
+		case types.EXPOSURE_DIMENSION_SECTOR:
+			value = p.security.Sector
+		case types.EXPOSURE_DIMENSION_COUNTRY:
+			value = p.security.Country
+		case types.EXPOSURE_DIMENSION_ISSUER:
+			value = p.security.Issuer
+		}
+	}
+	if value == "" {
+		return types.EXPOSURE_UNCLASSIFIED
+	}
+	return value
+}
+
+type exposureBucket struct {
+	value string
+	long  decimal.Decimal
+	short decimal.Decimal
+}
+
+func (b *exposureBucket) add(value decimal.Decimal) {
+	if value.Sign() < 0 {
+		b.short = b.short.Add(value)
+	} else {
+		b.long = b.long.Add(value)
+	}
+}
+
+func (b *exposureBucket) gross() decimal.Decimal {
+	return b.long.Sub(b.short)
+}
+
+func (b *exposureBucket) net() decimal.Decimal {
+	return b.long.Add(b.short)
+}
+
+// Groups the positions by their value in the dimension, largest gross first
+func bucketPositions(positions []*exposurePosition, dimension string) []*exposureBucket {
+	buckets := []*exposureBucket{}
+	byValue := map[string]*exposureBucket{}
+	for _, position := range positions {
+		value := position.classification(dimension)
+		bucket, ok := byValue[value]
+		if !ok {
+			bucket = &exposureBucket{value: value}
+			byValue[value] = bucket
+			buckets = append(buckets, bucket)
+		}
+		bucket.add(position.value)
+	}
+	sort.SliceStable(buckets, func(i, j int) bool {
+		if !buckets[i].gross().Equal(buckets[j].gross()) {
+			return buckets[i].gross().GreaterThan(buckets[j].gross())
+		}
+		return buckets[i].value < buckets[j].value
+	})
+	return buckets
+}
+
+// Weighs exposures against the net asset value. Without a positive net asset value
+// there are no weights and every limit counts as breached.
+type exposureWeights struct {
+	policy types.RoundingPolicy
+	nav    decimal.Decimal
+}
+
+func (e exposureWeights) weight(value decimal.Decimal) string {
+	if e.nav.Sign() != 1 {
+		return ""
+	}
+	return value.Div(e.nav).StringFixed(types.EXPOSURE_PRECISION)
+}
+
+func (e exposureWeights) buckets(buckets []*exposureBucket) []*types.ExposureBucket {
+	reported := []*types.ExposureBucket{}
+	for _, bucket := range buckets {
+		reported = append(reported, &types.ExposureBucket{
+			Value:       bucket.value,
+			Long:        e.policy.FormatCurrency(bucket.long),
+			Short:       e.policy.FormatCurrency(bucket.short),
+			Gross:       e.policy.FormatCurrency(bucket.gross()),
+			Net:         e.policy.FormatCurrency(bucket.net()),
+			GrossWeight: e.weight(bucket.gross()),
+			NetWeight:   e.weight(bucket.net()),
+		})
+	}
+	return reported
+}
+
+// Checks a limit against the gross exposure it applies to, or the net exposure in
+// either direction for a net limit
+func (e exposureWeights) check(limit types.ConcentrationLimit, book *exposureBucket, ranked []*exposurePosition, dimensions map[string][]*exposureBucket) *types.ConcentrationCheck {
+	check := &types.ConcentrationCheck{Dimension: limit.Dimension, Value: limit.Value, Count: limit.Count, Max: limit.Max}
+	exposure := decimal.Zero
+	switch limit.Dimension {
+	case types.CONCENTRATION_LIMIT_GROSS:
+		exposure = book.gross()
+	case types.CONCENTRATION_LIMIT_NET:
+		exposure = book.net().Abs()
+	case types.CONCENTRATION_LIMIT_TOP:
+		for i, position := range ranked {
+			if i == limit.Count {
+				break
+			}
+			exposure = exposure.Add(position.value.Abs())
+		}
+	default:
+		for _, bucket := range dimensions[limit.Dimension] {
+			if limit.Value == "" {
+				//buckets are largest first
+				check.Value = bucket.value
+				exposure = bucket.gross()
+				break
+			}
+			if bucket.value == limit.Value {
+				exposure = bucket.gross()
+			}
+		}
+	}
+	check.Actual = e.weight(exposure)
+	maximum, err := decimal.NewFromString(limit.Max)
+	if check.Actual == "" || err != nil {
+		check.Breached = true
+		return check
+	}
+	check.Breached = exposure.Div(e.nav).GreaterThan(maximum)
+	return check
+}
@@ -0,0 +1,214 @@
+package smartcontract_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// The fund of performanceFund holding Apple from February and, by the end of
+// March, Apple, Microsoft, an S&P 500 ETF, Tesla without a security master
+// record and gold without a price
+func exposureFund(t *testing.T) (*memstub.Stub, *smartcontract.AdminContract) {
+	stub, admin := performanceFund(t)
+	buy := func(id string, date string, name string, cusip string, amount string) func(ctx contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, id, "portfolio", "buy", date, 1, name, cusip, amount, "USD")
+		}
+	}
+	value := func(date string, name string, price string) func(ctx contractapi.TransactionContextInterface) error {
+		return func(ctx contractapi.TransactionContextInterface) error {
+			return admin.UpdatePortfolioValuation(ctx, "portfolio", date, name, price)
+		}
+	}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.SetSecurity(ctx, "037833100", "Apple Inc", types.ASSET_CLASS_EQUITY, "Information Technology", "US", "Apple Inc")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.SetSecurity(ctx, "594918104", "Microsoft Corp", types.ASSET_CLASS_EQUITY, "Information Technology", "US", "Microsoft Corp")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.SetSecurity(ctx, "78462F103", "SPDR S&P 500 ETF", types.ASSET_CLASS_FUND, "", "US", "State Street")
+		},
+		buy("apple", "02-28-2020", "Apple", "037833100", "100"),
+		value("02-28-2020", "Apple", "140"),
+		buy("microsoft", "03-31-2020", "Microsoft", "594918104", "50"),
+		buy("spy", "03-31-2020", "SPY", "78462F103", "20"),
+		buy("tesla", "03-31-2020", "Tesla", "88160R101", "10"),
+		buy("gold", "03-31-2020", "Gold", "78463V107", "5"),
+		value("03-31-2020", "Apple", "150"),
+		value("03-31-2020", "Microsoft", "200"),
+		value("03-31-2020", "SPY", "300"),
+		value("03-31-2020", "Tesla", "100"),
+	)
+	return stub, admin
+}
+
+func queryExposure(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, date string, top int) *types.ExposureReport {
+	var report *types.ExposureReport
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		report, err = admin.QueryExposure(ctx, "fund", date, top)
+		return err
+	})
+	return report
+}
+
+// The value, gross weight and net weight of each bucket
+func bucketWeights(buckets []*types.ExposureBucket) [][]string {
+	weights := [][]string{}
+	for _, bucket := range buckets {
+		weights = append(weights, []string{bucket.Value, bucket.GrossWeight, bucket.NetWeight})
+	}
+	return weights
+}
+
+func TestExposure(t *testing.T) {
+	stub, admin := exposureFund(t)
+	report := queryExposure(t, stub, admin, "", 2)
+	assert.Equal(t, "03-31-2020", report.Date)
+	assert.Equal(t, "32000.00", report.NAV)
+	assert.Equal(t, "32000.00", report.Long)
+	assert.Equal(t, "0.00", report.Short)
+	assert.Equal(t, "32000.00", report.Gross)
+	assert.Equal(t, "1.000000", report.GrossWeight)
+	assert.Equal(t, "1.000000", report.NetWeight)
+	assert.Equal(t, []string{"portfolio/Gold"}, report.Unpriced)
+	assert.Equal(t, []string{"88160R101"}, report.Unclassified)
+
+	assert.Equal(t, [][]string{
+		{"037833100", "0.468750", "0.468750"},
+		{"594918104", "0.312500", "0.312500"},
+		{"78462F103", "0.187500", "0.187500"},
+		{"88160R101", "0.031250", "0.031250"},
+	}, bucketWeights(report.BySecurity))
+	assert.Equal(t, [][]string{
+		{"equity", "0.781250", "0.781250"},
+		{"fund", "0.187500", "0.187500"},
+		{"unclassified", "0.031250", "0.031250"},
+	}, bucketWeights(report.ByAssetClass))
+	assert.Equal(t, [][]string{
+		{"Information Technology", "0.781250", "0.781250"},
+		{"unclassified", "0.218750", "0.218750"},
+	}, bucketWeights(report.BySector))
+	assert.Equal(t, [][]string{
+		{"US", "0.968750", "0.968750"},
+		{"unclassified", "0.031250", "0.031250"},
+	}, bucketWeights(report.ByCountry))
+	assert.Equal(t, "Apple Inc", report.ByIssuer[0].Value)
+	assert.Equal(t, "15000.00", report.ByIssuer[0].Long)
+	assert.Equal(t, "State Street", report.ByIssuer[2].Value)
+	assert.Equal(t, "6000.00", report.ByIssuer[2].Long)
+
+	assert.Len(t, report.TopPositions, 2)
+	assert.Equal(t, &types.ExposurePosition{
+		Rank: 2, CUSIP: "594918104", Name: "Microsoft Corp", Amount: "50", Value: "10000.00",
+		Weight: "0.312500", CumulativeGrossWeight: "0.781250",
+	}, report.TopPositions[1])
+	assert.Empty(t, report.Limits)
+	assert.False(t, report.Breached)
+
+	//the close values the same holdings, whatever the security master says
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		fund, err := admin.QueryFundById(ctx, "fund")
+		assert.Nil(t, err)
+		closingValue, err := admin.CalculateFundClosingValue(ctx, fund)
+		assertDecimal(t, closingValue, "32000")
+		return err
+	})
+
+	//earlier dates take the holdings and prices of the valuation before them
+	february := queryExposure(t, stub, admin, "03-15-2020", 0)
+	assert.Equal(t, "03-15-2020", february.Date)
+	assert.Equal(t, "14000.00", february.NAV)
+	assert.Len(t, february.TopPositions, 1)
+	assert.Empty(t, february.Unpriced)
+}
+
+func TestExposureConcentrationLimits(t *testing.T) {
+	stub, admin := exposureFund(t)
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		err := admin.SetFundConcentrationLimits(ctx, "fund", `[{"dimension":"region","max":"0.1"},{"dimension":"top","max":"1"},{"dimension":"gross","value":"US","max":"2"}]`)
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		coded, _ := pkgErrors.From(err)
+		assert.Contains(t, coded.Details, "limits.0.dimension")
+		assert.Contains(t, coded.Details, "limits.1.count")
+		assert.Contains(t, coded.Details, "limits.2.value")
+		err = admin.SetFundConcentrationLimits(ctx, "fund", `{}`)
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		err = admin.SetFundConcentrationLimits(ctx, "missing", `[]`)
+		assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+		return admin.SetFundConcentrationLimits(ctx, "fund", `[
+			{"dimension":"issuer","max":"0.4"},
+			{"dimension":"country","value":"US","max":"2"},
+			{"dimension":"sector","value":"Energy","max":"0.1"},
+			{"dimension":"gross","max":"0.9"},
+			{"dimension":"net","max":"1.1"},
+			{"dimension":"top","count":2,"max":"0.7"}
+		]`)
+	})
+
+	report := queryExposure(t, stub, admin, "", 0)
+	assert.True(t, report.Breached)
+	checks := [][]string{}
+	breached := []bool{}
+	for _, check := range report.Limits {
+		checks = append(checks, []string{check.Dimension, check.Value, check.Actual})
+		breached = append(breached, check.Breached)
+	}
+	assert.Equal(t, [][]string{
+		{"issuer", "Apple Inc", "0.468750"},
+		{"country", "US", "0.968750"},
+		{"sector", "Energy", "0.000000"},
+		{"gross", "", "1.000000"},
+		{"net", "", "1.000000"},
+		{"top", "", "0.781250"},
+	}, checks)
+	assert.Equal(t, []bool{true, false, false, true, false, true}, breached)
+	assert.Equal(t, 2, report.Limits[5].Count)
+}
+
+func TestSetSecurity(t *testing.T) {
+	stub := memstub.New()
+	admin := &smartcontract.AdminContract{}
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		err := admin.SetSecurity(ctx, "037833101", "Apple Inc", "stock", "", "USA", "")
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		coded, _ := pkgErrors.From(err)
+		assert.Len(t, coded.Details, 4)
+		for _, field := range []string{"assetClass", "country", "cusip", "issuer"} {
+			assert.Contains(t, coded.Details, field)
+		}
+		return admin.SetSecurity(ctx, "037833100", "Apple", types.ASSET_CLASS_EQUITY, "", "US", "Apple Inc")
+	})
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		return admin.SetSecurity(ctx, "037833100", "Apple Inc", types.ASSET_CLASS_EQUITY, "Information Technology", "US", "Apple Inc")
+	})
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		security, err := admin.QuerySecurityById(ctx, "037833100")
+		assert.Nil(t, err)
+		assert.Equal(t, "Apple Inc", security.Name)
+		assert.Equal(t, "Information Technology", security.Sector)
+		missing, err := admin.QuerySecurityById(ctx, "594918104")
+		assert.Nil(t, err)
+		assert.Nil(t, missing)
+		return admin.CreateInvestor(ctx, "38259P508", "Investor")
+	})
+
+	//an investor whose id looks like a CUSIP is neither read nor replaced as a security
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		security, err := admin.QuerySecurityById(ctx, "38259P508")
+		assert.Nil(t, err)
+		assert.Nil(t, security)
+		err = admin.SetSecurity(ctx, "38259P508", "Google", types.ASSET_CLASS_EQUITY, "", "US", "Alphabet Inc")
+		assert.True(t, errors.Is(err, pkgErrors.IdAlreadyInUseError))
+		return nil
+	})
+}
@@ -1,7 +1,6 @@
 package smartcontract
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/shopspring/decimal"
@@ -16,17 +15,53 @@ func (s *AdminContract) CreateFund(
 	name string,
 	inceptionDate string,
 ) error {
+	err := types.ValidateCreateFundRequest(&types.CreateFundRequest{Name: name, InceptionDate: inceptionDate})
+	if err != nil {
+		return err
+	}
 	obj, err := ctx.GetStub().GetState(fundId)
 	if err != nil {
 		return pkgErrors.ReadingWorldStateError
 	}
 	if obj != nil {
-		return pkgErrors.IdAlreadyInUseError
+		return pkgErrors.IdAlreadyInUseError.WithDetail("id", fundId)
 	}
 	fund := types.CreateDefaultFund(fundId, name, inceptionDate)
 	return SaveState(ctx, &fund)
 }
 
+// The rounding policy can only change before the fund is bootstrapped, so every
+// stored amount of a fund is rounded the same way
+func (s *AdminContract) SetFundRoundingPolicy(
+	ctx SmartContractContext,
+	fundId string,
+	currencyPrecision int32,
+	ownershipPrecision int32,
+	roundingMode string,
+) error {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return err
+	}
+	if fund == nil {
+		return pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	if fund.CurrentPeriod != 0 {
+		return pkgErrors.CannotChangeRoundingPolicyError
+	}
+	policy := types.RoundingPolicy{
+		CurrencyPrecision:  currencyPrecision,
+		OwnershipPrecision: ownershipPrecision,
+		RoundingMode:       roundingMode,
+	}
+	err = policy.Validate()
+	if err != nil {
+		return err
+	}
+	fund.RoundingPolicy = policy
+	return SaveState(ctx, fund)
+}
+
 func (s *AdminContract) QueryFundById(
 	ctx SmartContractContext,
 	fundId string,
@@ -39,13 +74,40 @@ func (s *AdminContract) QueryFundById(
 		return nil, nil
 	}
 	var fund types.Fund
-	err = LoadState(fundJSON, &fund)
+	err = LoadState(ctx, fundJSON, &fund)
 	if err != nil {
 		return nil, err
 	}
 	return &fund, err
 }
 
+// Lists every fund in id order
+func (s *AdminContract) QueryFunds(
+	ctx SmartContractContext,
+	pageSize int32,
+	bookmark string,
+) (*types.FundPage, error) {
+	funds := []*types.Fund{}
+	bookmark, err := queryIndexPage(ctx, types.INDEX_FUND, []string{}, pageSize, bookmark, func(data []byte) (bool, error) {
+		var fund types.Fund
+		err := LoadState(ctx, data, &fund)
+		if err != nil {
+			return false, err
+		}
+		funds = append(funds, &fund)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.FundPage{
+		Records:             funds,
+		FetchedRecordsCount: int32(len(funds)),
+		Bookmark:            bookmark,
+	}
+	return page, nil
+}
+
 func (s *AdminContract) StepFund(
 	ctx SmartContractContext,
 	fundId string,
@@ -55,15 +117,17 @@ func (s *AdminContract) StepFund(
 		return nil, err
 	}
 	if fund == nil {
-		return nil, pkgErrors.FundNotFoundError
+		return nil, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
 	}
 	if fund.CurrentPeriod == 0 {
 		return nil, pkgErrors.CannotStepFundError
 	}
+	policy := fund.RoundingPolicy
 	fundClosingValue, err := calculateFundClosingValue(ctx, fund)
 	if err != nil {
 		return nil, err
 	}
+	fundClosingValue = policy.RoundCurrency(fundClosingValue)
 	accounts, err := s.QueryCapitalAccountsByFund(ctx, fund.ID)
 	if err != nil {
 		return nil, err
@@ -71,7 +135,12 @@ func (s *AdminContract) StepFund(
 	if accounts == nil {
 		return nil, pkgErrors.NoCapitalAccountsFoundError
 	}
-	err = calculateCapitalAccountClosingValues(accounts, fundClosingValue)
+	assignInvestorNumbers(fund, accounts)
+	err = loadMidYearDeposits(ctx, fund)
+	if err != nil {
+		return nil, err
+	}
+	err = calculateCapitalAccountClosingValues(accounts, fundClosingValue, policy)
 	if err != nil {
 		return nil, err
 	}
@@ -79,18 +148,18 @@ func (s *AdminContract) StepFund(
 	if err != nil {
 		return nil, err
 	}
-	totalFixedFees, err := calculateAggregateFixedFees(accounts)
+	totalFixedFees, err := calculateAggregateFixedFees(accounts, policy)
 	if err != nil {
 		return nil, err
 	}
-	err = transferFixedFeesToGeneralPartner(accounts, totalFixedFees)
+	err = transferFixedFeesToGeneralPartner(accounts, totalFixedFees, policy)
 	if err != nil {
 		return nil, err
 	}
 	totalDeposits = totalDeposits.Add(
 		totalFixedFees,
 	) //fees from limited partners become deposits for general partner
-	fundOpeningValue, err := calculateCapitalAccountOpeningValues(accounts)
+	fundOpeningValue, err := calculateCapitalAccountOpeningValues(accounts, policy)
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +173,11 @@ func (s *AdminContract) StepFund(
 	if err != nil {
 		return nil, err
 	}
+	closedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fund.SetClosedAt(closedAt)
 	fund.IncrementCurrentPeriod()
 	fund.MidYearDeposits = []string{}
 	err = SaveState(ctx, fund)
@@ -112,10 +186,12 @@ func (s *AdminContract) StepFund(
 	}
 	for _, account := range accounts {
 		account.IncrementCurrentPeriod()
-		err := updateCapitalAccountOwnership(account, fundOpeningValue)
-		if err != nil {
-			return nil, err
-		}
+	}
+	err = updateCapitalAccountOwnerships(accounts, fundOpeningValue, policy)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
 		err = SaveState(ctx, account)
 		if err != nil {
 			return nil, err
@@ -135,16 +211,18 @@ func performWealthConservationFunction(
 	if !testValue.Equal(openingValue) {
 		return pkgErrors.WealthConservationFunctionError
 	}
-	fund.ClosingValues[fund.CurrentPeriod] = closingValue.String()
-	fund.Deposits[fund.CurrentPeriod] = deposits.String()
-	fund.FixedFees[fund.CurrentPeriod] = fees.String()
-	fund.OpeningValues[fund.CurrentPeriod] = openingValue.String()
+	policy := fund.RoundingPolicy
+	fund.ClosingValues[fund.CurrentPeriod] = policy.FormatCurrency(closingValue)
+	fund.Deposits[fund.CurrentPeriod] = policy.FormatCurrency(deposits)
+	fund.FixedFees[fund.CurrentPeriod] = policy.FormatCurrency(fees)
+	fund.OpeningValues[fund.CurrentPeriod] = policy.FormatCurrency(openingValue)
 	return nil
 }
 
 func transferFixedFeesToGeneralPartner(
 	accounts []*types.CapitalAccount,
 	fixedFees decimal.Decimal,
+	policy types.RoundingPolicy,
 ) error {
 	for _, account := range accounts {
 		if account.Number == 0 {
@@ -155,7 +233,7 @@ func transferFixedFeesToGeneralPartner(
 				return pkgErrors.DecimalConversionError
 			}
 			newDeposits := existingDeposits.Add(fixedFees)
-			account.Deposits[account.CurrentPeriod] = newDeposits.String()
+			account.Deposits[account.CurrentPeriod] = policy.FormatCurrency(newDeposits)
 			return nil
 		}
 	}
@@ -164,6 +242,7 @@ func transferFixedFeesToGeneralPartner(
 
 func calculateCapitalAccountOpeningValues(
 	accounts []*types.CapitalAccount,
+	policy types.RoundingPolicy,
 ) (decimal.Decimal, error) {
 	fundOpeningValue := decimal.Zero
 	for _, account := range accounts {
@@ -183,18 +262,21 @@ func calculateCapitalAccountOpeningValues(
 		}
 		openingValue := closingValue.Sub(fixedFees).Add(deposits)
 		if openingValue.Sign() == -1 {
-			return decimal.Zero, pkgErrors.NegativeCapitalAccountBalanceError
+			return decimal.Zero, pkgErrors.NegativeCapitalAccountBalanceError.WithDetail("capitalAccount", account.ID)
 		}
-		account.OpeningValue[account.CurrentPeriod] = openingValue.String()
+		account.OpeningValue[account.CurrentPeriod] = policy.FormatCurrency(openingValue)
 		fundOpeningValue = fundOpeningValue.Add(openingValue)
 	}
 	return fundOpeningValue, nil
 }
 
-func calculateAggregateFixedFees(accounts []*types.CapitalAccount) (decimal.Decimal, error) {
+func calculateAggregateFixedFees(
+	accounts []*types.CapitalAccount,
+	policy types.RoundingPolicy,
+) (decimal.Decimal, error) {
 	aggregateFixedFees := decimal.Zero
 	for _, account := range accounts {
-		accountFixedFees, err := calculateCapitalAccountFixedFees(account)
+		accountFixedFees, err := calculateCapitalAccountFixedFees(account, policy)
 		if err != nil {
 			return decimal.Zero, err
 		}
@@ -203,9 +285,14 @@ func calculateAggregateFixedFees(accounts []*types.CapitalAccount) (decimal.Deci
 	return aggregateFixedFees, nil
 }
 
-func calculateCapitalAccountFixedFees(account *types.CapitalAccount) (decimal.Decimal, error) {
+// Each fee is rounded on its own so the fees charged to the limited partners add
+// up to exactly the fees credited to the general partner
+func calculateCapitalAccountFixedFees(
+	account *types.CapitalAccount,
+	policy types.RoundingPolicy,
+) (decimal.Decimal, error) {
 	if account.Number == 0 {
-		account.FixedFees[account.CurrentPeriod] = decimal.Zero.String()
+		account.FixedFees[account.CurrentPeriod] = policy.FormatCurrency(decimal.Zero)
 		return decimal.Zero, nil
 	}
 	closingValue, err := decimal.NewFromString(
@@ -218,8 +305,8 @@ func calculateCapitalAccountFixedFees(account *types.CapitalAccount) (decimal.De
 	if err != nil {
 		return decimal.Zero, pkgErrors.DecimalConversionError
 	}
-	fixedFee := fixedFeePercentage.Mul(closingValue)
-	account.FixedFees[account.CurrentPeriod] = fixedFee.String()
+	fixedFee := policy.RoundCurrency(fixedFeePercentage.Mul(closingValue))
+	account.FixedFees[account.CurrentPeriod] = policy.FormatCurrency(fixedFee)
 	return fixedFee, nil
 }
 
@@ -233,10 +320,10 @@ func calculateAggregateDeposits(
 		//if the account has performance fees and isn't on the list of mid year deposits we don't want to aggregate deposits yet
 		//for the accounts that match this conditional we apply deposits after the application of performance fees
 		if account.HasPerformanceFees && !contains(fund.MidYearDeposits, account.ID) {
-			account.Deposits[account.CurrentPeriod] = decimal.Zero.String()
+			account.Deposits[account.CurrentPeriod] = fund.RoundingPolicy.FormatCurrency(decimal.Zero)
 			continue
 		}
-		accountDeposits, err := calculateCapitalAccountDeposits(ctx, account)
+		accountDeposits, err := calculateCapitalAccountDeposits(ctx, account, fund.RoundingPolicy)
 		if err != nil {
 			return decimal.Zero, err
 		}
@@ -248,12 +335,13 @@ func calculateAggregateDeposits(
 func calculateCapitalAccountDeposits(
 	ctx SmartContractContext,
 	account *types.CapitalAccount,
+	policy types.RoundingPolicy,
 ) (decimal.Decimal, error) {
-	deposits, err := QueryDepositsByFundAccountPeriod(ctx, account.ID, account.CurrentPeriod)
+	deposits, err := QueryDepositsByFundAccountPeriod(ctx, account.Fund, account.ID, account.CurrentPeriod)
 	if err != nil {
 		return decimal.Zero, err
 	}
-	withdrawals, err := QueryWithdrawalsByFundAccountPeriod(ctx, account.ID, account.CurrentPeriod)
+	withdrawals, err := QueryWithdrawalsByFundAccountPeriod(ctx, account.Fund, account.ID, account.CurrentPeriod)
 	if err != nil {
 		return decimal.Zero, err
 	}
@@ -261,7 +349,8 @@ func calculateCapitalAccountDeposits(
 	if err != nil {
 		return decimal.Zero, err
 	}
-	account.Deposits[account.CurrentPeriod] = total.String()
+	total = policy.RoundCurrency(total)
+	account.Deposits[account.CurrentPeriod] = policy.FormatCurrency(total)
 	return total, nil
 }
 
@@ -287,7 +376,7 @@ func calculateMidYearDeposits(
 		if !contains(fund.MidYearDeposits, account.ID) {
 			continue
 		}
-		accountDeposits, err := calculateCapitalAccountDeposits(ctx, account)
+		accountDeposits, err := calculateCapitalAccountDeposits(ctx, account, fund.RoundingPolicy)
 		if err != nil {
 			return decimal.Zero, err
 		}
@@ -296,12 +385,53 @@ func calculateMidYearDeposits(
 	return aggregateDeposits, nil
 }
 
+// Each limited partner's share of the fund closing value is rounded, and the general
+// partner takes the rest so the closing values add up to exactly the fund's
 func calculateCapitalAccountClosingValues(
 	accounts []*types.CapitalAccount,
 	fundClosingValue decimal.Decimal,
+	policy types.RoundingPolicy,
 ) error {
+	var generalPartner *types.CapitalAccount
+	remainingClosingValue := fundClosingValue
 	for _, account := range accounts {
-		account.UpdateClosingValue(fundClosingValue)
+		if account.Number == 0 {
+			generalPartner = account
+			continue
+		}
+		closingValue, err := updateCapitalAccountClosingValue(account, fundClosingValue, policy)
+		if err != nil {
+			return err
+		}
+		remainingClosingValue = remainingClosingValue.Sub(closingValue)
+	}
+	if generalPartner == nil {
+		return pkgErrors.GeneralPartnerNotFoundError
+	}
+	generalPartner.SetClosingValue(policy.FormatCurrency(remainingClosingValue))
+	return nil
+}
+
+// Rounding each ownership percentage to the fund's ownership precision can leave the
+// percentages summing to slightly more or less than 1, so the general partner's
+// percentage is replaced by whatever is left after the limited partners. A fund
+// without a general partner keeps each account's rounded share.
+func allocateOwnershipResidual(accounts []*types.CapitalAccount, policy types.RoundingPolicy) error {
+	var generalPartner *types.CapitalAccount
+	remainingOwnership := decimal.NewFromInt(1)
+	for _, account := range accounts {
+		if account.Number == 0 {
+			generalPartner = account
+			continue
+		}
+		ownership, err := decimal.NewFromString(account.OwnershipPercentage[account.PreviousPeriod()])
+		if err != nil {
+			return pkgErrors.DecimalConversionError.WithDetail("capitalAccount", account.ID)
+		}
+		remainingOwnership = remainingOwnership.Sub(ownership)
+	}
+	if generalPartner != nil {
+		generalPartner.OwnershipPercentage[generalPartner.PreviousPeriod()] = policy.FormatOwnership(remainingOwnership)
 	}
 	return nil
 }
@@ -309,18 +439,19 @@ func calculateCapitalAccountClosingValues(
 func updateCapitalAccountClosingValue(
 	account *types.CapitalAccount,
 	fundClosingValue decimal.Decimal,
-) error {
+	policy types.RoundingPolicy,
+) (decimal.Decimal, error) {
 	previousOwnershipPercentage, ok := account.OwnershipPercentage[account.PreviousPeriod()]
 	if !ok {
-		return pkgErrors.PreviousOwnershipPercentageNotFoundError
+		return decimal.Zero, pkgErrors.PreviousOwnershipPercentageNotFoundError.WithDetail("capitalAccount", account.ID)
 	}
 	ownershipPercentage, err := decimal.NewFromString(previousOwnershipPercentage)
 	if err != nil {
-		return pkgErrors.DecimalConversionError
+		return decimal.Zero, pkgErrors.DecimalConversionError
 	}
-	closingValue := ownershipPercentage.Mul(fundClosingValue)
-	account.ClosingValue[account.CurrentPeriod] = closingValue.String()
-	return nil
+	closingValue := policy.RoundCurrency(ownershipPercentage.Mul(fundClosingValue))
+	account.SetClosingValue(policy.FormatCurrency(closingValue))
+	return closingValue, nil
 }
 
 func (s *AdminContract) CalculateFundClosingValue(
@@ -390,15 +521,20 @@ func (s *AdminContract) BootstrapFund(
 		return nil, err
 	}
 	if fund == nil {
-		return nil, pkgErrors.FundNotFoundError
+		return nil, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
 	}
 	if fund.CurrentPeriod != 0 {
 		return nil, pkgErrors.CannotBootstrapFundError
 	}
-	bootstrappedFundValues, err := s.BootstrapCapitalAccountsForFund(ctx, fundId)
+	bootstrappedFundValues, err := bootstrapCapitalAccountsForFund(ctx, fund)
 	if err != nil {
 		return nil, err
 	}
+	closedAt, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fund.SetClosedAt(closedAt)
 	fund.BootstrapFundValues(
 		bootstrappedFundValues.TotalDeposits,
 		bootstrappedFundValues.OpeningFundValue,
@@ -414,17 +550,38 @@ func (s *AdminContract) BootstrapCapitalAccountsForFund(
 	ctx SmartContractContext,
 	fundId string,
 ) (*bootstrappedFundValues, error) {
-	accounts, err := queryCapitalAccountsByFund(ctx, fundId)
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return &bootstrappedFundValues{}, err
+	}
+	if fund == nil {
+		return &bootstrappedFundValues{}, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	values, err := bootstrapCapitalAccountsForFund(ctx, fund)
+	if err != nil {
+		return values, err
+	}
+	//the accounts were numbered, so the fund's next investor number changed
+	return values, SaveState(ctx, fund)
+}
+
+func bootstrapCapitalAccountsForFund(
+	ctx SmartContractContext,
+	fund *types.Fund,
+) (*bootstrappedFundValues, error) {
+	policy := fund.RoundingPolicy
+	accounts, err := queryCapitalAccountsByFund(ctx, fund.ID)
 	if err != nil {
 		return &bootstrappedFundValues{}, err
 	}
+	assignInvestorNumbers(fund, accounts)
 	//loop over accounts, aggregate deposits and track closing fund value
 	openingFundValue := decimal.Zero
 	totalDeposits := decimal.Zero
 	for _, account := range accounts {
-		err := s.BootstrapCapitalAccount(ctx, account)
+		err := bootstrapCapitalAccount(ctx, account, policy)
 		if err != nil {
-			return &bootstrappedFundValues{}, errors.New("err from bootstrap capital account")
+			return &bootstrappedFundValues{}, err
 		}
 		currentPeriod := account.CurrentPeriod - 1
 		deposit, err := decimal.NewFromString(account.Deposits[currentPeriod])
@@ -435,17 +592,17 @@ func (s *AdminContract) BootstrapCapitalAccountsForFund(
 		openingFundValue = openingFundValue.Add(deposit)
 	}
 	//update the ownership percentage for each account based on the closing fund value
+	err = updateCapitalAccountOwnerships(accounts, openingFundValue, policy)
+	if err != nil {
+		return &bootstrappedFundValues{}, err
+	}
 	for _, account := range accounts {
-		err := updateCapitalAccountOwnership(account, openingFundValue)
-		if err != nil {
-			return &bootstrappedFundValues{}, err
-		}
 		setHighWaterMark(account)
 		SaveState(ctx, account)
 	}
 	retValue := &bootstrappedFundValues{
-		OpeningFundValue: openingFundValue.String(),
-		TotalDeposits:    totalDeposits.String(),
+		OpeningFundValue: policy.FormatCurrency(openingFundValue),
+		TotalDeposits:    policy.FormatCurrency(totalDeposits),
 	}
 	return retValue, nil
 }
@@ -459,17 +616,40 @@ func setHighWaterMark(account *types.CapitalAccount) {
 	account.HighWaterMark = highWaterMark
 }
 
+// Every account's ownership is its share of the fund opening value, with any rounding
+// residual going to the general partner
+func updateCapitalAccountOwnerships(
+	accounts []*types.CapitalAccount,
+	openingFundValue decimal.Decimal,
+	policy types.RoundingPolicy,
+) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+	if openingFundValue.IsZero() {
+		return pkgErrors.ZeroFundOpeningValueError
+	}
+	for _, account := range accounts {
+		err := updateCapitalAccountOwnership(account, openingFundValue, policy)
+		if err != nil {
+			return err
+		}
+	}
+	return allocateOwnershipResidual(accounts, policy)
+}
+
 func updateCapitalAccountOwnership(
 	account *types.CapitalAccount,
 	openingFundValue decimal.Decimal,
+	policy types.RoundingPolicy,
 ) error {
 	currentPeriod := account.PreviousPeriod()
 	openingAccountValue, err := decimal.NewFromString(account.OpeningValue[currentPeriod])
 	if err != nil {
 		return err
 	}
-	ownership := openingAccountValue.Div(openingFundValue)
-	account.OwnershipPercentage[currentPeriod] = ownership.String()
+	ownership := policy.RoundOwnership(openingAccountValue.Div(openingFundValue))
+	account.OwnershipPercentage[currentPeriod] = policy.FormatOwnership(ownership)
 	return nil
 }
 
@@ -480,11 +660,29 @@ func (s *AdminContract) BootstrapCapitalAccount(
 	if account.CurrentPeriod != 0 {
 		return pkgErrors.CannotBootstrapCapitalAccountError
 	}
-	deposits, err := QueryDepositsByFundAccountPeriod(ctx, account.ID, account.CurrentPeriod)
+	fund, err := s.QueryFundById(ctx, account.Fund)
+	if err != nil {
+		return err
+	}
+	if fund == nil {
+		return pkgErrors.FundNotFoundError.WithDetail("fund", account.Fund)
+	}
+	return bootstrapCapitalAccount(ctx, account, fund.RoundingPolicy)
+}
+
+func bootstrapCapitalAccount(
+	ctx SmartContractContext,
+	account *types.CapitalAccount,
+	policy types.RoundingPolicy,
+) error {
+	if account.CurrentPeriod != 0 {
+		return pkgErrors.CannotBootstrapCapitalAccountError
+	}
+	deposits, err := QueryDepositsByFundAccountPeriod(ctx, account.Fund, account.ID, account.CurrentPeriod)
 	if err != nil {
 		return err
 	}
-	withdrawals, err := QueryWithdrawalsByFundAccountPeriod(ctx, account.ID, account.CurrentPeriod)
+	withdrawals, err := QueryWithdrawalsByFundAccountPeriod(ctx, account.Fund, account.ID, account.CurrentPeriod)
 	if err != nil {
 		return err
 	}
@@ -497,11 +695,11 @@ func (s *AdminContract) BootstrapCapitalAccount(
 	if err != nil {
 		return err
 	}
-	openingValue := closingValue.Add(total)
+	openingValue := policy.RoundCurrency(closingValue.Add(total))
 	if openingValue.Sign() == -1 {
-		return pkgErrors.NegativeCapitalAccountBalanceError
+		return pkgErrors.NegativeCapitalAccountBalanceError.WithDetail("capitalAccount", account.ID)
 	}
-	account.BootstrapAccountValues(openingValue.String())
+	account.BootstrapAccountValues(policy.FormatCurrency(openingValue))
 	return nil
 }
 
@@ -559,7 +757,7 @@ func (s *AdminContract) StepFundPerfFees(
 		return nil, err
 	}
 	if fund == nil {
-		return nil, pkgErrors.FundNotFoundError
+		return nil, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
 	}
 	if fund.CurrentPeriod == 0 {
 		return nil, pkgErrors.CannotStepFundError
@@ -575,6 +773,15 @@ func (s *AdminContract) StepFundPerfFees(
 	if accounts == nil {
 		return nil, pkgErrors.NoCapitalAccountsFoundError
 	}
+	assignInvestorNumbers(fund, accounts)
+	err = loadMidYearDeposits(ctx, fund)
+	if err != nil {
+		return nil, err
+	}
+	err = loadMidYearWithdrawals(ctx, fund)
+	if err != nil {
+		return nil, err
+	}
 	stepResult := createStepFundResult()
 	if fund.IsPerformanceFeePeriod() {
 		accountsNoPerfFees, accountsPerfFees := splitSubsetsPerfPeriod(accounts)
@@ -637,7 +844,7 @@ func processSubset1(
 	for i, account := range accounts {
 		account.UpdateClosingValue(closingValue)
 		accountDeposits := decimal.Zero
-		depositList, err := QueryDepositsByFundAccountPeriod(ctx, account.ID, account.CurrentPeriod)
+		depositList, err := QueryDepositsByFundAccountPeriod(ctx, account.Fund, account.ID, account.CurrentPeriod)
 		if err != nil {
 			return nil, err
 		}
@@ -645,7 +852,7 @@ func processSubset1(
 			amount := decimal.RequireFromString(deposit.Amount)
 			accountDeposits = accountDeposits.Add(amount)
 		}
-		withdrawalList, err := QueryWithdrawalsByFundAccountPeriod(ctx, account.ID, account.CurrentPeriod)
+		withdrawalList, err := QueryWithdrawalsByFundAccountPeriod(ctx, account.Fund, account.ID, account.CurrentPeriod)
 		if err != nil {
 			return nil, err
 		}
@@ -656,7 +863,7 @@ func processSubset1(
 		accountClosingValue := decimal.RequireFromString(account.ClosingValue[account.CurrentPeriod])
 		resultingAccountBalance := accountClosingValue.Add(accountDeposits)
 		if resultingAccountBalance.Sign() == -1 {
-			return nil, pkgErrors.NegativeCapitalAccountBalanceError
+			return nil, pkgErrors.NegativeCapitalAccountBalanceError.WithDetail("capitalAccount", account.ID)
 		}
 		account.Deposits[account.CurrentPeriod] = accountDeposits.String()
 		deposits = deposits.Add(accountDeposits)
@@ -675,7 +882,7 @@ func processSubset1(
 		//calculate opening value
 		accountOpeningValue := accountClosingValue.Sub(accountFixedFees).Add(accountDeposits)
 		if accountOpeningValue.Sign() == -1 {
-			return nil, pkgErrors.NegativeCapitalAccountBalanceError
+			return nil, pkgErrors.NegativeCapitalAccountBalanceError.WithDetail("capitalAccount", account.ID)
 		}
 		openingValue = openingValue.Add(openingValue)
 	}
@@ -0,0 +1,130 @@
+package smartcontract
+
+import (
+	"strconv"
+
+	"github.com/zacharyfrederick/admin/types"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Creates the investors, capital accounts and inception deposits of a batch of
+// rows in one transaction. Documents that already exist from an earlier run of
+// the same import are checked against the row and left alone, so a failed import
+// can be run again from the start. A row that cannot be imported fails the whole
+// batch with the line of the row in the error details. Imports migrate a fund
+// before it starts, so a fund that has been bootstrapped is rejected; investors
+// joining a running fund go through MidYearDeposit.
+//
+// Reads do not see the writes of the same transaction, so documents created for a
+// row are passed on rather than queried again, and investors created for an earlier
+// row of the batch are remembered so a later row is checked against them.
+func (s *AdminContract) ImportCapitalAccounts(
+	ctx SmartContractContext,
+	fundId string,
+	rows []types.ImportRow,
+) ([]types.ImportRowResult, error) {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return nil, err
+	}
+	if fund == nil {
+		return nil, smartcontracterrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	if fund.CurrentPeriod != 0 {
+		return nil, smartcontracterrors.CannotImportCapitalAccountsError.WithDetail("fund", fundId)
+	}
+	results := []types.ImportRowResult{}
+	created := map[string]*types.Investor{}
+	for i := range rows {
+		result, err := s.importRow(ctx, fund, &rows[i], i, created)
+		if err != nil {
+			return nil, withImportLine(err, rows[i].Line)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// The index of the row in the batch orders the accounts the batch creates, so
+// the first row of a sheet imported into a new fund becomes its general partner
+func (s *AdminContract) importRow(
+	ctx SmartContractContext,
+	fund *types.Fund,
+	row *types.ImportRow,
+	index int,
+	created map[string]*types.Investor,
+) (types.ImportRowResult, error) {
+	result := types.ImportRowResult{
+		Line:             row.Line,
+		Reference:        row.Reference,
+		InvestorId:       row.InvestorId,
+		CapitalAccountId: row.CapitalAccountId,
+		DepositId:        row.DepositId,
+		Status:           types.IMPORT_STATUS_SKIPPED,
+	}
+
+	investor, err := s.QueryInvestorById(ctx, row.InvestorId)
+	if err != nil {
+		return result, err
+	}
+	if investor == nil {
+		investor = created[row.InvestorId]
+	}
+	err = types.ValidateImportRow(row, investor != nil).Err()
+	if err != nil {
+		return result, err
+	}
+	if investor == nil {
+		err = s.CreateInvestor(ctx, row.InvestorId, row.InvestorName)
+		if err != nil {
+			return result, err
+		}
+		created[row.InvestorId] = &types.Investor{ID: row.InvestorId, Name: row.InvestorName}
+		result.Status = types.IMPORT_STATUS_IMPORTED
+	} else if row.InvestorName != "" && investor.Name != row.InvestorName {
+		return result, smartcontracterrors.IdAlreadyInUseError.WithDetail("id", row.InvestorId)
+	}
+
+	capitalAccount, err := s.QueryCapitalAccountById(ctx, row.CapitalAccountId)
+	if err != nil {
+		return result, err
+	}
+	if capitalAccount == nil {
+		capitalAccount, err = createCapitalAccount(ctx, fund, row.CapitalAccountId, row.InvestorId, row.HasPerformanceFees, row.PerformanceRate, row.FixedFee, index)
+		if err != nil {
+			return result, err
+		}
+		result.Status = types.IMPORT_STATUS_IMPORTED
+	} else if capitalAccount.Fund != fund.ID || capitalAccount.Investor != row.InvestorId ||
+		capitalAccount.HasPerformanceFees != row.HasPerformanceFees || !types.SameDecimal(capitalAccount.PerformanceFeeRate, row.PerformanceRate) ||
+		!types.SameDecimal(capitalAccount.FixedFee, row.FixedFee) {
+		return result, smartcontracterrors.IdAlreadyInUseError.WithDetail("id", row.CapitalAccountId)
+	}
+
+	if row.DepositAmount == "" {
+		return result, nil
+	}
+	deposit, err := s.QueryCapitalAccountActionById(ctx, row.DepositId)
+	if err != nil {
+		return result, err
+	}
+	if deposit == nil {
+		err = createCapitalAccountAction(ctx, fund, capitalAccount, row.DepositId, types.CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT,
+			row.DepositAmount, false, row.DepositDate, fund.CurrentPeriod)
+		if err != nil {
+			return result, err
+		}
+		result.Status = types.IMPORT_STATUS_IMPORTED
+	} else if deposit.CapitalAccount != row.CapitalAccountId || !types.SameDecimal(deposit.Amount, row.DepositAmount) || deposit.Date != row.DepositDate {
+		return result, smartcontracterrors.IdAlreadyInUseError.WithDetail("id", row.DepositId)
+	}
+	return result, nil
+}
+
+func withImportLine(err error, line int) error {
+	coded, ok := smartcontracterrors.From(err)
+	if !ok {
+		return err
+	}
+	return coded.WithDetail("line", strconv.Itoa(line))
+}
@@ -0,0 +1,187 @@
+package smartcontract
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Checks the accounting invariants of every closed period of a fund and reports
+// each violation instead of stopping at the first one:
+//   - ownership percentages sum to exactly 1
+//   - no capital account has a negative closing or opening value
+//   - the fund opening value equals the sum of the account opening values
+//   - fixed fees charged to limited partners equal the fund's fees, and the fund's
+//     deposits, which include the fees credited to the general partner, equal the
+//     sum of the account deposits
+//   - closing value minus fees plus deposits equals the opening value
+func (s *AdminContract) VerifyFundInvariants(
+	ctx SmartContractContext,
+	fundId string,
+) (*types.FundInvariantReport, error) {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return nil, err
+	}
+	if fund == nil {
+		return nil, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	accounts, err := queryCapitalAccountsByFund(ctx, fundId)
+	if err != nil {
+		return nil, err
+	}
+	report := &types.FundInvariantReport{Fund: fundId, Violations: []*types.InvariantViolation{}}
+	for period := 0; period < fund.CurrentPeriod; period++ {
+		checkPeriodInvariants(report, fund, accounts, period)
+		report.PeriodsChecked += 1
+	}
+	return report, nil
+}
+
+func checkPeriodInvariants(
+	report *types.FundInvariantReport,
+	fund *types.Fund,
+	accounts []*types.CapitalAccount,
+	period int,
+) {
+	ownership := decimal.Zero
+	openingValues := decimal.Zero
+	limitedPartnerFees := decimal.Zero
+	deposits := decimal.Zero
+	for _, account := range accounts {
+		values, err := accountPeriodValues(account, period)
+		if err != nil {
+			report.AddViolation(period, types.INVARIANT_NON_NEGATIVE_BALANCE, account.ID, err.Error())
+			continue
+		}
+		if values.closingValue.Sign() == -1 || values.openingValue.Sign() == -1 {
+			report.AddViolation(
+				period,
+				types.INVARIANT_NON_NEGATIVE_BALANCE,
+				account.ID,
+				fmt.Sprintf("closing value %s, opening value %s", values.closingValue, values.openingValue),
+			)
+		}
+		if account.Number == 0 && !values.fixedFees.IsZero() {
+			report.AddViolation(
+				period,
+				types.INVARIANT_FEE_CONSERVATION,
+				account.ID,
+				fmt.Sprintf("the general partner was charged %s in fixed fees", values.fixedFees),
+			)
+		}
+		ownership = ownership.Add(values.ownership)
+		openingValues = openingValues.Add(values.openingValue)
+		limitedPartnerFees = limitedPartnerFees.Add(values.fixedFees)
+		deposits = deposits.Add(values.deposits)
+	}
+	if !ownership.Equal(decimal.NewFromInt(1)) {
+		report.AddViolation(period, types.INVARIANT_OWNERSHIP_SUM, "", fmt.Sprintf("ownership sums to %s", ownership))
+	}
+	fundValues, err := fundPeriodValues(fund, period)
+	if err != nil {
+		report.AddViolation(period, types.INVARIANT_WEALTH_CONSERVATION, "", err.Error())
+		return
+	}
+	if !fundValues.openingValue.Equal(openingValues) {
+		report.AddViolation(
+			period,
+			types.INVARIANT_OPENING_VALUE,
+			"",
+			fmt.Sprintf("fund opening value %s, account opening values sum to %s", fundValues.openingValue, openingValues),
+		)
+	}
+	if !fundValues.fixedFees.Equal(limitedPartnerFees) {
+		report.AddViolation(
+			period,
+			types.INVARIANT_FEE_CONSERVATION,
+			"",
+			fmt.Sprintf("fund fixed fees %s, account fixed fees sum to %s", fundValues.fixedFees, limitedPartnerFees),
+		)
+	}
+	if !fundValues.deposits.Equal(deposits) {
+		report.AddViolation(
+			period,
+			types.INVARIANT_FEE_CONSERVATION,
+			"",
+			fmt.Sprintf("fund deposits %s, account deposits sum to %s", fundValues.deposits, deposits),
+		)
+	}
+	expectedOpeningValue := fundValues.closingValue.Sub(fundValues.fixedFees).Add(fundValues.deposits)
+	if !expectedOpeningValue.Equal(fundValues.openingValue) {
+		report.AddViolation(
+			period,
+			types.INVARIANT_WEALTH_CONSERVATION,
+			"",
+			fmt.Sprintf("closing value less fees plus deposits is %s, opening value is %s", expectedOpeningValue, fundValues.openingValue),
+		)
+	}
+}
+
+type periodValues struct {
+	closingValue decimal.Decimal
+	openingValue decimal.Decimal
+	fixedFees    decimal.Decimal
+	deposits     decimal.Decimal
+	ownership    decimal.Decimal
+}
+
+func accountPeriodValues(account *types.CapitalAccount, period int) (*periodValues, error) {
+	values := &periodValues{}
+	var err error
+	for _, field := range []struct {
+		values map[int]string
+		target *decimal.Decimal
+	}{
+		{account.ClosingValue, &values.closingValue},
+		{account.OpeningValue, &values.openingValue},
+		{account.FixedFees, &values.fixedFees},
+		{account.Deposits, &values.deposits},
+		{account.OwnershipPercentage, &values.ownership},
+	} {
+		*field.target, err = decimalForPeriod(field.values, period)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func fundPeriodValues(fund *types.Fund, period int) (*periodValues, error) {
+	values := &periodValues{}
+	var err error
+	for _, field := range []struct {
+		values map[int]string
+		target *decimal.Decimal
+	}{
+		{fund.ClosingValues, &values.closingValue},
+		{fund.OpeningValues, &values.openingValue},
+		{fund.FixedFees, &values.fixedFees},
+		{fund.Deposits, &values.deposits},
+	} {
+		*field.target, err = decimalForPeriod(field.values, period)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Periods without a recorded value count as zero, e.g. the closing value of the
+// inception period
+func decimalForPeriod(values map[int]string, period int) (decimal.Decimal, error) {
+	value, ok := values[period]
+	if !ok || value == "" {
+		return decimal.Zero, nil
+	}
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, pkgErrors.DecimalConversionError.
+			WithDetail("value", value).
+			WithDetail("period", strconv.Itoa(period))
+	}
+	return parsed, nil
+}
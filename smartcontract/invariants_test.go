@@ -0,0 +1,217 @@
+package smartcontract_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+)
+
+// Seeds are fixed so a failure can be replayed; add the seed of any failure found
+// with a wider search to the list
+var invariantSeeds = []int64{1, 2, 3, 5, 8, 13, 21, 34, 55, 89, 144, 233}
+
+// a random amount of whole cents in [min, max)
+func randomCents(r *rand.Rand, min int64, max int64) decimal.Decimal {
+	return decimal.New(min*100+r.Int63n((max-min)*100), -2)
+}
+
+func periodEndDate(period int) string {
+	return time.Date(2020, time.Month(period+1), 0, 0, 0, 0, 0, time.UTC).Format(types.DATE_FORMAT)
+}
+
+func TestStepFundInvariants(t *testing.T) {
+	for _, seed := range invariantSeeds {
+		seed := seed
+		t.Run(fmt.Sprintf("seed%d", seed), func(t *testing.T) {
+			runRandomFund(t, rand.New(rand.NewSource(seed)))
+		})
+	}
+}
+
+// Runs a fund with random investors, flows and prices for several periods and
+// verifies the invariants after every period
+func runRandomFund(t *testing.T, r *rand.Rand) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	accountIds := []string{}
+	addAccount := func(period int) {
+		investorId := fmt.Sprintf("investor%d", len(accountIds))
+		accountId := fmt.Sprintf("account%d", len(accountIds))
+		depositId := fmt.Sprintf("deposit%d", len(accountIds))
+		amount := randomCents(r, 1000, 100000).String()
+		transact(t, stub,
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateInvestor(ctx, investorId, investorId)
+			},
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccount(ctx, accountId, "fund", investorId, false, "0")
+			},
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccountAction(ctx, depositId, accountId, "deposit", amount, false, periodEndDate(period), period)
+			},
+		)
+		accountIds = append(accountIds, accountId)
+	}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Random Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolio(ctx, "portfolio", "fund", "Main")
+		},
+	)
+	investorCount := 2 + r.Intn(5)
+	for i := 0; i < investorCount; i++ {
+		addAccount(0)
+	}
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := admin.BootstrapFund(ctx, "fund")
+		return err
+	})
+	assertFundInvariants(t, stub, &admin)
+	assertTrialBalanceTiesOut(t, stub, &admin)
+	price := randomCents(r, 10, 100)
+	periods := 3 + r.Intn(6)
+	for period := 1; period <= periods; period++ {
+		date := periodEndDate(period)
+		units := decimal.NewFromInt(1 + r.Int63n(200))
+		price = price.Mul(decimal.NewFromFloat(0.8 + 0.45*r.Float64())).Round(2)
+		transact(t, stub,
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreatePortfolioAction(ctx, fmt.Sprintf("buy%d", period), "portfolio", "buy", date, period, "ASSET", "000000000", units.String(), "USD")
+			},
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.UpdatePortfolioValuation(ctx, "portfolio", date, "ASSET", price.String())
+			},
+		)
+		submitRandomFlows(t, r, stub, &admin, accountIds, period)
+		if r.Intn(4) == 0 {
+			addAccount(period)
+		}
+		transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.StepFund(ctx, "fund")
+			return err
+		})
+		assertFundInvariants(t, stub, &admin)
+		assertTrialBalanceTiesOut(t, stub, &admin)
+	}
+}
+
+// Deposits into or withdraws from some of the limited partners' accounts. A
+// withdrawal is at most half of what the account will hold after fees.
+func submitRandomFlows(t *testing.T, r *rand.Rand, stub *memstub.Stub, admin *smartcontract.AdminContract, accountIds []string, period int) {
+	var fundClosingValue decimal.Decimal
+	accounts := make([]*types.CapitalAccount, len(accountIds))
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		fund, err := admin.QueryFundById(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		closingValue, err := admin.CalculateFundClosingValue(ctx, fund)
+		if err != nil {
+			return err
+		}
+		fundClosingValue = decimal.RequireFromString(closingValue)
+		for i, accountId := range accountIds {
+			accounts[i], err = admin.QueryCapitalAccountById(ctx, accountId)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for i, account := range accounts[1:] {
+		actionId := fmt.Sprintf("flow%d-%d", period, i)
+		switch r.Intn(3) {
+		case 0:
+			amount := randomCents(r, 1, 20000).String()
+			transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccountAction(ctx, actionId, account.ID, "deposit", amount, false, periodEndDate(period), period)
+			})
+		case 1:
+			ownership := decimal.RequireFromString(account.OwnershipPercentage[period-1])
+			available := ownership.Mul(fundClosingValue).Mul(decimal.NewFromFloat(0.98))
+			amount := available.Mul(decimal.NewFromFloat(r.Float64() / 2)).Truncate(2)
+			if amount.Sign() <= 0 {
+				continue
+			}
+			transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccountAction(ctx, actionId, account.ID, "withdrawal", amount.String(), false, periodEndDate(period), period)
+			})
+		}
+	}
+}
+
+func assertFundInvariants(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract) {
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		report, err := admin.VerifyFundInvariants(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		for _, violation := range report.Violations {
+			t.Errorf("period %d %s %s: %s", violation.Period, violation.Invariant, violation.Account, violation.Detail)
+		}
+		if !report.OK() {
+			t.FailNow()
+		}
+		return nil
+	})
+}
+
+func TestVerifyFundInvariantsReportsViolations(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "gp", "General Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "gpAccount", "fund", "gp", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "gpDeposit", "gpAccount", "deposit", "1000", false, "01-01-2020", 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.BootstrapFund(ctx, "fund")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			account, err := admin.QueryCapitalAccountById(ctx, "gpAccount")
+			if err != nil {
+				return err
+			}
+			account.OwnershipPercentage[0] = "0.5"
+			account.OpeningValue[0] = "-1"
+			return smartcontract.SaveState(ctx, account)
+		},
+	)
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		report, err := admin.VerifyFundInvariants(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		assert.False(t, report.OK())
+		assert.Equal(t, report.PeriodsChecked, 1)
+		invariants := []string{}
+		for _, violation := range report.Violations {
+			invariants = append(invariants, violation.Invariant)
+		}
+		assert.ElementsMatch(t, invariants, []string{
+			types.INVARIANT_NON_NEGATIVE_BALANCE,
+			types.INVARIANT_OWNERSHIP_SUM,
+			types.INVARIANT_OPENING_VALUE,
+		})
+		return nil
+	})
+}
@@ -11,12 +11,16 @@ func (s *AdminContract) CreateInvestor(
 	investorId string,
 	name string,
 ) error {
+	err := types.ValidateCreateInvestorRequest(&types.CreateInvestorRequest{Name: name})
+	if err != nil {
+		return err
+	}
 	objExists, err := utils.AssetExists(ctx, investorId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if objExists {
-		return smartcontracterrors.IdAlreadyInUseError
+		return smartcontracterrors.IdAlreadyInUseError.WithDetail("id", investorId)
 	}
 	investor := types.CreateDefaultInvestor(investorId, name)
 	return SaveState(ctx, &investor)
@@ -34,9 +38,73 @@ func (s *AdminContract) QueryInvestorById(
 		return nil, nil
 	}
 	var investor types.Investor
-	err = LoadState(investorJson, &investor)
+	err = LoadState(ctx, investorJson, &investor)
 	if err != nil {
 		return nil, err
 	}
 	return &investor, nil
 }
+
+// Lists every investor in id order
+func (s *AdminContract) QueryInvestors(
+	ctx SmartContractContext,
+	pageSize int32,
+	bookmark string,
+) (*types.InvestorPage, error) {
+	investors := []*types.Investor{}
+	bookmark, err := queryIndexPage(ctx, types.INDEX_INVESTOR, []string{}, pageSize, bookmark, func(data []byte) (bool, error) {
+		var investor types.Investor
+		err := LoadState(ctx, data, &investor)
+		if err != nil {
+			return false, err
+		}
+		investors = append(investors, &investor)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.InvestorPage{
+		Records:             investors,
+		FetchedRecordsCount: int32(len(investors)),
+		Bookmark:            bookmark,
+	}
+	return page, nil
+}
+
+// Lists the investors holding capital accounts in a fund, each once however many
+// accounts they hold there. Accounts saved before the fund investor index existed
+// are only listed once MigrateIndexKeys has run for capital accounts.
+func (s *AdminContract) QueryInvestorsByFundWithPagination(
+	ctx SmartContractContext,
+	fundId string,
+	pageSize int32,
+	bookmark string,
+) (*types.InvestorPage, error) {
+	investors := []*types.Investor{}
+	bookmark, err := queryIndexPage(ctx, types.INDEX_FUNDINVESTOR, []string{fundId}, pageSize, bookmark, func(data []byte) (bool, error) {
+		var capitalAccount types.CapitalAccount
+		err := LoadState(ctx, data, &capitalAccount)
+		if err != nil {
+			return false, err
+		}
+		investor, err := s.QueryInvestorById(ctx, capitalAccount.Investor)
+		if err != nil {
+			return false, err
+		}
+		if investor == nil {
+			return false, smartcontracterrors.InvestorNotFoundError.WithDetail("investor", capitalAccount.Investor)
+		}
+		investors = append(investors, investor)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.InvestorPage{
+		Records:             investors,
+		FetchedRecordsCount: int32(len(investors)),
+		Bookmark:            bookmark,
+	}
+	return page, nil
+}
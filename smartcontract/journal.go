@@ -0,0 +1,542 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Sets the general ledger accounts the fund's journal is posted to. Roles left out
+// of the chart keep their default account. The journal is derived from the fund
+// whenever it is queried, so the chart can be changed at any time and applies to
+// every period.
+func (s *AdminContract) SetFundChartOfAccounts(
+	ctx SmartContractContext,
+	fundId string,
+	chartJSON string,
+) error {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return err
+	}
+	if fund == nil {
+		return pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	chart := types.ChartOfAccounts{}
+	if chartJSON != "" {
+		err = json.Unmarshal([]byte(chartJSON), &chart)
+		if err != nil {
+			return pkgErrors.ValidationError.WithDetail("chartOfAccounts", "must be an object of accounts by role")
+		}
+	}
+	err = chart.Validate()
+	if err != nil {
+		return err
+	}
+	fund.ChartOfAccounts = chart
+	return SaveState(ctx, fund)
+}
+
+// Returns the journal entries of a period: one per capital account action and
+// portfolio action of the period and, once the period is closed, the entries of
+// its close. A negative period is the last closed period.
+func (s *AdminContract) QueryJournal(
+	ctx SmartContractContext,
+	fundId string,
+	period int,
+) (*types.Journal, error) {
+	fund, period, err := s.queryJournalFund(ctx, fundId, period)
+	if err != nil {
+		return nil, err
+	}
+	books, err := postJournal(ctx, fund, period)
+	if err != nil {
+		return nil, err
+	}
+	journal := &types.Journal{
+		Fund:     fund.ID,
+		Period:   period,
+		Closed:   period < fund.CurrentPeriod,
+		Accounts: books.chart.Accounts(),
+		Entries:  []*types.JournalEntry{},
+		Unpriced: books.unpricedIn(period, period),
+	}
+	for _, entry := range books.entries {
+		if entry.Period == period {
+			journal.Entries = append(journal.Entries, entry)
+		}
+	}
+	return journal, nil
+}
+
+// Returns the balance of every account of the fund's chart after the journal
+// entries of every period up to and including period. A negative period is the
+// last closed period.
+func (s *AdminContract) QueryTrialBalance(
+	ctx SmartContractContext,
+	fundId string,
+	period int,
+) (*types.TrialBalance, error) {
+	fund, period, err := s.queryJournalFund(ctx, fundId, period)
+	if err != nil {
+		return nil, err
+	}
+	books, err := postJournal(ctx, fund, period)
+	if err != nil {
+		return nil, err
+	}
+	trialBalance := &types.TrialBalance{
+		Fund:     fund.ID,
+		Period:   period,
+		Closed:   period < fund.CurrentPeriod,
+		Lines:    []*types.TrialBalanceLine{},
+		Unpriced: books.unpricedIn(0, period),
+	}
+	totalDebit, totalCredit := decimal.Zero, decimal.Zero
+	for _, account := range books.chart.Accounts() {
+		balance := books.balances[account.Number]
+		debit, credit := decimal.Zero, decimal.Zero
+		if balance.Sign() == 1 {
+			debit = balance
+		} else {
+			credit = balance.Neg()
+		}
+		totalDebit = totalDebit.Add(debit)
+		totalCredit = totalCredit.Add(credit)
+		trialBalance.Lines = append(trialBalance.Lines, &types.TrialBalanceLine{
+			Account: account.Number,
+			Name:    account.Name,
+			Type:    account.Type,
+			Debit:   books.policy.FormatCurrency(debit),
+			Credit:  books.policy.FormatCurrency(credit),
+		})
+	}
+	trialBalance.TotalDebit = books.policy.FormatCurrency(totalDebit)
+	trialBalance.TotalCredit = books.policy.FormatCurrency(totalCredit)
+	trialBalance.Balanced = totalDebit.Equal(totalCredit)
+	return trialBalance, nil
+}
+
+// Loads the fund and resolves the period of a journal query. Periods after the
+// current one have no entries yet.
+func (s *AdminContract) queryJournalFund(
+	ctx SmartContractContext,
+	fundId string,
+	period int,
+) (*types.Fund, int, error) {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if fund == nil {
+		return nil, 0, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	if period < 0 {
+		period = fund.PreviousPeriod()
+		if period < 0 {
+			period = 0
+		}
+	}
+	if period > fund.CurrentPeriod {
+		return nil, 0, pkgErrors.ValidationError.WithDetail("period", "is after the current period "+strconv.Itoa(fund.CurrentPeriod))
+	}
+	return fund, period, nil
+}
+
+// The journal entries of a fund in the order they are posted
+type journalBooks struct {
+	fund     *types.Fund
+	chart    types.ChartOfAccounts
+	policy   types.RoundingPolicy
+	entries  []*types.JournalEntry
+	unpriced []*types.PortfolioAction
+	// The balance of each account by number, debits less credits
+	balances map[string]decimal.Decimal
+}
+
+// Posts the entries of every period up to and including lastPeriod. Each period
+// posts its capital account actions, then its portfolio actions, both by date,
+// then its close if it is closed.
+func postJournal(ctx SmartContractContext, fund *types.Fund, lastPeriod int) (*journalBooks, error) {
+	capitalAccountActions, err := queryCapitalAccountActionsByFundIndex(ctx, fund.ID)
+	if err != nil {
+		return nil, err
+	}
+	portfolioActions, err := queryPortfolioActionsByFundIndex(ctx, fund.ID)
+	if err != nil {
+		return nil, err
+	}
+	portfolios, err := queryPortfoliosByFund(ctx, fund.ID)
+	if err != nil {
+		return nil, err
+	}
+	portfoliosById := map[string]*types.Portfolio{}
+	for _, portfolio := range portfolios {
+		portfoliosById[portfolio.ID] = portfolio
+	}
+	accounts, err := queryCapitalAccountsByFund(ctx, fund.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(accounts, func(i, j int) bool {
+		if accounts[i].Number != accounts[j].Number {
+			return accounts[i].Number < accounts[j].Number
+		}
+		return accounts[i].ID < accounts[j].ID
+	})
+	sort.SliceStable(capitalAccountActions, func(i, j int) bool {
+		return actionBefore(capitalAccountActions[i].Date, capitalAccountActions[i].ID, capitalAccountActions[j].Date, capitalAccountActions[j].ID)
+	})
+	sort.SliceStable(portfolioActions, func(i, j int) bool {
+		return actionBefore(portfolioActions[i].Date, portfolioActions[i].ID, portfolioActions[j].Date, portfolioActions[j].ID)
+	})
+
+	books := &journalBooks{
+		fund:     fund,
+		chart:    fund.ChartOfAccounts.Complete(),
+		policy:   fund.RoundingPolicy,
+		entries:  []*types.JournalEntry{},
+		balances: map[string]decimal.Decimal{},
+	}
+	//closes without a recorded time are dated by the last entry before them
+	lastDate := fund.InceptionDate
+	for period := 0; period <= lastPeriod; period++ {
+		for _, action := range capitalAccountActions {
+			if action.Period != period || action.Status == types.TX_STATUS_ERROR {
+				continue
+			}
+			err = books.postCapitalAccountAction(action)
+			if err != nil {
+				return nil, err
+			}
+			lastDate = laterDate(lastDate, action.Date)
+		}
+		for _, action := range portfolioActions {
+			if action.Period != period || action.Status == types.TX_STATUS_ERROR {
+				continue
+			}
+			err = books.postPortfolioAction(action, portfoliosById[action.Portfolio])
+			if err != nil {
+				return nil, err
+			}
+			lastDate = laterDate(lastDate, action.Date)
+		}
+		if period < fund.CurrentPeriod {
+			closeDate := lastDate
+			if closedOn, ok := periodClosedOn(fund, period); ok {
+				closeDate = closedOn.Format(types.DATE_FORMAT)
+			}
+			err = books.postClose(period, closeDate, accounts)
+			if err != nil {
+				return nil, err
+			}
+			lastDate = laterDate(lastDate, closeDate)
+		}
+	}
+	return books, nil
+}
+
+// The ids of the unpriced portfolio actions of the periods from first to last
+func (b *journalBooks) unpricedIn(first int, last int) []string {
+	ids := []string{}
+	for _, action := range b.unpriced {
+		if action.Period >= first && action.Period <= last {
+			ids = append(ids, action.ID)
+		}
+	}
+	return ids
+}
+
+func (b *journalBooks) newEntry(id string, period int, date string, source string, reference string, description string) *types.JournalEntry {
+	return &types.JournalEntry{
+		ID:          id,
+		Fund:        b.fund.ID,
+		Period:      period,
+		Date:        date,
+		Source:      source,
+		Reference:   reference,
+		Description: description,
+		Lines:       []*types.JournalLine{},
+	}
+}
+
+// Adds a line debiting a positive amount or crediting a negative one to the
+// account of role. Zero amounts are left out.
+func (b *journalBooks) post(entry *types.JournalEntry, role string, amount decimal.Decimal, capitalAccount string) {
+	if amount.IsZero() {
+		return
+	}
+	account := b.chart.Account(role)
+	debit, credit := amount, decimal.Zero
+	if amount.Sign() == -1 {
+		debit, credit = decimal.Zero, amount.Neg()
+	}
+	b.balances[account.Number] = b.balances[account.Number].Add(amount)
+	entry.Lines = append(entry.Lines, &types.JournalLine{
+		Account:        account.Number,
+		AccountName:    account.Name,
+		Debit:          b.policy.FormatCurrency(debit),
+		Credit:         b.policy.FormatCurrency(credit),
+		CapitalAccount: capitalAccount,
+	})
+}
+
+// Keeps entries that have lines, since an entry of zero amounts records nothing
+func (b *journalBooks) add(entry *types.JournalEntry) {
+	if len(entry.Lines) > 0 {
+		b.entries = append(b.entries, entry)
+	}
+}
+
+func (b *journalBooks) balance(role string) decimal.Decimal {
+	return b.balances[b.chart.Account(role).Number]
+}
+
+// A deposit is cash received for capital that is credited to the account when the
+// period closes. A withdrawal is the reverse.
+func (b *journalBooks) postCapitalAccountAction(action *types.CapitalAccountAction) error {
+	amount, err := decimal.NewFromString(action.Amount)
+	if err != nil {
+		return pkgErrors.DecimalConversionError.WithDetail("value", action.Amount).WithDetail("capitalAccountAction", action.ID)
+	}
+	amount = b.policy.RoundCurrency(amount)
+	var description string
+	switch action.Type {
+	case types.CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT:
+		description = "Deposit to capital account " + action.CapitalAccount
+	case types.CAPITAL_ACCOUNT_ACTION_TYPE_WITHDRAWAL:
+		description = "Withdrawal from capital account " + action.CapitalAccount
+		amount = amount.Neg()
+	default:
+		return pkgErrors.InvalidCapitalAccountActionTypeError.WithDetail("capitalAccountAction", action.ID)
+	}
+	entry := b.newEntry(action.ID, action.Period, action.Date, types.JOURNAL_SOURCE_CAPITAL_ACCOUNT_ACTION, action.ID, description)
+	b.post(entry, types.GL_ROLE_CASH, amount, "")
+	b.post(entry, types.GL_ROLE_CAPITAL_IN_ADVANCE, amount.Neg(), action.CapitalAccount)
+	b.add(entry)
+	return nil
+}
+
+// A purchase moves the portfolio's cash into investments at the price of the
+// security on or before the date of the purchase. A sale is the reverse.
+func (b *journalBooks) postPortfolioAction(action *types.PortfolioAction, portfolio *types.Portfolio) error {
+	amount, err := decimal.NewFromString(action.Asset.Amount)
+	if err != nil {
+		return pkgErrors.DecimalConversionError.WithDetail("value", action.Asset.Amount).WithDetail("portfolioAction", action.ID)
+	}
+	price, ok := priceOnDate(portfolio, action.Asset.Name, action.Date)
+	if !ok {
+		b.unpriced = append(b.unpriced, action)
+		return nil
+	}
+	value := b.policy.RoundCurrency(amount.Mul(price))
+	var description string
+	switch action.Type {
+	case types.PORTFOLIO_ACTION_TYPE_BUY:
+		description = fmt.Sprintf("Buy %s %s at %s", action.Asset.Amount, action.Asset.Name, price)
+	case types.PORTFOLIO_ACTION_TYPE_SELL:
+		description = fmt.Sprintf("Sell %s %s at %s", action.Asset.Amount, action.Asset.Name, price)
+		value = value.Neg()
+	default:
+		return pkgErrors.InvalidPortfolioActionTypeError.WithDetail("portfolioAction", action.ID)
+	}
+	entry := b.newEntry(action.ID, action.Period, action.Date, types.JOURNAL_SOURCE_PORTFOLIO_ACTION, action.ID, description)
+	b.post(entry, types.GL_ROLE_INVESTMENTS, value, "")
+	b.post(entry, types.GL_ROLE_PORTFOLIO_CASH, value.Neg(), "")
+	b.add(entry)
+	return nil
+}
+
+// Posts the close of a period from the values StepFund stored, so the entries tie
+// to the capital account statements:
+//   - the change in the fund's value since the last opening value as unrealized
+//     gain, marking investments to the closing value. The fund is valued at the
+//     net asset value of its portfolios alone, so cash a portfolio holds at the
+//     close is taken into the gain as well, as it is for the capital accounts.
+//   - the fixed fees of the limited partners credited to the general partner
+//   - each account's share of the gain, less its fees, allocated to its capital
+//   - performance fees moved from the limited partners to the allocation account
+//   - the deposits and withdrawals of each account moved into its capital, and the
+//     cash they brought moved to the portfolios
+//
+// The bootstrap close of period 0 only moves the inception deposits.
+func (b *journalBooks) postClose(period int, date string, accounts []*types.CapitalAccount) error {
+	fundValues, err := fundPeriodValues(b.fund, period)
+	if err != nil {
+		return err
+	}
+	previousFundValues, err := fundPeriodValues(b.fund, period-1)
+	if err != nil {
+		return err
+	}
+	var generalPartner *types.CapitalAccount
+	for _, account := range accounts {
+		if account.Number == 0 {
+			generalPartner = account
+		}
+	}
+	if generalPartner == nil && !fundValues.fixedFees.IsZero() {
+		return pkgErrors.GeneralPartnerNotFoundError
+	}
+	closeEntry := func(kind string, description string) *types.JournalEntry {
+		return b.newEntry(
+			fmt.Sprintf("close-%d-%s", period, kind),
+			period,
+			date,
+			types.JOURNAL_SOURCE_CLOSE,
+			b.fund.ID,
+			fmt.Sprintf("Period %d close: %s", period, description),
+		)
+	}
+
+	//the bootstrap does not value the portfolios, so there is nothing to mark
+	entry := closeEntry("gain", "change in fair value of investments")
+	if period > 0 {
+		gain := fundValues.closingValue.Sub(previousFundValues.openingValue)
+		mark := fundValues.closingValue.Sub(b.balance(types.GL_ROLE_INVESTMENTS))
+		b.post(entry, types.GL_ROLE_INVESTMENTS, mark, "")
+		b.post(entry, types.GL_ROLE_PORTFOLIO_CASH, gain.Sub(mark), "")
+		b.post(entry, types.GL_ROLE_UNREALIZED_GAIN, gain.Neg(), "")
+	}
+	b.add(entry)
+
+	entry = closeEntry("fees", "management fees credited to the general partner")
+	if generalPartner != nil {
+		b.post(entry, types.GL_ROLE_MANAGEMENT_FEES, fundValues.fixedFees, "")
+		b.post(entry, types.GL_ROLE_INVESTOR_CAPITAL, fundValues.fixedFees.Neg(), generalPartner.ID)
+	}
+	b.add(entry)
+
+	type accountValues struct {
+		account  *types.CapitalAccount
+		current  *periodValues
+		previous *periodValues
+	}
+	values := []accountValues{}
+	for _, account := range accounts {
+		current, err := accountPeriodValues(account, period)
+		if err != nil {
+			return err
+		}
+		previous, err := accountPeriodValues(account, period-1)
+		if err != nil {
+			return err
+		}
+		values = append(values, accountValues{account, current, previous})
+	}
+
+	income := decimal.Zero
+	for _, v := range values {
+		income = income.Add(v.current.closingValue.Sub(v.previous.openingValue).Sub(v.current.fixedFees))
+	}
+	entry = closeEntry("allocation", "net income allocated to capital")
+	b.post(entry, types.GL_ROLE_INCOME_ALLOCATION, income, "")
+	for _, v := range values {
+		share := v.current.closingValue.Sub(v.previous.openingValue).Sub(v.current.fixedFees)
+		b.post(entry, types.GL_ROLE_INVESTOR_CAPITAL, share.Neg(), v.account.ID)
+	}
+	b.add(entry)
+
+	entry = closeEntry("performanceFees", "performance fees allocated")
+	performanceFees := decimal.Zero
+	for _, v := range values {
+		fees, err := decimalForPeriod(v.account.PerformanceFees, period)
+		if err != nil {
+			return err
+		}
+		b.post(entry, types.GL_ROLE_INVESTOR_CAPITAL, fees, v.account.ID)
+		performanceFees = performanceFees.Add(fees)
+	}
+	b.post(entry, types.GL_ROLE_PERFORMANCE_FEE_ALLOCATION, performanceFees.Neg(), "")
+	b.add(entry)
+
+	//the general partner's deposits include the fees already credited above
+	entry = closeEntry("capital", "deposits and withdrawals credited to capital")
+	transferred := decimal.Zero
+	for _, v := range values {
+		deposits := v.current.deposits
+		if v.account == generalPartner {
+			deposits = deposits.Sub(fundValues.fixedFees)
+		}
+		b.post(entry, types.GL_ROLE_CAPITAL_IN_ADVANCE, deposits, v.account.ID)
+		b.post(entry, types.GL_ROLE_INVESTOR_CAPITAL, deposits.Neg(), v.account.ID)
+		transferred = transferred.Add(deposits)
+	}
+	b.add(entry)
+
+	entry = closeEntry("transfer", "net capital moved to the portfolios")
+	b.post(entry, types.GL_ROLE_PORTFOLIO_CASH, transferred, "")
+	b.post(entry, types.GL_ROLE_CASH, transferred.Neg(), "")
+	b.add(entry)
+	return nil
+}
+
+// The latest price of the security recorded on or before date
+func priceOnDate(portfolio *types.Portfolio, name string, date string) (decimal.Decimal, bool) {
+	if portfolio == nil {
+		return decimal.Zero, false
+	}
+	on, err := types.ParseDate(date)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	var latest time.Time
+	price, found := decimal.Zero, false
+	for valuationDate, valuations := range portfolio.Valuations {
+		valued, ok := valuations[name]
+		if !ok {
+			continue
+		}
+		at, err := types.ParseDate(valuationDate)
+		if err != nil || at.After(on) || (found && !at.After(latest)) {
+			continue
+		}
+		parsed, err := decimal.NewFromString(valued.Price)
+		if err != nil {
+			continue
+		}
+		latest, price, found = at, parsed, true
+	}
+	return price, found
+}
+
+// Orders actions by date, then id. Dates that do not parse sort first.
+func actionBefore(date string, id string, otherDate string, otherId string) bool {
+	t, _ := types.ParseDate(date)
+	other, _ := types.ParseDate(otherDate)
+	if !t.Equal(other) {
+		return t.Before(other)
+	}
+	return id < otherId
+}
+
+func laterDate(date string, other string) string {
+	t, err := types.ParseDate(date)
+	if err != nil {
+		return other
+	}
+	o, err := types.ParseDate(other)
+	if err != nil || !o.After(t) {
+		return date
+	}
+	return other
+}
+
+// The day the transaction closing the period ran on, when the fund recorded it
+func periodClosedOn(fund *types.Fund, period int) (time.Time, bool) {
+	closedAt, ok := fund.ClosedAt[period]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(types.TIMESTAMP_FORMAT, closedAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true
+}
@@ -0,0 +1,234 @@
+package smartcontract_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// The fund of TestFundLifecycle: a general partner and a limited partner bootstrap
+// with 10000, the portfolio is worth 11000 at the first close and the limited
+// partner tops up by 500
+func journalFund(t *testing.T) (*memstub.Stub, *smartcontract.AdminContract) {
+	stub := memstub.New()
+	admin := &smartcontract.AdminContract{}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "gp", "General Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "lp", "Limited Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "gpAccount", "fund", "gp", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "lpAccount", "fund", "lp", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "gpDeposit", "gpAccount", "deposit", "1000", false, "01-01-2020", 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "lpDeposit", "lpAccount", "deposit", "9000", false, "01-01-2020", 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.BootstrapFund(ctx, "fund")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolio(ctx, "portfolio", "fund", "Main")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, "buy", "portfolio", "buy", "01-31-2020", 1, "ACME", "000000000", "100", "USD")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.UpdatePortfolioValuation(ctx, "portfolio", "01-31-2020", "ACME", "110")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "lpTopUp", "lpAccount", "deposit", "500", false, "01-31-2020", 1)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.StepFund(ctx, "fund")
+			return err
+		},
+	)
+	return stub, admin
+}
+
+func queryJournal(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, period int) *types.Journal {
+	var journal *types.Journal
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		journal, err = admin.QueryJournal(ctx, "fund", period)
+		return err
+	})
+	return journal
+}
+
+func queryTrialBalance(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, period int) *types.TrialBalance {
+	var trialBalance *types.TrialBalance
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		trialBalance, err = admin.QueryTrialBalance(ctx, "fund", period)
+		return err
+	})
+	return trialBalance
+}
+
+func assertBalancedEntries(t *testing.T, journal *types.Journal) {
+	for _, entry := range journal.Entries {
+		debits, credits := decimal.Zero, decimal.Zero
+		for _, line := range entry.Lines {
+			debits = debits.Add(decimal.RequireFromString(line.Debit))
+			credits = credits.Add(decimal.RequireFromString(line.Credit))
+		}
+		assert.True(t, debits.Equal(credits), "entry %s debits %s, credits %s", entry.ID, debits, credits)
+	}
+}
+
+// The debit balance of each account of the trial balance, credits negative
+func trialBalances(trialBalance *types.TrialBalance) map[string]string {
+	balances := map[string]string{}
+	for _, line := range trialBalance.Lines {
+		balance := decimal.RequireFromString(line.Debit).Sub(decimal.RequireFromString(line.Credit))
+		balances[line.Account] = balance.StringFixed(2)
+	}
+	return balances
+}
+
+func TestJournal(t *testing.T) {
+	stub, admin := journalFund(t)
+
+	bootstrap := queryJournal(t, stub, admin, 0)
+	assert.True(t, bootstrap.Closed)
+	assertBalancedEntries(t, bootstrap)
+	ids := []string{}
+	for _, entry := range bootstrap.Entries {
+		ids = append(ids, entry.ID)
+	}
+	assert.Equal(t, []string{"gpDeposit", "lpDeposit", "close-0-capital", "close-0-transfer"}, ids)
+	assert.Equal(t, "01-01-2020", bootstrap.Entries[0].Date)
+	assert.Equal(t, &types.JournalLine{Account: "1000", AccountName: "Cash", Debit: "1000.00", Credit: "0.00"}, bootstrap.Entries[0].Lines[0])
+	assert.Equal(t, "gpAccount", bootstrap.Entries[0].Lines[1].CapitalAccount)
+
+	//the last closed period by default
+	first := queryJournal(t, stub, admin, -1)
+	assert.Equal(t, 1, first.Period)
+	assertBalancedEntries(t, first)
+	entries := map[string]*types.JournalEntry{}
+	for _, entry := range first.Entries {
+		entries[entry.ID] = entry
+	}
+	assert.Len(t, entries, 7)
+	assert.Equal(t, types.JOURNAL_SOURCE_PORTFOLIO_ACTION, entries["buy"].Source)
+	assert.Equal(t, "11000.00", entries["buy"].Lines[0].Debit)
+	//the fees of the limited partner are credited to the general partner
+	assert.Equal(t, []*types.JournalLine{
+		{Account: "5100", AccountName: "Management fees", Debit: "198.00", Credit: "0.00"},
+		{Account: "3000", AccountName: "Investor capital", Debit: "0.00", Credit: "198.00", CapitalAccount: "gpAccount"},
+	}, entries["close-1-fees"].Lines)
+	allocation := entries["close-1-allocation"].Lines
+	assert.Equal(t, "802.00", allocation[0].Debit)
+	assert.Equal(t, "100.00", allocation[1].Credit)
+	assert.Equal(t, "702.00", allocation[2].Credit)
+	assert.Empty(t, first.Unpriced)
+
+	trialBalance := queryTrialBalance(t, stub, admin, 1)
+	assert.True(t, trialBalance.Balanced)
+	assert.Equal(t, "12500.00", trialBalance.TotalDebit)
+	assert.Len(t, trialBalance.Lines, len(types.DefaultChartOfAccounts()))
+	assert.Equal(t, map[string]string{
+		"1000": "0.00",
+		"1100": "500.00",
+		"1200": "11000.00",
+		"2100": "0.00",
+		"3000": "-11500.00",
+		"3100": "0.00",
+		"3900": "802.00",
+		"4100": "-1000.00",
+		"5100": "198.00",
+	}, trialBalances(trialBalance))
+
+	//the open period only has its actions
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		return admin.CreateCapitalAccountAction(ctx, "lpWithdrawal", "lpAccount", "withdrawal", "300", false, "02-15-2020", 2)
+	})
+	open := queryJournal(t, stub, admin, 2)
+	assert.False(t, open.Closed)
+	assert.Len(t, open.Entries, 1)
+	assert.Equal(t, "300.00", open.Entries[0].Lines[0].Credit)
+	trialBalance = queryTrialBalance(t, stub, admin, 2)
+	assert.True(t, trialBalance.Balanced)
+	assert.Equal(t, "-300.00", trialBalances(trialBalance)["1000"])
+
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := admin.QueryJournal(ctx, "fund", 3)
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		_, err = admin.QueryJournal(ctx, "missing", 0)
+		assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+		return nil
+	})
+}
+
+func TestJournalUsesTheFundsChartOfAccounts(t *testing.T) {
+	stub, admin := journalFund(t)
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		err := admin.SetFundChartOfAccounts(ctx, "fund", `{"cash":{"number":"1000","name":"Operating account","type":"asset"},"bogus":{}}`)
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		coded, _ := pkgErrors.From(err)
+		assert.Contains(t, coded.Details, "bogus")
+		err = admin.SetFundChartOfAccounts(ctx, "fund", `{"cash":{"number":"1100","name":"Operating account","type":"asset"}}`)
+		coded, _ = pkgErrors.From(err)
+		assert.Contains(t, coded.Details, "portfolioCash.number")
+		return admin.SetFundChartOfAccounts(ctx, "fund", `{"cash":{"number":"1010","name":"Operating account","type":"asset"}}`)
+	})
+	bootstrap := queryJournal(t, stub, admin, 0)
+	assert.Equal(t, "1010", bootstrap.Entries[0].Lines[0].Account)
+	assert.Equal(t, "Operating account", bootstrap.Entries[0].Lines[0].AccountName)
+	assert.Equal(t, "1200", queryJournal(t, stub, admin, 1).Entries[1].Lines[0].Account)
+	assert.True(t, queryTrialBalance(t, stub, admin, 1).Balanced)
+}
+
+func TestJournalListsUnpricedPortfolioActions(t *testing.T) {
+	stub, admin := journalFund(t)
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		return admin.CreatePortfolioAction(ctx, "unpriced", "portfolio", "buy", "02-10-2020", 2, "OTHER", "000000000", "5", "USD")
+	})
+	journal := queryJournal(t, stub, admin, 2)
+	assert.Empty(t, journal.Entries)
+	assert.Equal(t, []string{"unpriced"}, journal.Unpriced)
+	assert.Equal(t, []string{"unpriced"}, queryTrialBalance(t, stub, admin, 2).Unpriced)
+}
+
+// The trial balance balances after every close, and investor capital is the fund's
+// opening value, so the entries tie to the capital account statements
+func assertTrialBalanceTiesOut(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract) {
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		fund, err := admin.QueryFundById(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		trialBalance, err := admin.QueryTrialBalance(ctx, "fund", -1)
+		if err != nil {
+			return err
+		}
+		assert.True(t, trialBalance.Balanced, "debits %s, credits %s", trialBalance.TotalDebit, trialBalance.TotalCredit)
+		investorCapital := types.DefaultChartOfAccounts()[types.GL_ROLE_INVESTOR_CAPITAL].Number
+		for _, line := range trialBalance.Lines {
+			if line.Account == investorCapital {
+				assertDecimal(t, line.Credit, fund.OpeningValues[fund.PreviousPeriod()])
+			}
+		}
+		return nil
+	})
+}
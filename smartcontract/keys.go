@@ -0,0 +1,388 @@
+package smartcontract
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/zacharyfrederick/admin/types"
+	"github.com/zacharyfrederick/admin/types/doctypes"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func saveIndexKeys(ctx SmartContractContext, m Modeler) error {
+	for _, indexKey := range m.IndexKeys() {
+		key, err := ctx.GetStub().CreateCompositeKey(indexKey.ObjectType, indexKey.Attributes)
+		if err != nil {
+			return err
+		}
+		//the value holds the id of the document so a range query can load it without splitting the key
+		err = ctx.GetStub().PutState(key, []byte(m.GetID()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the ids stored under every index key matching the partial key. Unlike
+// rich queries, partial key queries are re-executed during validation and work
+// on both LevelDB and CouchDB peers.
+func queryIndexIds(
+	ctx SmartContractContext,
+	objectType string,
+	attributes []string,
+) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	ids := []string{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, string(queryResult.Value))
+	}
+	return ids, nil
+}
+
+// Loads a page of the documents the index keys matching the partial key point at,
+// in key order, returning the bookmark of the next page. keep decodes a document
+// and reports whether it belongs on the page, so a filtered page still fills up.
+// Range bookmarks are the key the next page starts at, so a page that fills up
+// part way through a fetch ends at the key of the first document left over.
+func queryIndexPage(
+	ctx SmartContractContext,
+	objectType string,
+	attributes []string,
+	pageSize int32,
+	bookmark string,
+	keep func(data []byte) (bool, error),
+) (string, error) {
+	pageSize = types.NormalizePageSize(pageSize)
+	var kept int32
+	for {
+		resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+			objectType,
+			attributes,
+			pageSize,
+			bookmark,
+		)
+		if err != nil {
+			return "", err
+		}
+		var fetched int32
+		for resultsIterator.HasNext() {
+			queryResult, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return "", err
+			}
+			if kept == pageSize {
+				resultsIterator.Close()
+				return queryResult.Key, nil
+			}
+			fetched += 1
+			id := string(queryResult.Value)
+			data, err := ctx.GetStub().GetState(id)
+			if err != nil {
+				resultsIterator.Close()
+				return "", err
+			}
+			if data == nil {
+				resultsIterator.Close()
+				return "", smartcontracterrors.ReadingWorldStateError.WithDetail("id", id)
+			}
+			ok, err := keep(data)
+			if err != nil {
+				resultsIterator.Close()
+				return "", err
+			}
+			if ok {
+				kept += 1
+			}
+		}
+		resultsIterator.Close()
+		bookmark = responseMetadata.Bookmark
+		//a short page is the last one
+		if fetched < pageSize || bookmark == "" {
+			return bookmark, nil
+		}
+	}
+}
+
+// Loads the document stored under id into m, returning false when it does not exist
+func loadStateById(ctx SmartContractContext, id string, m Modeler) (bool, error) {
+	data, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		return false, nil
+	}
+	err = LoadState(ctx, data, m)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func queryCapitalAccountsByFundIndex(
+	ctx SmartContractContext,
+	fundId string,
+) ([]*types.CapitalAccount, error) {
+	ids, err := queryIndexIds(ctx, types.INDEX_CAPITALACCOUNT, []string{fundId})
+	if err != nil {
+		return nil, err
+	}
+	var capitalAccounts []*types.CapitalAccount
+	for _, id := range ids {
+		var capitalAccount types.CapitalAccount
+		found, err := loadStateById(ctx, id, &capitalAccount)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, smartcontracterrors.CapitalAccountNotFoundError.WithDetail("capitalAccount", id)
+		}
+		capitalAccounts = append(capitalAccounts, &capitalAccount)
+	}
+	return capitalAccounts, nil
+}
+
+func queryPortfoliosByFundIndex(
+	ctx SmartContractContext,
+	fundId string,
+) ([]*types.Portfolio, error) {
+	ids, err := queryIndexIds(ctx, types.INDEX_PORTFOLIO, []string{fundId})
+	if err != nil {
+		return nil, err
+	}
+	var portfolios []*types.Portfolio
+	for _, id := range ids {
+		var portfolio types.Portfolio
+		found, err := loadStateById(ctx, id, &portfolio)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, smartcontracterrors.PortfolioNotFoundError.WithDetail("portfolio", id)
+		}
+		portfolios = append(portfolios, &portfolio)
+	}
+	return portfolios, nil
+}
+
+func queryCapitalAccountActionsByAccountPeriodIndex(
+	ctx SmartContractContext,
+	fundId string,
+	capitalAccountId string,
+	period int,
+) ([]*types.CapitalAccountAction, error) {
+	attributes := []string{fundId, capitalAccountId, types.FormatPeriodKey(period)}
+	ids, err := queryIndexIds(ctx, types.INDEX_CAPITALACCOUNTACTION, attributes)
+	if err != nil {
+		return nil, err
+	}
+	var capitalAccountActions []*types.CapitalAccountAction
+	for _, id := range ids {
+		var capitalAccountAction types.CapitalAccountAction
+		found, err := loadStateById(ctx, id, &capitalAccountAction)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, smartcontracterrors.ReadingWorldStateError
+		}
+		capitalAccountActions = append(capitalAccountActions, &capitalAccountAction)
+	}
+	return capitalAccountActions, nil
+}
+
+// Every action of every capital account of the fund
+func queryCapitalAccountActionsByFundIndex(
+	ctx SmartContractContext,
+	fundId string,
+) ([]*types.CapitalAccountAction, error) {
+	ids, err := queryIndexIds(ctx, types.INDEX_CAPITALACCOUNTACTION, []string{fundId})
+	if err != nil {
+		return nil, err
+	}
+	capitalAccountActions := []*types.CapitalAccountAction{}
+	for _, id := range ids {
+		var capitalAccountAction types.CapitalAccountAction
+		found, err := loadStateById(ctx, id, &capitalAccountAction)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, smartcontracterrors.CapitalAccountActionNotFoundError.WithDetail("capitalAccountAction", id)
+		}
+		capitalAccountActions = append(capitalAccountActions, &capitalAccountAction)
+	}
+	return capitalAccountActions, nil
+}
+
+// The actions of every capital account of the fund in period
+func queryCapitalAccountActionsByFundPeriodIndex(
+	ctx SmartContractContext,
+	fundId string,
+	period int,
+) ([]*types.CapitalAccountAction, error) {
+	capitalAccountActions, err := queryCapitalAccountActionsByFundIndex(ctx, fundId)
+	if err != nil {
+		return nil, err
+	}
+	var periodActions []*types.CapitalAccountAction
+	for _, capitalAccountAction := range capitalAccountActions {
+		if capitalAccountAction.Period == period {
+			periodActions = append(periodActions, capitalAccountAction)
+		}
+	}
+	return periodActions, nil
+}
+
+// Every action of every portfolio of the fund
+func queryPortfolioActionsByFundIndex(
+	ctx SmartContractContext,
+	fundId string,
+) ([]*types.PortfolioAction, error) {
+	ids, err := queryIndexIds(ctx, types.INDEX_PORTFOLIOACTION, []string{fundId})
+	if err != nil {
+		return nil, err
+	}
+	portfolioActions := []*types.PortfolioAction{}
+	for _, id := range ids {
+		var portfolioAction types.PortfolioAction
+		found, err := loadStateById(ctx, id, &portfolioAction)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, smartcontracterrors.PortfolioActionNotFoundError.WithDetail("portfolioAction", id)
+		}
+		portfolioActions = append(portfolioActions, &portfolioAction)
+	}
+	return portfolioActions, nil
+}
+
+func filterCapitalAccountActionsByType(
+	actions []*types.CapitalAccountAction,
+	type_ string,
+) []*types.CapitalAccountAction {
+	var filtered []*types.CapitalAccountAction
+	for _, action := range actions {
+		if action.Type == type_ {
+			filtered = append(filtered, action)
+		}
+	}
+	return filtered
+}
+
+// Returns an empty model for the doctype so that raw documents can be decoded
+// without knowing their type up front
+func newModelForDocType(docType string) (Modeler, error) {
+	switch docType {
+	case doctypes.DOCTYPE_FUND:
+		return &types.Fund{}, nil
+	case doctypes.DOCTYPE_INVESTOR:
+		return &types.Investor{}, nil
+	case doctypes.DOCTYPE_CAPITALACCOUNT:
+		return &types.CapitalAccount{}, nil
+	case doctypes.DOCTYPE_CAPITALACCOUNTACTION:
+		return &types.CapitalAccountAction{}, nil
+	case doctypes.DOCTYPE_PORTFOLIO:
+		return &types.Portfolio{}, nil
+	case doctypes.DOCTYPE_PORTFOLIOACTION:
+		return &types.PortfolioAction{}, nil
+	case doctypes.DOCTYPE_RISKLESSRATE:
+		return &types.RisklessRate{}, nil
+	case doctypes.DOCTYPE_SECURITY:
+		return &types.Security{}, nil
+	default:
+		return nil, smartcontracterrors.InvalidDocTypeError
+	}
+}
+
+// Writes a marker key pointing at the document id. Markers hold no state of their
+// own, so writing one never conflicts with a transaction marking another document.
+func saveMarker(ctx SmartContractContext, objectType string, attributes []string, id string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, []byte(id))
+}
+
+// Adds the accounts marked as mid year deposits in the fund's current period to
+// the fund's list, which still holds any that older transactions added directly
+func loadMidYearDeposits(ctx SmartContractContext, fund *types.Fund) error {
+	attributes := []string{fund.ID, types.FormatPeriodKey(fund.CurrentPeriod)}
+	ids, err := queryIndexIds(ctx, types.MARKER_MIDYEARDEPOSIT, attributes)
+	if err != nil {
+		return err
+	}
+	fund.MidYearDeposits = appendMissing(fund.MidYearDeposits, ids)
+	return nil
+}
+
+// Adds the accounts marked as having made a mid year withdrawal to the fund's list
+func loadMidYearWithdrawals(ctx SmartContractContext, fund *types.Fund) error {
+	ids, err := queryIndexIds(ctx, types.MARKER_MIDYEARWITHDRAWAL, []string{fund.ID})
+	if err != nil {
+		return err
+	}
+	fund.MidYearWithdrawals = appendMissing(fund.MidYearWithdrawals, ids)
+	return nil
+}
+
+func appendMissing(list []string, ids []string) []string {
+	for _, id := range ids {
+		if !contains(list, id) {
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// Numbers the accounts that do not have a number yet in the order they were
+// created, so the first account created for a fund is still its general partner.
+// Accounts created by the same transaction keep the order it created them in, and
+// any still tied are ordered by id.
+func assignInvestorNumbers(fund *types.Fund, accounts []*types.CapitalAccount) {
+	unassigned := []*types.CapitalAccount{}
+	for _, account := range accounts {
+		if !account.HasInvestorNumber() {
+			unassigned = append(unassigned, account)
+		}
+	}
+	sort.Slice(unassigned, func(i, j int) bool {
+		if unassigned[i].CreatedAt != unassigned[j].CreatedAt {
+			return unassigned[i].CreatedAt < unassigned[j].CreatedAt
+		}
+		if unassigned[i].CreatedOrder != unassigned[j].CreatedOrder {
+			return unassigned[i].CreatedOrder < unassigned[j].CreatedOrder
+		}
+		return unassigned[i].ID < unassigned[j].ID
+	})
+	for _, account := range unassigned {
+		account.Number = fund.NextInvestorNumber
+		fund.IncrementInvestorNumber()
+	}
+}
+
+// Returns the timestamp of the transaction in UTC, formatted so that timestamps
+// sort as strings
+func txTimestamp(ctx SmartContractContext) (string, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", err
+	}
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(types.TIMESTAMP_FORMAT), nil
+}
@@ -0,0 +1,113 @@
+package smartcontract_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+)
+
+// runs each step in a transaction of its own and fails the test on the first error
+func transact(t *testing.T, stub *memstub.Stub, steps ...func(ctx contractapi.TransactionContextInterface) error) {
+	for _, step := range steps {
+		err := stub.Transact(step)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+	}
+}
+
+func assertDecimal(t *testing.T, actual string, expected string) {
+	assert.True(t, decimal.RequireFromString(actual).Equal(decimal.RequireFromString(expected)), "expected %s, got %s", expected, actual)
+}
+
+func TestFundLifecycle(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "gp", "General Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "lp", "Limited Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "gpAccount", "fund", "gp", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "lpAccount", "fund", "lp", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "gpDeposit", "gpAccount", "deposit", "1000", false, "01-01-2020", 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "lpDeposit", "lpAccount", "deposit", "9000", false, "01-01-2020", 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.BootstrapFund(ctx, "fund")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolio(ctx, "portfolio", "fund", "Main")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, "buy", "portfolio", "buy", "01-31-2020", 1, "ACME", "000000000", "100", "USD")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.UpdatePortfolioValuation(ctx, "portfolio", "01-31-2020", "ACME", "110")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "lpTopUp", "lpAccount", "deposit", "500", false, "01-31-2020", 1)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.StepFund(ctx, "fund")
+			return err
+		},
+	)
+	var fund *types.Fund
+	var gpAccount, lpAccount *types.CapitalAccount
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		fund, err = admin.QueryFundById(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		gpAccount, err = admin.QueryCapitalAccountById(ctx, "gpAccount")
+		if err != nil {
+			return err
+		}
+		lpAccount, err = admin.QueryCapitalAccountById(ctx, "lpAccount")
+		return err
+	})
+	assert.Equal(t, fund.CurrentPeriod, 2)
+	assertDecimal(t, fund.OpeningValues[0], "10000")
+	assertDecimal(t, fund.ClosingValues[1], "11000")
+	assertDecimal(t, fund.FixedFees[1], "198")
+	assertDecimal(t, fund.Deposits[1], "698")
+	assertDecimal(t, fund.OpeningValues[1], "11500")
+	assertDecimal(t, gpAccount.ClosingValue[1], "1100")
+	assertDecimal(t, gpAccount.OpeningValue[1], "1298")
+	assertDecimal(t, lpAccount.ClosingValue[1], "9900")
+	assertDecimal(t, lpAccount.FixedFees[1], "198")
+	assertDecimal(t, lpAccount.OpeningValue[1], "10202")
+	gpOwnership := decimal.RequireFromString(gpAccount.OwnershipPercentage[1])
+	lpOwnership := decimal.RequireFromString(lpAccount.OwnershipPercentage[1])
+	assert.True(t, gpOwnership.Add(lpOwnership).Sub(decimal.NewFromInt(1)).Abs().LessThan(decimal.New(1, -12)))
+	assert.Equal(t, gpAccount.OwnershipPercentage[1][:6], "0.1128")
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		page, err := admin.QueryCapitalAccountActionsByFundWithPagination(ctx, "fund", `{"period":1}`, 10, "")
+		if err != nil {
+			return err
+		}
+		assert.Len(t, page.Records, 1)
+		assert.Equal(t, page.Records[0].ID, "lpTopUp")
+		return nil
+	})
+}
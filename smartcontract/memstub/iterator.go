@@ -0,0 +1,173 @@
+package memstub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const compositeKeyNamespace = "\x00"
+const emptyKeySubstitute = "\x01"
+const minUnicodeRuneValue = 0
+const maxUnicodeRuneValue = utf8.MaxRune
+
+var IteratorExhaustedError = errors.New("the iterator has no more results")
+
+type iterator struct {
+	kvs    []*queryresult.KV
+	index  int
+	closed bool
+}
+
+func newIterator(kvs []*queryresult.KV) shim.StateQueryIteratorInterface {
+	return &iterator{kvs: kvs}
+}
+
+func (it *iterator) HasNext() bool {
+	return !it.closed && it.index < len(it.kvs)
+}
+
+func (it *iterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, IteratorExhaustedError
+	}
+	kv := it.kvs[it.index]
+	it.index += 1
+	return kv, nil
+}
+
+func (it *iterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+	index         int
+	closed        bool
+}
+
+func (it *historyIterator) HasNext() bool {
+	return !it.closed && it.index < len(it.modifications)
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, IteratorExhaustedError
+	}
+	modification := it.modifications[it.index]
+	it.index += 1
+	return modification, nil
+}
+
+func (it *historyIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// Returns the entries of m with startKey <= key < endKey in key order. An empty
+// endKey leaves the range open ended.
+func rangeKVs(m map[string][]byte, startKey string, endKey string) []*queryresult.KV {
+	keys := make([]string, 0)
+	for key := range m {
+		if key >= startKey && (endKey == "" || key < endKey) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	kvs := make([]*queryresult.KV, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, &queryresult.KV{Key: key, Value: m[key]})
+	}
+	return kvs
+}
+
+// Range bookmarks are the key the next page starts at, as on a peer
+func paginateRange(kvs []*queryresult.KV, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata) {
+	start := 0
+	if bookmark != "" {
+		start = sort.Search(len(kvs), func(i int) bool { return kvs[i].Key >= bookmark })
+	}
+	end := len(kvs)
+	if pageSize > 0 && start+int(pageSize) < end {
+		end = start + int(pageSize)
+	}
+	page := kvs[start:end]
+	nextBookmark := ""
+	if end < len(kvs) {
+		nextBookmark = kvs[end].Key
+	}
+	metadata := &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page)), Bookmark: nextBookmark}
+	return newIterator(page), metadata
+}
+
+// Rich query bookmarks are the offset of the next page. CouchDB bookmarks are
+// opaque, so callers cannot tell the difference.
+func paginateQuery(kvs []*queryresult.KV, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	start := 0
+	if bookmark != "" {
+		offset, err := strconv.Atoi(bookmark)
+		if err != nil || offset < 0 {
+			return nil, nil, fmt.Errorf("invalid bookmark %q", bookmark)
+		}
+		start = offset
+	}
+	if start > len(kvs) {
+		start = len(kvs)
+	}
+	end := len(kvs)
+	if pageSize > 0 && start+int(pageSize) < end {
+		end = start + int(pageSize)
+	}
+	page := kvs[start:end]
+	metadata := &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page)), Bookmark: strconv.Itoa(end)}
+	return newIterator(page), metadata, nil
+}
+
+func simpleStartKey(startKey string) string {
+	if startKey == "" {
+		return emptyKeySubstitute
+	}
+	return startKey
+}
+
+func validateSimpleKeys(keys ...string) error {
+	for _, key := range keys {
+		if len(key) > 0 && key[0] == compositeKeyNamespace[0] {
+			return fmt.Errorf("first character of the key [%s] contains a null character which is not allowed", key)
+		}
+	}
+	return nil
+}
+
+func compositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	startKey, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+	return startKey, startKey + string(rune(maxUnicodeRuneValue)), nil
+}
+
+func splitCompositeKey(compositeKey string) (string, []string, error) {
+	if len(compositeKey) == 0 || compositeKey[0] != compositeKeyNamespace[0] {
+		return "", nil, fmt.Errorf("not a composite key: %q", compositeKey)
+	}
+	componentIndex := 1
+	components := []string{}
+	for i := 1; i < len(compositeKey); i++ {
+		if compositeKey[i] == minUnicodeRuneValue {
+			components = append(components, compositeKey[componentIndex:i])
+			componentIndex = i + 1
+		}
+	}
+	if len(components) == 0 {
+		return "", nil, fmt.Errorf("not a composite key: %q", compositeKey)
+	}
+	return components[0], components[1:], nil
+}
@@ -0,0 +1,398 @@
+package memstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// A parsed CouchDB rich query. Only the selector, sort, limit and skip members
+// are honoured; use_index, fields and the like are accepted and ignored.
+type query struct {
+	Selector map[string]interface{} `json:"selector"`
+	Sort     []interface{}          `json:"sort"`
+	Limit    int                    `json:"limit"`
+	Skip     int                    `json:"skip"`
+}
+
+type sortField struct {
+	path       string
+	descending bool
+}
+
+func parseQuery(queryString string) (*query, error) {
+	decoder := json.NewDecoder(strings.NewReader(queryString))
+	decoder.UseNumber()
+	q := &query{}
+	err := decoder.Decode(q)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidQueryError, err)
+	}
+	if q.Selector == nil {
+		return nil, fmt.Errorf("%w: missing selector", InvalidQueryError)
+	}
+	return q, nil
+}
+
+func (q *query) sortFields() ([]sortField, error) {
+	fields := make([]sortField, 0, len(q.Sort))
+	for _, entry := range q.Sort {
+		switch entry := entry.(type) {
+		case string:
+			fields = append(fields, sortField{path: entry})
+		case map[string]interface{}:
+			for path, direction := range entry {
+				fields = append(fields, sortField{path: path, descending: direction == "desc"})
+			}
+		default:
+			return nil, fmt.Errorf("%w: invalid sort %v", InvalidQueryError, entry)
+		}
+	}
+	return fields, nil
+}
+
+// Returns the JSON documents of state matching the selector, sorted by the sort
+// fields and then by key. Skip is applied; limit is left to the caller because
+// pagination replaces it.
+func (q *query) execute(state map[string][]byte) ([]*queryresult.KV, error) {
+	fields, err := q.sortFields()
+	if err != nil {
+		return nil, err
+	}
+	type match struct {
+		kv  *queryresult.KV
+		doc map[string]interface{}
+	}
+	matches := make([]match, 0)
+	for key, value := range state {
+		if strings.HasPrefix(key, compositeKeyNamespace) {
+			continue
+		}
+		doc, ok := decodeDocument(value)
+		if !ok {
+			continue
+		}
+		matched, err := matchSelector(doc, q.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, match{kv: &queryresult.KV{Key: key, Value: value}, doc: doc})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		for _, field := range fields {
+			a, _ := lookupField(matches[i].doc, field.path)
+			b, _ := lookupField(matches[j].doc, field.path)
+			c := compareValues(a, b)
+			if c == 0 {
+				continue
+			}
+			if field.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return matches[i].kv.Key < matches[j].kv.Key
+	})
+	kvs := make([]*queryresult.KV, 0, len(matches))
+	for _, m := range matches {
+		kvs = append(kvs, m.kv)
+	}
+	if q.Skip > 0 {
+		if q.Skip > len(kvs) {
+			q.Skip = len(kvs)
+		}
+		kvs = kvs[q.Skip:]
+	}
+	return kvs, nil
+}
+
+// Applies the query limit, or defaultLimit when the query has none
+func (q *query) limitResults(kvs []*queryresult.KV, defaultLimit int) []*queryresult.KV {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > 0 && limit < len(kvs) {
+		return kvs[:limit]
+	}
+	return kvs
+}
+
+func decodeDocument(value []byte) (map[string]interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	err := decoder.Decode(&doc)
+	if err != nil || doc == nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// Looks up a dotted field path in a document
+func lookupField(doc map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for key, condition := range selector {
+		var matched bool
+		var err error
+		switch key {
+		case "$and":
+			matched, err = matchCombination(doc, condition, true)
+		case "$or":
+			matched, err = matchCombination(doc, condition, false)
+		case "$nor":
+			matched, err = matchCombination(doc, condition, false)
+			matched = !matched
+		case "$not":
+			subSelector, ok := condition.(map[string]interface{})
+			if !ok {
+				return false, fmt.Errorf("%w: $not expects a selector", InvalidQueryError)
+			}
+			matched, err = matchSelector(doc, subSelector)
+			matched = !matched
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("%w: unsupported operator %s", InvalidQueryError, key)
+			}
+			value, exists := lookupField(doc, key)
+			matched, err = matchCondition(value, exists, condition)
+		}
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchCombination(doc map[string]interface{}, condition interface{}, all bool) (bool, error) {
+	selectors, ok := condition.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%w: combination operators expect an array", InvalidQueryError)
+	}
+	for _, s := range selectors {
+		subSelector, ok := s.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("%w: combination operators expect selectors", InvalidQueryError)
+		}
+		matched, err := matchSelector(doc, subSelector)
+		if err != nil {
+			return false, err
+		}
+		if matched != all {
+			return matched, nil
+		}
+	}
+	return all, nil
+}
+
+// A condition is either a value to compare for equality or an object of
+// operators that must all hold
+func matchCondition(value interface{}, exists bool, condition interface{}) (bool, error) {
+	operators, ok := condition.(map[string]interface{})
+	if !ok || !hasOperators(operators) {
+		return exists && compareValues(value, condition) == 0, nil
+	}
+	for operator, argument := range operators {
+		matched, err := matchOperator(value, exists, operator, argument)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func hasOperators(condition map[string]interface{}) bool {
+	for key := range condition {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperator(value interface{}, exists bool, operator string, argument interface{}) (bool, error) {
+	switch operator {
+	case "$exists":
+		want, ok := argument.(bool)
+		if !ok {
+			return false, fmt.Errorf("%w: $exists expects a boolean", InvalidQueryError)
+		}
+		return exists == want, nil
+	case "$ne":
+		return !exists || compareValues(value, argument) != 0, nil
+	case "$nin":
+		arguments, ok := argument.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("%w: $nin expects an array", InvalidQueryError)
+		}
+		for _, a := range arguments {
+			if exists && compareValues(value, a) == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	if !exists {
+		return false, nil
+	}
+	switch operator {
+	case "$eq":
+		return compareValues(value, argument) == 0, nil
+	case "$gt":
+		return sameKind(value, argument) && compareValues(value, argument) > 0, nil
+	case "$gte":
+		return sameKind(value, argument) && compareValues(value, argument) >= 0, nil
+	case "$lt":
+		return sameKind(value, argument) && compareValues(value, argument) < 0, nil
+	case "$lte":
+		return sameKind(value, argument) && compareValues(value, argument) <= 0, nil
+	case "$in":
+		arguments, ok := argument.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("%w: $in expects an array", InvalidQueryError)
+		}
+		for _, a := range arguments {
+			if compareValues(value, a) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$regex":
+		pattern, ok := argument.(string)
+		if !ok {
+			return false, fmt.Errorf("%w: $regex expects a string", InvalidQueryError)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", InvalidQueryError, err)
+		}
+		text, ok := value.(string)
+		return ok && re.MatchString(text), nil
+	case "$type":
+		return kindName(value) == argument, nil
+	case "$size":
+		array, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+		size, ok := argument.(json.Number)
+		return ok && size.String() == fmt.Sprint(len(array)), nil
+	}
+	return false, fmt.Errorf("%w: unsupported operator %s", InvalidQueryError, operator)
+}
+
+// Rank of each JSON kind in CouchDB collation order
+func kindRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case json.Number, float64, int:
+		return 2
+	case string:
+		return 3
+	case []interface{}:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func kindName(value interface{}) string {
+	return []string{"null", "boolean", "number", "string", "array", "object"}[kindRank(value)]
+}
+
+func sameKind(a, b interface{}) bool {
+	return kindRank(a) == kindRank(b)
+}
+
+func toFloat(value interface{}) float64 {
+	switch value := value.(type) {
+	case json.Number:
+		f, _ := value.Float64()
+		return f
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	}
+	return 0
+}
+
+// Compares two decoded JSON values in CouchDB collation order
+func compareValues(a, b interface{}) int {
+	rankA, rankB := kindRank(a), kindRank(b)
+	if rankA != rankB {
+		if rankA < rankB {
+			return -1
+		}
+		return 1
+	}
+	switch a := a.(type) {
+	case nil:
+		return 0
+	case bool:
+		bb := b.(bool)
+		if a == bb {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		bb := b.([]interface{})
+		for i := 0; i < len(a) && i < len(bb); i++ {
+			if c := compareValues(a[i], bb[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(a), len(bb))
+	case map[string]interface{}:
+		aJSON, _ := json.Marshal(a)
+		bJSON, _ := json.Marshal(b)
+		return bytes.Compare(aJSON, bJSON)
+	}
+	fa, fb := toFloat(a), toFloat(b)
+	if fa < fb {
+		return -1
+	}
+	if fa > fb {
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
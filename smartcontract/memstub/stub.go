@@ -0,0 +1,639 @@
+// Package memstub provides an in-memory implementation of
+// shim.ChaincodeStubInterface for running the chaincode in plain Go tests.
+//
+// The stub keeps a committed world state and buffers the writes of the current
+// transaction until it is committed, the same way a peer does: reads inside a
+// transaction see the committed state only, never the transaction's own writes.
+// Rich queries support the subset of the CouchDB selector syntax used by the
+// chaincode. A Stub is not safe for concurrent use.
+package memstub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const DEFAULT_CHANNEL_ID string = "memstub"
+
+// The time of the first transaction when none has been set with SetTime
+var DEFAULT_START_TIME = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var NoTransactionError = errors.New("no transaction in progress")
+var TransactionInProgressError = errors.New("a transaction is already in progress")
+var InvalidQueryError = errors.New("invalid rich query")
+
+// An event emitted by a committed transaction
+type Event struct {
+	TxID    string
+	Name    string
+	Payload []byte
+}
+
+// A write buffered by the current transaction. A nil value is a delete.
+type write struct {
+	value []byte
+}
+
+type transaction struct {
+	id           string
+	timestamp    time.Time
+	args         [][]byte
+	transient    map[string][]byte
+	writes       map[string]write
+	privateData  map[string]map[string]write
+	stateParams  map[string][]byte
+	privateParam map[string]map[string][]byte
+	event        *Event
+}
+
+type Stub struct {
+	ChannelID string
+
+	state        map[string][]byte
+	history      map[string][]*queryresult.KeyModification
+	privateData  map[string]map[string][]byte
+	stateParams  map[string][]byte
+	privateParam map[string]map[string][]byte
+	events       []Event
+	creator      []byte
+	clock        time.Time
+	txCount      int
+	tx           *transaction
+}
+
+func New() *Stub {
+	return &Stub{
+		ChannelID:    DEFAULT_CHANNEL_ID,
+		state:        make(map[string][]byte),
+		history:      make(map[string][]*queryresult.KeyModification),
+		privateData:  make(map[string]map[string][]byte),
+		stateParams:  make(map[string][]byte),
+		privateParam: make(map[string]map[string][]byte),
+		clock:        DEFAULT_START_TIME,
+	}
+}
+
+// Returns a transaction context backed by the stub, for calling AdminContract
+// methods directly
+func NewTransactionContext(stub *Stub) *contractapi.TransactionContext {
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(stub)
+	return ctx
+}
+
+// Sets the timestamp of the next transaction. Each transaction after it is one
+// second later than the previous one.
+func (s *Stub) SetTime(t time.Time) {
+	s.clock = t
+}
+
+// Sets the identity returned by GetCreator
+func (s *Stub) SetCreator(creator []byte) {
+	s.creator = creator
+}
+
+// Starts a transaction. An empty txID is replaced by a generated one.
+func (s *Stub) Begin(txID string, args [][]byte) error {
+	if s.tx != nil {
+		return TransactionInProgressError
+	}
+	s.txCount += 1
+	if txID == "" {
+		sum := sha256.Sum256([]byte(strconv.Itoa(s.txCount)))
+		txID = hex.EncodeToString(sum[:])
+	}
+	s.tx = &transaction{
+		id:           txID,
+		timestamp:    s.clock,
+		args:         args,
+		transient:    make(map[string][]byte),
+		writes:       make(map[string]write),
+		privateData:  make(map[string]map[string]write),
+		stateParams:  make(map[string][]byte),
+		privateParam: make(map[string]map[string][]byte),
+	}
+	s.clock = s.clock.Add(time.Second)
+	return nil
+}
+
+// Applies the writes and the event of the current transaction
+func (s *Stub) Commit() error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	tx := s.tx
+	s.tx = nil
+	txTimestamp, err := ptypes.TimestampProto(tx.timestamp)
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedWriteKeys(tx.writes) {
+		w := tx.writes[key]
+		if w.value == nil {
+			delete(s.state, key)
+		} else {
+			s.state[key] = w.value
+		}
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{
+			TxId:      tx.id,
+			Value:     w.value,
+			Timestamp: txTimestamp,
+			IsDelete:  w.value == nil,
+		})
+	}
+	for collection, writes := range tx.privateData {
+		if s.privateData[collection] == nil {
+			s.privateData[collection] = make(map[string][]byte)
+		}
+		for key, w := range writes {
+			if w.value == nil {
+				delete(s.privateData[collection], key)
+			} else {
+				s.privateData[collection][key] = w.value
+			}
+		}
+	}
+	for key, ep := range tx.stateParams {
+		s.stateParams[key] = ep
+	}
+	for collection, params := range tx.privateParam {
+		if s.privateParam[collection] == nil {
+			s.privateParam[collection] = make(map[string][]byte)
+		}
+		for key, ep := range params {
+			s.privateParam[collection][key] = ep
+		}
+	}
+	if tx.event != nil {
+		s.events = append(s.events, *tx.event)
+	}
+	return nil
+}
+
+// Discards the current transaction
+func (s *Stub) Rollback() {
+	s.tx = nil
+}
+
+// Runs fn in a transaction of its own, committing it when fn succeeds and
+// discarding it otherwise
+func (s *Stub) Transact(fn func(ctx contractapi.TransactionContextInterface) error) error {
+	err := s.Begin("", nil)
+	if err != nil {
+		return err
+	}
+	err = fn(NewTransactionContext(s))
+	if err != nil {
+		s.Rollback()
+		return err
+	}
+	return s.Commit()
+}
+
+// Invokes the chaincode with the function and arguments in a transaction of its
+// own, committing it when the response status is OK
+func (s *Stub) Invoke(cc shim.Chaincode, txID string, function string, args ...string) pb.Response {
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+	err := s.Begin(txID, invokeArgs)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	response := cc.Invoke(s)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		s.Rollback()
+		return response
+	}
+	err = s.Commit()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return response
+}
+
+// Events emitted by committed transactions, oldest first
+func (s *Stub) Events() []Event {
+	return s.events
+}
+
+// Committed keys and values, including composite keys. The map is a copy.
+func (s *Stub) State() map[string][]byte {
+	state := make(map[string][]byte, len(s.state))
+	for key, value := range s.state {
+		state[key] = value
+	}
+	return state
+}
+
+// The state as it will be once the current transaction commits, or the committed
+// state when no transaction is in progress. The map is a copy.
+func (s *Stub) PendingState() map[string][]byte {
+	state := s.State()
+	if s.tx == nil {
+		return state
+	}
+	for key, w := range s.tx.writes {
+		if w.value == nil {
+			delete(state, key)
+		} else {
+			state[key] = w.value
+		}
+	}
+	return state
+}
+
+// Replaces the committed state, e.g. to restore a snapshot taken with State.
+// History, events and private data are left as they are.
+func (s *Stub) LoadState(state map[string][]byte) {
+	s.state = make(map[string][]byte, len(state))
+	for key, value := range state {
+		s.state[key] = value
+	}
+}
+
+// Sets the transient data of the current transaction
+func (s *Stub) SetTransient(transient map[string][]byte) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	s.tx.transient = transient
+	return nil
+}
+
+func (s *Stub) GetArgs() [][]byte {
+	if s.tx == nil {
+		return nil
+	}
+	return s.tx.args
+}
+
+func (s *Stub) GetStringArgs() []string {
+	args := s.GetArgs()
+	strargs := make([]string, 0, len(args))
+	for _, arg := range args {
+		strargs = append(strargs, string(arg))
+	}
+	return strargs
+}
+
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	res := []byte{}
+	for _, arg := range s.GetArgs() {
+		res = append(res, arg...)
+	}
+	return res, nil
+}
+
+func (s *Stub) GetTxID() string {
+	if s.tx == nil {
+		return ""
+	}
+	return s.tx.id
+}
+
+func (s *Stub) GetChannelID() string {
+	return s.ChannelID
+}
+
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	return shim.Error(fmt.Sprintf("memstub cannot invoke chaincode %s", chaincodeName))
+}
+
+func (s *Stub) GetState(key string) ([]byte, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	return s.state[key], nil
+}
+
+func (s *Stub) PutState(key string, value []byte) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	s.tx.writes[key] = write{value: value}
+	return nil
+}
+
+func (s *Stub) DelState(key string) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	s.tx.writes[key] = write{}
+	return nil
+}
+
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	s.tx.stateParams[key] = ep
+	return nil
+}
+
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	return s.stateParams[key], nil
+}
+
+func (s *Stub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	err := validateSimpleKeys(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(rangeKVs(s.state, simpleStartKey(startKey), endKey)), nil
+}
+
+func (s *Stub) GetStateByRangeWithPagination(
+	startKey, endKey string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if s.tx == nil {
+		return nil, nil, NoTransactionError
+	}
+	err := validateSimpleKeys(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	iterator, metadata := paginateRange(rangeKVs(s.state, simpleStartKey(startKey), endKey), pageSize, bookmark)
+	return iterator, metadata, nil
+}
+
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	startKey, endKey, err := compositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(rangeKVs(s.state, startKey, endKey)), nil
+}
+
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(
+	objectType string,
+	keys []string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if s.tx == nil {
+		return nil, nil, NoTransactionError
+	}
+	startKey, endKey, err := compositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	iterator, metadata := paginateRange(rangeKVs(s.state, startKey, endKey), pageSize, bookmark)
+	return iterator, metadata, nil
+}
+
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	return splitCompositeKey(compositeKey)
+}
+
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := q.execute(s.state)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(q.limitResults(kvs, 0)), nil
+}
+
+func (s *Stub) GetQueryResultWithPagination(
+	query string,
+	pageSize int32,
+	bookmark string,
+) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if s.tx == nil {
+		return nil, nil, NoTransactionError
+	}
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	kvs, err := q.execute(s.state)
+	if err != nil {
+		return nil, nil, err
+	}
+	iterator, metadata, err := paginateQuery(kvs, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	return iterator, metadata, nil
+}
+
+// Returns the modifications of key, most recent first, as a peer does
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	modifications := s.history[key]
+	reversed := make([]*queryresult.KeyModification, 0, len(modifications))
+	for i := len(modifications) - 1; i >= 0; i-- {
+		reversed = append(reversed, modifications[i])
+	}
+	return &historyIterator{modifications: reversed}, nil
+}
+
+func (s *Stub) GetPrivateData(collection, key string) ([]byte, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	return s.privateData[collection][key], nil
+}
+
+func (s *Stub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	value, ok := s.privateData[collection][key]
+	if !ok {
+		return nil, nil
+	}
+	sum := sha256.Sum256(value)
+	return sum[:], nil
+}
+
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	if collection == "" {
+		return errors.New("collection must not be an empty string")
+	}
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	s.privateWrites(collection)[key] = write{value: value}
+	return nil
+}
+
+func (s *Stub) DelPrivateData(collection, key string) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	s.privateWrites(collection)[key] = write{}
+	return nil
+}
+
+func (s *Stub) privateWrites(collection string) map[string]write {
+	if s.tx.privateData[collection] == nil {
+		s.tx.privateData[collection] = make(map[string]write)
+	}
+	return s.tx.privateData[collection]
+}
+
+func (s *Stub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	if s.tx.privateParam[collection] == nil {
+		s.tx.privateParam[collection] = make(map[string][]byte)
+	}
+	s.tx.privateParam[collection][key] = ep
+	return nil
+}
+
+func (s *Stub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	return s.privateParam[collection][key], nil
+}
+
+func (s *Stub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	err := validateSimpleKeys(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(rangeKVs(s.privateData[collection], simpleStartKey(startKey), endKey)), nil
+}
+
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	startKey, endKey, err := compositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(rangeKVs(s.privateData[collection], startKey, endKey)), nil
+}
+
+func (s *Stub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := q.execute(s.privateData[collection])
+	if err != nil {
+		return nil, err
+	}
+	return newIterator(q.limitResults(kvs, 0)), nil
+}
+
+func (s *Stub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	return s.tx.transient, nil
+}
+
+func (s *Stub) GetBinding() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *Stub) GetDecorations() map[string][]byte {
+	return nil
+}
+
+func (s *Stub) GetSignedProposal() (*pb.SignedProposal, error) {
+	return &pb.SignedProposal{}, nil
+}
+
+func (s *Stub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	if s.tx == nil {
+		return nil, NoTransactionError
+	}
+	return ptypes.TimestampProto(s.tx.timestamp)
+}
+
+// Only the last event set by a transaction is emitted, as on a peer
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if s.tx == nil {
+		return NoTransactionError
+	}
+	if name == "" {
+		return errors.New("event name can not be empty string")
+	}
+	s.tx.event = &Event{TxID: s.tx.id, Name: name, Payload: payload}
+	return nil
+}
+
+func sortedWriteKeys(writes map[string]write) []string {
+	keys := make([]string, 0, len(writes))
+	for key := range writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
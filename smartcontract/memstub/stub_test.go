@@ -0,0 +1,256 @@
+package memstub_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+)
+
+func putStates(t *testing.T, stub *memstub.Stub, states map[string]string) {
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		for key, value := range states {
+			err := ctx.GetStub().PutState(key, []byte(value))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func readKeys(t *testing.T, iterator shim.StateQueryIteratorInterface) []string {
+	defer iterator.Close()
+	keys := []string{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		assert.Nil(t, err)
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+func TestWritesAreBufferedUntilCommit(t *testing.T) {
+	stub := memstub.New()
+	err := stub.Begin("tx1", nil)
+	assert.Nil(t, err)
+	err = stub.PutState("key", []byte("value"))
+	assert.Nil(t, err)
+	value, err := stub.GetState("key")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	assert.Equal(t, []byte("value"), stub.PendingState()["key"])
+	assert.NotContains(t, stub.State(), "key")
+	err = stub.Commit()
+	assert.Nil(t, err)
+	err = stub.Begin("tx2", nil)
+	assert.Nil(t, err)
+	value, err = stub.GetState("key")
+	assert.Nil(t, err)
+	assert.Equal(t, value, []byte("value"))
+	stub.Rollback()
+}
+
+func TestTransactRollsBackOnError(t *testing.T) {
+	stub := memstub.New()
+	failure := errors.New("failure")
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		err := ctx.GetStub().PutState("key", []byte("value"))
+		assert.Nil(t, err)
+		err = ctx.GetStub().SetEvent("event", []byte("payload"))
+		assert.Nil(t, err)
+		return failure
+	})
+	assert.Equal(t, err, failure)
+	assert.Empty(t, stub.State())
+	assert.Empty(t, stub.Events())
+}
+
+func TestWritesOutsideTransaction(t *testing.T) {
+	stub := memstub.New()
+	err := stub.PutState("key", []byte("value"))
+	assert.Equal(t, err, memstub.NoTransactionError)
+}
+
+func TestTransactionIdAndTimestamp(t *testing.T) {
+	stub := memstub.New()
+	start := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	stub.SetTime(start)
+	txIds := []string{}
+	for i := 0; i < 2; i++ {
+		err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+			txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+			assert.Nil(t, err)
+			txTime, err := ptypes.Timestamp(txTimestamp)
+			assert.Nil(t, err)
+			assert.Equal(t, txTime, start.Add(time.Duration(i)*time.Second))
+			txIds = append(txIds, ctx.GetStub().GetTxID())
+			return nil
+		})
+		assert.Nil(t, err)
+	}
+	assert.Len(t, txIds[0], 64)
+	assert.NotEqual(t, txIds[0], txIds[1])
+}
+
+func TestHistoryAndEvents(t *testing.T) {
+	stub := memstub.New()
+	putStates(t, stub, map[string]string{"key": "first"})
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		err := ctx.GetStub().DelState("key")
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().SetEvent("ignored", []byte("1"))
+		if err != nil {
+			return err
+		}
+		return ctx.GetStub().SetEvent("deleted", []byte("2"))
+	})
+	assert.Nil(t, err)
+	events := stub.Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, events[0].Name, "deleted")
+	assert.Equal(t, events[0].Payload, []byte("2"))
+	err = stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		history, err := ctx.GetStub().GetHistoryForKey("key")
+		assert.Nil(t, err)
+		defer history.Close()
+		latest, err := history.Next()
+		assert.Nil(t, err)
+		assert.True(t, latest.IsDelete)
+		assert.Equal(t, latest.TxId, events[0].TxID)
+		first, err := history.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, first.Value, []byte("first"))
+		assert.False(t, history.HasNext())
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestCompositeKeys(t *testing.T) {
+	stub := memstub.New()
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		for _, attributes := range [][]string{{"fund1", "b"}, {"fund1", "a"}, {"fund2", "c"}} {
+			key, err := ctx.GetStub().CreateCompositeKey("account", attributes)
+			assert.Nil(t, err)
+			err = ctx.GetStub().PutState(key, []byte(attributes[1]))
+			assert.Nil(t, err)
+		}
+		return ctx.GetStub().PutState("plain", []byte("{}"))
+	})
+	assert.Nil(t, err)
+	err = stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("account", []string{"fund1"})
+		assert.Nil(t, err)
+		keys := readKeys(t, iterator)
+		assert.Len(t, keys, 2)
+		objectType, attributes, err := ctx.GetStub().SplitCompositeKey(keys[0])
+		assert.Nil(t, err)
+		assert.Equal(t, objectType, "account")
+		assert.Equal(t, attributes, []string{"fund1", "a"})
+		iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("account", []string{}, 2, "")
+		assert.Nil(t, err)
+		assert.Len(t, readKeys(t, iterator), 2)
+		iterator, _, err = ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("account", []string{}, 2, metadata.Bookmark)
+		assert.Nil(t, err)
+		assert.Len(t, readKeys(t, iterator), 1)
+		iterator, err = ctx.GetStub().GetStateByRange("", "")
+		assert.Nil(t, err)
+		assert.Equal(t, readKeys(t, iterator), []string{"plain"})
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestRichQueries(t *testing.T) {
+	stub := memstub.New()
+	putStates(t, stub, map[string]string{
+		"a1":    `{"docType":"account","fund":"f1","number":2,"name":"b"}`,
+		"a2":    `{"docType":"account","fund":"f1","number":1,"name":"a"}`,
+		"a3":    `{"docType":"account","fund":"f2","number":3,"name":"c","closed":true}`,
+		"f1":    `{"docType":"fund","id":"f1"}`,
+		"plain": "not json",
+	})
+	tests := []struct {
+		query string
+		keys  []string
+	}{
+		{`{"selector":{"docType":"account","fund":"f1"}}`, []string{"a1", "a2"}},
+		{`{"selector":{"docType":"account"},"sort":[{"number":"desc"}]}`, []string{"a3", "a1", "a2"}},
+		{`{"selector":{"docType":"account"},"sort":["name"],"limit":2}`, []string{"a2", "a1"}},
+		{`{"selector":{"number":{"$gt":1,"$lte":3}}}`, []string{"a1", "a3"}},
+		{`{"selector":{"name":{"$in":["a","c"]}}}`, []string{"a2", "a3"}},
+		{`{"selector":{"docType":"account","name":{"$nin":["a"]}}}`, []string{"a1", "a3"}},
+		{`{"selector":{"closed":{"$exists":true}}}`, []string{"a3"}},
+		{`{"selector":{"docType":"account","closed":{"$ne":true}}}`, []string{"a1", "a2"}},
+		{`{"selector":{"$or":[{"docType":"fund"},{"number":3}]}}`, []string{"a3", "f1"}},
+		{`{"selector":{"$and":[{"fund":"f1"},{"name":{"$gte":"b"}}]}}`, []string{"a1"}},
+		{`{"selector":{"name":{"$regex":"^[ab]$"}}}`, []string{"a1", "a2"}},
+		{`{"selector":{"id":{"$gt":""}},"use_index":["_design/indexDoc","index"]}`, []string{"f1"}},
+	}
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		for _, test := range tests {
+			iterator, err := ctx.GetStub().GetQueryResult(test.query)
+			assert.Nil(t, err, test.query)
+			assert.Equal(t, readKeys(t, iterator), test.keys, test.query)
+		}
+		_, err := ctx.GetStub().GetQueryResult(`{"selector":{"number":{"$elemMatch":{"$eq":1}}}}`)
+		assert.True(t, errors.Is(err, memstub.InvalidQueryError))
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestRichQueryPagination(t *testing.T) {
+	stub := memstub.New()
+	putStates(t, stub, map[string]string{
+		"a1": `{"docType":"account","number":1}`,
+		"a2": `{"docType":"account","number":2}`,
+		"a3": `{"docType":"account","number":3}`,
+	})
+	query := `{"selector":{"docType":"account"},"sort":[{"number":"asc"}]}`
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, 2, "")
+		assert.Nil(t, err)
+		assert.Equal(t, readKeys(t, iterator), []string{"a1", "a2"})
+		assert.Equal(t, metadata.FetchedRecordsCount, int32(2))
+		iterator, metadata, err = ctx.GetStub().GetQueryResultWithPagination(query, 2, metadata.Bookmark)
+		assert.Nil(t, err)
+		assert.Equal(t, readKeys(t, iterator), []string{"a3"})
+		assert.Equal(t, metadata.FetchedRecordsCount, int32(1))
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestPrivateData(t *testing.T) {
+	stub := memstub.New()
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().PutPrivateData("collection", "key", []byte(`{"secret":true}`))
+	})
+	assert.Nil(t, err)
+	err = stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		value, err := ctx.GetStub().GetPrivateData("collection", "key")
+		assert.Nil(t, err)
+		assert.Equal(t, value, []byte(`{"secret":true}`))
+		hash, err := ctx.GetStub().GetPrivateDataHash("collection", "key")
+		assert.Nil(t, err)
+		assert.Len(t, hash, 32)
+		iterator, err := ctx.GetStub().GetPrivateDataQueryResult("collection", `{"selector":{"secret":true}}`)
+		assert.Nil(t, err)
+		assert.Equal(t, readKeys(t, iterator), []string{"key"})
+		value, err = ctx.GetStub().GetState("key")
+		assert.Nil(t, err)
+		assert.Nil(t, value)
+		return nil
+	})
+	assert.Nil(t, err)
+}
@@ -0,0 +1,127 @@
+package smartcontract
+
+import (
+	"encoding/json"
+
+	"github.com/zacharyfrederick/admin/types"
+)
+
+// Writes the composite index keys for documents that were stored before the
+// composite key layout existed. Documents are visited in id order after
+// startAfterId, batchSize at a time, so a large ledger can be migrated over
+// several transactions. This is a one-off administrative step; it relies on a
+// rich query and so has to run against a CouchDB peer.
+func (s *AdminContract) MigrateIndexKeys(
+	ctx SmartContractContext,
+	docType string,
+	startAfterId string,
+	batchSize int32,
+) (*types.MigrationProgress, error) {
+	return migrateDocTypeBatch(ctx, docType, startAfterId, batchSize, func(data []byte, model Modeler) (bool, error) {
+		err := LoadState(ctx, data, model)
+		if err != nil {
+			return false, err
+		}
+		return false, saveIndexKeys(ctx, model)
+	})
+}
+
+// Rewrites stored documents of docType at the current schema version, applying
+// the registered upgrades. Batching works as for MigrateIndexKeys. Documents that
+// are already current are left untouched. A SchemaMigration event carrying the
+// progress of the batch is emitted so operators can follow a long migration.
+func (s *AdminContract) MigrateSchema(
+	ctx SmartContractContext,
+	docType string,
+	startAfterId string,
+	batchSize int32,
+) (*types.MigrationProgress, error) {
+	progress, err := migrateDocTypeBatch(ctx, docType, startAfterId, batchSize, func(data []byte, model Modeler) (bool, error) {
+		upgraded, changed, err := upgradeDocument(ctx, data)
+		if err != nil {
+			return false, err
+		}
+		err = LoadState(ctx, upgraded, model)
+		if err != nil || !changed {
+			return false, err
+		}
+		return true, SaveState(ctx, model)
+	})
+	if err != nil {
+		return nil, err
+	}
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.GetStub().SetEvent(SCHEMA_MIGRATION_EVENT, progressJSON)
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+const SCHEMA_MIGRATION_EVENT string = "SchemaMigration"
+
+// Visits one batch of documents of docType in id order after startAfterId.
+// migrate reports whether it rewrote the document.
+func migrateDocTypeBatch(
+	ctx SmartContractContext,
+	docType string,
+	startAfterId string,
+	batchSize int32,
+	migrate func(data []byte, model Modeler) (bool, error),
+) (*types.MigrationProgress, error) {
+	batchSize = types.NormalizePageSize(batchSize)
+	_, err := newModelForDocType(docType)
+	if err != nil {
+		return nil, err
+	}
+	queryString, err := buildDocTypeBatchQuery(docType, startAfterId, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	progress := &types.MigrationProgress{DocType: docType, LastID: startAfterId}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		model, err := newModelForDocType(docType)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := migrate(queryResult.Value, model)
+		if err != nil {
+			return nil, err
+		}
+		progress.Processed += 1
+		if changed {
+			progress.Upgraded += 1
+		}
+		progress.LastID = model.GetID()
+	}
+	progress.Done = progress.Processed < batchSize
+	return progress, nil
+}
+
+func buildDocTypeBatchQuery(docType string, startAfterId string, batchSize int32) (string, error) {
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": docType,
+			"id":      map[string]string{"$gt": startAfterId},
+		},
+		"sort":  []map[string]string{{"docType": "asc"}, {"id": "asc"}},
+		"limit": batchSize,
+	}
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	return string(queryJSON), nil
+}
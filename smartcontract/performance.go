@@ -0,0 +1,447 @@
+package smartcontract
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Days in a year when discounting the cash flows of an IRR
+const IRR_DAYS_PER_YEAR float64 = 365
+
+// Bisection steps when solving for an IRR, enough for far more precision than
+// the result is reported with
+const IRR_ITERATIONS int = 200
+
+// Sets how many periods the fund closes a year. It only changes how performance is
+// annualized, not when performance fees are charged.
+func (s *AdminContract) SetFundPeriodsPerYear(
+	ctx SmartContractContext,
+	fundId string,
+	periodsPerYear int,
+) error {
+	err := types.ValidatePeriodsPerYear(periodsPerYear)
+	if err != nil {
+		return err
+	}
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return err
+	}
+	if fund == nil {
+		return pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	fund.PeriodsPerYear = periodsPerYear
+	return SaveState(ctx, fund)
+}
+
+// Returns the performance of the fund, of each class of capital accounts and of
+// each capital account through a closed period, a negative period being the last
+// closed one. The Sharpe ratios use the riskless rate in effect at each close,
+// and are left out when risklessRateId is empty.
+func (s *AdminContract) QueryPerformance(
+	ctx SmartContractContext,
+	fundId string,
+	period int,
+	risklessRateId string,
+) (*types.PerformanceReport, error) {
+	fund, err := s.QueryFundById(ctx, fundId)
+	if err != nil {
+		return nil, err
+	}
+	if fund == nil {
+		return nil, pkgErrors.FundNotFoundError.WithDetail("fund", fundId)
+	}
+	if fund.CurrentPeriod == 0 {
+		return nil, pkgErrors.ValidationError.WithDetail("period", "the fund has not been bootstrapped")
+	}
+	if period < 0 {
+		period = fund.PreviousPeriod()
+	}
+	if period >= fund.CurrentPeriod {
+		return nil, pkgErrors.ValidationError.WithDetail("period", "is not closed, the last closed period is "+strconv.Itoa(fund.PreviousPeriod()))
+	}
+
+	analysis := &performanceAnalysis{
+		policy:         fund.RoundingPolicy,
+		periodsPerYear: fund.AnnualPeriods(),
+		dates:          make([]time.Time, period+1),
+	}
+	inception, err := types.ParseDate(fund.InceptionDate)
+	if err != nil {
+		return nil, pkgErrors.ValidationError.WithDetail("inceptionDate", types.CheckDate(fund.InceptionDate))
+	}
+	for p := 0; p <= period; p++ {
+		analysis.dates[p] = periodCloseDate(fund, p, inception)
+	}
+	if risklessRateId != "" {
+		risklessRate, err := queryRisklessRate(ctx, risklessRateId)
+		if err != nil {
+			return nil, err
+		}
+		analysis.riskless = make([]float64, period+1)
+		for p := 1; p <= period; p++ {
+			rate, ok := risklessRate.RateOn(analysis.dates[p])
+			if !ok {
+				return nil, pkgErrors.ValidationError.WithDetail("risklessRate", "has no rate on or before "+analysis.dates[p].Format(types.DATE_FORMAT))
+			}
+			analysis.riskless[p], _ = rate.Float64()
+		}
+	}
+
+	accounts, err := queryCapitalAccountsByFund(ctx, fund.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(accounts, func(i, j int) bool {
+		if accounts[i].Number != accounts[j].Number {
+			return accounts[i].Number < accounts[j].Number
+		}
+		return accounts[i].ID < accounts[j].ID
+	})
+	actions, err := queryCapitalAccountActionsByFundIndex(ctx, fund.ID)
+	if err != nil {
+		return nil, err
+	}
+	flowsByAccount := map[string][]cashFlow{}
+	for _, action := range actions {
+		if action.Period > period || action.Status == types.TX_STATUS_ERROR {
+			continue
+		}
+		flow, err := actionCashFlow(action)
+		if err != nil {
+			return nil, err
+		}
+		flowsByAccount[action.CapitalAccount] = append(flowsByAccount[action.CapitalAccount], flow)
+	}
+
+	fundSeries, err := newPerformanceSeries(period, fund.OpeningValues, fund.ClosingValues, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	report := &types.PerformanceReport{
+		Fund:           fund.ID,
+		Period:         period,
+		PeriodsPerYear: analysis.periodsPerYear,
+		RisklessRate:   risklessRateId,
+		Classes:        []*types.Performance{},
+		Accounts:       []*types.Performance{},
+	}
+	classes := []string{}
+	classSeries := map[string]*performanceSeries{}
+	for _, account := range accounts {
+		series, err := newPerformanceSeries(period, account.OpeningValue, account.ClosingValue, account.FixedFees, account.PerformanceFees)
+		if err != nil {
+			return nil, err
+		}
+		series.flows = flowsByAccount[account.ID]
+		fundSeries.flows = append(fundSeries.flows, series.flows...)
+		report.Accounts = append(report.Accounts, analysis.analyze(types.PERFORMANCE_LEVEL_CAPITAL_ACCOUNT, account.ID, series))
+
+		class := account.FeeClass()
+		if _, ok := classSeries[class]; !ok {
+			classes = append(classes, class)
+			classSeries[class] = &performanceSeries{
+				start: make([]decimal.Decimal, period+1),
+				end:   make([]decimal.Decimal, period+1),
+				after: make([]decimal.Decimal, period+1),
+			}
+		}
+		classSeries[class].add(series)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		report.Classes = append(report.Classes, analysis.analyze(types.PERFORMANCE_LEVEL_CLASS, class, classSeries[class]))
+	}
+	report.FundPerformance = analysis.analyze(types.PERFORMANCE_LEVEL_FUND, fund.ID, fundSeries)
+	return report, nil
+}
+
+// The day a period closed. Periods closed before the close was recorded are taken
+// to close at the end of their month, counting the first period as the month of
+// the inception date.
+func periodCloseDate(fund *types.Fund, period int, inception time.Time) time.Time {
+	if closedOn, ok := periodClosedOn(fund, period); ok {
+		return closedOn
+	}
+	if period == 0 {
+		return inception
+	}
+	//day zero of the month after the period's month is its last day
+	return time.Date(inception.Year(), inception.Month()+time.Month(period), 0, 0, 0, 0, 0, inception.Location())
+}
+
+// A dated amount from the investor's side: contributions are negative and
+// withdrawals and the value held at the end positive
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+func actionCashFlow(action *types.CapitalAccountAction) (cashFlow, error) {
+	date, err := types.ParseDate(action.Date)
+	if err != nil {
+		return cashFlow{}, pkgErrors.ValidationError.WithDetail("date", types.CheckDate(action.Date)).WithDetail("capitalAccountAction", action.ID)
+	}
+	amount, err := decimal.NewFromString(action.Amount)
+	if err != nil {
+		return cashFlow{}, pkgErrors.DecimalConversionError.WithDetail("value", action.Amount).WithDetail("capitalAccountAction", action.ID)
+	}
+	value, _ := amount.Float64()
+	switch action.Type {
+	case types.CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT:
+		return cashFlow{date: date, amount: -value}, nil
+	case types.CAPITAL_ACCOUNT_ACTION_TYPE_WITHDRAWAL:
+		return cashFlow{date: date, amount: value}, nil
+	}
+	return cashFlow{}, pkgErrors.InvalidCapitalAccountActionTypeError.WithDetail("capitalAccountAction", action.ID)
+}
+
+// The values of a fund, class or capital account in each period up to the last.
+// A period starts at the value opened the period before and ends at the closing
+// value net of fees. After is the value opened once the period's deposits and
+// withdrawals are credited.
+type performanceSeries struct {
+	start []decimal.Decimal
+	end   []decimal.Decimal
+	after []decimal.Decimal
+	flows []cashFlow
+}
+
+// Builds the series from the value maps of a fund or capital account. Fees are
+// nil for the fund, whose fees are paid to the general partner's account and so
+// never leave it.
+func newPerformanceSeries(last int, opening map[int]string, closing map[int]string, fixedFees map[int]string, performanceFees map[int]string) (*performanceSeries, error) {
+	series := &performanceSeries{
+		start: make([]decimal.Decimal, last+1),
+		end:   make([]decimal.Decimal, last+1),
+		after: make([]decimal.Decimal, last+1),
+	}
+	for p := 0; p <= last; p++ {
+		after, err := periodValue(opening, p)
+		if err != nil {
+			return nil, err
+		}
+		series.after[p] = after
+		if p == 0 {
+			continue
+		}
+		series.start[p] = series.after[p-1]
+		end, err := periodValue(closing, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, fees := range []map[int]string{fixedFees, performanceFees} {
+			fee, err := periodValue(fees, p)
+			if err != nil {
+				return nil, err
+			}
+			end = end.Sub(fee)
+		}
+		series.end[p] = end
+	}
+	return series, nil
+}
+
+// Periods a value was never set for, e.g. before an account was opened, are zero
+func periodValue(values map[int]string, period int) (decimal.Decimal, error) {
+	value, ok := values[period]
+	if !ok || value == "" {
+		return decimal.Zero, nil
+	}
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, pkgErrors.DecimalConversionError.WithDetail("value", value).WithDetail("period", strconv.Itoa(period))
+	}
+	return parsed, nil
+}
+
+func (s *performanceSeries) add(other *performanceSeries) {
+	for p := range s.start {
+		s.start[p] = s.start[p].Add(other.start[p])
+		s.end[p] = s.end[p].Add(other.end[p])
+		s.after[p] = s.after[p].Add(other.after[p])
+	}
+	s.flows = append(s.flows, other.flows...)
+}
+
+type performanceAnalysis struct {
+	policy         types.RoundingPolicy
+	periodsPerYear int
+	// The close date of each period
+	dates []time.Time
+	// The annual riskless rate at each close, nil without a riskless rate
+	riskless []float64
+}
+
+func (a *performanceAnalysis) analyze(level string, id string, series *performanceSeries) *types.Performance {
+	last := len(series.start) - 1
+	performance := &types.Performance{Level: level, ID: id, Periods: []*types.PerformancePeriod{}}
+	returns, excess := []float64{}, []float64{}
+	itd, ytd := 1.0, 1.0
+	peak, peakPeriod := 1.0, 0
+	maxDrawdown, drawdownPeak, drawdownTrough := 0.0, 0, 0
+	recovered, recoveredAt, lastReturn := false, 0, 0
+	for p := 1; p <= last; p++ {
+		//the year to date starts again with the first close of each calendar year
+		if a.dates[p].Year() != a.dates[p-1].Year() {
+			ytd = 1
+		}
+		period := &types.PerformancePeriod{
+			Period:     p,
+			Date:       a.dates[p].Format(types.DATE_FORMAT),
+			StartValue: a.policy.FormatCurrency(series.start[p]),
+			EndValue:   a.policy.FormatCurrency(series.end[p]),
+			Flows:      a.policy.FormatCurrency(series.after[p].Sub(series.end[p])),
+		}
+		performance.Periods = append(performance.Periods, period)
+		if series.start[p].Sign() != 1 {
+			//nothing was invested, e.g. before the account was opened
+			if len(returns) == 0 {
+				peakPeriod = p
+			}
+			continue
+		}
+		r, _ := series.end[p].Div(series.start[p]).Sub(decimal.NewFromInt(1)).Float64()
+		returns = append(returns, r)
+		if a.riskless != nil {
+			excess = append(excess, r-a.riskless[p]/float64(a.periodsPerYear))
+		}
+		itd *= 1 + r
+		ytd *= 1 + r
+		lastReturn = p
+		if itd >= peak {
+			if maxDrawdown < 0 && !recovered && drawdownPeak == peakPeriod {
+				recovered, recoveredAt = true, p
+			}
+			peak, peakPeriod = itd, p
+		}
+		drawdown := itd/peak - 1
+		if drawdown < maxDrawdown {
+			maxDrawdown, drawdownPeak, drawdownTrough, recovered = drawdown, peakPeriod, p, false
+		}
+		period.Return = formatRatio(r)
+		period.YearToDate = formatRatio(ytd - 1)
+		period.InceptionToDate = formatRatio(itd - 1)
+		period.Drawdown = formatRatio(drawdown)
+	}
+
+	if len(returns) > 0 {
+		//the year to date is only current when the last period has a return
+		if lastReturn == last {
+			performance.YearToDate = formatRatio(ytd - 1)
+		}
+		performance.InceptionToDate = formatRatio(itd - 1)
+		performance.MaxDrawdown = formatRatio(maxDrawdown)
+		performance.MaxDrawdownPeak = drawdownPeak
+		performance.MaxDrawdownTrough = drawdownTrough
+		performance.Recovered = maxDrawdown == 0 || recovered
+		switch {
+		case maxDrawdown == 0:
+			performance.MaxDrawdownDuration = 0
+		case recovered:
+			performance.MaxDrawdownDuration = recoveredAt - drawdownPeak
+		default:
+			performance.MaxDrawdownDuration = last - drawdownPeak
+		}
+	}
+	annualization := math.Sqrt(float64(a.periodsPerYear))
+	if deviation, ok := sampleStandardDeviation(returns); ok {
+		performance.Volatility = formatRatio(deviation * annualization)
+	}
+	if deviation, ok := sampleStandardDeviation(excess); ok && deviation > 0 {
+		performance.SharpeRatio = formatRatio(mean(excess) / deviation * annualization)
+	}
+
+	//the value held is realized at the last close, and never before the last action
+	flows := append([]cashFlow{}, series.flows...)
+	held, _ := series.after[last].Float64()
+	end := a.dates[last]
+	for _, flow := range flows {
+		if flow.date.After(end) {
+			end = flow.date
+		}
+	}
+	flows = append(flows, cashFlow{date: end, amount: held})
+	if irr, ok := xirr(flows); ok {
+		performance.IRR = formatRatio(irr)
+	}
+	return performance
+}
+
+func formatRatio(value float64) string {
+	return decimal.NewFromFloat(value).StringFixed(types.PERFORMANCE_PRECISION)
+}
+
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+// Needs two values, a single period has no dispersion to measure
+func sampleStandardDeviation(values []float64) (float64, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+	average := mean(values)
+	squares := 0.0
+	for _, value := range values {
+		squares += (value - average) * (value - average)
+	}
+	return math.Sqrt(squares / float64(len(values)-1)), true
+}
+
+// The annual rate that discounts the flows to a net present value of zero, found
+// by bisection. There is none unless money went both in and out over some time.
+func xirr(flows []cashFlow) (float64, bool) {
+	if len(flows) < 2 {
+		return 0, false
+	}
+	first, latest := flows[0].date, flows[0].date
+	hasIn, hasOut := false, false
+	for _, flow := range flows {
+		if flow.date.Before(first) {
+			first = flow.date
+		}
+		if flow.date.After(latest) {
+			latest = flow.date
+		}
+		hasIn = hasIn || flow.amount < 0
+		hasOut = hasOut || flow.amount > 0
+	}
+	if !hasIn || !hasOut || !latest.After(first) {
+		return 0, false
+	}
+	npv := func(rate float64) float64 {
+		total := 0.0
+		for _, flow := range flows {
+			years := flow.date.Sub(first).Hours() / 24 / IRR_DAYS_PER_YEAR
+			total += flow.amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+	low, high := -0.999999, 1.0
+	for npv(low)*npv(high) > 0 {
+		high *= 2
+		if high > 1e6 {
+			return 0, false
+		}
+	}
+	for i := 0; i < IRR_ITERATIONS; i++ {
+		middle := (low + high) / 2
+		if npv(low)*npv(middle) <= 0 {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return (low + high) / 2, true
+}
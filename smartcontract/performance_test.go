@@ -0,0 +1,338 @@
+package smartcontract_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// A general partner, a limited partner and a limited partner with performance fees
+// bootstrap with 10000 in January 2020, and the first limited partner tops up by
+// 500 at the first close
+func performanceFund(t *testing.T) (*memstub.Stub, *smartcontract.AdminContract) {
+	return performanceFundFrom(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// The fund of performanceFund with another inception date. The top up is dated
+// at the end of the inception month.
+func performanceFundFrom(t *testing.T, inception time.Time) (*memstub.Stub, *smartcontract.AdminContract) {
+	stub := memstub.New()
+	admin := &smartcontract.AdminContract{}
+	inceptionDate := inception.Format(types.DATE_FORMAT)
+	topUpDate := time.Date(inception.Year(), inception.Month()+1, 0, 0, 0, 0, 0, time.UTC).Format(types.DATE_FORMAT)
+	stub.SetTime(inception.Add(12 * time.Hour))
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", inceptionDate)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "gp", "General Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "lp", "Limited Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "hwm", "Performance Fee Partner")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "gpAccount", "fund", "gp", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "lpAccount", "fund", "lp", false, "0")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, "hwmAccount", "fund", "hwm", true, "0.2")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "gpDeposit", "gpAccount", "deposit", "1000", false, inceptionDate, 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "lpDeposit", "lpAccount", "deposit", "7000", false, inceptionDate, 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "hwmDeposit", "hwmAccount", "deposit", "2000", false, inceptionDate, 0)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.BootstrapFund(ctx, "fund")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolio(ctx, "portfolio", "fund", "Main")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, "lpTopUp", "lpAccount", "deposit", "500", false, topUpDate, 1)
+		},
+	)
+	return stub, admin
+}
+
+// Buys a unit at the close of the period, prices the holding and closes the period
+// on date
+func closePerformancePeriod(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, period int, date time.Time, units string, price string) {
+	day := date.Format(types.DATE_FORMAT)
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, fmt.Sprintf("buy%d", period), "portfolio", "buy", day, period, "ACME", "000000000", units, "USD")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.UpdatePortfolioValuation(ctx, "portfolio", day, "ACME", price)
+		},
+	)
+	stub.SetTime(date.Add(17 * time.Hour))
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := admin.StepFund(ctx, "fund")
+		return err
+	})
+}
+
+func queryPerformance(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, period int, risklessRateId string) *types.PerformanceReport {
+	var report *types.PerformanceReport
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		report, err = admin.QueryPerformance(ctx, "fund", period, risklessRateId)
+		return err
+	})
+	return report
+}
+
+func periodReturns(performance *types.Performance) []string {
+	returns := []string{}
+	for _, period := range performance.Periods {
+		returns = append(returns, period.Return)
+	}
+	return returns
+}
+
+// The net present value of the flows of the limited partner at the rate
+func lpNetPresentValue(rate float64, held float64) float64 {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	years := func(date time.Time) float64 { return date.Sub(start).Hours() / 24 / 365 }
+	return -7000 - 500/math.Pow(1+rate, years(time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC))) +
+		held/math.Pow(1+rate, years(time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestPerformance(t *testing.T) {
+	stub, admin := performanceFund(t)
+	closePerformancePeriod(t, stub, admin, 1, time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), "100", "110")
+	closePerformancePeriod(t, stub, admin, 2, time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC), "1", "99")
+	closePerformancePeriod(t, stub, admin, 3, time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC), "1", "120")
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		return admin.SetRisklessRate(ctx, "tbill", "3 month treasury bill", "01-01-2020", "0.012")
+	})
+
+	report := queryPerformance(t, stub, admin, -1, "tbill")
+	assert.Equal(t, 3, report.Period)
+	assert.Equal(t, 12, report.PeriodsPerYear)
+
+	//fees stay in the fund, so its returns are those of the portfolio
+	fund := report.FundPerformance
+	assert.Equal(t, []string{"0.100000", "-0.130522", "0.224122"}, periodReturns(fund))
+	assert.Equal(t, "03-31-2020", fund.Periods[2].Date)
+	assert.Equal(t, "11500.00", fund.Periods[1].StartValue)
+	assert.Equal(t, "500.00", fund.Periods[0].Flows)
+	assert.Equal(t, "0.170783", fund.InceptionToDate)
+	assert.Equal(t, "0.170783", fund.YearToDate)
+	assert.Equal(t, "0.623409", fund.Volatility)
+	assert.Equal(t, "1.222958", fund.SharpeRatio)
+	assert.Equal(t, "-0.130522", fund.MaxDrawdown)
+	assert.Equal(t, 1, fund.MaxDrawdownPeak)
+	assert.Equal(t, 2, fund.MaxDrawdownTrough)
+	assert.Equal(t, 2, fund.MaxDrawdownDuration)
+	assert.True(t, fund.Recovered)
+
+	//accounts are net of their fees, and the performance fee account is a class of
+	//its own
+	assert.Len(t, report.Accounts, 3)
+	assert.Equal(t, "gpAccount", report.Accounts[0].ID)
+	lp := report.Accounts[1]
+	assert.Equal(t, types.PERFORMANCE_LEVEL_CAPITAL_ACCOUNT, lp.Level)
+	assert.Equal(t, "lpAccount", lp.ID)
+	assert.Equal(t, "0.078000", lp.Periods[0].Return)
+	assert.Len(t, report.Classes, 2)
+	assert.Equal(t, "0.02/0", report.Classes[0].ID)
+	assert.Equal(t, "0.02/0.2", report.Classes[1].ID)
+	hwm := report.Accounts[2]
+	assert.Equal(t, "hwmAccount", hwm.ID)
+	assert.Equal(t, types.PERFORMANCE_LEVEL_CLASS, report.Classes[1].Level)
+	assert.Equal(t, periodReturns(hwm), periodReturns(report.Classes[1]))
+
+	//the IRR discounts the actions and the value held at the last close to nothing
+	irr, err := decimal.NewFromString(lp.IRR)
+	assert.Nil(t, err)
+	held, _ := decimal.RequireFromString(lp.Periods[2].EndValue).Float64()
+	rate, _ := irr.Float64()
+	assert.InDelta(t, 0, lpNetPresentValue(rate, held), 0.01)
+	assert.True(t, rate > 0)
+
+	//through the trough the drawdown has lasted since the peak
+	trough := queryPerformance(t, stub, admin, 2, "")
+	assert.Equal(t, "-0.043574", trough.FundPerformance.YearToDate)
+	assert.Equal(t, 1, trough.FundPerformance.MaxDrawdownDuration)
+	assert.False(t, trough.FundPerformance.Recovered)
+	assert.Equal(t, "", trough.FundPerformance.SharpeRatio)
+	assert.Equal(t, "", trough.RisklessRate)
+
+	//a single return has no volatility
+	first := queryPerformance(t, stub, admin, 1, "")
+	assert.Equal(t, "", first.FundPerformance.Volatility)
+	assert.Equal(t, "0.000000", first.FundPerformance.MaxDrawdown)
+	assert.True(t, first.FundPerformance.Recovered)
+}
+
+func TestPerformanceYearToDateStartsEachYear(t *testing.T) {
+	stub, admin := performanceFund(t)
+	date := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	for period := 1; period <= 13; period++ {
+		closePerformancePeriod(t, stub, admin, period, date, "1", fmt.Sprintf("%d", 9000+period*100))
+		date = time.Date(date.Year(), date.Month()+2, 0, 0, 0, 0, 0, time.UTC)
+	}
+	report := queryPerformance(t, stub, admin, -1, "")
+	last := report.FundPerformance.Periods[12]
+	assert.Equal(t, "01-31-2021", last.Date)
+	assert.Equal(t, last.Return, last.YearToDate)
+	assert.Equal(t, last.Return, report.FundPerformance.YearToDate)
+	assert.NotEqual(t, last.InceptionToDate, last.YearToDate)
+}
+
+// The year to date follows the calendar year rather than the anniversary of the
+// inception
+func TestPerformanceYearToDateFollowsTheCalendarYear(t *testing.T) {
+	stub, admin := performanceFundFrom(t, time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC))
+	date := time.Date(2020, 9, 30, 0, 0, 0, 0, time.UTC)
+	for period := 1; period <= 6; period++ {
+		closePerformancePeriod(t, stub, admin, period, date, "1", fmt.Sprintf("%d", 9000+period*100))
+		date = time.Date(date.Year(), date.Month()+2, 0, 0, 0, 0, 0, time.UTC)
+	}
+	report := queryPerformance(t, stub, admin, -1, "")
+	periods := report.FundPerformance.Periods
+	assert.Equal(t, "12-31-2020", periods[3].Date)
+	assert.Equal(t, periods[3].InceptionToDate, periods[3].YearToDate)
+	assert.Equal(t, "01-31-2021", periods[4].Date)
+	assert.Equal(t, periods[4].Return, periods[4].YearToDate)
+	first, _ := strconv.ParseFloat(periods[4].Return, 64)
+	second, _ := strconv.ParseFloat(periods[5].Return, 64)
+	yearToDate, _ := strconv.ParseFloat(report.FundPerformance.YearToDate, 64)
+	assert.InDelta(t, (1+first)*(1+second)-1, yearToDate, 0.000002)
+}
+
+// Periods closed before their close was recorded are dated at the end of their
+// month, whatever the day of the inception
+func TestPerformanceDatesUnrecordedClosesAtMonthEnd(t *testing.T) {
+	stub, admin := performanceFundFrom(t, time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC))
+	closePerformancePeriod(t, stub, admin, 1, time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), "100", "110")
+	closePerformancePeriod(t, stub, admin, 2, time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC), "1", "99")
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		data, err := ctx.GetStub().GetState("fund")
+		if err != nil {
+			return err
+		}
+		var fund map[string]interface{}
+		err = json.Unmarshal(data, &fund)
+		if err != nil {
+			return err
+		}
+		delete(fund, "closedAt")
+		data, err = json.Marshal(fund)
+		if err != nil {
+			return err
+		}
+		return ctx.GetStub().PutState("fund", data)
+	})
+	report := queryPerformance(t, stub, admin, -1, "")
+	assert.Equal(t, "01-31-2020", report.FundPerformance.Periods[0].Date)
+	assert.Equal(t, "02-29-2020", report.FundPerformance.Periods[1].Date)
+}
+
+// Performance is annualized by the fund's own setting, not its performance fee year
+func TestSetFundPeriodsPerYear(t *testing.T) {
+	stub, admin := performanceFund(t)
+	closePerformancePeriod(t, stub, admin, 1, time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC), "100", "110")
+	closePerformancePeriod(t, stub, admin, 2, time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC), "1", "99")
+	closePerformancePeriod(t, stub, admin, 3, time.Date(2020, 9, 30, 0, 0, 0, 0, time.UTC), "1", "120")
+	monthly := queryPerformance(t, stub, admin, -1, "")
+	assert.Equal(t, types.DEFAULT_PERIODS_PER_YEAR, monthly.PeriodsPerYear)
+
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		err := admin.SetFundPeriodsPerYear(ctx, "fund", 0)
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		err = admin.SetFundPeriodsPerYear(ctx, "missing", 4)
+		assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+		return admin.SetFundPeriodsPerYear(ctx, "fund", 4)
+	})
+	quarterly := queryPerformance(t, stub, admin, -1, "")
+	assert.Equal(t, 4, quarterly.PeriodsPerYear)
+	assert.Equal(t, periodReturns(monthly.FundPerformance), periodReturns(quarterly.FundPerformance))
+	monthlyVolatility, _ := strconv.ParseFloat(monthly.FundPerformance.Volatility, 64)
+	quarterlyVolatility, _ := strconv.ParseFloat(quarterly.FundPerformance.Volatility, 64)
+	assert.InDelta(t, monthlyVolatility*math.Sqrt(4.0/12.0), quarterlyVolatility, 0.000001)
+}
+
+func TestPerformanceErrors(t *testing.T) {
+	stub, admin := performanceFund(t)
+	closePerformancePeriod(t, stub, admin, 1, time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), "100", "110")
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := admin.QueryPerformance(ctx, "fund", 2, "")
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		_, err = admin.QueryPerformance(ctx, "missing", -1, "")
+		assert.True(t, errors.Is(err, pkgErrors.FundNotFoundError))
+		_, err = admin.QueryPerformance(ctx, "fund", -1, "missing")
+		assert.True(t, errors.Is(err, pkgErrors.RisklessRateNotFoundError))
+		return admin.SetRisklessRate(ctx, "late", "Late", "02-01-2020", "0.01")
+	})
+
+	//a rate that only starts after a close cannot price it
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := admin.QueryPerformance(ctx, "fund", -1, "late")
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		coded, _ := pkgErrors.From(err)
+		assert.Contains(t, coded.Details, "risklessRate")
+		return nil
+	})
+}
+
+func TestSetRisklessRate(t *testing.T) {
+	stub := memstub.New()
+	admin := &smartcontract.AdminContract{}
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		err := admin.SetRisklessRate(ctx, "tbill", "", "01-01-2020", "0.01")
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		err = admin.SetRisklessRate(ctx, "tbill", "T-bill", "01-01-2020", "2")
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		err = admin.SetRisklessRate(ctx, "tbill", "T-bill", "2020-01-01", "0.01")
+		assert.True(t, errors.Is(err, pkgErrors.ValidationError))
+		return admin.SetRisklessRate(ctx, "tbill", "T-bill", "01-01-2020", "0.01")
+	})
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		return admin.SetRisklessRate(ctx, "tbill", "", "07-01-2020", "0.005")
+	})
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		risklessRate, err := admin.QueryRisklessRateById(ctx, "tbill")
+		assert.Nil(t, err)
+		assert.Equal(t, "T-bill", risklessRate.Name)
+		assert.Equal(t, map[string]string{"01-01-2020": "0.01", "07-01-2020": "0.005"}, risklessRate.Values)
+		rate, ok := risklessRate.RateOn(time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC))
+		assert.True(t, ok)
+		assertDecimal(t, rate.String(), "0.01")
+		rate, _ = risklessRate.RateOn(time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC))
+		assertDecimal(t, rate.String(), "0.005")
+		_, ok = risklessRate.RateOn(time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC))
+		assert.False(t, ok)
+
+		missing, err := admin.QueryRisklessRateById(ctx, "missing")
+		assert.Nil(t, err)
+		assert.Nil(t, missing)
+		return nil
+	})
+}
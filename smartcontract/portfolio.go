@@ -1,10 +1,6 @@
 package smartcontract
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-
 	"github.com/shopspring/decimal"
 	"github.com/zacharyfrederick/admin/types"
 	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
@@ -17,19 +13,23 @@ func (s *AdminContract) CreatePortfolio(
 	fundId string,
 	name string,
 ) error {
+	err := types.ValidateCreatePortfolioRequest(&types.CreatePortfolioRequest{Fund: fundId, Name: name})
+	if err != nil {
+		return err
+	}
 	idInUse, err := utils.AssetExists(ctx, portfolioId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if idInUse {
-		return smartcontracterrors.IdAlreadyInUseError
+		return smartcontracterrors.IdAlreadyInUseError.WithDetail("id", portfolioId)
 	}
 	fund, err := s.QueryFundById(ctx, fundId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if fund == nil {
-		return smartcontracterrors.FundNotFoundError
+		return smartcontracterrors.FundNotFoundError.WithDetail("fund", fundId)
 	}
 	portfolio := types.CreateDefaultPortfolio(portfolioId, fundId, name)
 	return SaveState(ctx, &portfolio)
@@ -47,18 +47,39 @@ func (s *AdminContract) CreatePortfolioAction(
 	amount string,
 	currency string,
 ) error {
-	if type_ != "buy" && type_ != "sell" {
+	if type_ != types.PORTFOLIO_ACTION_TYPE_BUY && type_ != types.PORTFOLIO_ACTION_TYPE_SELL {
 		return smartcontracterrors.InvalidPortfolioActionTypeError
 	}
+	err := types.ValidateCreatePortfolioActionRequest(&types.CreatePortfolioActionRequest{
+		Portfolio: portfolioId,
+		Type:      type_,
+		Date:      date,
+		Period:    period,
+		Name:      name,
+		CUSIP:     cusip,
+		Amount:    amount,
+		Currency:  currency,
+	})
+	if err != nil {
+		return err
+	}
+	idInUse, err := utils.AssetExists(ctx, actionId)
+	if err != nil {
+		return smartcontracterrors.ReadingWorldStateError
+	}
+	if idInUse {
+		return smartcontracterrors.IdAlreadyInUseError.WithDetail("id", actionId)
+	}
 	portfolio, err := s.QueryPortfolioById(ctx, portfolioId)
 	if err != nil {
 		return smartcontracterrors.ReadingWorldStateError
 	}
 	if portfolio == nil {
-		return smartcontracterrors.PortfolioNotFoundError
+		return smartcontracterrors.PortfolioNotFoundError.WithDetail("portfolio", portfolioId)
 	}
 	asset := types.CreateAsset(name, cusip, amount, currency)
 	portfolioAction := types.CreateDefaultPortfolioAction(
+		portfolio.Fund,
 		portfolioId,
 		type_,
 		date,
@@ -84,20 +105,29 @@ func (s *AdminContract) UpdatePortfolioValuation(
 	name string,
 	price string,
 ) error {
+	err := types.ValidateValuePortfolioRequest(&types.ValuePortfolioRequest{
+		Portfolio: portfolioId,
+		Name:      name,
+		Date:      date,
+		Price:     price,
+	})
+	if err != nil {
+		return err
+	}
 	portfolio, err := s.QueryPortfolioById(ctx, portfolioId)
 	if err != nil {
 		return err
 	}
 	if portfolio == nil {
-		return errors.New("a portfolio with that ID does not exist")
+		return smartcontracterrors.PortfolioNotFoundError.WithDetail("portfolio", portfolioId)
 	}
 	currentAssets, ok := portfolio.Assets[date]
 	if !ok {
-		return errors.New("a portfolio snapshop with that date does not exist")
+		return smartcontracterrors.ValuationDateNotFoundError.WithDetail("date", date)
 	}
 	asset, ok := currentAssets[name]
 	if !ok {
-		return errors.New("the specified asset is not in the portfolio")
+		return smartcontracterrors.AssetNotFoundError.WithDetail("asset", name)
 	}
 	//initialize the valuations if it hasn't been created yet
 	if portfolio.Valuations == nil {
@@ -117,11 +147,7 @@ func (s *AdminContract) UpdatePortfolioValuation(
 			valuationForDate[name] = valuedAsset
 		}
 	}
-	portfolioJson, err := json.Marshal(portfolio)
-	if err != nil {
-		return err
-	}
-	return ctx.GetStub().PutState(portfolioId, portfolioJson)
+	return SaveState(ctx, portfolio)
 }
 
 func createValuedAsset(asset types.Asset, price string) types.ValuedAsset {
@@ -182,7 +208,7 @@ func getMostRecentAssetsForPortfolio(
 			newAssets := copyAssetMap(currentAssets)
 			return newAssets, nil
 		} else {
-			return nil, errors.New("no portfolio found for the most recent date")
+			return nil, smartcontracterrors.NoValuationsFoundForDateError.WithDetail("date", portfolio.MostRecentDate)
 		}
 	}
 }
@@ -228,12 +254,12 @@ func removeAsset(assets types.AssetMap, assetToAdd types.Asset) error {
 		}
 		totalAmount := currentAmount.Sub(newAmount)
 		if totalAmount.Sign() == -1 {
-			return errors.New("cannot have a negative security amount")
+			return smartcontracterrors.NegativeSecurityAmountError.WithDetail("asset", assetToAdd.Name)
 		}
 		currentAsset.Amount = totalAmount.String()
 		assets[assetToAdd.Name] = currentAsset
 	} else {
-		return errors.New("cannot have a negative security amount")
+		return smartcontracterrors.NegativeSecurityAmountError.WithDetail("asset", assetToAdd.Name)
 	}
 	return nil
 }
@@ -241,7 +267,7 @@ func removeAsset(assets types.AssetMap, assetToAdd types.Asset) error {
 func sellSecurityForPortfolio(portfolio *types.Portfolio, action *types.PortfolioAction) error {
 	transactionDate := action.Date
 	if portfolio.MostRecentDate == "" {
-		return errors.New("cannot sell a security from an empty portfolio")
+		return smartcontracterrors.EmptyPortfolioError.WithDetail("portfolio", portfolio.ID)
 	}
 	currentAssets, err := getMostRecentAssetsForPortfolio(portfolio, transactionDate)
 	if err != nil {
@@ -263,9 +289,104 @@ func (s *AdminContract) QueryPortfoliosByFund(
 	return queryPortfoliosByFund(ctx, fundId)
 }
 
+// Lists the portfolios of a fund in id order
+func (s *AdminContract) QueryPortfoliosByFundWithPagination(
+	ctx SmartContractContext,
+	fundId string,
+	pageSize int32,
+	bookmark string,
+) (*types.PortfolioPage, error) {
+	portfolios := []*types.Portfolio{}
+	bookmark, err := queryIndexPage(ctx, types.INDEX_PORTFOLIO, []string{fundId}, pageSize, bookmark, func(data []byte) (bool, error) {
+		var portfolio types.Portfolio
+		err := LoadState(ctx, data, &portfolio)
+		if err != nil {
+			return false, err
+		}
+		portfolios = append(portfolios, &portfolio)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.PortfolioPage{
+		Records:             portfolios,
+		FetchedRecordsCount: int32(len(portfolios)),
+		Bookmark:            bookmark,
+	}
+	return page, nil
+}
+
+// Lists the actions of a portfolio in period order. The index is keyed by fund
+// first, so the portfolio is loaded for its fund, and a portfolio that does not
+// exist has no actions.
+func (s *AdminContract) QueryPortfolioActionsByPortfolioWithPagination(
+	ctx SmartContractContext,
+	portfolioId string,
+	pageSize int32,
+	bookmark string,
+) (*types.PortfolioActionPage, error) {
+	portfolio, err := s.QueryPortfolioById(ctx, portfolioId)
+	if err != nil {
+		return nil, err
+	}
+	if portfolio == nil {
+		return &types.PortfolioActionPage{Records: []*types.PortfolioAction{}}, nil
+	}
+	attributes := []string{portfolio.Fund, portfolioId}
+	return queryPortfolioActionPage(ctx, attributes, &types.ActionFilter{}, pageSize, bookmark)
+}
+
+func (s *AdminContract) QueryPortfolioActionsByFundWithPagination(
+	ctx SmartContractContext,
+	fundId string,
+	filterJSON string,
+	pageSize int32,
+	bookmark string,
+) (*types.PortfolioActionPage, error) {
+	filter, err := parseActionFilter(filterJSON)
+	if err != nil {
+		return nil, err
+	}
+	return queryPortfolioActionPage(ctx, []string{fundId}, filter, pageSize, bookmark)
+}
+
+// Loads a page of the actions matching the filter under the partial index key,
+// ordered by portfolio and then period. Portfolio actions have no status, so a
+// filter on status matches none of them.
+func queryPortfolioActionPage(
+	ctx SmartContractContext,
+	attributes []string,
+	filter *types.ActionFilter,
+	pageSize int32,
+	bookmark string,
+) (*types.PortfolioActionPage, error) {
+	portfolioActions := []*types.PortfolioAction{}
+	bookmark, err := queryIndexPage(ctx, types.INDEX_PORTFOLIOACTION, attributes, pageSize, bookmark, func(data []byte) (bool, error) {
+		var portfolioAction types.PortfolioAction
+		err := LoadState(ctx, data, &portfolioAction)
+		if err != nil {
+			return false, err
+		}
+		if !filter.Matches(portfolioAction.Period, portfolioAction.Type, "", portfolioAction.Date) {
+			return false, nil
+		}
+		portfolioActions = append(portfolioActions, &portfolioAction)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page := &types.PortfolioActionPage{
+		Records:             portfolioActions,
+		FetchedRecordsCount: int32(len(portfolioActions)),
+		Bookmark:            bookmark,
+	}
+	return page, nil
+}
+
 func queryPortfoliosByFund(ctx SmartContractContext, fundId string) ([]*types.Portfolio, error) {
-	queryString := fmt.Sprintf(`{"selector":{"docType": "portfolio", "fund": "%s"}}`, fundId)
-	return executePortfolioQuery(ctx, queryString)
+	return queryPortfoliosByFundIndex(ctx, fundId)
 }
 
 func (s *AdminContract) QueryPortfolioById(
@@ -280,7 +401,7 @@ func (s *AdminContract) QueryPortfolioById(
 		return nil, nil
 	}
 	var portfolio types.Portfolio
-	err = json.Unmarshal(data, &portfolio)
+	err = LoadState(ctx, data, &portfolio)
 	if err != nil {
 		return nil, err
 	}
@@ -299,34 +420,9 @@ func (s *AdminContract) QueryPortfolioActionById(
 		return nil, nil
 	}
 	var portfolioAction types.PortfolioAction
-	err = json.Unmarshal(data, &portfolioAction)
+	err = LoadState(ctx, data, &portfolioAction)
 	if err != nil {
 		return nil, err
 	}
 	return &portfolioAction, nil
 }
-
-func executePortfolioQuery(
-	ctx SmartContractContext,
-	queryString string,
-) ([]*types.Portfolio, error) {
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
-	if err != nil {
-		return nil, err
-	}
-	defer resultsIterator.Close()
-	var portfolios []*types.Portfolio
-	for resultsIterator.HasNext() {
-		queryResult, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-		var portfolio types.Portfolio
-		err = json.Unmarshal(queryResult.Value, &portfolio)
-		if err != nil {
-			return nil, err
-		}
-		portfolios = append(portfolios, &portfolio)
-	}
-	return portfolios, nil
-}
@@ -0,0 +1,17 @@
+package smartcontract
+
+import (
+	"github.com/zacharyfrederick/admin/types"
+)
+
+func parseActionFilter(filterJSON string) (*types.ActionFilter, error) {
+	var filter types.ActionFilter
+	if filterJSON == "" {
+		return &filter, nil
+	}
+	err := filter.FromJSON([]byte(filterJSON))
+	if err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
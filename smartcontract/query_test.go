@@ -0,0 +1,97 @@
+package smartcontract_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+)
+
+func TestDateFilteredPagesAreFull(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolio(ctx, "portfolio", "fund", "Main")
+		},
+	)
+	for _, month := range []string{"01", "02", "03", "04", "05", "06"} {
+		id := "buy" + month
+		date := month + "-15-2020"
+		transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, id, "portfolio", "buy", date, 1, "ACME", "000000000", "1", "USD")
+		})
+	}
+
+	//the actions before March are skipped without leaving the page short
+	var first, second *types.PortfolioActionPage
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		first, err = admin.QueryPortfolioActionsByFundWithPagination(ctx, "fund", `{"startDate":"03-01-2020"}`, 3, "")
+		if err != nil {
+			return err
+		}
+		second, err = admin.QueryPortfolioActionsByFundWithPagination(ctx, "fund", `{"startDate":"03-01-2020"}`, 3, first.Bookmark)
+		return err
+	})
+	assert.Len(t, first.Records, 3)
+	assert.Equal(t, int32(3), first.FetchedRecordsCount)
+	assert.Len(t, second.Records, 1)
+	assert.Equal(t, int32(1), second.FetchedRecordsCount)
+	ids := []string{}
+	for _, action := range append(first.Records, second.Records...) {
+		ids = append(ids, action.ID)
+	}
+	assert.ElementsMatch(t, []string{"buy03", "buy04", "buy05", "buy06"}, ids)
+}
+
+func TestInvestorPagesListEachInvestorOnce(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "alice", "Alice")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "bob", "Bob")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, "carol", "Carol")
+		},
+	)
+	//alice's accounts sort first and last, so they would land on different pages
+	for _, account := range [][2]string{{"a1", "alice"}, {"b1", "bob"}, {"c1", "carol"}, {"z1", "alice"}} {
+		id, investor := account[0], account[1]
+		transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, id, "fund", investor, false, "0")
+		})
+	}
+
+	var first, second *types.InvestorPage
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		first, err = admin.QueryInvestorsByFundWithPagination(ctx, "fund", 2, "")
+		if err != nil {
+			return err
+		}
+		second, err = admin.QueryInvestorsByFundWithPagination(ctx, "fund", 2, first.Bookmark)
+		return err
+	})
+	assert.Len(t, first.Records, 2)
+	assert.Equal(t, int32(2), first.FetchedRecordsCount)
+	assert.Len(t, second.Records, 1)
+	ids := []string{}
+	for _, investor := range append(first.Records, second.Records...) {
+		ids = append(ids, investor.ID)
+	}
+	assert.Equal(t, []string{"alice", "bob", "carol"}, ids)
+}
@@ -0,0 +1,71 @@
+package smartcontract
+
+import (
+	"github.com/zacharyfrederick/admin/types"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Sets the rate from date on. A new series is created with the name, which is
+// required then and otherwise renames the series when given.
+func (s *AdminContract) SetRisklessRate(
+	ctx SmartContractContext,
+	risklessRateId string,
+	name string,
+	date string,
+	rate string,
+) error {
+	err := types.ValidateSetRisklessRateRequest(&types.SetRisklessRateRequest{Name: name, Date: date, Rate: rate})
+	if err != nil {
+		return err
+	}
+	risklessRate, err := s.QueryRisklessRateById(ctx, risklessRateId)
+	if err != nil {
+		return err
+	}
+	if risklessRate == nil {
+		errs := types.FieldErrors{}
+		errs.Check("name", types.CheckRequired(name))
+		err = errs.Err()
+		if err != nil {
+			return err
+		}
+		created := types.CreateDefaultRisklessRate(risklessRateId, name)
+		risklessRate = &created
+	}
+	if name != "" {
+		risklessRate.Name = name
+	}
+	if risklessRate.Values == nil {
+		risklessRate.Values = map[string]string{}
+	}
+	risklessRate.Values[date] = rate
+	return SaveState(ctx, risklessRate)
+}
+
+func (s *AdminContract) QueryRisklessRateById(
+	ctx SmartContractContext,
+	risklessRateId string,
+) (*types.RisklessRate, error) {
+	var risklessRate types.RisklessRate
+	found, err := loadStateById(ctx, risklessRateId, &risklessRate)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return &risklessRate, nil
+}
+
+// Loads the riskless rate, failing when it does not exist
+func queryRisklessRate(ctx SmartContractContext, risklessRateId string) (*types.RisklessRate, error) {
+	var risklessRate types.RisklessRate
+	found, err := loadStateById(ctx, risklessRateId, &risklessRate)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, smartcontracterrors.RisklessRateNotFoundError.WithDetail("risklessRate", risklessRateId)
+	}
+	return &risklessRate, nil
+}
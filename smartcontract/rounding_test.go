@@ -0,0 +1,164 @@
+package smartcontract_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func TestRoundingPolicyModes(t *testing.T) {
+	for _, test := range []struct {
+		mode     string
+		value    string
+		expected string
+	}{
+		{types.ROUNDING_MODE_HALF_UP, "2.345", "2.35"},
+		{types.ROUNDING_MODE_HALF_UP, "-2.345", "-2.35"},
+		{types.ROUNDING_MODE_HALF_EVEN, "2.345", "2.34"},
+		{types.ROUNDING_MODE_HALF_EVEN, "2.355", "2.36"},
+		{types.ROUNDING_MODE_DOWN, "2.349", "2.34"},
+		{types.ROUNDING_MODE_HALF_UP, "1100", "1100.00"},
+	} {
+		policy := types.DefaultRoundingPolicy()
+		policy.RoundingMode = test.mode
+		assert.Equal(t, policy.FormatCurrency(decimal.RequireFromString(test.value)), test.expected, "%s %s", test.mode, test.value)
+	}
+}
+
+func TestRoundingPolicyValidate(t *testing.T) {
+	assert.Nil(t, types.DefaultRoundingPolicy().Validate())
+	for _, policy := range []types.RoundingPolicy{
+		{CurrencyPrecision: -1, OwnershipPrecision: 10, RoundingMode: types.ROUNDING_MODE_HALF_UP},
+		{CurrencyPrecision: 2, OwnershipPrecision: types.MAX_OWNERSHIP_PRECISION + 1, RoundingMode: types.ROUNDING_MODE_HALF_UP},
+		{CurrencyPrecision: 2, OwnershipPrecision: 10, RoundingMode: "ceiling"},
+	} {
+		assert.Equal(t, policy.Validate(), smartcontracterrors.InvalidRoundingPolicyError)
+	}
+}
+
+// Three equal partners own a third of the fund each, so neither the ownership
+// percentages nor the closing values divide evenly
+func createThirdsFund(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract) {
+	steps := []func(ctx contractapi.TransactionContextInterface) error{
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, "fund", "Test Fund", "01-01-2020")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolio(ctx, "portfolio", "fund", "Main")
+		},
+	}
+	for _, id := range []string{"gp", "lp1", "lp2"} {
+		id := id
+		steps = append(steps,
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateInvestor(ctx, id, id)
+			},
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccount(ctx, id+"Account", "fund", id, false, "0")
+			},
+			func(ctx contractapi.TransactionContextInterface) error {
+				return admin.CreateCapitalAccountAction(ctx, id+"Deposit", id+"Account", "deposit", "1000", false, "01-01-2020", 0)
+			},
+		)
+	}
+	transact(t, stub, steps...)
+}
+
+func TestStepFundAllocatesRoundingResidualToGeneralPartner(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	createThirdsFund(t, stub, &admin)
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.BootstrapFund(ctx, "fund")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, "buy", "portfolio", "buy", "01-31-2020", 1, "ACME", "000000000", "1", "USD")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.UpdatePortfolioValuation(ctx, "portfolio", "01-31-2020", "ACME", "1000.01")
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.StepFund(ctx, "fund")
+			return err
+		},
+	)
+	accounts := map[string]*types.CapitalAccount{}
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		for _, id := range []string{"gpAccount", "lp1Account", "lp2Account"} {
+			account, err := admin.QueryCapitalAccountById(ctx, id)
+			if err != nil {
+				return err
+			}
+			accounts[id] = account
+		}
+		return nil
+	})
+	assert.Equal(t, accounts["lp1Account"].OwnershipPercentage[0], "0.3333333333")
+	assert.Equal(t, accounts["gpAccount"].OwnershipPercentage[0], "0.3333333334")
+	assert.Equal(t, accounts["lp1Account"].ClosingValue[1], "333.34")
+	assert.Equal(t, accounts["lp2Account"].ClosingValue[1], "333.34")
+	assert.Equal(t, accounts["gpAccount"].ClosingValue[1], "333.33")
+	assert.Equal(t, accounts["lp1Account"].FixedFees[1], "6.67")
+	assertFundInvariants(t, stub, &admin)
+}
+
+func TestSetFundRoundingPolicy(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	createThirdsFund(t, stub, &admin)
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		return admin.SetFundRoundingPolicy(ctx, "fund", 2, 10, "ceiling")
+	})
+	assert.ErrorIs(t, err, smartcontracterrors.InvalidRoundingPolicyError)
+	transact(t, stub,
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.SetFundRoundingPolicy(ctx, "fund", 0, 4, types.ROUNDING_MODE_DOWN)
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := admin.BootstrapFund(ctx, "fund")
+			return err
+		},
+	)
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		fund, err := admin.QueryFundById(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, fund.OpeningValues[0], "3000")
+		account, err := admin.QueryCapitalAccountById(ctx, "lp1Account")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, account.OwnershipPercentage[0], "0.3333")
+		return nil
+	})
+	err = stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		return admin.SetFundRoundingPolicy(ctx, "fund", 2, 10, types.ROUNDING_MODE_HALF_UP)
+	})
+	assert.ErrorIs(t, err, smartcontracterrors.CannotChangeRoundingPolicyError)
+}
+
+func TestQueryFundByIdUpgradesRoundingPolicy(t *testing.T) {
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	stub.LoadState(map[string][]byte{
+		"fund": []byte(`{"docType":"fund","id":"fund","name":"Legacy Fund","schemaVersion":1,"midYearDeposits":[],"midYearWithdrawals":[]}`),
+	})
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		fund, err := admin.QueryFundById(ctx, "fund")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, fund.SchemaVersion, types.FUND_SCHEMA_VERSION)
+		assert.Equal(t, fund.RoundingPolicy, types.DefaultRoundingPolicy())
+		return nil
+	})
+}
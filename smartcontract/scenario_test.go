@@ -0,0 +1,348 @@
+package smartcontract_test
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/smartcontract/memstub"
+	"github.com/zacharyfrederick/admin/types"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+var updateScenarios = flag.Bool("update", false, "rewrite the expected values in testdata/scenarios from the actual results")
+
+// A fund lifecycle described declaratively in testdata/scenarios. Periods[0] is
+// bootstrapped with BootstrapFund and every later period is closed with StepFund.
+// Expected values are compared after rounding the actual value to the number of
+// decimal places written in the file, so "1298.00" checks to the cent. A period
+// that is expected to fail gives the code of the error instead.
+type scenario struct {
+	Description string              `json:"description"`
+	Fund        scenarioFund        `json:"fund"`
+	Investors   []scenarioInvestor  `json:"investors"`
+	Accounts    []scenarioAccount   `json:"accounts"`
+	Portfolios  []scenarioPortfolio `json:"portfolios"`
+	Periods     []scenarioPeriod    `json:"periods"`
+}
+
+type scenarioFund struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	InceptionDate string `json:"inceptionDate"`
+}
+
+type scenarioInvestor struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// The first account is the general partner
+type scenarioAccount struct {
+	ID                 string `json:"id"`
+	Investor           string `json:"investor"`
+	HasPerformanceFees bool   `json:"hasPerformanceFees"`
+	PerformanceFeeRate string `json:"performanceFeeRate"`
+}
+
+type scenarioPortfolio struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type scenarioPeriod struct {
+	Actions  []scenarioAction  `json:"actions,omitempty"`
+	Trades   []scenarioTrade   `json:"trades,omitempty"`
+	Prices   []scenarioPrice   `json:"prices,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Expected *scenarioExpected `json:"expected,omitempty"`
+}
+
+type scenarioAction struct {
+	ID      string `json:"id"`
+	Account string `json:"account"`
+	Type    string `json:"type"`
+	Amount  string `json:"amount"`
+	Date    string `json:"date"`
+}
+
+type scenarioTrade struct {
+	ID        string `json:"id"`
+	Portfolio string `json:"portfolio"`
+	Type      string `json:"type"`
+	Date      string `json:"date"`
+	Name      string `json:"name"`
+	CUSIP     string `json:"cusip"`
+	Amount    string `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+type scenarioPrice struct {
+	Portfolio string `json:"portfolio"`
+	Date      string `json:"date"`
+	Name      string `json:"name"`
+	Price     string `json:"price"`
+}
+
+type scenarioExpected struct {
+	Fund     map[string]string            `json:"fund,omitempty"`
+	Accounts map[string]map[string]string `json:"accounts,omitempty"`
+}
+
+var scenarioFundFields = map[string]func(*types.Fund, int) (string, bool){
+	"closingValue": func(f *types.Fund, p int) (string, bool) { v, ok := f.ClosingValues[p]; return v, ok },
+	"openingValue": func(f *types.Fund, p int) (string, bool) { v, ok := f.OpeningValues[p]; return v, ok },
+	"fixedFees":    func(f *types.Fund, p int) (string, bool) { v, ok := f.FixedFees[p]; return v, ok },
+	"deposits":     func(f *types.Fund, p int) (string, bool) { v, ok := f.Deposits[p]; return v, ok },
+}
+
+var scenarioAccountFields = map[string]func(*types.CapitalAccount, int) (string, bool){
+	"closingValue": func(a *types.CapitalAccount, p int) (string, bool) { v, ok := a.ClosingValue[p]; return v, ok },
+	"openingValue": func(a *types.CapitalAccount, p int) (string, bool) { v, ok := a.OpeningValue[p]; return v, ok },
+	"fixedFees":    func(a *types.CapitalAccount, p int) (string, bool) { v, ok := a.FixedFees[p]; return v, ok },
+	"deposits":     func(a *types.CapitalAccount, p int) (string, bool) { v, ok := a.Deposits[p]; return v, ok },
+	"ownership":    func(a *types.CapitalAccount, p int) (string, bool) { v, ok := a.OwnershipPercentage[p]; return v, ok },
+}
+
+// decimal places written by -update for fields without an expected value yet
+func defaultScenarioPrecision(field string) int32 {
+	if field == "ownership" {
+		return 6
+	}
+	return 2
+}
+
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "scenarios", "*.json"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, paths)
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			runScenarioFile(t, path)
+		})
+	}
+}
+
+func runScenarioFile(t *testing.T, path string) {
+	data, err := ioutil.ReadFile(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	s := scenario{}
+	err = json.Unmarshal(data, &s)
+	if !assert.Nil(t, err, "parsing %s", path) {
+		return
+	}
+	stub := memstub.New()
+	admin := smartcontract.AdminContract{}
+	setupScenario(t, stub, &admin, &s)
+	for period := range s.Periods {
+		if !runScenarioPeriod(t, stub, &admin, &s, period) {
+			return
+		}
+	}
+	if *updateScenarios {
+		updated, err := json.MarshalIndent(s, "", "  ")
+		assert.Nil(t, err)
+		err = ioutil.WriteFile(path, append(updated, '\n'), 0644)
+		assert.Nil(t, err)
+	}
+}
+
+func setupScenario(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, s *scenario) {
+	steps := []func(ctx contractapi.TransactionContextInterface) error{
+		func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateFund(ctx, s.Fund.ID, s.Fund.Name, s.Fund.InceptionDate)
+		},
+	}
+	for _, investor := range s.Investors {
+		investor := investor
+		steps = append(steps, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateInvestor(ctx, investor.ID, investor.Name)
+		})
+	}
+	for _, account := range s.Accounts {
+		account := account
+		steps = append(steps, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccount(ctx, account.ID, s.Fund.ID, account.Investor, account.HasPerformanceFees, account.PerformanceFeeRate)
+		})
+	}
+	for _, portfolio := range s.Portfolios {
+		portfolio := portfolio
+		steps = append(steps, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolio(ctx, portfolio.ID, s.Fund.ID, portfolio.Name)
+		})
+	}
+	transact(t, stub, steps...)
+}
+
+// Submits the period's trades, prices and capital account actions, then closes
+// the period and checks the results. Returns false when the scenario cannot go on.
+func runScenarioPeriod(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, s *scenario, period int) bool {
+	p := s.Periods[period]
+	steps := []func(ctx contractapi.TransactionContextInterface) error{}
+	for _, trade := range p.Trades {
+		trade := trade
+		steps = append(steps, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreatePortfolioAction(ctx, trade.ID, trade.Portfolio, trade.Type, trade.Date, period, trade.Name, trade.CUSIP, trade.Amount, trade.Currency)
+		})
+	}
+	for _, price := range p.Prices {
+		price := price
+		steps = append(steps, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.UpdatePortfolioValuation(ctx, price.Portfolio, price.Date, price.Name, price.Price)
+		})
+	}
+	for _, action := range p.Actions {
+		action := action
+		steps = append(steps, func(ctx contractapi.TransactionContextInterface) error {
+			return admin.CreateCapitalAccountAction(ctx, action.ID, action.Account, action.Type, action.Amount, false, action.Date, period)
+		})
+	}
+	transact(t, stub, steps...)
+	err := stub.Transact(func(ctx contractapi.TransactionContextInterface) error {
+		if period == 0 {
+			_, err := admin.BootstrapFund(ctx, s.Fund.ID)
+			return err
+		}
+		_, err := admin.StepFund(ctx, s.Fund.ID)
+		return err
+	})
+	if p.Error != "" {
+		if assert.NotNil(t, err, "period %d", period) {
+			coded, ok := pkgErrors.From(err)
+			if assert.True(t, ok, "period %d: %v", period, err) {
+				assert.Equal(t, coded.Code, p.Error, "period %d", period)
+			}
+		}
+		return false
+	}
+	if !assert.Nil(t, err, "period %d", period) {
+		return false
+	}
+	fund, accounts := loadScenarioResults(t, stub, admin, s)
+	if *updateScenarios {
+		p.Expected = updatedScenarioExpectations(p.Expected, fund, accounts, period)
+		s.Periods[period] = p
+		return true
+	}
+	if p.Expected != nil {
+		checkScenarioExpectations(t, p.Expected, fund, accounts, period)
+	}
+	return true
+}
+
+func loadScenarioResults(t *testing.T, stub *memstub.Stub, admin *smartcontract.AdminContract, s *scenario) (*types.Fund, map[string]*types.CapitalAccount) {
+	var fund *types.Fund
+	accounts := make(map[string]*types.CapitalAccount)
+	transact(t, stub, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		fund, err = admin.QueryFundById(ctx, s.Fund.ID)
+		if err != nil {
+			return err
+		}
+		for _, account := range s.Accounts {
+			accounts[account.ID], err = admin.QueryCapitalAccountById(ctx, account.ID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return fund, accounts
+}
+
+func checkScenarioExpectations(t *testing.T, expected *scenarioExpected, fund *types.Fund, accounts map[string]*types.CapitalAccount, period int) {
+	for field, value := range expected.Fund {
+		getter, ok := scenarioFundFields[field]
+		if !assert.True(t, ok, "period %d: unknown fund field %s", period, field) {
+			continue
+		}
+		actual, ok := getter(fund, period)
+		checkScenarioValue(t, "fund "+field, period, value, actual, ok)
+	}
+	for accountId, fields := range expected.Accounts {
+		account, ok := accounts[accountId]
+		if !assert.True(t, ok, "period %d: unknown account %s", period, accountId) {
+			continue
+		}
+		for field, value := range fields {
+			getter, ok := scenarioAccountFields[field]
+			if !assert.True(t, ok, "period %d: unknown account field %s", period, field) {
+				continue
+			}
+			actual, ok := getter(account, period)
+			checkScenarioValue(t, accountId+" "+field, period, value, actual, ok)
+		}
+	}
+}
+
+func checkScenarioValue(t *testing.T, name string, period int, expected string, actual string, found bool) {
+	if !assert.True(t, found, "period %d: %s was not recorded", period, name) {
+		return
+	}
+	expectedValue, err := decimal.NewFromString(expected)
+	if !assert.Nil(t, err, "period %d: %s expected value %q", period, name, expected) {
+		return
+	}
+	actualValue, err := decimal.NewFromString(actual)
+	if !assert.Nil(t, err, "period %d: %s actual value %q", period, name, actual) {
+		return
+	}
+	places := decimalPlaces(expectedValue)
+	assert.True(
+		t,
+		actualValue.Round(places).Equal(expectedValue),
+		"period %d: %s expected %s, got %s", period, name, expected, actualValue.StringFixed(places),
+	)
+}
+
+func decimalPlaces(value decimal.Decimal) int32 {
+	if value.Exponent() >= 0 {
+		return 0
+	}
+	return -value.Exponent()
+}
+
+// Fills in every recorded value, keeping the precision of existing expectations
+func updatedScenarioExpectations(
+	existing *scenarioExpected,
+	fund *types.Fund,
+	accounts map[string]*types.CapitalAccount,
+	period int,
+) *scenarioExpected {
+	updated := &scenarioExpected{Fund: map[string]string{}, Accounts: map[string]map[string]string{}}
+	if existing == nil {
+		existing = &scenarioExpected{}
+	}
+	for field, getter := range scenarioFundFields {
+		if actual, ok := getter(fund, period); ok {
+			updated.Fund[field] = roundScenarioValue(actual, existing.Fund[field], field)
+		}
+	}
+	for accountId, account := range accounts {
+		updated.Accounts[accountId] = map[string]string{}
+		for field, getter := range scenarioAccountFields {
+			if actual, ok := getter(account, period); ok {
+				updated.Accounts[accountId][field] = roundScenarioValue(actual, existing.Accounts[accountId][field], field)
+			}
+		}
+	}
+	return updated
+}
+
+func roundScenarioValue(actual string, existing string, field string) string {
+	places := defaultScenarioPrecision(field)
+	if existingValue, err := decimal.NewFromString(existing); err == nil {
+		places = decimalPlaces(existingValue)
+	}
+	return decimal.RequireFromString(actual).StringFixed(places)
+}
@@ -0,0 +1,159 @@
+package smartcontract
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/zacharyfrederick/admin/types"
+	"github.com/zacharyfrederick/admin/types/doctypes"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Upgrades a decoded document from one schema version to the next. The document
+// is modified in place; the caller stamps the new version afterwards.
+type schemaUpgrade func(ctx SmartContractContext, doc map[string]interface{}) error
+
+var currentSchemaVersions = map[string]int{
+	doctypes.DOCTYPE_FUND:                 types.FUND_SCHEMA_VERSION,
+	doctypes.DOCTYPE_INVESTOR:             types.INVESTOR_SCHEMA_VERSION,
+	doctypes.DOCTYPE_CAPITALACCOUNT:       types.CAPITALACCOUNT_SCHEMA_VERSION,
+	doctypes.DOCTYPE_CAPITALACCOUNTACTION: types.CAPITALACCOUNTACTION_SCHEMA_VERSION,
+	doctypes.DOCTYPE_PORTFOLIO:            types.PORTFOLIO_SCHEMA_VERSION,
+	doctypes.DOCTYPE_PORTFOLIOACTION:      types.PORTFOLIOACTION_SCHEMA_VERSION,
+	doctypes.DOCTYPE_RISKLESSRATE:         types.RISKLESSRATE_SCHEMA_VERSION,
+	doctypes.DOCTYPE_SECURITY:             types.SECURITY_SCHEMA_VERSION,
+}
+
+// Registry of upgrades keyed by doctype and the version they upgrade from. A
+// version without an entry is upgraded by stamping the next version only.
+var schemaUpgrades = map[string]map[int]schemaUpgrade{
+	doctypes.DOCTYPE_FUND: {
+		0: upgradeFundV0,
+		1: upgradeFundV1,
+	},
+	doctypes.DOCTYPE_CAPITALACCOUNTACTION: {
+		0: upgradeCapitalAccountActionV0,
+	},
+	doctypes.DOCTYPE_PORTFOLIOACTION: {
+		0: upgradePortfolioActionV0,
+	},
+}
+
+// Funds written before mid year withdrawals existed have no list at all, and the
+// mid year lists of older funds may have been stored as null
+func upgradeFundV0(ctx SmartContractContext, doc map[string]interface{}) error {
+	for _, field := range []string{"midYearDeposits", "midYearWithdrawals"} {
+		if doc[field] == nil {
+			doc[field] = []interface{}{}
+		}
+	}
+	return nil
+}
+
+// Funds written before rounding policies existed get the default policy
+func upgradeFundV1(ctx SmartContractContext, doc map[string]interface{}) error {
+	if doc["roundingPolicy"] == nil {
+		doc["roundingPolicy"] = types.DefaultRoundingPolicy()
+	}
+	return nil
+}
+
+// Actions written before the fund was denormalised onto them inherit it from
+// their capital account
+func upgradeCapitalAccountActionV0(ctx SmartContractContext, doc map[string]interface{}) error {
+	return fillFundFromParent(ctx, doc, "capitalAccount")
+}
+
+// Actions written before the fund was denormalised onto them inherit it from
+// their portfolio
+func upgradePortfolioActionV0(ctx SmartContractContext, doc map[string]interface{}) error {
+	return fillFundFromParent(ctx, doc, "portfolio")
+}
+
+func fillFundFromParent(ctx SmartContractContext, doc map[string]interface{}, parentField string) error {
+	if fund, ok := doc["fund"].(string); ok && fund != "" {
+		return nil
+	}
+	parentId, _ := doc[parentField].(string)
+	if parentId == "" {
+		return nil
+	}
+	data, err := ctx.GetStub().GetState(parentId)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	var parent struct {
+		Fund string `json:"fund"`
+	}
+	err = json.Unmarshal(data, &parent)
+	if err != nil {
+		return smartcontracterrors.LoadStateError
+	}
+	doc["fund"] = parent.Fund
+	return nil
+}
+
+// Brings a stored document up to the current schema version of its doctype.
+// Returns the document unchanged, and false, when no upgrade was needed.
+// Documents of unknown doctypes are passed through as is.
+func upgradeDocument(ctx SmartContractContext, data []byte) ([]byte, bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	err := decoder.Decode(&doc)
+	if err != nil {
+		return nil, false, smartcontracterrors.LoadStateError
+	}
+	docType, _ := doc["docType"].(string)
+	currentVersion, ok := currentSchemaVersions[docType]
+	if !ok {
+		return data, false, nil
+	}
+	version, err := documentSchemaVersion(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if version > currentVersion {
+		return nil, false, smartcontracterrors.UnsupportedSchemaVersionError.
+			WithDetail("docType", docType).
+			WithDetail("schemaVersion", strconv.Itoa(version))
+	}
+	if version == currentVersion {
+		return data, false, nil
+	}
+	for ; version < currentVersion; version++ {
+		if upgrade, ok := schemaUpgrades[docType][version]; ok {
+			err = upgrade(ctx, doc)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		doc["schemaVersion"] = version + 1
+	}
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, smartcontracterrors.LoadStateError
+	}
+	return upgraded, true, nil
+}
+
+// Documents written before versioning have no schemaVersion and count as version 0
+func documentSchemaVersion(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["schemaVersion"]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	number, ok := raw.(json.Number)
+	if !ok {
+		return 0, smartcontracterrors.LoadStateError
+	}
+	version, err := number.Int64()
+	if err != nil {
+		return 0, smartcontracterrors.LoadStateError
+	}
+	return int(version), nil
+}
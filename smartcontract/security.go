@@ -0,0 +1,86 @@
+package smartcontract
+
+import (
+	"github.com/zacharyfrederick/admin/types"
+	"github.com/zacharyfrederick/admin/types/doctypes"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+	"github.com/zacharyfrederick/admin/utils"
+)
+
+// Sets the security master record of a CUSIP, replacing any earlier one. A CUSIP
+// that is the id of a document of another type is rejected.
+func (s *AdminContract) SetSecurity(
+	ctx SmartContractContext,
+	cusip string,
+	name string,
+	assetClass string,
+	sector string,
+	country string,
+	issuer string,
+) error {
+	request := &types.SetSecurityRequest{
+		Name:       name,
+		AssetClass: assetClass,
+		Sector:     sector,
+		Country:    country,
+		Issuer:     issuer,
+	}
+	err := types.ValidateSetSecurityRequest(cusip, request)
+	if err != nil {
+		return err
+	}
+	existing, err := loadSecurity(ctx, cusip)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		idInUse, err := utils.AssetExists(ctx, cusip)
+		if err != nil {
+			return smartcontracterrors.ReadingWorldStateError
+		}
+		if idInUse {
+			return smartcontracterrors.IdAlreadyInUseError.WithDetail("id", cusip)
+		}
+	}
+	security := types.CreateSecurity(cusip, request)
+	return SaveState(ctx, &security)
+}
+
+func (s *AdminContract) QuerySecurityById(
+	ctx SmartContractContext,
+	cusip string,
+) (*types.Security, error) {
+	return loadSecurity(ctx, cusip)
+}
+
+// Loads the security master records of the CUSIPs that have one
+func querySecurities(ctx SmartContractContext, cusips []string) (map[string]*types.Security, error) {
+	securities := map[string]*types.Security{}
+	for _, cusip := range cusips {
+		if _, ok := securities[cusip]; ok {
+			continue
+		}
+		security, err := loadSecurity(ctx, cusip)
+		if err != nil {
+			return nil, err
+		}
+		if security != nil {
+			securities[cusip] = security
+		}
+	}
+	return securities, nil
+}
+
+// Securities are stored under the bare CUSIP, so a document of another type whose
+// id looks like a CUSIP is not a security and is treated as missing
+func loadSecurity(ctx SmartContractContext, cusip string) (*types.Security, error) {
+	var security types.Security
+	found, err := loadStateById(ctx, cusip, &security)
+	if err != nil {
+		return nil, err
+	}
+	if !found || security.DocType != doctypes.DOCTYPE_SECURITY {
+		return nil, nil
+	}
+	return &security, nil
+}
@@ -2,6 +2,7 @@ package smartcontract
 
 import (
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/zacharyfrederick/admin/types"
 	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
 )
 
@@ -15,18 +16,31 @@ type Modeler interface {
 	ToJSON() ([]byte, error)
 	FromJSON([]byte) error
 	GetID() string
+	IndexKeys() []types.IndexKey
 }
 
+// Writes the document under its id together with the composite index keys that
+// point back to it
 func SaveState(ctx contractapi.TransactionContextInterface, m Modeler) error {
 	modelJSON, err := m.ToJSON()
 	if err != nil {
 		return smartcontracterrors.SaveStateError
 	}
-	return ctx.GetStub().PutState(m.GetID(), modelJSON)
+	err = ctx.GetStub().PutState(m.GetID(), modelJSON)
+	if err != nil {
+		return err
+	}
+	return saveIndexKeys(ctx, m)
 }
 
-func LoadState(data []byte, m Modeler) error {
-	err := m.FromJSON(data)
+// Decodes a stored document into m, upgrading it to the current schema version of
+// its doctype first. The upgrade is not written back; see MigrateSchema.
+func LoadState(ctx SmartContractContext, data []byte, m Modeler) error {
+	data, _, err := upgradeDocument(ctx, data)
+	if err != nil {
+		return err
+	}
+	err = m.FromJSON(data)
 	if err != nil {
 		return smartcontracterrors.LoadStateError
 	}
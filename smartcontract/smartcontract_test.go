@@ -3,14 +3,17 @@ package smartcontract_test
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/zacharyfrederick/admin/smartcontract"
 	"github.com/zacharyfrederick/admin/types"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/stretchr/testify/assert"
 	"github.com/zacharyfrederick/admin/smartcontract/mocks"
 	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
@@ -35,8 +38,37 @@ func prepareTest() (*mocks.ChaincodeStub, *mocks.TransactionContext) {
 	chaincodeStub := &mocks.ChaincodeStub{}
 	transactionContext := &mocks.TransactionContext{}
 	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.GetTxTimestampReturns(&timestamp.Timestamp{Seconds: 1577836800}, nil)
 	return chaincodeStub, transactionContext
 }
+
+//serves GetState from the supplied documents keyed by id
+func stubState(chaincodeStub *mocks.ChaincodeStub, state map[string][]byte) {
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		return state[key], nil
+	}
+}
+
+//serves partial composite key queries from the supplied ids keyed by indexKey
+func stubIndex(chaincodeStub *mocks.ChaincodeStub, index map[string][]string) {
+	chaincodeStub.GetStateByPartialCompositeKeyStub = func(
+		objectType string,
+		attributes []string,
+	) (shim.StateQueryIteratorInterface, error) {
+		ids := index[indexKey(objectType, attributes...)]
+		iterator := &mocks.StateQueryIterator{}
+		for i, id := range ids {
+			iterator.HasNextReturnsOnCall(i, true)
+			iterator.NextReturnsOnCall(i, &queryresult.KV{Value: []byte(id)}, nil)
+		}
+		iterator.HasNextReturnsOnCall(len(ids), false)
+		return iterator, nil
+	}
+}
+
+func indexKey(objectType string, attributes ...string) string {
+	return objectType + ":" + strings.Join(attributes, "~")
+}
 func TestCreateFund(t *testing.T) {
 	_, transactionContext := prepareTest()
 	admin := smartcontract.AdminContract{}
@@ -49,7 +81,7 @@ func TestCreateFundExistingId(t *testing.T) {
 	chaincodeStub.GetStateReturns([]byte("fake_object"), nil)
 	admin := smartcontract.AdminContract{}
 	err := admin.CreateFund(transactionContext, "test_id", "test_fund", "12-27-1996")
-	assert.Equal(t, err, smartcontracterrors.IdAlreadyInUseError)
+	assert.ErrorIs(t, err, smartcontracterrors.IdAlreadyInUseError)
 }
 
 func TestCreateFundErrorReadingWorldState(t *testing.T) {
@@ -57,7 +89,7 @@ func TestCreateFundErrorReadingWorldState(t *testing.T) {
 	chaincodeStub.GetStateReturns(nil, smartcontracterrors.ReadingWorldStateError)
 	admin := smartcontract.AdminContract{}
 	err := admin.CreateFund(transactionContext, "test_id", "test_fund", "12-27-1996")
-	assert.Equal(t, err, smartcontracterrors.ReadingWorldStateError)
+	assert.ErrorIs(t, err, smartcontracterrors.ReadingWorldStateError)
 }
 
 func TestCreateInvestor(t *testing.T) {
@@ -73,7 +105,7 @@ func TestCreateInvestorErrorReadingWorldState(t *testing.T) {
 	chaincodeStub.GetStateReturns(nil, errors.New("fake error reading world state"))
 	admin := smartcontract.AdminContract{}
 	err := admin.CreateInvestor(transactionContext, "test_id", "test_name")
-	assert.Equal(t, err, smartcontracterrors.ReadingWorldStateError)
+	assert.ErrorIs(t, err, smartcontracterrors.ReadingWorldStateError)
 }
 
 func TestCreateInvestorExistingId(t *testing.T) {
@@ -81,7 +113,7 @@ func TestCreateInvestorExistingId(t *testing.T) {
 	chaincodeStub.GetStateReturns([]byte("fake object"), nil)
 	admin := smartcontract.AdminContract{}
 	err := admin.CreateInvestor(transactionContext, "test_id", "test_name")
-	assert.Equal(t, err, smartcontracterrors.IdAlreadyInUseError)
+	assert.ErrorIs(t, err, smartcontracterrors.IdAlreadyInUseError)
 }
 
 func TestCreateCapitalAccount(t *testing.T) {
@@ -99,8 +131,6 @@ func TestCreateCapitalAccount(t *testing.T) {
 	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)          //test if the id is in use
 	chaincodeStub.GetStateReturnsOnCall(1, fundJSON, nil)     //QueryFundById
 	chaincodeStub.GetStateReturnsOnCall(2, investorJSON, nil) //QueryInvestorById
-	chaincodeStub.PutStateReturnsOnCall(0, nil)               //fund.SaveState(ctx)
-	chaincodeStub.PutStateReturnsOnCall(1, nil)               //capitalAccount.SaveState(ctx)
 	//run the test
 	err = admin.CreateCapitalAccount(
 		transactionContext,
@@ -111,6 +141,14 @@ func TestCreateCapitalAccount(t *testing.T) {
 		"0",
 	)
 	assert.Nil(t, err)
+	//the fund is not written, the account is numbered when the fund is bootstrapped
+	key, data := chaincodeStub.PutStateArgsForCall(0)
+	assert.Equal(t, key, "testAccountId")
+	var capitalAccount types.CapitalAccount
+	err = json.Unmarshal(data, &capitalAccount)
+	assert.Nil(t, err)
+	assert.Equal(t, capitalAccount.Number, types.UNASSIGNED_INVESTOR_NUMBER)
+	assert.Equal(t, capitalAccount.CreatedAt, "2020-01-01T00:00:00.000000000Z")
 }
 
 func TestCreateCapitalAccountExistingId(t *testing.T) {
@@ -125,7 +163,7 @@ func TestCreateCapitalAccountExistingId(t *testing.T) {
 		false,
 		"0",
 	)
-	assert.Equal(t, err, smartcontracterrors.IdAlreadyInUseError)
+	assert.ErrorIs(t, err, smartcontracterrors.IdAlreadyInUseError)
 }
 
 func TestCreateCapitalAccountMissingFund(t *testing.T) {
@@ -141,7 +179,7 @@ func TestCreateCapitalAccountMissingFund(t *testing.T) {
 		false,
 		"0",
 	)
-	assert.Equal(t, err, smartcontracterrors.FundNotFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.FundNotFoundError)
 }
 
 func TestCreateCapitalAccountMissingInvestor(t *testing.T) {
@@ -161,7 +199,7 @@ func TestCreateCapitalAccountMissingInvestor(t *testing.T) {
 		false,
 		"0",
 	)
-	assert.Equal(t, err, smartcontracterrors.InvestorNotFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.InvestorNotFoundError)
 }
 
 func TestCreateCapitalAccountAction(t *testing.T) {
@@ -178,7 +216,8 @@ func TestCreateCapitalAccountAction(t *testing.T) {
 	)
 	capitalAccountJSON, err := capitalAccount.ToJSON()
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturns(capitalAccountJSON, nil)
+	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)                //test if the id is in use
+	chaincodeStub.GetStateReturnsOnCall(1, capitalAccountJSON, nil) //QueryCapitalAccountById
 	err = admin.CreateCapitalAccountAction(
 		transactionContext,
 		"testTransactionId",
@@ -206,7 +245,7 @@ func TestCreateCapitalAccountActionMissingAccount(t *testing.T) {
 		"12-27-1996",
 		0,
 	)
-	assert.Equal(t, err, smartcontracterrors.CapitalAccountNotFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.CapitalAccountNotFoundError)
 }
 
 func TestCreateCapitalAccountActionInvalidType(t *testing.T) {
@@ -223,7 +262,8 @@ func TestCreateCapitalAccountActionInvalidType(t *testing.T) {
 	)
 	capitalAccountJSON, err := capitalAccount.ToJSON()
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturns(capitalAccountJSON, nil)
+	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)                //test if the id is in use
+	chaincodeStub.GetStateReturnsOnCall(1, capitalAccountJSON, nil) //QueryCapitalAccountById
 	err = admin.CreateCapitalAccountAction(
 		transactionContext,
 		"testTransactionId",
@@ -234,7 +274,7 @@ func TestCreateCapitalAccountActionInvalidType(t *testing.T) {
 		"12-27-1996",
 		0,
 	)
-	assert.Equal(t, err, smartcontracterrors.InvalidCapitalAccountActionTypeError)
+	assert.ErrorIs(t, err, smartcontracterrors.InvalidCapitalAccountActionTypeError)
 }
 
 func TestCreatePortfolio(t *testing.T) {
@@ -265,7 +305,7 @@ func TestCreatePortfolioMissingFund(t *testing.T) {
 		"testFundId",
 		"testPortfolio",
 	)
-	assert.Equal(t, err, smartcontracterrors.FundNotFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.FundNotFoundError)
 }
 
 func TestCreatePortfolioAction(t *testing.T) {
@@ -274,7 +314,8 @@ func TestCreatePortfolioAction(t *testing.T) {
 	portfolio := types.CreateDefaultPortfolio("testPortfolioId", "testFundId", "testPortfolio")
 	portfolioJSON, err := portfolio.ToJSON()
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturnsOnCall(0, portfolioJSON, nil)
+	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)           //test if the id is in use
+	chaincodeStub.GetStateReturnsOnCall(1, portfolioJSON, nil) //QueryPortfolioById
 	err = admin.CreatePortfolioAction(
 		transactionContext,
 		"testActionId",
@@ -283,7 +324,7 @@ func TestCreatePortfolioAction(t *testing.T) {
 		"12-27-1996",
 		0,
 		"AMZN",
-		"testCusip",
+		"037833100",
 		"100",
 		"USD",
 	)
@@ -302,11 +343,11 @@ func TestCreatePortfolioActionMissingPortfolio(t *testing.T) {
 		"12-27-1996",
 		0,
 		"AMZN",
-		"testCusip",
+		"037833100",
 		"100",
 		"USD",
 	)
-	assert.Equal(t, err, smartcontracterrors.PortfolioNotFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.PortfolioNotFoundError)
 }
 
 func TestCreatePortfolioActionInvalidAction(t *testing.T) {
@@ -315,7 +356,8 @@ func TestCreatePortfolioActionInvalidAction(t *testing.T) {
 	portfolio := types.CreateDefaultPortfolio("testPortfolioId", "testFundId", "testPortfolio")
 	portfolioJSON, err := portfolio.ToJSON()
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturnsOnCall(0, portfolioJSON, nil)
+	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)           //test if the id is in use
+	chaincodeStub.GetStateReturnsOnCall(1, portfolioJSON, nil) //QueryPortfolioById
 	err = admin.CreatePortfolioAction(
 		transactionContext,
 		"testActionId",
@@ -324,11 +366,11 @@ func TestCreatePortfolioActionInvalidAction(t *testing.T) {
 		"12-27-1996",
 		0,
 		"AMZN",
-		"testCusip",
+		"037833100",
 		"100",
 		"USD",
 	)
-	assert.Equal(t, err, smartcontracterrors.InvalidPortfolioActionTypeError)
+	assert.ErrorIs(t, err, smartcontracterrors.InvalidPortfolioActionTypeError)
 }
 
 func TestBootstrapCapitalAccount(t *testing.T) {
@@ -337,8 +379,9 @@ func TestBootstrapCapitalAccount(t *testing.T) {
 
 	firstDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit1",
+		"testFundId",
 		"testAccountId",
-		"buy",
+		"deposit",
 		"10000",
 		false,
 		"12-27-1996",
@@ -349,8 +392,9 @@ func TestBootstrapCapitalAccount(t *testing.T) {
 
 	secondDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit2",
+		"testFundId",
 		"testAccountId",
-		"buy",
+		"deposit",
 		"3000",
 		false,
 		"12-27-1996",
@@ -359,23 +403,21 @@ func TestBootstrapCapitalAccount(t *testing.T) {
 	secondDepositJSON, err := json.Marshal(secondDeposit)
 	assert.Nil(t, err)
 
-	depositIterator := mocks.StateQueryIterator{}
-	depositIterator.HasNextReturnsOnCall(0, true)
-	depositIterator.HasNextReturnsOnCall(1, true)
-	depositIterator.HasNextReturnsOnCall(2, false)
-	depositIterator.NextReturnsOnCall(0, &queryresult.KV{
-		Value: firstDepositJSON,
-	}, nil)
-	depositIterator.NextReturnsOnCall(1, &queryresult.KV{
-		Value: secondDepositJSON,
-	}, nil)
-
-	withdrawalIterator := mocks.StateQueryIterator{}
-	withdrawalIterator.HasNextReturnsOnCall(0, false)
-	withdrawalIterator.CloseReturns(nil)
+	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
+	fundJSON, err := json.Marshal(fund)
+	assert.Nil(t, err)
 
-	chaincodeStub.GetQueryResultReturnsOnCall(0, &depositIterator, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(1, &withdrawalIterator, nil)
+	stubState(chaincodeStub, map[string][]byte{
+		"testFundId":   fundJSON,
+		"testDeposit1": firstDepositJSON,
+		"testDeposit2": secondDepositJSON,
+	})
+	stubIndex(chaincodeStub, map[string][]string{
+		indexKey(types.INDEX_CAPITALACCOUNTACTION, "testFundId", "testAccountId", "00000000"): {
+			"testDeposit1",
+			"testDeposit2",
+		},
+	})
 
 	capitalAccount := types.CreateDefaultCapitalAccount(
 		0,
@@ -388,14 +430,15 @@ func TestBootstrapCapitalAccount(t *testing.T) {
 	)
 
 	err = admin.BootstrapCapitalAccount(transactionContext, &capitalAccount)
+	assert.Nil(t, err)
 
 	currentPeriod := capitalAccount.CurrentPeriod
 	deposits := capitalAccount.Deposits[0]
 	openingValue := capitalAccount.OpeningValue[0]
 
 	assert.Equal(t, currentPeriod, 1)
-	assert.Equal(t, deposits, "13000")
-	assert.Equal(t, openingValue, "13000")
+	assert.Equal(t, deposits, "13000.00")
+	assert.Equal(t, openingValue, "13000.00")
 }
 
 func TestBootstrapCapitalAccountInvalidPeriod(t *testing.T) {
@@ -411,7 +454,7 @@ func TestBootstrapCapitalAccountInvalidPeriod(t *testing.T) {
 		"0",
 	)
 	err := admin.BootstrapCapitalAccount(transactionContext, &capitalAccount)
-	assert.Equal(t, err, smartcontracterrors.CannotBootstrapCapitalAccountError)
+	assert.ErrorIs(t, err, smartcontracterrors.CannotBootstrapCapitalAccountError)
 }
 
 func TestBootstrapCapitalAccountWithWithdrawals(t *testing.T) {
@@ -420,6 +463,7 @@ func TestBootstrapCapitalAccountWithWithdrawals(t *testing.T) {
 
 	firstDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit1",
+		"testFundId",
 		"testAccountId",
 		"deposit",
 		"10000",
@@ -432,6 +476,7 @@ func TestBootstrapCapitalAccountWithWithdrawals(t *testing.T) {
 
 	secondDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit2",
+		"testFundId",
 		"testAccountId",
 		"deposit",
 		"3000",
@@ -442,19 +487,9 @@ func TestBootstrapCapitalAccountWithWithdrawals(t *testing.T) {
 	secondDepositJSON, err := json.Marshal(secondDeposit)
 	assert.Nil(t, err)
 
-	depositIterator := mocks.StateQueryIterator{}
-	depositIterator.HasNextReturnsOnCall(0, true)
-	depositIterator.HasNextReturnsOnCall(1, true)
-	depositIterator.HasNextReturnsOnCall(2, false)
-	depositIterator.NextReturnsOnCall(0, &queryresult.KV{
-		Value: firstDepositJSON,
-	}, nil)
-	depositIterator.NextReturnsOnCall(1, &queryresult.KV{
-		Value: secondDepositJSON,
-	}, nil)
-
 	withdrawal := types.CreateDefaultCapitalAccountAction(
-		"testDeposit2",
+		"testWithdrawal1",
+		"testFundId",
 		"testAccountId",
 		"withdrawal",
 		"3000",
@@ -463,15 +498,25 @@ func TestBootstrapCapitalAccountWithWithdrawals(t *testing.T) {
 		0,
 	)
 	withdrawalJSON, err := json.Marshal(withdrawal)
+	assert.Nil(t, err)
 
-	withdrawalIterator := mocks.StateQueryIterator{}
-	withdrawalIterator.HasNextReturnsOnCall(0, true)
-	withdrawalIterator.NextReturnsOnCall(0, &queryresult.KV{
-		Value: withdrawalJSON,
-	}, nil)
+	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
+	fundJSON, err := json.Marshal(fund)
+	assert.Nil(t, err)
 
-	chaincodeStub.GetQueryResultReturnsOnCall(0, &depositIterator, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(1, &withdrawalIterator, nil)
+	stubState(chaincodeStub, map[string][]byte{
+		"testFundId":      fundJSON,
+		"testDeposit1":    firstDepositJSON,
+		"testDeposit2":    secondDepositJSON,
+		"testWithdrawal1": withdrawalJSON,
+	})
+	stubIndex(chaincodeStub, map[string][]string{
+		indexKey(types.INDEX_CAPITALACCOUNTACTION, "testFundId", "testAccountId", "00000000"): {
+			"testDeposit1",
+			"testDeposit2",
+			"testWithdrawal1",
+		},
+	})
 
 	capitalAccount := types.CreateDefaultCapitalAccount(
 		0,
@@ -484,14 +529,15 @@ func TestBootstrapCapitalAccountWithWithdrawals(t *testing.T) {
 	)
 
 	err = admin.BootstrapCapitalAccount(transactionContext, &capitalAccount)
+	assert.Nil(t, err)
 
 	currentPeriod := capitalAccount.CurrentPeriod
 	deposits := capitalAccount.Deposits[0]
 	openingValue := capitalAccount.OpeningValue[0]
 
 	assert.Equal(t, currentPeriod, 1)
-	assert.Equal(t, deposits, "10000")
-	assert.Equal(t, openingValue, "10000")
+	assert.Equal(t, deposits, "10000.00")
+	assert.Equal(t, openingValue, "10000.00")
 }
 
 func TestBootstrapCapitalAccountInvalidWithdrawals(t *testing.T) {
@@ -500,6 +546,7 @@ func TestBootstrapCapitalAccountInvalidWithdrawals(t *testing.T) {
 
 	firstDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit1",
+		"testFundId",
 		"testAccountId",
 		"deposit",
 		"10000",
@@ -512,6 +559,7 @@ func TestBootstrapCapitalAccountInvalidWithdrawals(t *testing.T) {
 
 	secondDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit2",
+		"testFundId",
 		"testAccountId",
 		"deposit",
 		"3000",
@@ -522,19 +570,9 @@ func TestBootstrapCapitalAccountInvalidWithdrawals(t *testing.T) {
 	secondDepositJSON, err := json.Marshal(secondDeposit)
 	assert.Nil(t, err)
 
-	depositIterator := mocks.StateQueryIterator{}
-	depositIterator.HasNextReturnsOnCall(0, true)
-	depositIterator.HasNextReturnsOnCall(1, true)
-	depositIterator.HasNextReturnsOnCall(2, false)
-	depositIterator.NextReturnsOnCall(0, &queryresult.KV{
-		Value: firstDepositJSON,
-	}, nil)
-	depositIterator.NextReturnsOnCall(1, &queryresult.KV{
-		Value: secondDepositJSON,
-	}, nil)
-
 	withdrawal := types.CreateDefaultCapitalAccountAction(
-		"testDeposit2",
+		"testWithdrawal1",
+		"testFundId",
 		"testAccountId",
 		"withdrawal",
 		"14000",
@@ -543,14 +581,26 @@ func TestBootstrapCapitalAccountInvalidWithdrawals(t *testing.T) {
 		0,
 	)
 	withdrawalJSON, err := json.Marshal(withdrawal)
+	assert.Nil(t, err)
+
+	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
+	fundJSON, err := json.Marshal(fund)
+	assert.Nil(t, err)
+
+	stubState(chaincodeStub, map[string][]byte{
+		"testFundId":      fundJSON,
+		"testDeposit1":    firstDepositJSON,
+		"testDeposit2":    secondDepositJSON,
+		"testWithdrawal1": withdrawalJSON,
+	})
+	stubIndex(chaincodeStub, map[string][]string{
+		indexKey(types.INDEX_CAPITALACCOUNTACTION, "testFundId", "testAccountId", "00000000"): {
+			"testDeposit1",
+			"testDeposit2",
+			"testWithdrawal1",
+		},
+	})
 
-	withdrawalIterator := mocks.StateQueryIterator{}
-	withdrawalIterator.HasNextReturnsOnCall(0, true)
-	withdrawalIterator.NextReturnsOnCall(0, &queryresult.KV{
-		Value: withdrawalJSON,
-	}, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(0, &depositIterator, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(1, &withdrawalIterator, nil)
 	capitalAccount := types.CreateDefaultCapitalAccount(
 		0,
 		0,
@@ -561,7 +611,7 @@ func TestBootstrapCapitalAccountInvalidWithdrawals(t *testing.T) {
 		"0",
 	)
 	err = admin.BootstrapCapitalAccount(transactionContext, &capitalAccount)
-	assert.Equal(t, err, smartcontracterrors.NegativeCapitalAccountBalanceError)
+	assert.ErrorIs(t, err, smartcontracterrors.NegativeCapitalAccountBalanceError)
 }
 
 func TestBootstrapFund(t *testing.T) {
@@ -569,10 +619,9 @@ func TestBootstrapFund(t *testing.T) {
 	admin := smartcontract.AdminContract{}
 
 	//query fund
-	fund := types.CreateDefaultFund("testId", "testFund", "12-27-1996")
+	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
 	fundJSON, err := json.Marshal(fund)
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturnsOnCall(0, fundJSON, nil)
 
 	//query capital accounts
 	capitalAccount1 := types.CreateDefaultCapitalAccount(
@@ -597,17 +646,11 @@ func TestBootstrapFund(t *testing.T) {
 	)
 	capitalAccount2JSON, err := json.Marshal(capitalAccount2)
 	assert.Nil(t, err)
-	capitalAccountIterator := mocks.StateQueryIterator{}
-	capitalAccountIterator.HasNextReturnsOnCall(0, true)
-	capitalAccountIterator.HasNextReturnsOnCall(1, true)
-	capitalAccountIterator.HasNextReturnsOnCall(2, false)
-	capitalAccountIterator.NextReturnsOnCall(0, &queryresult.KV{Value: capitalAccount1JSON}, nil)
-	capitalAccountIterator.NextReturnsOnCall(1, &queryresult.KV{Value: capitalAccount2JSON}, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(0, &capitalAccountIterator, nil)
 
 	//capital account 1 bootstrap
 	firstDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit1",
+		"testFundId",
 		"testAccountId1",
 		"deposit",
 		"10000",
@@ -617,18 +660,11 @@ func TestBootstrapFund(t *testing.T) {
 	)
 	firstDepositJSON, err := json.Marshal(firstDeposit)
 	assert.Nil(t, err)
-	depositIterator1 := mocks.StateQueryIterator{}
-	depositIterator1.HasNextReturnsOnCall(0, true)
-	depositIterator1.HasNextReturnsOnCall(1, false)
-	depositIterator1.NextReturnsOnCall(0, &queryresult.KV{Value: firstDepositJSON}, nil)
-	withdrawalIterator1 := mocks.StateQueryIterator{}
-	withdrawalIterator1.HasNextReturnsOnCall(0, false)
-	chaincodeStub.GetQueryResultReturnsOnCall(1, &depositIterator1, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(2, &withdrawalIterator1, nil)
 
 	//capital account 2 bootstrap
 	secondDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit2",
+		"testFundId",
 		"testAccountId2",
 		"deposit",
 		"90000",
@@ -638,24 +674,99 @@ func TestBootstrapFund(t *testing.T) {
 	)
 	secondDepositJSON, err := json.Marshal(secondDeposit)
 	assert.Nil(t, err)
-	depositIterator2 := mocks.StateQueryIterator{}
-	depositIterator2.HasNextReturnsOnCall(0, true)
-	depositIterator2.HasNextReturnsOnCall(1, false)
-	depositIterator2.NextReturnsOnCall(0, &queryresult.KV{Value: secondDepositJSON}, nil)
-	withdrawalIterator2 := mocks.StateQueryIterator{}
-	withdrawalIterator2.HasNextReturnsOnCall(0, false)
-	chaincodeStub.GetQueryResultReturnsOnCall(3, &depositIterator2, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(4, &withdrawalIterator2, nil)
+
+	stubState(chaincodeStub, map[string][]byte{
+		"testFundId":     fundJSON,
+		"testAccountId1": capitalAccount1JSON,
+		"testAccountId2": capitalAccount2JSON,
+		"testDeposit1":   firstDepositJSON,
+		"testDeposit2":   secondDepositJSON,
+	})
+	stubIndex(chaincodeStub, map[string][]string{
+		indexKey(types.INDEX_CAPITALACCOUNT, "testFundId"): {"testAccountId1", "testAccountId2"},
+		indexKey(types.INDEX_CAPITALACCOUNTACTION, "testFundId", "testAccountId1", "00000000"): {
+			"testDeposit1",
+		},
+		indexKey(types.INDEX_CAPITALACCOUNTACTION, "testFundId", "testAccountId2", "00000000"): {
+			"testDeposit2",
+		},
+	})
 
 	//run the test
-	resultFund, err := admin.BootstrapFund(transactionContext, "testId")
+	resultFund, err := admin.BootstrapFund(transactionContext, "testFundId")
 	assert.Nil(t, err)
 
 	openingValue := resultFund.OpeningValues[0]
 	deposits := resultFund.OpeningValues[0]
 
-	assert.Equal(t, openingValue, "100000")
-	assert.Equal(t, deposits, "100000")
+	assert.Equal(t, openingValue, "100000.00")
+	assert.Equal(t, deposits, "100000.00")
+}
+
+func TestBootstrapFundWithoutGeneralPartner(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
+	fundJSON, err := json.Marshal(fund)
+	assert.Nil(t, err)
+	capitalAccount := types.CreateDefaultCapitalAccount(
+		1,
+		0,
+		"testAccountId",
+		"testFundId",
+		"testInvestorId",
+		false,
+		"0",
+	)
+	capitalAccountJSON, err := json.Marshal(capitalAccount)
+	assert.Nil(t, err)
+	deposit := types.CreateDefaultCapitalAccountAction(
+		"testDeposit",
+		"testFundId",
+		"testAccountId",
+		"deposit",
+		"10000",
+		false,
+		"12-27-1996",
+		0,
+	)
+	depositJSON, err := json.Marshal(deposit)
+	assert.Nil(t, err)
+	stubState(chaincodeStub, map[string][]byte{
+		"testFundId":    fundJSON,
+		"testAccountId": capitalAccountJSON,
+		"testDeposit":   depositJSON,
+	})
+	stubIndex(chaincodeStub, map[string][]string{
+		indexKey(types.INDEX_CAPITALACCOUNT, "testFundId"): {"testAccountId"},
+		indexKey(types.INDEX_CAPITALACCOUNTACTION, "testFundId", "testAccountId", "00000000"): {
+			"testDeposit",
+		},
+	})
+
+	//the only account is not numbered 0, so it keeps its own share
+	resultFund, err := admin.BootstrapFund(transactionContext, "testFundId")
+	assert.Nil(t, err)
+	assert.Equal(t, "10000.00", resultFund.OpeningValues[0])
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	assert.Equal(t, "testAccountId", key)
+	var savedAccount types.CapitalAccount
+	assert.Nil(t, json.Unmarshal(value, &savedAccount))
+	assert.Equal(t, "1.0000000000", savedAccount.OwnershipPercentage[0])
+}
+
+func TestBootstrapFundWithoutCapitalAccounts(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
+	fundJSON, err := json.Marshal(fund)
+	assert.Nil(t, err)
+	stubState(chaincodeStub, map[string][]byte{"testFundId": fundJSON})
+	stubIndex(chaincodeStub, map[string][]string{})
+
+	resultFund, err := admin.BootstrapFund(transactionContext, "testFundId")
+	assert.Nil(t, err)
+	assert.Equal(t, "0.00", resultFund.OpeningValues[0])
 }
 
 func TestBootstrapFundInvalidPeriod(t *testing.T) {
@@ -668,7 +779,7 @@ func TestBootstrapFundInvalidPeriod(t *testing.T) {
 	chaincodeStub.GetStateReturnsOnCall(0, fundJSON, nil)
 	result, err := admin.BootstrapFund(transactionContext, "testId")
 	assert.Nil(t, result)
-	assert.Equal(t, err, smartcontracterrors.CannotBootstrapFundError)
+	assert.ErrorIs(t, err, smartcontracterrors.CannotBootstrapFundError)
 }
 
 func TestStepFundInvalidPeriod(t *testing.T) {
@@ -679,7 +790,7 @@ func TestStepFundInvalidPeriod(t *testing.T) {
 	assert.Nil(t, err)
 	chaincodeStub.GetStateReturnsOnCall(0, fundJSON, nil)
 	_, err = admin.StepFund(transactionContext, "testId")
-	assert.Equal(t, err, smartcontracterrors.CannotStepFundError)
+	assert.ErrorIs(t, err, smartcontracterrors.CannotStepFundError)
 }
 
 func TestStepFundCannotReadWorldState(t *testing.T) {
@@ -687,7 +798,7 @@ func TestStepFundCannotReadWorldState(t *testing.T) {
 	admin := smartcontract.AdminContract{}
 	chaincodeStub.GetStateReturnsOnCall(0, nil, smartcontracterrors.ReadingWorldStateError)
 	_, err := admin.StepFund(transactionContext, "testId")
-	assert.Equal(t, err, smartcontracterrors.ReadingWorldStateError)
+	assert.ErrorIs(t, err, smartcontracterrors.ReadingWorldStateError)
 }
 
 func TestStepFundInvalidFund(t *testing.T) {
@@ -695,7 +806,7 @@ func TestStepFundInvalidFund(t *testing.T) {
 	admin := smartcontract.AdminContract{}
 	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)
 	_, err := admin.StepFund(transactionContext, "testId")
-	assert.Equal(t, err, smartcontracterrors.FundNotFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.FundNotFoundError)
 }
 
 func TestBootstrapFundCannotReadWorldState(t *testing.T) {
@@ -703,7 +814,7 @@ func TestBootstrapFundCannotReadWorldState(t *testing.T) {
 	admin := smartcontract.AdminContract{}
 	chaincodeStub.GetStateReturnsOnCall(0, nil, smartcontracterrors.ReadingWorldStateError)
 	_, err := admin.BootstrapFund(transactionContext, "testId")
-	assert.Equal(t, err, smartcontracterrors.ReadingWorldStateError)
+	assert.ErrorIs(t, err, smartcontracterrors.ReadingWorldStateError)
 }
 
 func TestBootstrapFundInvalidFund(t *testing.T) {
@@ -711,7 +822,7 @@ func TestBootstrapFundInvalidFund(t *testing.T) {
 	admin := smartcontract.AdminContract{}
 	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)
 	_, err := admin.BootstrapFund(transactionContext, "testId")
-	assert.Equal(t, err, smartcontracterrors.FundNotFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.FundNotFoundError)
 }
 
 func TestStepFund(t *testing.T) {
@@ -722,7 +833,6 @@ func TestStepFund(t *testing.T) {
 	fund.IncrementCurrentPeriod() //step fund checks that it is not 0 which is the default value
 	fundJSON, err := json.Marshal(fund)
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturnsOnCall(0, fundJSON, nil)
 
 	//create the first portfolio
 	portfolio1 := types.CreateDefaultPortfolio("testPortfolioId", "testFundId", "testPortfolio1")
@@ -748,12 +858,6 @@ func TestStepFund(t *testing.T) {
 	portfolio1JSON, err := json.Marshal(portfolio1)
 	assert.Nil(t, err)
 
-	portfolioIterator := mocks.StateQueryIterator{}
-	portfolioIterator.HasNextReturnsOnCall(0, true)
-	portfolioIterator.HasNextReturnsOnCall(1, false)
-	portfolioIterator.NextReturnsOnCall(0, &queryresult.KV{Value: portfolio1JSON}, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(0, &portfolioIterator, nil)
-
 	//capital accounts
 	capitalAccount1 := types.CreateDefaultCapitalAccount(
 		0,
@@ -784,21 +888,10 @@ func TestStepFund(t *testing.T) {
 	capitalAccount2JSON, err := json.Marshal(capitalAccount2)
 	assert.Nil(t, err)
 
-	capitalAccountIterator := mocks.StateQueryIterator{}
-	capitalAccountIterator.HasNextReturnsOnCall(0, true)
-	capitalAccountIterator.HasNextReturnsOnCall(1, true)
-	capitalAccountIterator.HasNextReturnsOnCall(2, false)
-	capitalAccountIterator.NextReturnsOnCall(0, &queryresult.KV{Value: capitalAccount1JSON}, nil)
-	capitalAccountIterator.NextReturnsOnCall(1, &queryresult.KV{Value: capitalAccount2JSON}, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(1, &capitalAccountIterator, nil)
-
-	depositsIterator := mocks.StateQueryIterator{}
-	depositsIterator.HasNextReturnsOnCall(0, false) //capital account 1
-	depositsIterator.HasNextReturnsOnCall(1, true)  //capital account 2
-	depositsIterator.HasNextReturnsOnCall(2, false) //capital account 2
 	firstDeposit := types.CreateDefaultCapitalAccountAction(
 		"testDeposit1",
-		"testAccountId1",
+		"testFundId",
+		"testAccountId2",
 		"deposit",
 		"10000",
 		false,
@@ -807,14 +900,21 @@ func TestStepFund(t *testing.T) {
 	)
 	firstDepositJSON, err := json.Marshal(firstDeposit)
 	assert.Nil(t, err)
-	depositsIterator.NextReturnsOnCall(0, &queryresult.KV{Value: firstDepositJSON}, nil)
-	withdrawalIterator := mocks.StateQueryIterator{}
-	withdrawalIterator.HasNextReturnsOnCall(9, false)
-	withdrawalIterator.HasNextReturnsOnCall(1, false)
-	chaincodeStub.GetQueryResultReturnsOnCall(2, &depositsIterator, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(3, &withdrawalIterator, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(4, &depositsIterator, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(5, &withdrawalIterator, nil)
+
+	stubState(chaincodeStub, map[string][]byte{
+		"testFundId":      fundJSON,
+		"testPortfolioId": portfolio1JSON,
+		"testAccountId1":  capitalAccount1JSON,
+		"testAccountId2":  capitalAccount2JSON,
+		"testDeposit1":    firstDepositJSON,
+	})
+	stubIndex(chaincodeStub, map[string][]string{
+		indexKey(types.INDEX_PORTFOLIO, "testFundId"):      {"testPortfolioId"},
+		indexKey(types.INDEX_CAPITALACCOUNT, "testFundId"): {"testAccountId1", "testAccountId2"},
+		indexKey(types.INDEX_CAPITALACCOUNTACTION, "testFundId", "testAccountId2", "00000001"): {
+			"testDeposit1",
+		},
+	})
 	result, err := admin.StepFund(transactionContext, "testFundId")
 	assert.Nil(t, err)
 
@@ -823,10 +923,10 @@ func TestStepFund(t *testing.T) {
 	resultOpeningValue := resultFund.OpeningValues[resultFund.PreviousPeriod()]
 	resultFixedFees := resultFund.FixedFees[resultFund.PreviousPeriod()]
 	resultDeposits := resultFund.Deposits[resultFund.PreviousPeriod()]
-	assert.Equal(t, resultClosingValue, "144664")
-	assert.Equal(t, resultOpeningValue, "154664")
-	assert.Equal(t, resultFixedFees, "2603.952")
-	assert.Equal(t, resultDeposits, "12603.952")
+	assert.Equal(t, resultClosingValue, "144664.00")
+	assert.Equal(t, resultOpeningValue, "154664.00")
+	assert.Equal(t, resultFixedFees, "2603.95")
+	assert.Equal(t, resultDeposits, "12603.95")
 }
 
 func TestStepFundNoCapitalAccounts(t *testing.T) {
@@ -837,7 +937,6 @@ func TestStepFundNoCapitalAccounts(t *testing.T) {
 	fund.IncrementCurrentPeriod() //stepFund checks that it is not 0 which is the default value
 	fundJSON, err := json.Marshal(fund)
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturnsOnCall(0, fundJSON, nil)
 
 	//create the first portfolio
 	portfolio1 := types.CreateDefaultPortfolio("testPortfolioId", "testFundId", "testPortfolio1")
@@ -863,19 +962,17 @@ func TestStepFundNoCapitalAccounts(t *testing.T) {
 	portfolio1JSON, err := json.Marshal(portfolio1)
 	assert.Nil(t, err)
 
-	portfolioIterator := mocks.StateQueryIterator{}
-	portfolioIterator.HasNextReturnsOnCall(0, true)
-	portfolioIterator.HasNextReturnsOnCall(1, false)
-	portfolioIterator.NextReturnsOnCall(0, &queryresult.KV{Value: portfolio1JSON}, nil)
-	chaincodeStub.GetQueryResultReturnsOnCall(0, &portfolioIterator, nil)
-
-	capitalAccountIterator := mocks.StateQueryIterator{}
-	capitalAccountIterator.HasNextReturnsOnCall(0, false)
-	chaincodeStub.GetQueryResultReturnsOnCall(1, &capitalAccountIterator, nil)
+	stubState(chaincodeStub, map[string][]byte{
+		"testFundId":      fundJSON,
+		"testPortfolioId": portfolio1JSON,
+	})
+	stubIndex(chaincodeStub, map[string][]string{
+		indexKey(types.INDEX_PORTFOLIO, "testFundId"): {"testPortfolioId"},
+	})
 
 	result, err := admin.StepFund(transactionContext, "testFundId")
 	assert.Nil(t, result)
-	assert.Equal(t, err, smartcontracterrors.NoCapitalAccountsFoundError)
+	assert.ErrorIs(t, err, smartcontracterrors.NoCapitalAccountsFoundError)
 }
 
 func TestCreateCapitalAccountNonZeroPeriod(t *testing.T) {
@@ -916,8 +1013,9 @@ func TestCreateCapitalAccountActionMidYearDeposit(t *testing.T) {
 	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
 	fundJSON, err := fund.ToJSON()
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturnsOnCall(0, capitalAccountJSON, nil)
-	chaincodeStub.GetStateReturnsOnCall(1, fundJSON, nil)
+	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)                //test if the id is in use
+	chaincodeStub.GetStateReturnsOnCall(1, capitalAccountJSON, nil) //QueryCapitalAccountById
+	chaincodeStub.GetStateReturnsOnCall(2, fundJSON, nil)           //QueryFundById
 	err = admin.CreateCapitalAccountAction(
 		transactionContext,
 		"testTransactionId",
@@ -928,7 +1026,7 @@ func TestCreateCapitalAccountActionMidYearDeposit(t *testing.T) {
 		"12-27-1996",
 		3,
 	)
-	assert.Equal(t, err, smartcontracterrors.MidYearDepositError)
+	assert.ErrorIs(t, err, smartcontracterrors.MidYearDepositError)
 }
 
 func TestCreateCapitalAccountActionEndYearDeposit(t *testing.T) {
@@ -948,8 +1046,9 @@ func TestCreateCapitalAccountActionEndYearDeposit(t *testing.T) {
 	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
 	fundJSON, err := fund.ToJSON()
 	assert.Nil(t, err)
-	chaincodeStub.GetStateReturnsOnCall(0, capitalAccountJSON, nil)
-	chaincodeStub.GetStateReturnsOnCall(1, fundJSON, nil)
+	chaincodeStub.GetStateReturnsOnCall(0, nil, nil)                //test if the id is in use
+	chaincodeStub.GetStateReturnsOnCall(1, capitalAccountJSON, nil) //QueryCapitalAccountById
+	chaincodeStub.GetStateReturnsOnCall(2, fundJSON, nil)           //QueryFundById
 	err = admin.CreateCapitalAccountAction(
 		transactionContext,
 		"testTransactionId",
@@ -962,3 +1061,237 @@ func TestCreateCapitalAccountActionEndYearDeposit(t *testing.T) {
 	)
 	assert.Nil(t, err)
 }
+
+func TestQueryFundsWithPagination(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	fund := types.CreateDefaultFund("testFundId", "testFund", "12-27-1996")
+	fundJSON, err := fund.ToJSON()
+	assert.Nil(t, err)
+	stubState(chaincodeStub, map[string][]byte{"testFundId": fundJSON})
+	fundIterator := mocks.StateQueryIterator{}
+	fundIterator.HasNextReturnsOnCall(0, true)
+	fundIterator.HasNextReturnsOnCall(1, false)
+	fundIterator.NextReturnsOnCall(0, &queryresult.KV{Key: "testIndexKey", Value: []byte("testFundId")}, nil)
+	chaincodeStub.GetStateByPartialCompositeKeyWithPaginationReturns(
+		&fundIterator,
+		&peer.QueryResponseMetadata{FetchedRecordsCount: 1, Bookmark: "nextBookmark"},
+		nil,
+	)
+	page, err := admin.QueryFunds(transactionContext, 0, "")
+	assert.Nil(t, err)
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].ID, "testFundId")
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+	assert.Equal(t, page.Bookmark, "nextBookmark")
+	objectType, attributes, pageSize, _ := chaincodeStub.GetStateByPartialCompositeKeyWithPaginationArgsForCall(0)
+	assert.Equal(t, objectType, types.INDEX_FUND)
+	assert.Empty(t, attributes)
+	assert.Equal(t, pageSize, types.DEFAULT_PAGE_SIZE)
+}
+
+func TestQueryCapitalAccountActionsByFundWithFilter(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	actions := []types.CapitalAccountAction{
+		types.CreateDefaultCapitalAccountAction("testDeposit1", "testFundId", "testAccountId", "deposit", "100", false, "01-15-2021", 1),
+		types.CreateDefaultCapitalAccountAction("testDeposit2", "testFundId", "testAccountId", "deposit", "100", false, "03-15-2021", 1),
+		types.CreateDefaultCapitalAccountAction("testWithdrawal", "testFundId", "testAccountId", "withdrawal", "100", false, "03-20-2021", 1),
+		types.CreateDefaultCapitalAccountAction("testDeposit3", "testFundId", "testAccountId", "deposit", "100", false, "03-15-2021", 2),
+	}
+	state := map[string][]byte{}
+	actionIterator := mocks.StateQueryIterator{}
+	for i, action := range actions {
+		actionJSON, err := json.Marshal(action)
+		assert.Nil(t, err)
+		state[action.ID] = actionJSON
+		actionIterator.HasNextReturnsOnCall(i, true)
+		actionIterator.NextReturnsOnCall(i, &queryresult.KV{Key: "testIndexKey" + action.ID, Value: []byte(action.ID)}, nil)
+	}
+	actionIterator.HasNextReturnsOnCall(len(actions), false)
+	stubState(chaincodeStub, state)
+	chaincodeStub.GetStateByPartialCompositeKeyWithPaginationReturns(&actionIterator, &peer.QueryResponseMetadata{}, nil)
+	page, err := admin.QueryCapitalAccountActionsByFundWithPagination(
+		transactionContext,
+		"testFundId",
+		`{"period":1,"type":"deposit","startDate":"02-01-2021"}`,
+		10,
+		"",
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].ID, "testDeposit2")
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+	objectType, attributes, _, _ := chaincodeStub.GetStateByPartialCompositeKeyWithPaginationArgsForCall(0)
+	assert.Equal(t, objectType, types.INDEX_CAPITALACCOUNTACTION)
+	assert.Equal(t, attributes, []string{"testFundId"})
+	assert.Equal(t, chaincodeStub.GetQueryResultWithPaginationCallCount(), 0)
+}
+
+func TestMigrateIndexKeys(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	capitalAccount := types.CreateDefaultCapitalAccount(
+		0,
+		0,
+		"testAccountId",
+		"testFundId",
+		"testInvestorId",
+		false,
+		"0",
+	)
+	capitalAccountJSON, err := capitalAccount.ToJSON()
+	assert.Nil(t, err)
+	capitalAccountIterator := mocks.StateQueryIterator{}
+	capitalAccountIterator.HasNextReturnsOnCall(0, true)
+	capitalAccountIterator.HasNextReturnsOnCall(1, false)
+	capitalAccountIterator.NextReturnsOnCall(0, &queryresult.KV{Value: capitalAccountJSON}, nil)
+	chaincodeStub.GetQueryResultReturns(&capitalAccountIterator, nil)
+	chaincodeStub.CreateCompositeKeyReturns("testIndexKey", nil)
+	progress, err := admin.MigrateIndexKeys(transactionContext, "capitalAccount", "", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, progress.Processed, int32(1))
+	assert.Equal(t, progress.LastID, "testAccountId")
+	assert.True(t, progress.Done)
+	objectType, attributes := chaincodeStub.CreateCompositeKeyArgsForCall(0)
+	assert.Equal(t, objectType, types.INDEX_CAPITALACCOUNT)
+	assert.Equal(t, attributes, []string{"testFundId", "testAccountId"})
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	assert.Equal(t, key, "testIndexKey")
+	assert.Equal(t, value, []byte("testAccountId"))
+}
+
+func TestMigrateIndexKeysInvalidDocType(t *testing.T) {
+	_, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	_, err := admin.MigrateIndexKeys(transactionContext, "fake docType", "", 10)
+	assert.ErrorIs(t, err, smartcontracterrors.InvalidDocTypeError)
+}
+
+func TestQueryCapitalAccountActionByIdUpgradesLegacyDocument(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	capitalAccount := types.CreateDefaultCapitalAccount(0, 0, "testAccountId", "testFundId", "testInvestorId", false, "0")
+	capitalAccountJSON, err := capitalAccount.ToJSON()
+	assert.Nil(t, err)
+	legacyAction := []byte(`{"docType":"capitalAccountAction","id":"testActionId","capitalAccount":"testAccountId","type":"deposit","amount":"100","period":1}`)
+	stubState(chaincodeStub, map[string][]byte{
+		"testAccountId": capitalAccountJSON,
+		"testActionId":  legacyAction,
+	})
+	action, err := admin.QueryCapitalAccountActionById(transactionContext, "testActionId")
+	assert.Nil(t, err)
+	assert.Equal(t, action.Fund, "testFundId")
+	assert.Equal(t, action.SchemaVersion, types.CAPITALACCOUNTACTION_SCHEMA_VERSION)
+	assert.Equal(t, action.Amount, "100")
+}
+
+func TestQueryFundByIdNewerSchemaVersion(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	chaincodeStub.GetStateReturns([]byte(`{"docType":"fund","id":"testFundId","schemaVersion":99}`), nil)
+	_, err := admin.QueryFundById(transactionContext, "testFundId")
+	assert.True(t, errors.Is(err, smartcontracterrors.UnsupportedSchemaVersionError))
+}
+
+func TestMigrateSchema(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	currentFund := types.CreateDefaultFund("testFundId2", "testFund2", "01-01-2020")
+	currentFundJSON, err := currentFund.ToJSON()
+	assert.Nil(t, err)
+	fundIterator := mocks.StateQueryIterator{}
+	fundIterator.HasNextReturnsOnCall(0, true)
+	fundIterator.HasNextReturnsOnCall(1, true)
+	fundIterator.HasNextReturnsOnCall(2, false)
+	fundIterator.NextReturnsOnCall(0, &queryresult.KV{Value: []byte(`{"docType":"fund","id":"testFundId","name":"testFund","midYearDeposits":null}`)}, nil)
+	fundIterator.NextReturnsOnCall(1, &queryresult.KV{Value: currentFundJSON}, nil)
+	chaincodeStub.GetQueryResultReturns(&fundIterator, nil)
+	chaincodeStub.CreateCompositeKeyReturns("testIndexKey", nil)
+	progress, err := admin.MigrateSchema(transactionContext, "fund", "", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, progress.Processed, int32(2))
+	assert.Equal(t, progress.Upgraded, int32(1))
+	assert.Equal(t, progress.LastID, "testFundId2")
+	assert.True(t, progress.Done)
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	assert.Equal(t, key, "testFundId")
+	upgradedFund := types.Fund{}
+	err = json.Unmarshal(value, &upgradedFund)
+	assert.Nil(t, err)
+	assert.Equal(t, upgradedFund.SchemaVersion, types.FUND_SCHEMA_VERSION)
+	assert.Equal(t, upgradedFund.MidYearDeposits, []string{})
+	assert.Equal(t, upgradedFund.MidYearWithdrawals, []string{})
+	assert.Equal(t, upgradedFund.RoundingPolicy, types.DefaultRoundingPolicy())
+	eventName, eventPayload := chaincodeStub.SetEventArgsForCall(0)
+	assert.Equal(t, eventName, smartcontract.SCHEMA_MIGRATION_EVENT)
+	eventProgress := types.MigrationProgress{}
+	err = json.Unmarshal(eventPayload, &eventProgress)
+	assert.Nil(t, err)
+	assert.Equal(t, eventProgress, *progress)
+}
+
+func TestCreateCapitalAccountActionMissingAccountDetails(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	chaincodeStub.GetStateReturns(nil, nil)
+	err := admin.CreateCapitalAccountAction(transactionContext, "testActionId", "testAccountId", "deposit", "100", false, "12-27-1996", 0)
+	assert.ErrorIs(t, err, smartcontracterrors.CapitalAccountNotFoundError)
+	coded, ok := smartcontracterrors.From(err)
+	assert.True(t, ok)
+	assert.Equal(t, coded.Details, map[string]string{"capitalAccount": "testAccountId"})
+}
+
+func TestCreateCapitalAccountActionIdInUse(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	action := types.CreateDefaultCapitalAccountAction(
+		"testTransactionId",
+		"testFundId",
+		"testAccountId",
+		"deposit",
+		"100000",
+		false,
+		"12-27-1996",
+		0,
+	)
+	actionJSON, err := action.ToJSON()
+	assert.Nil(t, err)
+	chaincodeStub.GetStateReturnsOnCall(0, actionJSON, nil) //test if the id is in use
+	err = admin.CreateCapitalAccountAction(
+		transactionContext,
+		"testTransactionId",
+		"testAccountId",
+		"deposit",
+		"100000",
+		false,
+		"12-27-1996",
+		0,
+	)
+	assert.ErrorIs(t, err, smartcontracterrors.IdAlreadyInUseError)
+	assert.Equal(t, chaincodeStub.PutStateCallCount(), 0)
+}
+
+func TestCreatePortfolioActionIdInUse(t *testing.T) {
+	chaincodeStub, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	asset := types.CreateAsset("AAPL", "037833100", "100", "USD")
+	action := types.CreateDefaultPortfolioAction("testFundId", "testPortfolioId", "buy", "12-27-1996", "testActionId", asset, 0)
+	actionJSON, err := action.ToJSON()
+	assert.Nil(t, err)
+	chaincodeStub.GetStateReturnsOnCall(0, actionJSON, nil) //test if the id is in use
+	err = admin.CreatePortfolioAction(
+		transactionContext,
+		"testActionId",
+		"testPortfolioId",
+		"buy",
+		"12-27-1996",
+		0,
+		"AAPL",
+		"037833100",
+		"100",
+		"USD",
+	)
+	assert.ErrorIs(t, err, smartcontracterrors.IdAlreadyInUseError)
+	assert.Equal(t, chaincodeStub.PutStateCallCount(), 0)
+}
@@ -0,0 +1,103 @@
+package smartcontract_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zacharyfrederick/admin/smartcontract"
+	"github.com/zacharyfrederick/admin/types"
+	smartcontracterrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func TestCheckCUSIP(t *testing.T) {
+	for _, cusip := range []string{"037833100", "023135106", "000000000", "38259P508"} {
+		assert.Equal(t, "", types.CheckCUSIP(cusip), cusip)
+	}
+	for _, cusip := range []string{"037833101", "03783310", "0378331000", "03783310a", "testCusip"} {
+		assert.NotEqual(t, "", types.CheckCUSIP(cusip), cusip)
+	}
+}
+
+func TestCheckCurrency(t *testing.T) {
+	assert.Equal(t, "", types.CheckCurrency("USD"))
+	assert.Equal(t, "", types.CheckCurrency("EUR"))
+	assert.NotEqual(t, "", types.CheckCurrency("usd"))
+	assert.NotEqual(t, "", types.CheckCurrency("XYZ"))
+}
+
+func TestCheckRate(t *testing.T) {
+	for _, rate := range []string{"0", "0.2", "1"} {
+		assert.Equal(t, "", types.CheckRate(rate), rate)
+	}
+	for _, rate := range []string{"-0.1", "1.01", "20%", ""} {
+		assert.NotEqual(t, "", types.CheckRate(rate), rate)
+	}
+}
+
+func TestValidateCreateCapitalAccountActionRequestReportsEveryField(t *testing.T) {
+	err := types.ValidateCreateCapitalAccountActionRequest(&types.CreateCapitalAccountActionRequest{
+		CapitalAccount: "",
+		Type:           "transfer",
+		Amount:         "-100",
+		Date:           "2020-01-01",
+		Period:         -1,
+	})
+	assert.ErrorIs(t, err, smartcontracterrors.ValidationError)
+	coded, ok := smartcontracterrors.From(err)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"capitalAccount": "is required",
+		"type":           "must be one of deposit, withdrawal",
+		"amount":         "must be greater than zero",
+		"date":           "must use the MM-DD-YYYY format",
+		"period":         "must not be negative",
+	}, coded.Details)
+}
+
+func TestValidateCreateCapitalAccountActionRequestValid(t *testing.T) {
+	err := types.ValidateCreateCapitalAccountActionRequest(&types.CreateCapitalAccountActionRequest{
+		CapitalAccount: "testCapitalAccountId",
+		Type:           types.CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT,
+		Amount:         "100.50",
+		Date:           "01-31-2020",
+	})
+	assert.Nil(t, err)
+}
+
+func TestCreateCapitalAccountActionRejectsInvalidAmount(t *testing.T) {
+	_, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	err := admin.CreateCapitalAccountAction(
+		transactionContext,
+		"testActionId",
+		"testCapitalAccountId",
+		"deposit",
+		"abc",
+		false,
+		"12-27-1996",
+		0,
+	)
+	assert.ErrorIs(t, err, smartcontracterrors.ValidationError)
+	coded, _ := smartcontracterrors.From(err)
+	assert.Equal(t, "must be a decimal number", coded.Details["amount"])
+}
+
+func TestCreatePortfolioActionRejectsInvalidCUSIP(t *testing.T) {
+	_, transactionContext := prepareTest()
+	admin := smartcontract.AdminContract{}
+	err := admin.CreatePortfolioAction(
+		transactionContext,
+		"testActionId",
+		"testPortfolioId",
+		"buy",
+		"12-27-1996",
+		0,
+		"AAPL",
+		"037833101",
+		"100",
+		"USD",
+	)
+	assert.ErrorIs(t, err, smartcontracterrors.ValidationError)
+	coded, _ := smartcontracterrors.From(err)
+	assert.Equal(t, "has an invalid check digit", coded.Details["cusip"])
+}
@@ -3,13 +3,13 @@ package types
 import (
 	"encoding/json"
 
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/shopspring/decimal"
 	"github.com/zacharyfrederick/admin/types/doctypes"
 )
 
 type CapitalAccount struct {
 	DocType             string         `json:"docType"`
+	SchemaVersion       int            `json:"schemaVersion"`
 	ID                  string         `json:"id"`
 	Fund                string         `json:"fund"`
 	Investor            string         `json:"investor"`
@@ -26,6 +26,23 @@ type CapitalAccount struct {
 	FixedFee            string         `json:"fixedFee"`
 	HasPerformanceFees  bool           `json:"hasPerformanceFees"`
 	PerformanceFeeRate  string         `json:"performanceFeeRate"`
+	// Timestamp of the transaction that created the account, which orders the
+	// accounts when their numbers are assigned
+	CreatedAt string `json:"createdAt,omitempty"`
+	// Position of the account among those created by the same transaction, e.g.
+	// the row of an import, which orders accounts with the same CreatedAt
+	CreatedOrder int `json:"createdOrder,omitempty"`
+}
+
+// Accounts are numbered when the fund is bootstrapped or stepped rather than
+// when they are created, so creating an account never writes the fund
+const UNASSIGNED_INVESTOR_NUMBER int = -1
+
+// The management fee rate of accounts whose terms don't set one
+const DEFAULT_FIXED_FEE string = "0.02"
+
+func (c *CapitalAccount) HasInvestorNumber() bool {
+	return c.Number != UNASSIGNED_INVESTOR_NUMBER
 }
 
 func (c *CapitalAccount) UpdateClosingValue(fundClosingValue decimal.Decimal) {
@@ -61,12 +78,11 @@ func (f *CapitalAccount) FromJSON(data []byte) error {
 	return nil
 }
 
-func (c *CapitalAccount) SaveState(ctx contractapi.TransactionContextInterface) error {
-	capitalAccountJSON, err := c.ToJSON()
-	if err != nil {
-		return err
+func (f *CapitalAccount) IndexKeys() []IndexKey {
+	return []IndexKey{
+		{ObjectType: INDEX_CAPITALACCOUNT, Attributes: []string{f.Fund, f.ID}},
+		{ObjectType: INDEX_FUNDINVESTOR, Attributes: []string{f.Fund, f.Investor}},
 	}
-	return ctx.GetStub().PutState(c.ID, capitalAccountJSON)
 }
 
 func (c *CapitalAccount) PreviousPeriod() int {
@@ -94,6 +110,7 @@ func CreateDefaultCapitalAccount(
 ) CapitalAccount {
 	capitalAccount := CapitalAccount{
 		DocType:             doctypes.DOCTYPE_CAPITALACCOUNT,
+		SchemaVersion:       CAPITALACCOUNT_SCHEMA_VERSION,
 		ID:                  accountId,
 		Fund:                fundId,
 		Investor:            investorId,
@@ -107,7 +124,7 @@ func CreateDefaultCapitalAccount(
 		OwnershipPercentage: map[int]string{0: "0"},
 		HighWaterMark:       HighWaterMark{Amount: decimal.Zero.String(), Date: 0},
 		PeriodUpdated:       false,
-		FixedFee:            "0.02",
+		FixedFee:            DEFAULT_FIXED_FEE,
 		HasPerformanceFees:  hasPerformanceFees,
 		PerformanceFeeRate:  performanceFeeRate,
 	}
@@ -128,17 +145,11 @@ func CreateDefaultCapitalAccount(
 	return capitalAccount
 }
 
-func (c *CapitalAccountAction) SaveState(ctx contractapi.TransactionContextInterface) error {
-	capitalAccountActionJSON, err := json.Marshal(c)
-	if err != nil {
-		return err
-	}
-	return ctx.GetStub().PutState(c.ID, capitalAccountActionJSON)
-}
-
 type CapitalAccountAction struct {
 	DocType        string `json:"docType"`
+	SchemaVersion  int    `json:"schemaVersion"`
 	ID             string `json:"id"`
+	Fund           string `json:"fund"`
 	CapitalAccount string `json:"capitalAccount"`
 	Type           string `json:"type"`
 	Amount         string `json:"amount"`
@@ -149,8 +160,34 @@ type CapitalAccountAction struct {
 	Period         int    `json:"period"`
 }
 
+func (f *CapitalAccountAction) GetID() string {
+	return f.ID
+}
+
+func (f *CapitalAccountAction) ToJSON() ([]byte, error) {
+	capitalAccountActionJSON, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return capitalAccountActionJSON, nil
+}
+
+func (f *CapitalAccountAction) FromJSON(data []byte) error {
+	err := json.Unmarshal(data, f)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f *CapitalAccountAction) IndexKeys() []IndexKey {
+	attributes := []string{f.Fund, f.CapitalAccount, FormatPeriodKey(f.Period), f.ID}
+	return []IndexKey{{ObjectType: INDEX_CAPITALACCOUNTACTION, Attributes: attributes}}
+}
+
 func CreateDefaultCapitalAccountAction(
 	transactionId string,
+	fundId string,
 	capitalAccountId string,
 	type_ string,
 	amount string,
@@ -160,7 +197,9 @@ func CreateDefaultCapitalAccountAction(
 ) CapitalAccountAction {
 	capitalAccountAction := CapitalAccountAction{
 		DocType:        doctypes.DOCTYPE_CAPITALACCOUNTACTION,
+		SchemaVersion:  CAPITALACCOUNTACTION_SCHEMA_VERSION,
 		ID:             transactionId,
+		Fund:           fundId,
 		CapitalAccount: capitalAccountId,
 		Type:           type_,
 		Amount:         amount,
@@ -179,25 +218,35 @@ type HighWaterMark struct {
 }
 
 type CreateCapitalAccountRequest struct {
-	Fund               string `json:"fund"     binding:"required"`
-	Investor           string `json:"investor" binding:"required"`
-	HasPerformanceFees bool   `json:"hasPerformanceFees" binding:"isdefault|required"`
-	PerformanceRate    string `json:"performanceRate" binding:"required"`
+	Fund               string `json:"fund"`
+	Investor           string `json:"investor"`
+	HasPerformanceFees bool   `json:"hasPerformanceFees"`
+	PerformanceRate    string `json:"performanceRate"`
 }
 
-func ValidateCreateCapitalAccountRequest(r *CreateCapitalAccountRequest) bool {
-	return true
+func ValidateCreateCapitalAccountRequest(r *CreateCapitalAccountRequest) error {
+	errs := FieldErrors{}
+	errs.Check("fund", CheckRequired(r.Fund))
+	errs.Check("investor", CheckRequired(r.Investor))
+	errs.Check("performanceRate", CheckRate(r.PerformanceRate))
+	return errs.Err()
 }
 
 type CreateCapitalAccountActionRequest struct {
-	CapitalAccount string `json:"capitalAccount" binding:"required"`
-	Type           string `json:"type"           binding:"required"`
-	Amount         string `json:"amount"         binding:"required"`
-	Full           bool   `json:"full"           binding:"isdefault|required"`
-	Date           string `json:"date"           binding:"required"`
-	Period         int    `json:"period"         binding:"isdefault|required"`
+	CapitalAccount string `json:"capitalAccount"`
+	Type           string `json:"type"`
+	Amount         string `json:"amount"`
+	Full           bool   `json:"full"`
+	Date           string `json:"date"`
+	Period         int    `json:"period"`
 }
 
-func ValidateCreateCapitalAccountActionRequest(r *CreateCapitalAccountActionRequest) bool {
-	return true
+func ValidateCreateCapitalAccountActionRequest(r *CreateCapitalAccountActionRequest) error {
+	errs := FieldErrors{}
+	errs.Check("capitalAccount", CheckRequired(r.CapitalAccount))
+	errs.Check("type", CheckOneOf(r.Type, CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT, CAPITAL_ACCOUNT_ACTION_TYPE_WITHDRAWAL))
+	errs.Check("amount", CheckPositiveDecimal(r.Amount))
+	errs.Check("date", CheckDate(r.Date))
+	errs.Check("period", CheckPeriod(r.Period))
+	return errs.Err()
 }
@@ -0,0 +1,13 @@
+package types
+
+import "time"
+
+// Layout used for every date stored on the ledger, e.g. 12-27-1996
+const DATE_FORMAT string = "01-02-2006"
+
+// Transaction timestamps keep every fractional digit so that they sort as strings
+const TIMESTAMP_FORMAT string = "2006-01-02T15:04:05.000000000Z"
+
+func ParseDate(date string) (time.Time, error) {
+	return time.Parse(DATE_FORMAT, date)
+}
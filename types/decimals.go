@@ -0,0 +1,17 @@
+package types
+
+import "github.com/shopspring/decimal"
+
+// Amounts are compared as decimals, so "100" and "100.00" are the same amount. A
+// value that is not a decimal is never the same as anything, itself included.
+func SameDecimal(a string, b string) bool {
+	x, err := decimal.NewFromString(a)
+	if err != nil {
+		return false
+	}
+	y, err := decimal.NewFromString(b)
+	if err != nil {
+		return false
+	}
+	return x.Equal(y)
+}
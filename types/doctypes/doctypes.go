@@ -6,3 +6,5 @@ const DOCTYPE_INVESTOR string = "investor"
 const DOCTYPE_CAPITALACCOUNT string = "capitalAccount"
 const DOCTYPE_CAPITALACCOUNTACTION string = "capitalAccountAction"
 const DOCTYPE_PORTFOLIOACTION string = "portfolioAction"
+const DOCTYPE_RISKLESSRATE string = "risklessRate"
+const DOCTYPE_SECURITY string = "security"
@@ -1,28 +1,161 @@
 package errors
 
-import "errors"
-
-var ReadingWorldStateError = errors.New("error retrieving the world state")
-var IdAlreadyInUseError = errors.New("an object already exists with that id")
-var FundNotFoundError = errors.New("a fund with that id does not exist")
-var PortfolioNotFoundError = errors.New("a portfolio with that id does not exist")
-var InvestorNotFoundError = errors.New("an investor with that id does not exist")
-var CapitalAccountNotFoundError = errors.New("a capital account with that id does not exist")
-var InvalidPortfolioActionTypeError = errors.New("invalid portfolio action type")
-var WritingWorldStateError = errors.New("error writing the world state")
-var InvalidCapitalAccountActionTypeError = errors.New("invalid capital account action type")
-var SaveStateError = errors.New("error saving the state to the blockchain")
-var LoadStateError = errors.New("error loading the state from JSON")
-var CannotBootstrapCapitalAccountError = errors.New("this capital account cannot be bootstrapped")
-var NegativeCapitalAccountBalanceError = errors.New("the actions resulted in a negative capital account balance")
-var CannotBootstrapFundError = errors.New("this fund cannot be bootstrapped")
-var CannotStepFundError = errors.New("this fund cannot be stepped")
-var NoPortfoliosFoundError = errors.New("no portfolios found for this fund")
-var NoMostRecentDateForPortfolioError = errors.New("this portfolio does not have a most recent date")
-var NoValuationsFoundForDateError = errors.New("no valuations found for date")
-var DecimalConversionError = errors.New("error converting decimal")
-var NoCapitalAccountsFoundError = errors.New("no capital accounts found")
-var PreviousOwnershipPercentageNotFoundError = errors.New("previous ownership percentage not found")
-var GeneralPartnerNotFoundError = errors.New("general partner not found")
-var WealthConservationFunctionError = errors.New("the wealth conservation identity did not hold true")
-var MidYearDepositError = errors.New("a mid year deposit cannot be made on a capital account with performance fees")
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const CODE_READING_WORLD_STATE string = "READING_WORLD_STATE"
+const CODE_ID_ALREADY_IN_USE string = "ID_ALREADY_IN_USE"
+const CODE_FUND_NOT_FOUND string = "FUND_NOT_FOUND"
+const CODE_PORTFOLIO_NOT_FOUND string = "PORTFOLIO_NOT_FOUND"
+const CODE_INVESTOR_NOT_FOUND string = "INVESTOR_NOT_FOUND"
+const CODE_CAPITAL_ACCOUNT_NOT_FOUND string = "CAPITAL_ACCOUNT_NOT_FOUND"
+const CODE_CAPITAL_ACCOUNT_ACTION_NOT_FOUND string = "CAPITAL_ACCOUNT_ACTION_NOT_FOUND"
+const CODE_PORTFOLIO_ACTION_NOT_FOUND string = "PORTFOLIO_ACTION_NOT_FOUND"
+const CODE_RISKLESS_RATE_NOT_FOUND string = "RISKLESS_RATE_NOT_FOUND"
+const CODE_SECURITY_NOT_FOUND string = "SECURITY_NOT_FOUND"
+const CODE_INVALID_PORTFOLIO_ACTION_TYPE string = "INVALID_PORTFOLIO_ACTION_TYPE"
+const CODE_WRITING_WORLD_STATE string = "WRITING_WORLD_STATE"
+const CODE_INVALID_CAPITAL_ACCOUNT_ACTION_TYPE string = "INVALID_CAPITAL_ACCOUNT_ACTION_TYPE"
+const CODE_SAVE_STATE string = "SAVE_STATE"
+const CODE_LOAD_STATE string = "LOAD_STATE"
+const CODE_CANNOT_BOOTSTRAP_CAPITAL_ACCOUNT string = "CANNOT_BOOTSTRAP_CAPITAL_ACCOUNT"
+const CODE_NEGATIVE_BALANCE string = "NEGATIVE_BALANCE"
+const CODE_CANNOT_BOOTSTRAP_FUND string = "CANNOT_BOOTSTRAP_FUND"
+const CODE_CANNOT_STEP_FUND string = "CANNOT_STEP_FUND"
+const CODE_CANNOT_IMPORT_CAPITAL_ACCOUNTS string = "CANNOT_IMPORT_CAPITAL_ACCOUNTS"
+const CODE_NO_PORTFOLIOS string = "NO_PORTFOLIOS"
+const CODE_NO_MOST_RECENT_DATE string = "NO_MOST_RECENT_DATE"
+const CODE_NO_VALUATIONS_FOR_DATE string = "NO_VALUATIONS_FOR_DATE"
+const CODE_DECIMAL_CONVERSION string = "DECIMAL_CONVERSION"
+const CODE_NO_CAPITAL_ACCOUNTS string = "NO_CAPITAL_ACCOUNTS"
+const CODE_PREVIOUS_OWNERSHIP_NOT_FOUND string = "PREVIOUS_OWNERSHIP_NOT_FOUND"
+const CODE_GENERAL_PARTNER_NOT_FOUND string = "GENERAL_PARTNER_NOT_FOUND"
+const CODE_WEALTH_CONSERVATION string = "WEALTH_CONSERVATION"
+const CODE_MID_YEAR_DEPOSIT string = "MID_YEAR_DEPOSIT"
+const CODE_INVALID_DOC_TYPE string = "INVALID_DOC_TYPE"
+const CODE_UNSUPPORTED_SCHEMA_VERSION string = "UNSUPPORTED_SCHEMA_VERSION"
+const CODE_ZERO_FUND_OPENING_VALUE string = "ZERO_FUND_OPENING_VALUE"
+const CODE_INVALID_ROUNDING_POLICY string = "INVALID_ROUNDING_POLICY"
+const CODE_CANNOT_CHANGE_ROUNDING_POLICY string = "CANNOT_CHANGE_ROUNDING_POLICY"
+const CODE_VALUATION_DATE_NOT_FOUND string = "VALUATION_DATE_NOT_FOUND"
+const CODE_ASSET_NOT_FOUND string = "ASSET_NOT_FOUND"
+const CODE_NEGATIVE_SECURITY_AMOUNT string = "NEGATIVE_SECURITY_AMOUNT"
+const CODE_EMPTY_PORTFOLIO string = "EMPTY_PORTFOLIO"
+const CODE_INVALID_REQUEST string = "INVALID_REQUEST"
+const CODE_VALIDATION_FAILED string = "VALIDATION_FAILED"
+const CODE_IDEMPOTENCY_KEY_REUSED string = "IDEMPOTENCY_KEY_REUSED"
+const CODE_TRANSACTION_NOT_FOUND string = "TRANSACTION_NOT_FOUND"
+const CODE_UNAUTHENTICATED string = "UNAUTHENTICATED"
+const CODE_NO_FABRIC_IDENTITY string = "NO_FABRIC_IDENTITY"
+const CODE_MISSING_SCOPE string = "MISSING_SCOPE"
+const CODE_INTERNAL string = "INTERNAL"
+
+// An error with a stable code and optional details, e.g. the id of the account
+// that went negative. Error returns the error as JSON so the code and details
+// survive the trip through the peer and the gateway, where Parse recovers them.
+type Error struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func New(code string, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(data)
+}
+
+// Errors match on their code, so an error with details added still matches the
+// sentinel it was created from
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Returns a copy of the error with the detail added. The sentinels are shared, so
+// they are never modified.
+func (e *Error) WithDetail(key string, value string) *Error {
+	details := make(map[string]string, len(e.Details)+1)
+	for k, v := range e.Details {
+		details[k] = v
+	}
+	details[key] = value
+	return &Error{Code: e.Code, Message: e.Message, Details: details}
+}
+
+// Finds an error in a message that embeds one, such as the message the gateway
+// returns for a failed transaction
+func Parse(message string) (*Error, bool) {
+	start := strings.Index(message, `{"code":`)
+	if start == -1 {
+		return nil, false
+	}
+	var parsed Error
+	err := json.NewDecoder(strings.NewReader(message[start:])).Decode(&parsed)
+	if err != nil || parsed.Code == "" {
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// Returns the coded error in the chain of err, or the one embedded in its message
+func From(err error) (*Error, bool) {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded, true
+	}
+	return Parse(err.Error())
+}
+
+var ReadingWorldStateError = New(CODE_READING_WORLD_STATE, "error retrieving the world state")
+var IdAlreadyInUseError = New(CODE_ID_ALREADY_IN_USE, "an object already exists with that id")
+var FundNotFoundError = New(CODE_FUND_NOT_FOUND, "a fund with that id does not exist")
+var PortfolioNotFoundError = New(CODE_PORTFOLIO_NOT_FOUND, "a portfolio with that id does not exist")
+var InvestorNotFoundError = New(CODE_INVESTOR_NOT_FOUND, "an investor with that id does not exist")
+var CapitalAccountNotFoundError = New(CODE_CAPITAL_ACCOUNT_NOT_FOUND, "a capital account with that id does not exist")
+var CapitalAccountActionNotFoundError = New(CODE_CAPITAL_ACCOUNT_ACTION_NOT_FOUND, "a capital account action with that id does not exist")
+var PortfolioActionNotFoundError = New(CODE_PORTFOLIO_ACTION_NOT_FOUND, "a portfolio action with that id does not exist")
+var RisklessRateNotFoundError = New(CODE_RISKLESS_RATE_NOT_FOUND, "a riskless rate with that id does not exist")
+var SecurityNotFoundError = New(CODE_SECURITY_NOT_FOUND, "a security with that cusip does not exist")
+var InvalidPortfolioActionTypeError = New(CODE_INVALID_PORTFOLIO_ACTION_TYPE, "invalid portfolio action type")
+var WritingWorldStateError = New(CODE_WRITING_WORLD_STATE, "error writing the world state")
+var InvalidCapitalAccountActionTypeError = New(CODE_INVALID_CAPITAL_ACCOUNT_ACTION_TYPE, "invalid capital account action type")
+var SaveStateError = New(CODE_SAVE_STATE, "error saving the state to the blockchain")
+var LoadStateError = New(CODE_LOAD_STATE, "error loading the state from JSON")
+var CannotBootstrapCapitalAccountError = New(CODE_CANNOT_BOOTSTRAP_CAPITAL_ACCOUNT, "this capital account cannot be bootstrapped")
+var NegativeCapitalAccountBalanceError = New(CODE_NEGATIVE_BALANCE, "the actions resulted in a negative capital account balance")
+var CannotBootstrapFundError = New(CODE_CANNOT_BOOTSTRAP_FUND, "this fund cannot be bootstrapped")
+var CannotStepFundError = New(CODE_CANNOT_STEP_FUND, "this fund cannot be stepped")
+var CannotImportCapitalAccountsError = New(CODE_CANNOT_IMPORT_CAPITAL_ACCOUNTS, "capital accounts can only be imported before the fund is bootstrapped")
+var NoPortfoliosFoundError = New(CODE_NO_PORTFOLIOS, "no portfolios found for this fund")
+var NoMostRecentDateForPortfolioError = New(CODE_NO_MOST_RECENT_DATE, "this portfolio does not have a most recent date")
+var NoValuationsFoundForDateError = New(CODE_NO_VALUATIONS_FOR_DATE, "no valuations found for date")
+var DecimalConversionError = New(CODE_DECIMAL_CONVERSION, "error converting decimal")
+var NoCapitalAccountsFoundError = New(CODE_NO_CAPITAL_ACCOUNTS, "no capital accounts found")
+var PreviousOwnershipPercentageNotFoundError = New(CODE_PREVIOUS_OWNERSHIP_NOT_FOUND, "previous ownership percentage not found")
+var GeneralPartnerNotFoundError = New(CODE_GENERAL_PARTNER_NOT_FOUND, "general partner not found")
+var WealthConservationFunctionError = New(CODE_WEALTH_CONSERVATION, "the wealth conservation identity did not hold true")
+var MidYearDepositError = New(CODE_MID_YEAR_DEPOSIT, "a mid year deposit cannot be made on a capital account with performance fees")
+var InvalidDocTypeError = New(CODE_INVALID_DOC_TYPE, "invalid document type")
+var UnsupportedSchemaVersionError = New(CODE_UNSUPPORTED_SCHEMA_VERSION, "the document was written by a newer schema version")
+var ZeroFundOpeningValueError = New(CODE_ZERO_FUND_OPENING_VALUE, "ownership cannot be allocated when the fund opening value is zero")
+var InvalidRoundingPolicyError = New(CODE_INVALID_ROUNDING_POLICY, "invalid rounding policy")
+var CannotChangeRoundingPolicyError = New(CODE_CANNOT_CHANGE_ROUNDING_POLICY, "the rounding policy cannot be changed once the fund has been bootstrapped")
+var ValuationDateNotFoundError = New(CODE_VALUATION_DATE_NOT_FOUND, "a portfolio snapshot with that date does not exist")
+var AssetNotFoundError = New(CODE_ASSET_NOT_FOUND, "the specified asset is not in the portfolio")
+var NegativeSecurityAmountError = New(CODE_NEGATIVE_SECURITY_AMOUNT, "cannot have a negative security amount")
+var EmptyPortfolioError = New(CODE_EMPTY_PORTFOLIO, "cannot sell a security from an empty portfolio")
+var ValidationError = New(CODE_VALIDATION_FAILED, "the request has invalid fields")
+var TransactionNotFoundError = New(CODE_TRANSACTION_NOT_FOUND, "a transaction with that id does not exist or is no longer tracked")
+var UnauthenticatedError = New(CODE_UNAUTHENTICATED, "the request does not carry valid credentials")
+var NoFabricIdentityError = New(CODE_NO_FABRIC_IDENTITY, "no Fabric identity is enrolled for the caller")
+var MissingScopeError = New(CODE_MISSING_SCOPE, "the caller is not granted the scope this request needs")
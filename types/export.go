@@ -0,0 +1,58 @@
+package types
+
+import "github.com/zacharyfrederick/admin/types/doctypes"
+
+// The doctypes of an export in the order they are restored, so the fund, investor
+// or portfolio of a document is always on the ledger before the document
+var EXPORT_DOCTYPES = []string{
+	doctypes.DOCTYPE_FUND,
+	doctypes.DOCTYPE_INVESTOR,
+	doctypes.DOCTYPE_CAPITALACCOUNT,
+	doctypes.DOCTYPE_PORTFOLIO,
+	doctypes.DOCTYPE_CAPITALACCOUNTACTION,
+	doctypes.DOCTYPE_PORTFOLIOACTION,
+	doctypes.DOCTYPE_RISKLESSRATE,
+	doctypes.DOCTYPE_SECURITY,
+}
+
+// The marker keys of an export, restored after every document
+var EXPORT_MARKERS = []string{
+	MARKER_MIDYEARDEPOSIT,
+	MARKER_MIDYEARWITHDRAWAL,
+}
+
+// The section of an export holding the marker keys
+const EXPORT_MARKERS_SECTION string = "markers"
+
+// A stored document, kept as the JSON it was saved as
+type ExportedDocument struct {
+	ID       string `json:"id"`
+	Document string `json:"document"`
+}
+
+type ExportPage struct {
+	Records             []*ExportedDocument `json:"records"`
+	FetchedRecordsCount int32               `json:"fetchedRecordsCount"`
+	Bookmark            string              `json:"bookmark"`
+}
+
+// A marker key split into its object type and attributes, with the id it points to
+type ExportedMarker struct {
+	ObjectType string   `json:"objectType"`
+	Attributes []string `json:"attributes"`
+	Value      string   `json:"value"`
+}
+
+type ExportMarkerPage struct {
+	Records             []*ExportedMarker `json:"records"`
+	FetchedRecordsCount int32             `json:"fetchedRecordsCount"`
+	Bookmark            string            `json:"bookmark"`
+}
+
+// The outcome of restoring a batch. Documents already on the ledger exactly as
+// exported are skipped.
+type RestoreResult struct {
+	Section  string `json:"section"`
+	Restored int    `json:"restored"`
+	Skipped  int    `json:"skipped"`
+}
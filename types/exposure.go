@@ -0,0 +1,159 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Weights are fractions of the net asset value with this many decimal places
+const EXPOSURE_PRECISION int32 = 6
+
+// Positions reported by default in order of their size
+const DEFAULT_TOP_POSITIONS int = 10
+
+// The bucket of positions whose security has no value for a dimension, or no
+// security master record at all
+const EXPOSURE_UNCLASSIFIED string = "unclassified"
+
+const EXPOSURE_DIMENSION_SECURITY string = "security"
+const EXPOSURE_DIMENSION_ASSET_CLASS string = "assetClass"
+const EXPOSURE_DIMENSION_SECTOR string = "sector"
+const EXPOSURE_DIMENSION_COUNTRY string = "country"
+const EXPOSURE_DIMENSION_ISSUER string = "issuer"
+
+var EXPOSURE_DIMENSIONS = []string{
+	EXPOSURE_DIMENSION_SECURITY,
+	EXPOSURE_DIMENSION_ASSET_CLASS,
+	EXPOSURE_DIMENSION_SECTOR,
+	EXPOSURE_DIMENSION_COUNTRY,
+	EXPOSURE_DIMENSION_ISSUER,
+}
+
+// Limits on the whole book rather than a dimension
+const CONCENTRATION_LIMIT_GROSS string = "gross"
+const CONCENTRATION_LIMIT_NET string = "net"
+const CONCENTRATION_LIMIT_TOP string = "top"
+
+// A maximum weight. A limit on a dimension applies to the bucket named by Value,
+// or to every bucket when Value is empty, e.g. no issuer above 0.1 of the net
+// asset value. Gross and net limits apply to the whole book, net in either
+// direction, and a top limit to the Count largest positions together.
+type ConcentrationLimit struct {
+	Dimension string `json:"dimension"`
+	Value     string `json:"value,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Max       string `json:"max"`
+}
+
+type ConcentrationLimits []ConcentrationLimit
+
+func (c ConcentrationLimits) Validate() error {
+	errs := FieldErrors{}
+	kinds := append([]string{CONCENTRATION_LIMIT_GROSS, CONCENTRATION_LIMIT_NET, CONCENTRATION_LIMIT_TOP}, EXPOSURE_DIMENSIONS...)
+	for i, limit := range c {
+		field := "limits." + strconv.Itoa(i)
+		errs.Check(field+".dimension", CheckOneOf(limit.Dimension, kinds...))
+		errs.Check(field+".max", CheckPositiveDecimal(limit.Max))
+		switch limit.Dimension {
+		case CONCENTRATION_LIMIT_TOP:
+			if limit.Count < 1 {
+				errs.Check(field+".count", "must be at least 1")
+			}
+		case CONCENTRATION_LIMIT_GROSS, CONCENTRATION_LIMIT_NET:
+			if limit.Value != "" {
+				errs.Check(field+".value", fmt.Sprintf("does not apply to %s limits", limit.Dimension))
+			}
+		}
+		if limit.Count != 0 && limit.Dimension != CONCENTRATION_LIMIT_TOP {
+			errs.Check(field+".count", "only applies to top limits")
+		}
+	}
+	return errs.Err()
+}
+
+type SetConcentrationLimitsRequest struct {
+	Limits ConcentrationLimits `json:"limits"`
+}
+
+type ConcentrationLimitsSet struct {
+	FundId string              `json:"fundId"`
+	Limits ConcentrationLimits `json:"limits"`
+}
+
+// The positions of a bucket of a dimension. Long and Short are market values,
+// short ones negative, Gross is their size together and Net their sum. Weights
+// are fractions of the net asset value.
+type ExposureBucket struct {
+	Value       string `json:"value"`
+	Long        string `json:"long"`
+	Short       string `json:"short"`
+	Gross       string `json:"gross"`
+	Net         string `json:"net"`
+	GrossWeight string `json:"grossWeight"`
+	NetWeight   string `json:"netWeight"`
+}
+
+// A position across the fund's portfolios, ranked by its size
+type ExposurePosition struct {
+	Rank                  int    `json:"rank"`
+	CUSIP                 string `json:"cusip"`
+	Name                  string `json:"name"`
+	Amount                string `json:"amount"`
+	Value                 string `json:"value"`
+	Weight                string `json:"weight"`
+	CumulativeGrossWeight string `json:"cumulativeGrossWeight"`
+}
+
+// A concentration limit and the weight it was checked against. For a limit on
+// every bucket of a dimension Value is the largest bucket.
+type ConcentrationCheck struct {
+	Dimension string `json:"dimension"`
+	Value     string `json:"value,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Max       string `json:"max"`
+	Actual    string `json:"actual"`
+	Breached  bool   `json:"breached"`
+}
+
+// The exposure of a fund's portfolios on Date. Weights are left empty when the
+// net asset value is not positive. Unpriced names the positions without a price
+// on their portfolio's valuation date, which are left out, and Unclassified the
+// CUSIPs without a security master record.
+type ExposureReport struct {
+	Fund         string                `json:"fund"`
+	Date         string                `json:"date"`
+	NAV          string                `json:"nav"`
+	Long         string                `json:"long"`
+	Short        string                `json:"short"`
+	Gross        string                `json:"gross"`
+	Net          string                `json:"net"`
+	GrossWeight  string                `json:"grossWeight"`
+	NetWeight    string                `json:"netWeight"`
+	BySecurity   []*ExposureBucket     `json:"bySecurity"`
+	ByAssetClass []*ExposureBucket     `json:"byAssetClass"`
+	BySector     []*ExposureBucket     `json:"bySector"`
+	ByCountry    []*ExposureBucket     `json:"byCountry"`
+	ByIssuer     []*ExposureBucket     `json:"byIssuer"`
+	TopPositions []*ExposurePosition   `json:"topPositions"`
+	Limits       []*ConcentrationCheck `json:"limits"`
+	Breached     bool                  `json:"breached"`
+	Unpriced     []string              `json:"unpriced"`
+	Unclassified []string              `json:"unclassified"`
+}
+
+// The buckets of a dimension of the report
+func (r *ExposureReport) Buckets(dimension string) []*ExposureBucket {
+	switch dimension {
+	case EXPOSURE_DIMENSION_SECURITY:
+		return r.BySecurity
+	case EXPOSURE_DIMENSION_ASSET_CLASS:
+		return r.ByAssetClass
+	case EXPOSURE_DIMENSION_SECTOR:
+		return r.BySector
+	case EXPOSURE_DIMENSION_COUNTRY:
+		return r.ByCountry
+	case EXPOSURE_DIMENSION_ISSUER:
+		return r.ByIssuer
+	}
+	return nil
+}
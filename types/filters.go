@@ -0,0 +1,68 @@
+package types
+
+import "encoding/json"
+
+// Optional criteria used when listing capital account and portfolio actions
+type ActionFilter struct {
+	Period    *int   `json:"period,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Status    string `json:"status,omitempty"`
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+}
+
+func (f *ActionFilter) ToJSON() ([]byte, error) {
+	filterJSON, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return filterJSON, nil
+}
+
+func (f *ActionFilter) FromJSON(data []byte) error {
+	err := json.Unmarshal(data, f)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reports whether an action with the period, type, status and date meets every
+// criterion the filter sets
+func (f *ActionFilter) Matches(period int, type_ string, status string, date string) bool {
+	if f.Period != nil && *f.Period != period {
+		return false
+	}
+	if f.Type != "" && f.Type != type_ {
+		return false
+	}
+	if f.Status != "" && f.Status != status {
+		return false
+	}
+	return f.MatchesDate(date)
+}
+
+// Reports whether the date falls inside the filter's inclusive date range. Dates that
+// cannot be parsed never match a filter that has a range set.
+func (f *ActionFilter) MatchesDate(date string) bool {
+	if f.StartDate == "" && f.EndDate == "" {
+		return true
+	}
+	actionDate, err := ParseDate(date)
+	if err != nil {
+		return false
+	}
+	if f.StartDate != "" {
+		startDate, err := ParseDate(f.StartDate)
+		if err != nil || actionDate.Before(startDate) {
+			return false
+		}
+	}
+	if f.EndDate != "" {
+		endDate, err := ParseDate(f.EndDate)
+		if err != nil || actionDate.After(endDate) {
+			return false
+		}
+	}
+	return true
+}
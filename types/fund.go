@@ -3,12 +3,12 @@ package types
 import (
 	"encoding/json"
 
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/zacharyfrederick/admin/types/doctypes"
 )
 
 type Fund struct {
 	DocType              string         `json:"docType"`
+	SchemaVersion        int            `json:"schemaVersion"`
 	ID                   string         `json:"id"`
 	Name                 string         `json:"name"`
 	CurrentPeriod        int            `json:"currentPeriod"`
@@ -24,11 +24,38 @@ type Fund struct {
 	PerformanceFeePeriod int            `json:"performanceFeePeriod"`
 	MidYearDeposits      []string       `json:"midYearDeposits"`
 	MidYearWithdrawals   []string       `json:"midYearWithdrawals"`
+	RoundingPolicy       RoundingPolicy `json:"roundingPolicy"`
+	// Timestamp of the transaction that closed each period, the bootstrap for
+	// period 0, which dates the journal entries of the close
+	ClosedAt map[int]string `json:"closedAt,omitempty"`
+	// The general ledger accounts of the fund's journal, the defaults when empty
+	ChartOfAccounts ChartOfAccounts `json:"chartOfAccounts,omitempty"`
+	// The concentration limits its exposure is checked against
+	ConcentrationLimits ConcentrationLimits `json:"concentrationLimits,omitempty"`
+	// How many periods the fund closes a year, which annualizes its performance.
+	// Zero means DEFAULT_PERIODS_PER_YEAR.
+	PeriodsPerYear int `json:"periodsPerYear,omitempty"`
+}
+
+func (f *Fund) AnnualPeriods() int {
+	if f.PeriodsPerYear <= 0 {
+		return DEFAULT_PERIODS_PER_YEAR
+	}
+	return f.PeriodsPerYear
 }
 
 func (f *Fund) IsPerformanceFeePeriod() bool {
 	return f.CurrentPeriod%f.PerformanceFeePeriod == 0
 }
+
+// Records when the current period was closed, before it is incremented
+func (f *Fund) SetClosedAt(timestamp string) {
+	if f.ClosedAt == nil {
+		f.ClosedAt = map[int]string{}
+	}
+	f.ClosedAt[f.CurrentPeriod] = timestamp
+}
+
 func (f *Fund) IncrementInvestorNumber() {
 	f.NextInvestorNumber += 1
 }
@@ -61,12 +88,8 @@ func (f *Fund) FromJSON(data []byte) error {
 	return nil
 }
 
-func (f *Fund) SaveState(ctx contractapi.TransactionContextInterface) error {
-	fundJson, err := f.ToJSON()
-	if err != nil {
-		return err
-	}
-	return ctx.GetStub().PutState(f.ID, fundJson)
+func (f *Fund) IndexKeys() []IndexKey {
+	return []IndexKey{{ObjectType: INDEX_FUND, Attributes: []string{f.ID}}}
 }
 
 func (f *Fund) BootstrapFundValues(totalDeposits string, openingFundValue string) {
@@ -78,6 +101,7 @@ func (f *Fund) BootstrapFundValues(totalDeposits string, openingFundValue string
 func CreateDefaultFund(fundId string, name string, inceptionDate string) Fund {
 	fund := Fund{
 		DocType:              doctypes.DOCTYPE_FUND,
+		SchemaVersion:        FUND_SCHEMA_VERSION,
 		ID:                   fundId,
 		Name:                 name,
 		CurrentPeriod:        0,
@@ -92,6 +116,8 @@ func CreateDefaultFund(fundId string, name string, inceptionDate string) Fund {
 		HasPerformanceFees:   true,
 		PerformanceFeePeriod: 12,
 		MidYearDeposits:      make([]string, 0),
+		MidYearWithdrawals:   make([]string, 0),
+		RoundingPolicy:       DefaultRoundingPolicy(),
 	}
 	return fund
 }
@@ -106,12 +132,26 @@ func CreateFundFromJSON(data []byte) (*Fund, error) {
 }
 
 type CreateFundRequest struct {
-	Name          string `json:"name" binding:"required"`
-	InceptionDate string `json:"inceptionDate" binding:"required"`
+	Name          string `json:"name"`
+	InceptionDate string `json:"inceptionDate"`
+}
+
+func ValidateCreateFundRequest(r *CreateFundRequest) error {
+	errs := FieldErrors{}
+	errs.Check("name", CheckRequired(r.Name))
+	errs.Check("inceptionDate", CheckDate(r.InceptionDate))
+	return errs.Err()
+}
+
+type SetRoundingPolicyRequest struct {
+	CurrencyPrecision  *int32 `json:"currencyPrecision" binding:"required"`
+	OwnershipPrecision *int32 `json:"ownershipPrecision" binding:"required"`
+	RoundingMode       string `json:"roundingMode" binding:"required"`
 }
 
-func ValidateCreateFundRequest(r *CreateFundRequest) bool {
-	return true
+type RoundingPolicySet struct {
+	FundId         string         `json:"fundId"`
+	RoundingPolicy RoundingPolicy `json:"roundingPolicy"`
 }
 
 type FundAndCapitalAccounts struct {
@@ -0,0 +1,79 @@
+package types
+
+import "fmt"
+
+const DEFAULT_IMPORT_BATCH_SIZE int = 50
+const MAX_IMPORT_BATCH_SIZE int = 200
+
+const IMPORT_STATUS_IMPORTED string = "imported"
+const IMPORT_STATUS_SKIPPED string = "skipped"
+
+// One investor of a bulk import with their capital account and inception deposit.
+// The ids are derived from the fund and the reference, so importing the same row
+// again finds the documents it created instead of creating new ones.
+type ImportRow struct {
+	Line               int    `json:"line"`
+	Reference          string `json:"reference"`
+	InvestorId         string `json:"investorId"`
+	InvestorName       string `json:"investorName"`
+	CapitalAccountId   string `json:"capitalAccountId"`
+	HasPerformanceFees bool   `json:"hasPerformanceFees"`
+	PerformanceRate    string `json:"performanceRate"`
+	FixedFee           string `json:"fixedFee"`
+	DepositId          string `json:"depositId,omitempty"`
+	DepositAmount      string `json:"depositAmount,omitempty"`
+	DepositDate        string `json:"depositDate,omitempty"`
+}
+
+// Checks the row on its own. The investor name may be left out when the row
+// names an investor already on the ledger.
+func ValidateImportRow(r *ImportRow, existingInvestor bool) FieldErrors {
+	errs := FieldErrors{}
+	errs.Check("reference", CheckRequired(r.Reference))
+	if !existingInvestor {
+		errs.Check("investorName", CheckRequired(r.InvestorName))
+	}
+	errs.Check("performanceRate", CheckRate(r.PerformanceRate))
+	errs.Check("fixedFee", CheckRate(r.FixedFee))
+	if r.DepositAmount != "" || r.DepositDate != "" {
+		errs.Check("depositAmount", CheckPositiveDecimal(r.DepositAmount))
+		errs.Check("depositDate", CheckDate(r.DepositDate))
+	}
+	return errs
+}
+
+// What a batch did with a row: imported when it created any document and skipped
+// when everything already existed from an earlier run
+type ImportRowResult struct {
+	Line             int    `json:"line"`
+	Reference        string `json:"reference"`
+	InvestorId       string `json:"investorId"`
+	CapitalAccountId string `json:"capitalAccountId"`
+	DepositId        string `json:"depositId,omitempty"`
+	Status           string `json:"status"`
+}
+
+type ImportReport struct {
+	Fund     string            `json:"fund"`
+	Rows     []ImportRowResult `json:"rows"`
+	Imported int               `json:"imported"`
+	Skipped  int               `json:"skipped"`
+	Batches  int               `json:"batches"`
+}
+
+func (r *ImportReport) Add(results []ImportRowResult) {
+	r.Batches += 1
+	for _, result := range results {
+		r.Rows = append(r.Rows, result)
+		if result.Status == IMPORT_STATUS_IMPORTED {
+			r.Imported += 1
+		} else {
+			r.Skipped += 1
+		}
+	}
+}
+
+// Key of a field of a row in the details of a ValidationError
+func ImportFieldKey(line int, field string) string {
+	return fmt.Sprintf("line %d: %s", line, field)
+}
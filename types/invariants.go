@@ -0,0 +1,35 @@
+package types
+
+const INVARIANT_OWNERSHIP_SUM string = "ownershipSum"
+const INVARIANT_NON_NEGATIVE_BALANCE string = "nonNegativeBalance"
+const INVARIANT_OPENING_VALUE string = "openingValue"
+const INVARIANT_FEE_CONSERVATION string = "feeConservation"
+const INVARIANT_WEALTH_CONSERVATION string = "wealthConservation"
+
+// A single failed invariant. Account is empty for fund level invariants.
+type InvariantViolation struct {
+	Period    int    `json:"period"`
+	Invariant string `json:"invariant"`
+	Account   string `json:"account"`
+	Detail    string `json:"detail"`
+}
+
+// Result of checking a fund's accounting invariants over its closed periods
+type FundInvariantReport struct {
+	Fund           string                `json:"fund"`
+	PeriodsChecked int                   `json:"periodsChecked"`
+	Violations     []*InvariantViolation `json:"violations"`
+}
+
+func (r *FundInvariantReport) OK() bool {
+	return len(r.Violations) == 0
+}
+
+func (r *FundInvariantReport) AddViolation(period int, invariant string, account string, detail string) {
+	r.Violations = append(r.Violations, &InvariantViolation{
+		Period:    period,
+		Invariant: invariant,
+		Account:   account,
+		Detail:    detail,
+	})
+}
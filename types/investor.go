@@ -3,14 +3,14 @@ package types
 import (
 	"encoding/json"
 
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/zacharyfrederick/admin/types/doctypes"
 )
 
 type Investor struct {
-	DocType string `json:"docType"`
-	ID      string `json:"id"`
-	Name    string `json:"name"`
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
 }
 
 func (f *Investor) GetID() string {
@@ -33,27 +33,26 @@ func (f *Investor) FromJSON(data []byte) error {
 	return nil
 }
 
-func (t *Investor) SaveState(ctx contractapi.TransactionContextInterface) error {
-	investorJSON, err := t.ToJSON()
-	if err != nil {
-		return err
-	}
-	return ctx.GetStub().PutState(t.ID, investorJSON)
+func (f *Investor) IndexKeys() []IndexKey {
+	return []IndexKey{{ObjectType: INDEX_INVESTOR, Attributes: []string{f.ID}}}
 }
 
 type CreateInvestorRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name string `json:"name"`
 }
 
-func ValidateCreateInvestorRequest(r *CreateInvestorRequest) bool {
-	return true
+func ValidateCreateInvestorRequest(r *CreateInvestorRequest) error {
+	errs := FieldErrors{}
+	errs.Check("name", CheckRequired(r.Name))
+	return errs.Err()
 }
 
 func CreateDefaultInvestor(investorId string, name string) Investor {
 	investor := Investor{
-		DocType: doctypes.DOCTYPE_INVESTOR,
-		Name:    name,
-		ID:      investorId,
+		DocType:       doctypes.DOCTYPE_INVESTOR,
+		SchemaVersion: INVESTOR_SCHEMA_VERSION,
+		Name:          name,
+		ID:            investorId,
 	}
 	return investor
 }
@@ -0,0 +1,178 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The roles a general ledger account plays in the journal entries of a fund
+const GL_ROLE_CASH string = "cash"
+const GL_ROLE_PORTFOLIO_CASH string = "portfolioCash"
+const GL_ROLE_INVESTMENTS string = "investments"
+const GL_ROLE_CAPITAL_IN_ADVANCE string = "capitalInAdvance"
+const GL_ROLE_INVESTOR_CAPITAL string = "investorCapital"
+const GL_ROLE_PERFORMANCE_FEE_ALLOCATION string = "performanceFeeAllocation"
+const GL_ROLE_INCOME_ALLOCATION string = "incomeAllocation"
+const GL_ROLE_UNREALIZED_GAIN string = "unrealizedGain"
+const GL_ROLE_MANAGEMENT_FEES string = "managementFees"
+
+const GL_TYPE_ASSET string = "asset"
+const GL_TYPE_LIABILITY string = "liability"
+const GL_TYPE_EQUITY string = "equity"
+const GL_TYPE_INCOME string = "income"
+const GL_TYPE_EXPENSE string = "expense"
+
+// Where a journal entry comes from
+const JOURNAL_SOURCE_CAPITAL_ACCOUNT_ACTION string = "capitalAccountAction"
+const JOURNAL_SOURCE_PORTFOLIO_ACTION string = "portfolioAction"
+const JOURNAL_SOURCE_CLOSE string = "close"
+
+// An account of the general ledger the fund's books are kept in
+type GLAccount struct {
+	Number string `json:"number"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+}
+
+// The general ledger account of each role. Roles missing from a fund's chart use
+// the default account, so a chart only needs the accounts that differ.
+type ChartOfAccounts map[string]GLAccount
+
+func DefaultChartOfAccounts() ChartOfAccounts {
+	return ChartOfAccounts{
+		GL_ROLE_CASH:                       {Number: "1000", Name: "Cash", Type: GL_TYPE_ASSET},
+		GL_ROLE_PORTFOLIO_CASH:             {Number: "1100", Name: "Cash held in portfolios", Type: GL_TYPE_ASSET},
+		GL_ROLE_INVESTMENTS:                {Number: "1200", Name: "Investments at fair value", Type: GL_TYPE_ASSET},
+		GL_ROLE_CAPITAL_IN_ADVANCE:         {Number: "2100", Name: "Capital received in advance", Type: GL_TYPE_LIABILITY},
+		GL_ROLE_INVESTOR_CAPITAL:           {Number: "3000", Name: "Investor capital", Type: GL_TYPE_EQUITY},
+		GL_ROLE_PERFORMANCE_FEE_ALLOCATION: {Number: "3100", Name: "Performance fee allocation", Type: GL_TYPE_EQUITY},
+		GL_ROLE_INCOME_ALLOCATION:          {Number: "3900", Name: "Net income allocated to investors", Type: GL_TYPE_EQUITY},
+		GL_ROLE_UNREALIZED_GAIN:            {Number: "4100", Name: "Unrealized gain on investments", Type: GL_TYPE_INCOME},
+		GL_ROLE_MANAGEMENT_FEES:            {Number: "5100", Name: "Management fees", Type: GL_TYPE_EXPENSE},
+	}
+}
+
+// The chart with the defaults filled in for the roles it does not name
+func (c ChartOfAccounts) Complete() ChartOfAccounts {
+	complete := DefaultChartOfAccounts()
+	for role, account := range c {
+		complete[role] = account
+	}
+	return complete
+}
+
+// The accounts of the completed chart by number
+func (c ChartOfAccounts) Accounts() []GLAccount {
+	accounts := []GLAccount{}
+	for _, account := range c.Complete() {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Number < accounts[j].Number })
+	return accounts
+}
+
+func (c ChartOfAccounts) Account(role string) GLAccount {
+	if account, ok := c[role]; ok {
+		return account
+	}
+	return DefaultChartOfAccounts()[role]
+}
+
+// Checks every account of the chart, and that no two roles of the completed chart
+// share an account number
+func (c ChartOfAccounts) Validate() error {
+	errs := FieldErrors{}
+	defaults := DefaultChartOfAccounts()
+	for role, account := range c {
+		if _, ok := defaults[role]; !ok {
+			errs.Check(role, "is not a role of the chart of accounts")
+			continue
+		}
+		errs.Check(role+".number", CheckRequired(account.Number))
+		errs.Check(role+".name", CheckRequired(account.Name))
+		errs.Check(role+".type", CheckOneOf(account.Type, GL_TYPE_ASSET, GL_TYPE_LIABILITY, GL_TYPE_EQUITY, GL_TYPE_INCOME, GL_TYPE_EXPENSE))
+	}
+	complete := c.Complete()
+	roles := []string{}
+	for role := range complete {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	numbers := map[string]string{}
+	for _, role := range roles {
+		number := complete[role].Number
+		if other, ok := numbers[number]; ok {
+			errs.Check(role+".number", fmt.Sprintf("is also the number of %s", other))
+			continue
+		}
+		numbers[number] = role
+	}
+	return errs.Err()
+}
+
+type SetChartOfAccountsRequest struct {
+	ChartOfAccounts ChartOfAccounts `json:"chartOfAccounts" binding:"required"`
+}
+
+type ChartOfAccountsSet struct {
+	FundId          string          `json:"fundId"`
+	ChartOfAccounts ChartOfAccounts `json:"chartOfAccounts"`
+}
+
+// A debit or a credit to an account. Lines of investor capital name the capital
+// account they belong to.
+type JournalLine struct {
+	Account        string `json:"account"`
+	AccountName    string `json:"accountName"`
+	Debit          string `json:"debit"`
+	Credit         string `json:"credit"`
+	CapitalAccount string `json:"capitalAccount,omitempty"`
+}
+
+// A balanced entry. Reference is the id of the action it records, or the fund
+// for the entries of a period close.
+type JournalEntry struct {
+	ID          string         `json:"id"`
+	Fund        string         `json:"fund"`
+	Period      int            `json:"period"`
+	Date        string         `json:"date"`
+	Source      string         `json:"source"`
+	Reference   string         `json:"reference"`
+	Description string         `json:"description"`
+	Lines       []*JournalLine `json:"lines"`
+}
+
+// The journal entries of a period of a fund. The close entries are only there
+// once the period is closed. Portfolio actions without a price on or before their
+// date cannot be valued, so they are listed in Unpriced instead of journaled.
+type Journal struct {
+	Fund   string `json:"fund"`
+	Period int    `json:"period"`
+	Closed bool   `json:"closed"`
+	// Every account of the fund's chart by number
+	Accounts []GLAccount     `json:"accounts"`
+	Entries  []*JournalEntry `json:"entries"`
+	Unpriced []string        `json:"unpriced"`
+}
+
+// The balance of an account, on its debit or its credit side
+type TrialBalanceLine struct {
+	Account string `json:"account"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Debit   string `json:"debit"`
+	Credit  string `json:"credit"`
+}
+
+// The balance of every account of the chart after the journal entries of the
+// periods up to and including Period
+type TrialBalance struct {
+	Fund        string              `json:"fund"`
+	Period      int                 `json:"period"`
+	Closed      bool                `json:"closed"`
+	Lines       []*TrialBalanceLine `json:"lines"`
+	TotalDebit  string              `json:"totalDebit"`
+	TotalCredit string              `json:"totalCredit"`
+	Balanced    bool                `json:"balanced"`
+	Unpriced    []string            `json:"unpriced"`
+}
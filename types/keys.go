@@ -0,0 +1,36 @@
+package types
+
+import "fmt"
+
+// Object types of the composite keys written alongside every document. The last
+// attribute of each key is always the id of the document it points to.
+const INDEX_FUND string = "fund~id"
+const INDEX_INVESTOR string = "investor~id"
+const INDEX_CAPITALACCOUNT string = "capitalAccount~fund~id"
+const INDEX_CAPITALACCOUNTACTION string = "action~fund~account~period~id"
+const INDEX_PORTFOLIO string = "portfolio~fund~id"
+const INDEX_PORTFOLIOACTION string = "portfolioAction~fund~portfolio~period~id"
+const INDEX_RISKLESSRATE string = "risklessRate~id"
+const INDEX_SECURITY string = "security~id"
+
+// The investors with capital accounts in a fund. Every account of an investor in
+// the fund writes the same key, so each investor is listed once, and the key
+// points at the account saved last rather than at the investor.
+const INDEX_FUNDINVESTOR string = "fundInvestor~fund~investor"
+
+// Marker keys that flag capital accounts for special treatment when the fund is
+// stepped. They are written instead of appending to lists on the fund, so that
+// concurrent transactions for different accounts never write the same key.
+const MARKER_MIDYEARDEPOSIT string = "midYearDeposit~fund~period~account"
+const MARKER_MIDYEARWITHDRAWAL string = "midYearWithdrawal~fund~account"
+
+// A secondary key that lets a document be found with a partial composite key query
+type IndexKey struct {
+	ObjectType string
+	Attributes []string
+}
+
+// Periods are zero padded so that composite keys sort in period order
+func FormatPeriodKey(period int) string {
+	return fmt.Sprintf("%08d", period)
+}
@@ -0,0 +1,11 @@
+package types
+
+// Progress of a batched migration. LastID is passed back as the starting point of
+// the next batch until Done is true.
+type MigrationProgress struct {
+	DocType   string `json:"docType"`
+	Processed int32  `json:"processed"`
+	Upgraded  int32  `json:"upgraded"`
+	LastID    string `json:"lastId"`
+	Done      bool   `json:"done"`
+}
@@ -0,0 +1,51 @@
+package types
+
+const DEFAULT_PAGE_SIZE int32 = 100
+const MAX_PAGE_SIZE int32 = 1000
+
+// Clamps a requested page size to the range supported by the chaincode
+func NormalizePageSize(pageSize int32) int32 {
+	if pageSize <= 0 {
+		return DEFAULT_PAGE_SIZE
+	}
+	if pageSize > MAX_PAGE_SIZE {
+		return MAX_PAGE_SIZE
+	}
+	return pageSize
+}
+
+type FundPage struct {
+	Records             []*Fund `json:"records"`
+	FetchedRecordsCount int32   `json:"fetchedRecordsCount"`
+	Bookmark            string  `json:"bookmark"`
+}
+
+type InvestorPage struct {
+	Records             []*Investor `json:"records"`
+	FetchedRecordsCount int32       `json:"fetchedRecordsCount"`
+	Bookmark            string      `json:"bookmark"`
+}
+
+type CapitalAccountPage struct {
+	Records             []*CapitalAccount `json:"records"`
+	FetchedRecordsCount int32             `json:"fetchedRecordsCount"`
+	Bookmark            string            `json:"bookmark"`
+}
+
+type CapitalAccountActionPage struct {
+	Records             []*CapitalAccountAction `json:"records"`
+	FetchedRecordsCount int32                   `json:"fetchedRecordsCount"`
+	Bookmark            string                  `json:"bookmark"`
+}
+
+type PortfolioPage struct {
+	Records             []*Portfolio `json:"records"`
+	FetchedRecordsCount int32        `json:"fetchedRecordsCount"`
+	Bookmark            string       `json:"bookmark"`
+}
+
+type PortfolioActionPage struct {
+	Records             []*PortfolioAction `json:"records"`
+	FetchedRecordsCount int32              `json:"fetchedRecordsCount"`
+	Bookmark            string             `json:"bookmark"`
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Returns and ratios are fractions with this many decimal places
+const PERFORMANCE_PRECISION int32 = 6
+
+// Funds close a period each month unless they are set to close more or less often
+const DEFAULT_PERIODS_PER_YEAR int = 12
+
+// A fund closes at most a period a day
+const MAX_PERIODS_PER_YEAR int = 366
+
+type SetPeriodsPerYearRequest struct {
+	PeriodsPerYear int `json:"periodsPerYear"`
+}
+
+type PeriodsPerYearSet struct {
+	FundId         string `json:"fundId"`
+	PeriodsPerYear int    `json:"periodsPerYear"`
+}
+
+func ValidatePeriodsPerYear(periodsPerYear int) error {
+	errs := FieldErrors{}
+	if periodsPerYear < 1 || periodsPerYear > MAX_PERIODS_PER_YEAR {
+		errs.Check("periodsPerYear", "must be between 1 and "+strconv.Itoa(MAX_PERIODS_PER_YEAR))
+	}
+	return errs.Err()
+}
+
+// Capital accounts on the same fee terms form a class, named by the fixed fee and
+// the performance fee rate, e.g. 0.02/0.2. Accounts without performance fees have
+// a rate of 0.
+func (c *CapitalAccount) FeeClass() string {
+	performanceFeeRate := "0"
+	if c.HasPerformanceFees && c.PerformanceFeeRate != "" {
+		performanceFeeRate = c.PerformanceFeeRate
+	}
+	return fmt.Sprintf("%s/%s", c.FixedFee, performanceFeeRate)
+}
+
+// A closed period of a performance history. The period starts at the opening value
+// of the period before and ends at the closing value net of the period's fees.
+// Flows are the deposits less withdrawals credited at the close, after the end
+// value. Return is empty for periods that start with nothing invested.
+type PerformancePeriod struct {
+	Period          int    `json:"period"`
+	Date            string `json:"date"`
+	StartValue      string `json:"startValue"`
+	EndValue        string `json:"endValue"`
+	Flows           string `json:"flows"`
+	Return          string `json:"return"`
+	YearToDate      string `json:"yearToDate"`
+	InceptionToDate string `json:"inceptionToDate"`
+	Drawdown        string `json:"drawdown"`
+}
+
+// The performance of a fund, a class or a capital account through Period.
+// Returns are time weighted, IRR is money weighted over the dates and amounts of
+// the capital account actions. A statistic is empty when there are too few
+// periods for it, and the Sharpe ratio is empty without a riskless rate.
+type Performance struct {
+	Level           string               `json:"level"`
+	ID              string               `json:"id"`
+	Periods         []*PerformancePeriod `json:"periods"`
+	YearToDate      string               `json:"yearToDate"`
+	InceptionToDate string               `json:"inceptionToDate"`
+	IRR             string               `json:"irr"`
+	// Annualized standard deviation of the period returns
+	Volatility  string `json:"volatility"`
+	SharpeRatio string `json:"sharpeRatio"`
+	// The largest fall from a peak as a negative fraction, the periods of the
+	// peak and the trough, and the periods it took to recover to the peak, or
+	// that have passed since the peak when it has not recovered
+	MaxDrawdown         string `json:"maxDrawdown"`
+	MaxDrawdownPeak     int    `json:"maxDrawdownPeak"`
+	MaxDrawdownTrough   int    `json:"maxDrawdownTrough"`
+	MaxDrawdownDuration int    `json:"maxDrawdownDuration"`
+	Recovered           bool   `json:"recovered"`
+}
+
+const PERFORMANCE_LEVEL_FUND string = "fund"
+const PERFORMANCE_LEVEL_CLASS string = "class"
+const PERFORMANCE_LEVEL_CAPITAL_ACCOUNT string = "capitalAccount"
+
+// The performance of a fund, of each of its classes and of each of its capital
+// accounts through the same period
+type PerformanceReport struct {
+	Fund            string         `json:"fund"`
+	Period          int            `json:"period"`
+	PeriodsPerYear  int            `json:"periodsPerYear"`
+	RisklessRate    string         `json:"risklessRate,omitempty"`
+	FundPerformance *Performance   `json:"fundPerformance"`
+	Classes         []*Performance `json:"classes"`
+	Accounts        []*Performance `json:"accounts"`
+}
@@ -3,7 +3,6 @@ package types
 import (
 	"encoding/json"
 
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/zacharyfrederick/admin/types/doctypes"
 )
 
@@ -21,6 +20,7 @@ type DateValuedAssetMap map[string]ValuedAssetMap
 
 type Portfolio struct {
 	DocType        string             `json:"docType"`
+	SchemaVersion  int                `json:"schemaVersion"`
 	ID             string             `json:"id"`
 	Fund           string             `json:"fund"`
 	Name           string             `json:"name"`
@@ -46,55 +46,79 @@ type ValuedAsset struct {
 }
 
 type PortfolioAction struct {
-	DocType     string `json:"docType"`
-	ID          string `json:"id"`
-	Portfolio   string `json:"portfolio"`
-	Asset       Asset  `json:"asset"`
-	Type        string `json:"type"`
-	Date        string `json:"date"`
-	Period      int    `json:"period"`
-	Status      string `json:"status"`
-	Description string `json:"description"`
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	ID            string `json:"id"`
+	Fund          string `json:"fund"`
+	Portfolio     string `json:"portfolio"`
+	Asset         Asset  `json:"asset"`
+	Type          string `json:"type"`
+	Date          string `json:"date"`
+	Period        int    `json:"period"`
+	Status        string `json:"status"`
+	Description   string `json:"description"`
 }
 
 type CreatePortfolioRequest struct {
-	Fund string `json:"fund" binding:"required"`
-	Name string `json:"name" binding:"required"`
+	Fund string `json:"fund"`
+	Name string `json:"name"`
 }
 
-func ValidateCreatePortfolioRequest(r *CreatePortfolioRequest) bool {
-	return true
+func ValidateCreatePortfolioRequest(r *CreatePortfolioRequest) error {
+	errs := FieldErrors{}
+	errs.Check("fund", CheckRequired(r.Fund))
+	errs.Check("name", CheckRequired(r.Name))
+	return errs.Err()
 }
 
 type CreatePortfolioActionRequest struct {
-	Portfolio string `json:"portfolio" binding:"required"`
-	Type      string `json:"type" binding:"required"`
-	Date      string `json:"date" binding:"required"`
-	Period    int    `json:"period" binding:"isdefault|required"`
-	Name      string `json:"name" binding:"required"`
-	CUSIP     string `json:"cusip" binding:"required"`
-	Amount    string `json:"amount" binding:"required"`
-	Currency  string `json:"currency" binding:"required"`
+	Portfolio string `json:"portfolio"`
+	Type      string `json:"type"`
+	Date      string `json:"date"`
+	Period    int    `json:"period"`
+	Name      string `json:"name"`
+	CUSIP     string `json:"cusip"`
+	Amount    string `json:"amount"`
+	Currency  string `json:"currency"`
 }
 
 type ValuePortfolioRequest struct {
-	Portfolio string `json:"portfolio" binding:"required"`
-	Name      string `json:"name" binding:"required"`
-	Date      string `json:"date" binding:"required"`
-	Price     string `json:"price" binding:"isdefault|required"`
+	Portfolio string `json:"portfolio"`
+	Name      string `json:"name"`
+	Date      string `json:"date"`
+	Price     string `json:"price"`
 }
 
-func ValidateCreatePortfolioActionRequest(r *CreatePortfolioActionRequest) bool {
-	return true
+type ValuePortfolioResponse struct {
+	Status string `json:"status"`
 }
 
-func ValidateValuePortfolioRequest(r *ValuePortfolioRequest) bool {
-	return true
+func ValidateCreatePortfolioActionRequest(r *CreatePortfolioActionRequest) error {
+	errs := FieldErrors{}
+	errs.Check("portfolio", CheckRequired(r.Portfolio))
+	errs.Check("type", CheckOneOf(r.Type, PORTFOLIO_ACTION_TYPE_BUY, PORTFOLIO_ACTION_TYPE_SELL))
+	errs.Check("date", CheckDate(r.Date))
+	errs.Check("period", CheckPeriod(r.Period))
+	errs.Check("name", CheckRequired(r.Name))
+	errs.Check("cusip", CheckCUSIP(r.CUSIP))
+	errs.Check("amount", CheckPositiveDecimal(r.Amount))
+	errs.Check("currency", CheckCurrency(r.Currency))
+	return errs.Err()
+}
+
+func ValidateValuePortfolioRequest(r *ValuePortfolioRequest) error {
+	errs := FieldErrors{}
+	errs.Check("portfolio", CheckRequired(r.Portfolio))
+	errs.Check("name", CheckRequired(r.Name))
+	errs.Check("date", CheckDate(r.Date))
+	errs.Check("price", CheckNonNegativeDecimal(r.Price))
+	return errs.Err()
 }
 
 func CreateDefaultPortfolio(portfolioId string, fundId string, name string) Portfolio {
 	portfolio := Portfolio{
 		DocType:        doctypes.DOCTYPE_PORTFOLIO,
+		SchemaVersion:  PORTFOLIO_SCHEMA_VERSION,
 		Name:           name,
 		ID:             portfolioId,
 		Fund:           fundId,
@@ -122,12 +146,8 @@ func (f *Portfolio) FromJSON(data []byte) error {
 	}
 	return nil
 }
-func (p *Portfolio) SaveState(ctx contractapi.TransactionContextInterface) error {
-	portfolioJSON, err := p.ToJSON()
-	if err != nil {
-		return err
-	}
-	return ctx.GetStub().PutState(p.ID, portfolioJSON)
+func (f *Portfolio) IndexKeys() []IndexKey {
+	return []IndexKey{{ObjectType: INDEX_PORTFOLIO, Attributes: []string{f.Fund, f.ID}}}
 }
 
 func (f *PortfolioAction) GetID() string {
@@ -150,12 +170,9 @@ func (f *PortfolioAction) FromJSON(data []byte) error {
 	return nil
 }
 
-func (p *PortfolioAction) SaveState(ctx contractapi.TransactionContextInterface) error {
-	portfolioActionJSON, err := json.Marshal(p)
-	if err != nil {
-		return err
-	}
-	return ctx.GetStub().PutState(p.ID, portfolioActionJSON)
+func (f *PortfolioAction) IndexKeys() []IndexKey {
+	attributes := []string{f.Fund, f.Portfolio, FormatPeriodKey(f.Period), f.ID}
+	return []IndexKey{{ObjectType: INDEX_PORTFOLIOACTION, Attributes: attributes}}
 }
 
 func CreateAsset(name string, cusip string, amount string, currency string) Asset {
@@ -168,16 +185,18 @@ func CreateAsset(name string, cusip string, amount string, currency string) Asse
 	return security
 }
 
-func CreateDefaultPortfolioAction(portfolioId string, type_ string, date string, id string, asset Asset, period int) PortfolioAction {
+func CreateDefaultPortfolioAction(fundId string, portfolioId string, type_ string, date string, id string, asset Asset, period int) PortfolioAction {
 	portfolioAction := PortfolioAction{
-		DocType:   doctypes.DOCTYPE_PORTFOLIOACTION,
-		Portfolio: portfolioId,
-		Type:      type_,
-		Date:      date,
-		ID:        id,
-		Asset:     asset,
-		Period:    period,
-		Status:    TX_STATUS_SUBMITTED,
+		DocType:       doctypes.DOCTYPE_PORTFOLIOACTION,
+		SchemaVersion: PORTFOLIOACTION_SCHEMA_VERSION,
+		Fund:          fundId,
+		Portfolio:     portfolioId,
+		Type:          type_,
+		Date:          date,
+		ID:            id,
+		Asset:         asset,
+		Period:        period,
+		Status:        TX_STATUS_SUBMITTED,
 	}
 	return portfolioAction
 }
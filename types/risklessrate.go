@@ -1,7 +1,91 @@
 package types
 
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/zacharyfrederick/admin/types/doctypes"
+)
+
+// A series of annual riskless rates, e.g. the 3 month treasury bill yield. Values
+// are keyed by the date each rate takes effect and hold fractions, 0.015 for 1.5%.
 type RisklessRate struct {
-	ID     string            `json:"id"`
-	Name   string            `json:"name"`
-	Values map[string]string `json:"values"`
+	DocType       string            `json:"docType"`
+	SchemaVersion int               `json:"schemaVersion"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Values        map[string]string `json:"values"`
+}
+
+func (r *RisklessRate) GetID() string {
+	return r.ID
+}
+
+func (r *RisklessRate) ToJSON() ([]byte, error) {
+	risklessRateJSON, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return risklessRateJSON, nil
+}
+
+func (r *RisklessRate) FromJSON(data []byte) error {
+	err := json.Unmarshal(data, r)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *RisklessRate) IndexKeys() []IndexKey {
+	return []IndexKey{{ObjectType: INDEX_RISKLESSRATE, Attributes: []string{r.ID}}}
+}
+
+// The rate in effect on date, the value of the latest date on or before it
+func (r *RisklessRate) RateOn(date time.Time) (decimal.Decimal, bool) {
+	dates := []time.Time{}
+	values := map[time.Time]string{}
+	for key, value := range r.Values {
+		effective, err := ParseDate(key)
+		if err != nil || effective.After(date) {
+			continue
+		}
+		dates = append(dates, effective)
+		values[effective] = value
+	}
+	if len(dates) == 0 {
+		return decimal.Zero, false
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	rate, err := decimal.NewFromString(values[dates[len(dates)-1]])
+	if err != nil {
+		return decimal.Zero, false
+	}
+	return rate, true
+}
+
+func CreateDefaultRisklessRate(risklessRateId string, name string) RisklessRate {
+	return RisklessRate{
+		DocType:       doctypes.DOCTYPE_RISKLESSRATE,
+		SchemaVersion: RISKLESSRATE_SCHEMA_VERSION,
+		ID:            risklessRateId,
+		Name:          name,
+		Values:        map[string]string{},
+	}
+}
+
+// Sets the rate from a date on, creating the series with the name if it is new
+type SetRisklessRateRequest struct {
+	Name string `json:"name"`
+	Date string `json:"date"`
+	Rate string `json:"rate"`
+}
+
+func ValidateSetRisklessRateRequest(r *SetRisklessRateRequest) error {
+	errs := FieldErrors{}
+	errs.Check("date", CheckDate(r.Date))
+	errs.Check("rate", CheckAnnualRate(r.Rate))
+	return errs.Err()
 }
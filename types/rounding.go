@@ -0,0 +1,83 @@
+package types
+
+import (
+	"github.com/shopspring/decimal"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Rounds half away from zero, e.g. 2.345 to 2.35 and -2.345 to -2.35
+const ROUNDING_MODE_HALF_UP string = "halfUp"
+
+// Rounds half to the nearest even digit, e.g. 2.345 to 2.34 and 2.355 to 2.36
+const ROUNDING_MODE_HALF_EVEN string = "halfEven"
+
+// Drops the digits past the precision, e.g. 2.349 to 2.34
+const ROUNDING_MODE_DOWN string = "down"
+
+const DEFAULT_CURRENCY_PRECISION int32 = 2
+const DEFAULT_OWNERSHIP_PRECISION int32 = 10
+const DEFAULT_ROUNDING_MODE string = ROUNDING_MODE_HALF_UP
+
+const MAX_CURRENCY_PRECISION int32 = 8
+const MAX_OWNERSHIP_PRECISION int32 = 16
+
+// How a fund rounds the amounts and ownership percentages it stores. Currency
+// amounts are kept to CurrencyPrecision decimal places and ownership percentages,
+// as fractions of 1, to OwnershipPrecision places. The residual left by rounding
+// is allocated to the general partner.
+type RoundingPolicy struct {
+	CurrencyPrecision  int32  `json:"currencyPrecision"`
+	OwnershipPrecision int32  `json:"ownershipPrecision"`
+	RoundingMode       string `json:"roundingMode"`
+}
+
+func DefaultRoundingPolicy() RoundingPolicy {
+	return RoundingPolicy{
+		CurrencyPrecision:  DEFAULT_CURRENCY_PRECISION,
+		OwnershipPrecision: DEFAULT_OWNERSHIP_PRECISION,
+		RoundingMode:       DEFAULT_ROUNDING_MODE,
+	}
+}
+
+func (p RoundingPolicy) Validate() error {
+	if p.CurrencyPrecision < 0 || p.CurrencyPrecision > MAX_CURRENCY_PRECISION {
+		return pkgErrors.InvalidRoundingPolicyError
+	}
+	if p.OwnershipPrecision < 2 || p.OwnershipPrecision > MAX_OWNERSHIP_PRECISION {
+		return pkgErrors.InvalidRoundingPolicyError
+	}
+	switch p.RoundingMode {
+	case ROUNDING_MODE_HALF_UP, ROUNDING_MODE_HALF_EVEN, ROUNDING_MODE_DOWN:
+		return nil
+	}
+	return pkgErrors.InvalidRoundingPolicyError
+}
+
+func (p RoundingPolicy) round(d decimal.Decimal, places int32) decimal.Decimal {
+	switch p.RoundingMode {
+	case ROUNDING_MODE_HALF_EVEN:
+		return d.RoundBank(places)
+	case ROUNDING_MODE_DOWN:
+		return d.Truncate(places)
+	default:
+		return d.Round(places)
+	}
+}
+
+func (p RoundingPolicy) RoundCurrency(d decimal.Decimal) decimal.Decimal {
+	return p.round(d, p.CurrencyPrecision)
+}
+
+func (p RoundingPolicy) RoundOwnership(d decimal.Decimal) decimal.Decimal {
+	return p.round(d, p.OwnershipPrecision)
+}
+
+// Formats a currency amount with exactly CurrencyPrecision decimal places, so
+// statements read 1100.00 rather than 1100
+func (p RoundingPolicy) FormatCurrency(d decimal.Decimal) string {
+	return p.RoundCurrency(d).StringFixed(p.CurrencyPrecision)
+}
+
+func (p RoundingPolicy) FormatOwnership(d decimal.Decimal) string {
+	return p.RoundOwnership(d).StringFixed(p.OwnershipPrecision)
+}
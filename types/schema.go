@@ -0,0 +1,12 @@
+package types
+
+// Current schema version of each document type. Bump the version and register an
+// upgrade in the smartcontract package whenever the stored shape of a document changes.
+const FUND_SCHEMA_VERSION int = 4
+const INVESTOR_SCHEMA_VERSION int = 1
+const CAPITALACCOUNT_SCHEMA_VERSION int = 2
+const CAPITALACCOUNTACTION_SCHEMA_VERSION int = 1
+const PORTFOLIO_SCHEMA_VERSION int = 1
+const PORTFOLIOACTION_SCHEMA_VERSION int = 1
+const RISKLESSRATE_SCHEMA_VERSION int = 1
+const SECURITY_SCHEMA_VERSION int = 1
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"encoding/json"
+
+	"github.com/zacharyfrederick/admin/types/doctypes"
+)
+
+const ASSET_CLASS_EQUITY string = "equity"
+const ASSET_CLASS_FIXED_INCOME string = "fixedIncome"
+const ASSET_CLASS_CASH string = "cash"
+const ASSET_CLASS_COMMODITY string = "commodity"
+const ASSET_CLASS_REAL_ESTATE string = "realEstate"
+const ASSET_CLASS_DERIVATIVE string = "derivative"
+const ASSET_CLASS_FUND string = "fund"
+const ASSET_CLASS_OTHER string = "other"
+
+var ASSET_CLASSES = []string{
+	ASSET_CLASS_EQUITY,
+	ASSET_CLASS_FIXED_INCOME,
+	ASSET_CLASS_CASH,
+	ASSET_CLASS_COMMODITY,
+	ASSET_CLASS_REAL_ESTATE,
+	ASSET_CLASS_DERIVATIVE,
+	ASSET_CLASS_FUND,
+	ASSET_CLASS_OTHER,
+}
+
+// The security master record of a CUSIP, which classifies every position in it.
+// Whether a position is long or short belongs to the position, not the security.
+type Security struct {
+	DocType       string `json:"docType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	// The CUSIP
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	AssetClass string `json:"assetClass"`
+	Sector     string `json:"sector"`
+	Country    string `json:"country"`
+	Issuer     string `json:"issuer"`
+}
+
+func (s *Security) GetID() string {
+	return s.ID
+}
+
+func (s *Security) ToJSON() ([]byte, error) {
+	securityJSON, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return securityJSON, nil
+}
+
+func (s *Security) FromJSON(data []byte) error {
+	err := json.Unmarshal(data, s)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Security) IndexKeys() []IndexKey {
+	return []IndexKey{{ObjectType: INDEX_SECURITY, Attributes: []string{s.ID}}}
+}
+
+// Sets the security master record of a CUSIP, replacing any earlier one. The sector
+// may be left empty, e.g. for cash.
+type SetSecurityRequest struct {
+	Name       string `json:"name"`
+	AssetClass string `json:"assetClass"`
+	Sector     string `json:"sector"`
+	Country    string `json:"country"`
+	Issuer     string `json:"issuer"`
+}
+
+func ValidateSetSecurityRequest(cusip string, r *SetSecurityRequest) error {
+	errs := FieldErrors{}
+	errs.Check("cusip", CheckCUSIP(cusip))
+	errs.Check("name", CheckRequired(r.Name))
+	errs.Check("assetClass", CheckOneOf(r.AssetClass, ASSET_CLASSES...))
+	errs.Check("country", CheckCountry(r.Country))
+	errs.Check("issuer", CheckRequired(r.Issuer))
+	return errs.Err()
+}
+
+func CreateSecurity(cusip string, r *SetSecurityRequest) Security {
+	return Security{
+		DocType:       doctypes.DOCTYPE_SECURITY,
+		SchemaVersion: SECURITY_SCHEMA_VERSION,
+		ID:            cusip,
+		Name:          r.Name,
+		AssetClass:    r.AssetClass,
+		Sector:        r.Sector,
+		Country:       r.Country,
+		Issuer:        r.Issuer,
+	}
+}
@@ -0,0 +1,199 @@
+package types
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const CAPITAL_ACCOUNT_ACTION_TYPE_DEPOSIT string = "deposit"
+const CAPITAL_ACCOUNT_ACTION_TYPE_WITHDRAWAL string = "withdrawal"
+const PORTFOLIO_ACTION_TYPE_BUY string = "buy"
+const PORTFOLIO_ACTION_TYPE_SELL string = "sell"
+
+// Collects the problems with a request keyed by the JSON name of the field, so a
+// client can show every problem at once instead of fixing them one at a time
+type FieldErrors map[string]string
+
+func (f FieldErrors) Check(field string, message string) {
+	if message == "" {
+		return
+	}
+	if _, ok := f[field]; !ok {
+		f[field] = message
+	}
+}
+
+// Returns a ValidationError with a detail per invalid field, or nil when every
+// field is valid
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	err := pkgErrors.ValidationError
+	for field, message := range f {
+		err = err.WithDetail(field, message)
+	}
+	return err
+}
+
+// Each check returns an empty string for a valid value and otherwise a short
+// description of what is wrong with it
+
+func CheckRequired(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "is required"
+	}
+	return ""
+}
+
+func CheckPositiveDecimal(value string) string {
+	amount, err := decimal.NewFromString(value)
+	if err != nil {
+		return "must be a decimal number"
+	}
+	if amount.Sign() != 1 {
+		return "must be greater than zero"
+	}
+	return ""
+}
+
+// Prices may be zero, e.g. for a security whose issuer has defaulted
+func CheckNonNegativeDecimal(value string) string {
+	amount, err := decimal.NewFromString(value)
+	if err != nil {
+		return "must be a decimal number"
+	}
+	if amount.Sign() == -1 {
+		return "must not be negative"
+	}
+	return ""
+}
+
+// Rates are fractions, e.g. 0.2 for a 20% performance fee
+func CheckRate(value string) string {
+	rate, err := decimal.NewFromString(value)
+	if err != nil {
+		return "must be a decimal number"
+	}
+	if rate.Sign() == -1 || rate.GreaterThan(decimal.NewFromInt(1)) {
+		return "must be between 0 and 1"
+	}
+	return ""
+}
+
+// Annual rates such as riskless rates can be below zero, but not below -100%
+func CheckAnnualRate(value string) string {
+	rate, err := decimal.NewFromString(value)
+	if err != nil {
+		return "must be a decimal number"
+	}
+	if rate.LessThan(decimal.NewFromInt(-1)) || rate.GreaterThan(decimal.NewFromInt(1)) {
+		return "must be between -1 and 1"
+	}
+	return ""
+}
+
+func CheckDate(value string) string {
+	_, err := ParseDate(value)
+	if err != nil {
+		return "must use the MM-DD-YYYY format"
+	}
+	return ""
+}
+
+func CheckPeriod(period int) string {
+	if period < 0 {
+		return "must not be negative"
+	}
+	return ""
+}
+
+func CheckOneOf(value string, allowed ...string) string {
+	for _, a := range allowed {
+		if value == a {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(allowed, ", ")
+}
+
+// A CUSIP is 8 characters identifying the issuer and issue followed by a check
+// digit computed with the modulus 10 double add double algorithm
+func CheckCUSIP(value string) string {
+	if len(value) != 9 {
+		return "must be 9 characters"
+	}
+	sum := 0
+	for i := 0; i < 8; i++ {
+		v, ok := cusipCharacterValue(value[i])
+		if !ok {
+			return "must contain only digits, capital letters, *, @ and #"
+		}
+		if i%2 == 1 {
+			v *= 2
+		}
+		sum += v/10 + v%10
+	}
+	checkDigit := byte('0' + (10-sum%10)%10)
+	if value[8] != checkDigit {
+		return "has an invalid check digit"
+	}
+	return ""
+}
+
+func cusipCharacterValue(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10, true
+	case c == '*':
+		return 36, true
+	case c == '@':
+		return 37, true
+	case c == '#':
+		return 38, true
+	}
+	return 0, false
+}
+
+// Only the shape of an ISO 3166 alpha-2 code is checked, two capital letters
+func CheckCountry(value string) string {
+	if len(value) != 2 || value[0] < 'A' || value[0] > 'Z' || value[1] < 'A' || value[1] > 'Z' {
+		return "must be an ISO 3166 alpha-2 country code"
+	}
+	return ""
+}
+
+func CheckCurrency(value string) string {
+	if !isoCurrencies[value] {
+		return "must be an ISO 4217 currency code"
+	}
+	return ""
+}
+
+// Active ISO 4217 currency codes
+var isoCurrencies = map[string]bool{
+	"AED": true, "AFN": true, "ALL": true, "AMD": true, "ANG": true, "AOA": true, "ARS": true, "AUD": true,
+	"AWG": true, "AZN": true, "BAM": true, "BBD": true, "BDT": true, "BGN": true, "BHD": true, "BIF": true,
+	"BMD": true, "BND": true, "BOB": true, "BRL": true, "BSD": true, "BTN": true, "BWP": true, "BYN": true,
+	"BZD": true, "CAD": true, "CDF": true, "CHF": true, "CLP": true, "CNY": true, "COP": true, "CRC": true,
+	"CUP": true, "CVE": true, "CZK": true, "DJF": true, "DKK": true, "DOP": true, "DZD": true, "EGP": true,
+	"ERN": true, "ETB": true, "EUR": true, "FJD": true, "FKP": true, "GBP": true, "GEL": true, "GHS": true,
+	"GIP": true, "GMD": true, "GNF": true, "GTQ": true, "GYD": true, "HKD": true, "HNL": true, "HTG": true,
+	"HUF": true, "IDR": true, "ILS": true, "INR": true, "IQD": true, "IRR": true, "ISK": true, "JMD": true,
+	"JOD": true, "JPY": true, "KES": true, "KGS": true, "KHR": true, "KMF": true, "KPW": true, "KRW": true,
+	"KWD": true, "KYD": true, "KZT": true, "LAK": true, "LBP": true, "LKR": true, "LRD": true, "LSL": true,
+	"LYD": true, "MAD": true, "MDL": true, "MGA": true, "MKD": true, "MMK": true, "MNT": true, "MOP": true,
+	"MRU": true, "MUR": true, "MVR": true, "MWK": true, "MXN": true, "MYR": true, "MZN": true, "NAD": true,
+	"NGN": true, "NIO": true, "NOK": true, "NPR": true, "NZD": true, "OMR": true, "PAB": true, "PEN": true,
+	"PGK": true, "PHP": true, "PKR": true, "PLN": true, "PYG": true, "QAR": true, "RON": true, "RSD": true,
+	"RUB": true, "RWF": true, "SAR": true, "SBD": true, "SCR": true, "SDG": true, "SEK": true, "SGD": true,
+	"SHP": true, "SLE": true, "SOS": true, "SRD": true, "SSP": true, "STN": true, "SVC": true, "SYP": true,
+	"SZL": true, "THB": true, "TJS": true, "TMT": true, "TND": true, "TOP": true, "TRY": true, "TTD": true,
+	"TWD": true, "TZS": true, "UAH": true, "UGX": true, "USD": true, "UYU": true, "UZS": true, "VES": true,
+	"VND": true, "VUV": true, "WST": true, "XAF": true, "XCD": true, "XOF": true, "XPF": true, "YER": true,
+	"ZAR": true, "ZMW": true, "ZWL": true,
+}
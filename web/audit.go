@@ -0,0 +1,59 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// One request as it is written to the audit log. Requests that fail to
+// authenticate are logged too, without a subject.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Route      string    `json:"route,omitempty"`
+	Status     int       `json:"status"`
+	ErrorCode  string    `json:"errorCode,omitempty"`
+	Subject    string    `json:"subject,omitempty"`
+	AuthMethod string    `json:"authMethod,omitempty"`
+	Identity   string    `json:"identity,omitempty"`
+	ClientIP   string    `json:"clientIp"`
+	LatencyMs  int64     `json:"latencyMs"`
+}
+
+// Writes audit entries as JSON lines
+type AuditLog struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewAuditLog(writer io.Writer) *AuditLog {
+	return &AuditLog{writer: writer}
+}
+
+// Opens the audit log at path for appending, or logs to stdout when path is empty
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if path == "" {
+		return NewAuditLog(os.Stdout), nil
+	}
+	file, err := os.OpenFile(filepath.Clean(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuditLog(file), nil
+}
+
+func (l *AuditLog) Record(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.writer.Write(append(line, '\n'))
+	return err
+}
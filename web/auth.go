@@ -0,0 +1,162 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// Creating or changing funds, investors, capital accounts, portfolios and their
+// actions, and bootstrapping funds
+const SCOPE_FUNDS_WRITE string = "funds:write"
+
+// Recording portfolio valuations
+const SCOPE_VALUATIONS_WRITE string = "valuations:write"
+
+// Reading any document, listing and transaction status
+const SCOPE_REPORTS_READ string = "reports:read"
+
+const AUTH_METHOD_JWT string = "jwt"
+const AUTH_METHOD_API_KEY string = "api_key"
+
+const API_KEY_HEADER string = "X-API-Key"
+
+var invalidAPIKeyError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the API key is not valid")
+var malformedAuthorizationError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the Authorization header must be Bearer <token>")
+
+// The authenticated caller of a request
+type Principal struct {
+	Subject string   `json:"subject"`
+	Method  string   `json:"method"`
+	Scopes  []string `json:"scopes"`
+	// The wallet identity the caller's transactions are signed with
+	Identity string `json:"identity"`
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+// Authenticates one kind of credentials. An authenticator returns false when the
+// request carries none of its credentials so that the next one can try, and an
+// error when it carries credentials that are not valid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, bool, error)
+}
+
+// Authenticates Authorization: Bearer tokens
+type JWTAuthenticator struct {
+	Verifier *JWTVerifier
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, bool, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, false, nil
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		return nil, true, malformedAuthorizationError
+	}
+	claims, err := a.Verifier.Verify(parts[1])
+	if err != nil {
+		return nil, true, err
+	}
+	return &Principal{Subject: claims.Subject, Method: AUTH_METHOD_JWT, Scopes: claims.Scopes()}, true, nil
+}
+
+// An API key as it is stored: the hex SHA-256 of the key, never the key itself.
+// Keys are long random strings, so a fast hash is enough to keep a leaked config
+// from leaking working keys.
+type APIKey struct {
+	ID      string   `yaml:"id"`
+	Subject string   `yaml:"subject"`
+	Scopes  []string `yaml:"scopes"`
+	Hash    string   `yaml:"hash"`
+}
+
+// Authenticates X-API-Key headers. Keys have the form <id>.<secret>, so the
+// stored hash is found by id and compared in constant time.
+type APIKeyAuthenticator struct {
+	keys map[string]APIKey
+}
+
+func NewAPIKeyAuthenticator(keys []APIKey) (*APIKeyAuthenticator, error) {
+	authenticator := &APIKeyAuthenticator{keys: map[string]APIKey{}}
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ".") {
+			return nil, fmt.Errorf("API key id %q must be non empty and cannot contain a dot", key.ID)
+		}
+		if _, ok := authenticator.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate API key id %q", key.ID)
+		}
+		if hash, err := hex.DecodeString(key.Hash); err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("API key %s: hash must be a hex SHA-256", key.ID)
+		}
+		if key.Subject == "" {
+			return nil, fmt.Errorf("API key %s: subject is required", key.ID)
+		}
+		authenticator.keys[key.ID] = key
+	}
+	return authenticator, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, bool, error) {
+	value := r.Header.Get(API_KEY_HEADER)
+	if value == "" {
+		return nil, false, nil
+	}
+	parts := strings.SplitN(value, ".", 2)
+	key, ok := a.keys[parts[0]]
+	if !ok || len(parts) != 2 {
+		return nil, true, invalidAPIKeyError
+	}
+	if subtle.ConstantTimeCompare([]byte(HashAPIKey(value)), []byte(strings.ToLower(key.Hash))) != 1 {
+		return nil, true, invalidAPIKeyError
+	}
+	return &Principal{Subject: key.Subject, Method: AUTH_METHOD_API_KEY, Scopes: key.Scopes}, true, nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns a new key with the id and the hash to store for it
+func GenerateAPIKey(id string) (string, string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+	key := id + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return key, HashAPIKey(key), nil
+}
+
+// Returns the authenticators for the configuration, none when authentication is
+// not configured
+func NewAuthenticators(cfg AuthConfig) ([]Authenticator, error) {
+	authenticators := []Authenticator{}
+	if cfg.JWKS != "" {
+		verifier, err := LoadJWTVerifier(cfg.JWKS, cfg.Issuer, cfg.Audience)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, &JWTAuthenticator{Verifier: verifier})
+	}
+	if len(cfg.APIKeys) > 0 {
+		apiKeys, err := NewAPIKeyAuthenticator(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, apiKeys)
+	}
+	return authenticators, nil
+}
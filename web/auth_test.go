@@ -0,0 +1,58 @@
+package web
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	key, hash, err := GenerateAPIKey("ci")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(key, "ci."))
+	assert.Equal(t, HashAPIKey(key), hash)
+
+	authenticator, err := NewAPIKeyAuthenticator([]APIKey{
+		{ID: "ci", Subject: "valuation-bot", Scopes: []string{SCOPE_VALUATIONS_WRITE}, Hash: strings.ToUpper(hash)},
+	})
+	assert.Nil(t, err)
+
+	request := httptest.NewRequest("GET", "/funds", nil)
+	_, ok, err := authenticator.Authenticate(request)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	request.Header.Set(API_KEY_HEADER, key)
+	principal, ok, err := authenticator.Authenticate(request)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, &Principal{Subject: "valuation-bot", Method: AUTH_METHOD_API_KEY, Scopes: []string{SCOPE_VALUATIONS_WRITE}}, principal)
+
+	for _, invalid := range []string{key + "x", "ci", "other." + strings.TrimPrefix(key, "ci.")} {
+		request.Header.Set(API_KEY_HEADER, invalid)
+		_, ok, err = authenticator.Authenticate(request)
+		assert.True(t, ok, invalid)
+		assert.Equal(t, invalidAPIKeyError, err, invalid)
+	}
+}
+
+func TestNewAPIKeyAuthenticatorRejectsInvalidKeys(t *testing.T) {
+	_, hash, _ := GenerateAPIKey("ci")
+	for _, keys := range [][]APIKey{
+		{{ID: "", Subject: "ci", Hash: hash}},
+		{{ID: "c.i", Subject: "ci", Hash: hash}},
+		{{ID: "ci", Subject: "", Hash: hash}},
+		{{ID: "ci", Subject: "ci", Hash: "plaintext"}},
+		{{ID: "ci", Subject: "ci", Hash: hash}, {ID: "ci", Subject: "ci", Hash: hash}},
+	} {
+		_, err := NewAPIKeyAuthenticator(keys)
+		assert.Error(t, err, keys)
+	}
+}
+
+func TestJWTScopes(t *testing.T) {
+	claims := Claims{Scope: "reports:read  funds:write", ScopeList: stringList{"valuations:write"}}
+	assert.Equal(t, []string{SCOPE_REPORTS_READ, SCOPE_FUNDS_WRITE, SCOPE_VALUATIONS_WRITE}, claims.Scopes())
+}
@@ -0,0 +1,286 @@
+package web
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Environment variable naming the config file, used when no path is given on the
+// command line
+const CONFIG_PATH_ENV string = "ADMIN_CONFIG"
+
+const ENV_CONNECTION_PROFILE string = "ADMIN_CONNECTION_PROFILE"
+const ENV_CHANNEL string = "ADMIN_CHANNEL"
+const ENV_CHAINCODE string = "ADMIN_CHAINCODE"
+const ENV_DISCOVERY_AS_LOCALHOST string = "ADMIN_DISCOVERY_AS_LOCALHOST"
+const ENV_WALLET_DIR string = "ADMIN_WALLET_DIR"
+const ENV_IDENTITY_LABEL string = "ADMIN_IDENTITY_LABEL"
+const ENV_IDENTITY_MSP_ID string = "ADMIN_IDENTITY_MSP_ID"
+const ENV_IDENTITY_CERT string = "ADMIN_IDENTITY_CERT"
+const ENV_IDENTITY_KEY string = "ADMIN_IDENTITY_KEY"
+const ENV_LISTEN_ADDRESS string = "ADMIN_LISTEN_ADDRESS"
+const ENV_TLS_CERT string = "ADMIN_TLS_CERT"
+const ENV_TLS_KEY string = "ADMIN_TLS_KEY"
+const ENV_AUTH_JWKS string = "ADMIN_AUTH_JWKS"
+const ENV_AUTH_ISSUER string = "ADMIN_AUTH_ISSUER"
+const ENV_AUTH_AUDIENCE string = "ADMIN_AUTH_AUDIENCE"
+const ENV_AUDIT_LOG string = "ADMIN_AUDIT_LOG"
+const ENV_BACKEND string = "ADMIN_BACKEND"
+const ENV_LOCAL_DATA_FILE string = "ADMIN_LOCAL_DATA_FILE"
+
+// Submit to the chaincode on a Fabric network
+const BACKEND_FABRIC string = "fabric"
+
+// Run the chaincode in the server, for development and CI
+const BACKEND_LOCAL string = "local"
+
+// The Fabric network the server submits transactions to
+type NetworkConfig struct {
+	ConnectionProfile string `yaml:"connectionProfile"`
+	Channel           string `yaml:"channel"`
+	Chaincode         string `yaml:"chaincode"`
+	// Only for networks running in docker on the same machine, where the peers
+	// advertise container hostnames that do not resolve from the host
+	DiscoveryAsLocalhost bool `yaml:"discoveryAsLocalhost"`
+}
+
+// The identity the server signs transactions with. The certificate and key are
+// only read to populate the wallet when it does not hold the label yet. Key may
+// be the key file or a keystore directory holding a single file.
+type IdentityConfig struct {
+	WalletDir string `yaml:"walletDir"`
+	Label     string `yaml:"label"`
+	MSPID     string `yaml:"mspId"`
+	Cert      string `yaml:"cert"`
+	Key       string `yaml:"key"`
+}
+
+// Where the REST api listens. The server uses TLS when a certificate and key are
+// both given.
+type ServerConfig struct {
+	ListenAddress string `yaml:"listenAddress"`
+	TLSCert       string `yaml:"tlsCert"`
+	TLSKey        string `yaml:"tlsKey"`
+}
+
+// How API callers are authenticated. When a key set or API keys are given every
+// request must carry a bearer token signed by one of the keys or an API key, and
+// is submitted with the wallet identity of the caller's subject.
+type AuthConfig struct {
+	// A JSON Web Key Set file
+	JWKS     string `yaml:"jwks"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// Hashes of the API keys, generated with the server's -generate-api-key flag
+	APIKeys []APIKey `yaml:"apiKeys"`
+	// The wallet label of each subject that is not enrolled under its own subject
+	Identities map[string]string `yaml:"identities"`
+}
+
+type AuditConfig struct {
+	// The file audit entries are appended to, stdout when empty
+	Path string `yaml:"path"`
+}
+
+// The in-process backend. The network and identity sections are ignored when it
+// is used.
+type LocalConfig struct {
+	// The file the world state is saved to after every transaction, memory only
+	// when empty
+	DataFile string `yaml:"dataFile"`
+}
+
+type Config struct {
+	// BACKEND_FABRIC or BACKEND_LOCAL
+	Backend  string         `yaml:"backend"`
+	Local    LocalConfig    `yaml:"local"`
+	Network  NetworkConfig  `yaml:"network"`
+	Identity IdentityConfig `yaml:"identity"`
+	Server   ServerConfig   `yaml:"server"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Audit    AuditConfig    `yaml:"audit"`
+}
+
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCert != "" && s.TLSKey != ""
+}
+
+func (a AuthConfig) Enabled() bool {
+	return a.JWKS != "" || len(a.APIKeys) > 0
+}
+
+// Returns the configuration for the fabric-samples test network checked out next
+// to this repository, with paths relative to cmd/server
+func CreateDefaultConfig() Config {
+	org1 := filepath.Join("..", "..", "..", "..", "..", "fabric-samples", "test-network", "organizations", "peerOrganizations", "org1.example.com")
+	msp := filepath.Join(org1, "users", "User1@org1.example.com", "msp")
+	return Config{
+		Backend: BACKEND_FABRIC,
+		Network: NetworkConfig{
+			ConnectionProfile:    filepath.Join(org1, "connection-org1.yaml"),
+			Channel:              "mychannel",
+			Chaincode:            "admin",
+			DiscoveryAsLocalhost: true,
+		},
+		Identity: IdentityConfig{
+			WalletDir: "wallet",
+			Label:     "appUser",
+			MSPID:     "Org1MSP",
+			Cert:      filepath.Join(msp, "signcerts", "cert.pem"),
+			Key:       filepath.Join(msp, "keystore"),
+		},
+		Server: ServerConfig{
+			ListenAddress: ":8080",
+		},
+	}
+}
+
+// Loads the configuration from the defaults, then the file at path if one is
+// given, then the environment, and validates the result. Values missing from the
+// file keep their defaults.
+func LoadConfig(path string) (Config, error) {
+	config := CreateDefaultConfig()
+	if path != "" {
+		data, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return config, fmt.Errorf("reading config file: %w", err)
+		}
+		err = yaml.UnmarshalStrict(data, &config)
+		if err != nil {
+			return config, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+	err := config.applyEnv(os.LookupEnv)
+	if err != nil {
+		return config, err
+	}
+	err = config.Validate()
+	if err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	fields := map[string]*string{
+		ENV_CONNECTION_PROFILE: &c.Network.ConnectionProfile,
+		ENV_CHANNEL:            &c.Network.Channel,
+		ENV_CHAINCODE:          &c.Network.Chaincode,
+		ENV_WALLET_DIR:         &c.Identity.WalletDir,
+		ENV_IDENTITY_LABEL:     &c.Identity.Label,
+		ENV_IDENTITY_MSP_ID:    &c.Identity.MSPID,
+		ENV_IDENTITY_CERT:      &c.Identity.Cert,
+		ENV_IDENTITY_KEY:       &c.Identity.Key,
+		ENV_LISTEN_ADDRESS:     &c.Server.ListenAddress,
+		ENV_TLS_CERT:           &c.Server.TLSCert,
+		ENV_TLS_KEY:            &c.Server.TLSKey,
+		ENV_AUTH_JWKS:          &c.Auth.JWKS,
+		ENV_AUTH_ISSUER:        &c.Auth.Issuer,
+		ENV_AUTH_AUDIENCE:      &c.Auth.Audience,
+		ENV_AUDIT_LOG:          &c.Audit.Path,
+		ENV_BACKEND:            &c.Backend,
+		ENV_LOCAL_DATA_FILE:    &c.Local.DataFile,
+	}
+	for name, field := range fields {
+		if value, ok := lookup(name); ok {
+			*field = value
+		}
+	}
+	if value, ok := lookup(ENV_DISCOVERY_AS_LOCALHOST); ok {
+		discoveryAsLocalhost, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", ENV_DISCOVERY_AS_LOCALHOST)
+		}
+		c.Network.DiscoveryAsLocalhost = discoveryAsLocalhost
+	}
+	return nil
+}
+
+// Reports every problem with the configuration at once, so a bad deployment is
+// fixed in one pass rather than one restart per mistake
+func (c Config) Validate() error {
+	problems := []string{}
+	type requiredField struct {
+		name  string
+		value string
+	}
+	required := []requiredField{
+		{"server.listenAddress", c.Server.ListenAddress},
+	}
+	if c.Backend == BACKEND_FABRIC {
+		required = append(required,
+			requiredField{"network.connectionProfile", c.Network.ConnectionProfile},
+			requiredField{"network.channel", c.Network.Channel},
+			requiredField{"network.chaincode", c.Network.Chaincode},
+			requiredField{"identity.walletDir", c.Identity.WalletDir},
+			requiredField{"identity.label", c.Identity.Label},
+			requiredField{"identity.mspId", c.Identity.MSPID},
+		)
+	} else if c.Backend != BACKEND_LOCAL {
+		problems = append(problems, fmt.Sprintf("backend must be %s or %s", BACKEND_FABRIC, BACKEND_LOCAL))
+	}
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			problems = append(problems, field.name+" is required")
+		}
+	}
+	if c.Backend == BACKEND_FABRIC && c.Network.ConnectionProfile != "" {
+		problems = appendFileProblem(problems, "network.connectionProfile", c.Network.ConnectionProfile)
+	}
+	if c.Backend == BACKEND_LOCAL && c.Local.DataFile != "" {
+		if info, err := os.Stat(c.Local.DataFile); err == nil && info.IsDir() {
+			problems = append(problems, fmt.Sprintf("local.dataFile %s is a directory", c.Local.DataFile))
+		}
+	}
+	if c.Server.ListenAddress != "" {
+		if _, _, err := net.SplitHostPort(c.Server.ListenAddress); err != nil {
+			problems = append(problems, "server.listenAddress must be host:port or :port")
+		}
+	}
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		problems = append(problems, "server.tlsCert and server.tlsKey must be given together")
+	}
+	if c.Server.TLSEnabled() {
+		problems = appendFileProblem(problems, "server.tlsCert", c.Server.TLSCert)
+		problems = appendFileProblem(problems, "server.tlsKey", c.Server.TLSKey)
+	}
+	if c.Auth.JWKS != "" {
+		problems = appendFileProblem(problems, "auth.jwks", c.Auth.JWKS)
+	}
+	if _, err := NewAPIKeyAuthenticator(c.Auth.APIKeys); err != nil {
+		problems = append(problems, "auth.apiKeys: "+err.Error())
+	}
+	subjects := []string{}
+	for subject := range c.Auth.Identities {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+	for _, subject := range subjects {
+		if label := c.Auth.Identities[subject]; label == "" || strings.ContainsAny(label, `/\`) {
+			problems = append(problems, fmt.Sprintf("auth.identities label of %s must be a wallet label", subject))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func appendFileProblem(problems []string, name string, path string) []string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return append(problems, fmt.Sprintf("%s %s does not exist", name, path))
+	}
+	if info.IsDir() {
+		return append(problems, fmt.Sprintf("%s %s is a directory", name, path))
+	}
+	return problems
+}
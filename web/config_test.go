@@ -0,0 +1,137 @@
+package web
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func writeFile(t *testing.T, dir string, name string, contents string) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFileAndEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	profile := writeFile(t, dir, "connection.yaml", "")
+	path := writeFile(t, dir, "config.yaml", `
+network:
+  connectionProfile: `+profile+`
+  channel: funds
+identity:
+  label: server
+server:
+  listenAddress: 127.0.0.1:9000
+`)
+	t.Setenv(ENV_CHAINCODE, "admin-v2")
+	t.Setenv(ENV_DISCOVERY_AS_LOCALHOST, "false")
+
+	config, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, profile, config.Network.ConnectionProfile)
+	assert.Equal(t, "funds", config.Network.Channel)
+	assert.Equal(t, "admin-v2", config.Network.Chaincode)
+	assert.False(t, config.Network.DiscoveryAsLocalhost)
+	assert.Equal(t, "server", config.Identity.Label)
+	//values the file leaves out keep their defaults
+	assert.Equal(t, "Org1MSP", config.Identity.MSPID)
+	assert.Equal(t, "wallet", config.Identity.WalletDir)
+	assert.Equal(t, "127.0.0.1:9000", config.Server.ListenAddress)
+	assert.False(t, config.Server.TLSEnabled())
+}
+
+// The example must stay in step with the fields the server reads
+func TestExampleConfigParses(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("..", "cmd", "server", "config.example.yaml"))
+	assert.Nil(t, err)
+	var config Config
+	assert.Nil(t, yaml.UnmarshalStrict(data, &config))
+	assert.True(t, config.Server.TLSEnabled())
+}
+
+func TestLoadConfigRejectsUnknownFields(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yaml", "network:\n  chanel: funds\n")
+	_, err := LoadConfig(path)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "chanel")
+}
+
+func TestLoadConfigRejectsInvalidBoolean(t *testing.T) {
+	t.Setenv(ENV_DISCOVERY_AS_LOCALHOST, "sometimes")
+	_, err := LoadConfig("")
+	assert.EqualError(t, err, ENV_DISCOVERY_AS_LOCALHOST+" must be true or false")
+}
+
+func TestValidateConfigReportsEveryProblem(t *testing.T) {
+	dir := t.TempDir()
+	config := CreateDefaultConfig()
+	config.Network.ConnectionProfile = filepath.Join(dir, "missing.yaml")
+	config.Network.Channel = ""
+	config.Server.ListenAddress = "8080"
+	config.Server.TLSCert = writeFile(t, dir, "cert.pem", "")
+
+	err := config.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "network.connectionProfile "+config.Network.ConnectionProfile+" does not exist")
+	assert.Contains(t, err.Error(), "network.channel is required")
+	assert.Contains(t, err.Error(), "server.listenAddress must be host:port or :port")
+	assert.Contains(t, err.Error(), "server.tlsCert and server.tlsKey must be given together")
+}
+
+func TestValidateConfigWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	config := CreateDefaultConfig()
+	config.Network.ConnectionProfile = writeFile(t, dir, "connection.yaml", "")
+	config.Server.TLSCert = writeFile(t, dir, "cert.pem", "")
+	config.Server.TLSKey = filepath.Join(dir, "key.pem")
+
+	err := config.Validate()
+	assert.EqualError(t, err, "invalid configuration: server.tlsKey "+config.Server.TLSKey+" does not exist")
+
+	writeFile(t, dir, "key.pem", "")
+	assert.Nil(t, config.Validate())
+	assert.True(t, config.Server.TLSEnabled())
+}
+
+func TestFindKey(t *testing.T) {
+	dir := t.TempDir()
+	key := writeFile(t, dir, "priv_sk", "key")
+
+	path, err := findKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, key, path)
+
+	path, err = findKey(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, key, path)
+
+	writeFile(t, dir, "other_sk", "key")
+	_, err = findKey(dir)
+	assert.Error(t, err)
+}
+
+func TestLocalBackendNeedsNoNetwork(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(ENV_BACKEND, BACKEND_LOCAL)
+	t.Setenv(ENV_LOCAL_DATA_FILE, filepath.Join(dir, "ledger.json"))
+	t.Setenv(ENV_CONNECTION_PROFILE, filepath.Join(dir, "missing.yaml"))
+	t.Setenv(ENV_IDENTITY_LABEL, "")
+
+	config, err := LoadConfig("")
+	assert.Nil(t, err)
+	assert.Equal(t, BACKEND_LOCAL, config.Backend)
+	assert.Equal(t, filepath.Join(dir, "ledger.json"), config.Local.DataFile)
+
+	config.Local.DataFile = dir
+	assert.EqualError(t, config.Validate(), "invalid configuration: local.dataFile "+dir+" is a directory")
+
+	config.Backend = "docker"
+	assert.EqualError(t, config.Validate(), "invalid configuration: backend must be fabric or local")
+}
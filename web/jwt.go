@@ -0,0 +1,300 @@
+package web
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+// How far the clocks of the token issuer and the server may drift apart
+const DEFAULT_JWT_LEEWAY time.Duration = time.Minute
+
+// The signature algorithms accepted in the alg header. Symmetric algorithms are
+// not accepted, since the server only holds public keys, and neither is "none".
+var jwtAlgorithms = map[string]struct {
+	kty  string
+	hash crypto.Hash
+}{
+	"RS256": {"RSA", crypto.SHA256},
+	"RS384": {"RSA", crypto.SHA384},
+	"RS512": {"RSA", crypto.SHA512},
+	"ES256": {"EC", crypto.SHA256},
+	"ES384": {"EC", crypto.SHA384},
+	"ES512": {"EC", crypto.SHA512},
+}
+
+// Each ES algorithm is only defined for one curve
+var curveHashes = map[string]crypto.Hash{
+	"P-256": crypto.SHA256,
+	"P-384": crypto.SHA384,
+	"P-521": crypto.SHA512,
+}
+
+var expiredTokenError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the token has expired")
+var tokenNotYetValidError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the token is not valid yet")
+var malformedTokenError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the token is malformed")
+var tokenSignatureError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the token signature is invalid")
+var unknownTokenKeyError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the token was not signed by a trusted key")
+var tokenIssuerError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the token was not issued by the trusted issuer")
+var tokenAudienceError = pkgErrors.UnauthenticatedError.WithDetail("reason", "the token was not issued for this server")
+
+// The registered claims the server reads from a token
+type Claims struct {
+	Subject   string     `json:"sub"`
+	Issuer    string     `json:"iss"`
+	Audience  stringList `json:"aud"`
+	ExpiresAt int64      `json:"exp"`
+	NotBefore int64      `json:"nbf"`
+	// Space separated scopes as in OAuth 2.0, or the list some providers send as scp
+	Scope     string     `json:"scope"`
+	ScopeList stringList `json:"scp"`
+}
+
+func (c *Claims) Scopes() []string {
+	return append(strings.Fields(c.Scope), c.ScopeList...)
+}
+
+// A claim that is either a single string or an array of strings
+type stringList []string
+
+func (a *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = stringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type verificationKey struct {
+	kty string
+	alg string
+	key crypto.PublicKey
+}
+
+// Verifies bearer tokens against a local JSON Web Key Set, e.g. the keys of an
+// OIDC provider saved from its jwks_uri. Keys are not fetched, so rotating them
+// means replacing the file and restarting the server.
+type JWTVerifier struct {
+	keys     map[string]verificationKey
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+	now      func() time.Time
+}
+
+func LoadJWTVerifier(path string, issuer string, audience string) (*JWTVerifier, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("reading the key set: %w", err)
+	}
+	return NewJWTVerifier(data, issuer, audience)
+}
+
+func NewJWTVerifier(jwks []byte, issuer string, audience string) (*JWTVerifier, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err := json.Unmarshal(jwks, &set)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the key set: %w", err)
+	}
+	verifier := &JWTVerifier{
+		keys:     map[string]verificationKey{},
+		Issuer:   issuer,
+		Audience: audience,
+		Leeway:   DEFAULT_JWT_LEEWAY,
+		now:      time.Now,
+	}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d of the key set: %w", i, err)
+		}
+		if _, ok := verifier.keys[k.Kid]; ok {
+			return nil, fmt.Errorf("key %d of the key set: duplicate kid %q", i, k.Kid)
+		}
+		verifier.keys[k.Kid] = verificationKey{kty: k.Kty, alg: k.Alg, key: key}
+	}
+	if len(verifier.keys) == 0 {
+		return nil, fmt.Errorf("the key set has no signing keys")
+	}
+	return verifier, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("the point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// Checks the signature and the time, issuer and audience claims of a compact
+// serialized token and returns its claims
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, malformedTokenError
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, malformedTokenError
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, malformedTokenError
+	}
+	algorithm, ok := jwtAlgorithms[header.Alg]
+	if !ok {
+		return nil, malformedTokenError.WithDetail("alg", header.Alg)
+	}
+	key, ok := v.keys[header.Kid]
+	if !ok || key.kty != algorithm.kty || (key.alg != "" && key.alg != header.Alg) {
+		return nil, unknownTokenKeyError
+	}
+	if ecKey, ok := key.key.(*ecdsa.PublicKey); ok && curveHashes[ecKey.Curve.Params().Name] != algorithm.hash {
+		return nil, unknownTokenKeyError
+	}
+	digest := algorithm.hash.New()
+	digest.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(key.key, algorithm.hash, digest.Sum(nil), signature) {
+		return nil, tokenSignatureError
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, malformedTokenError
+	}
+	now := v.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.Leeway)) {
+		return nil, expiredTokenError
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-v.Leeway)) {
+		return nil, tokenNotYetValidError
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return nil, tokenIssuerError
+	}
+	if v.Audience != "" && !contains(claims.Audience, v.Audience) {
+		return nil, tokenAudienceError
+	}
+	if claims.Subject == "" {
+		return nil, malformedTokenError
+	}
+	return &claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifySignature(key crypto.PublicKey, hash crypto.Hash, digest []byte, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		//ES signatures are r and s concatenated, each padded to the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
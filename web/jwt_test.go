@@ -0,0 +1,182 @@
+package web
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+var testNow = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	input := encodeSegment(t, map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func testVerifier(t *testing.T) (*JWTVerifier, *rsa.PrivateKey, *ecdsa.PrivateKey) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "use": "sig", "n": encodeBigInt(rsaKey.N), "e": encodeBigInt(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encodeBigInt(ecKey.X), "y": encodeBigInt(ecKey.Y)},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "", "e": ""},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewJWTVerifier(jwks, "https://login.example.com/", "fund-admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.now = func() time.Time { return testNow }
+	return verifier, rsaKey, ecKey
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://login.example.com/",
+		"aud": []string{"fund-admin", "reports"},
+		"exp": testNow.Add(time.Hour).Unix(),
+		"nbf": testNow.Add(-time.Hour).Unix(),
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	verifier, rsaKey, ecKey := testVerifier(t)
+
+	claims, err := verifier.Verify(signRS256(t, rsaKey, "rsa", validClaims()))
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+
+	single := validClaims()
+	single["aud"] = "fund-admin"
+	claims, err = verifier.Verify(signES256(t, ecKey, "ec", single))
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+}
+
+func TestVerifyJWTRejectsInvalidClaims(t *testing.T) {
+	verifier, rsaKey, _ := testVerifier(t)
+	cases := map[string]struct {
+		claim string
+		value interface{}
+		err   error
+	}{
+		"expired":       {"exp", testNow.Add(-2 * time.Minute).Unix(), expiredTokenError},
+		"no expiry":     {"exp", nil, expiredTokenError},
+		"not yet valid": {"nbf", testNow.Add(2 * time.Minute).Unix(), tokenNotYetValidError},
+		"issuer":        {"iss", "https://other.example.com/", tokenIssuerError},
+		"audience":      {"aud", "reports", tokenAudienceError},
+		"subject":       {"sub", nil, malformedTokenError},
+	}
+	for name, tc := range cases {
+		claims := validClaims()
+		if tc.value == nil {
+			delete(claims, tc.claim)
+		} else {
+			claims[tc.claim] = tc.value
+		}
+		_, err := verifier.Verify(signRS256(t, rsaKey, "rsa", claims))
+		assert.Equal(t, tc.err, err, name)
+	}
+
+	//the leeway absorbs clock drift
+	claims := validClaims()
+	claims["exp"] = testNow.Add(-30 * time.Second).Unix()
+	_, err := verifier.Verify(signRS256(t, rsaKey, "rsa", claims))
+	assert.Nil(t, err)
+}
+
+func TestVerifyJWTRejectsUntrustedSignatures(t *testing.T) {
+	verifier, rsaKey, ecKey := testVerifier(t)
+	token := signRS256(t, rsaKey, "rsa", validClaims())
+	parts := strings.Split(token, ".")
+
+	tampered := validClaims()
+	tampered["sub"] = "mallory"
+	_, err := verifier.Verify(parts[0] + "." + encodeSegment(t, tampered) + "." + parts[2])
+	assert.Equal(t, tokenSignatureError, err)
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err = verifier.Verify(signRS256(t, other, "rsa", validClaims()))
+	assert.Equal(t, tokenSignatureError, err)
+
+	_, err = verifier.Verify(signRS256(t, rsaKey, "unknown", validClaims()))
+	assert.Equal(t, unknownTokenKeyError, err)
+
+	//an RSA key never verifies an EC signature, whatever the header claims
+	_, err = verifier.Verify(signES256(t, ecKey, "rsa", validClaims()))
+	assert.Equal(t, unknownTokenKeyError, err)
+
+	unsigned := encodeSegment(t, map[string]string{"alg": "none", "kid": "rsa"}) + "." + parts[1] + "."
+	_, err = verifier.Verify(unsigned)
+	assert.ErrorIs(t, err, pkgErrors.UnauthenticatedError)
+
+	for _, malformed := range []string{"", "a.b", "a.b.c.d", "!.!.!"} {
+		_, err = verifier.Verify(malformed)
+		assert.Equal(t, malformedTokenError, err, malformed)
+	}
+}
+
+func TestNewJWTVerifierRejectsInvalidKeySets(t *testing.T) {
+	for _, jwks := range []string{
+		`not json`,
+		`{"keys": []}`,
+		`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`,
+		`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+		`{"keys": [{"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}, {"kty": "RSA", "kid": "a", "n": "AQAB", "e": "AQAB"}]}`,
+	} {
+		_, err := NewJWTVerifier([]byte(jwks), "", "")
+		assert.Error(t, err, jwks)
+	}
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// A gateway connected to the channel as one wallet identity
+type Connection struct {
+	Gateway  *gateway.Gateway
+	Network  *gateway.Network
+	Contract *gateway.Contract
+}
+
+type pooledConnection struct {
+	once       sync.Once
+	connection *Connection
+	err        error
+}
+
+// Keeps one gateway connection per wallet identity so that every request is
+// signed by its caller's certificate. Connections are opened on first use and
+// kept until the server stops, which suits the handful of operators of a fund
+// administrator rather than an unbounded set of users.
+type GatewayPool struct {
+	mu          sync.Mutex
+	connect     func(label string) (*Connection, error)
+	connections map[string]*pooledConnection
+}
+
+func NewGatewayPool(connect func(label string) (*Connection, error)) *GatewayPool {
+	return &GatewayPool{
+		connect:     connect,
+		connections: map[string]*pooledConnection{},
+	}
+}
+
+// Returns the connection for the identity, connecting once however many requests
+// from the identity arrive at the same time. A failed connection is not kept, so
+// the next request tries again.
+func (p *GatewayPool) Get(label string) (*Connection, error) {
+	p.mu.Lock()
+	pooled, ok := p.connections[label]
+	if !ok {
+		pooled = &pooledConnection{}
+		p.connections[label] = pooled
+	}
+	p.mu.Unlock()
+
+	pooled.once.Do(func() {
+		pooled.connection, pooled.err = p.connect(label)
+	})
+	if pooled.err != nil {
+		p.mu.Lock()
+		if p.connections[label] == pooled {
+			delete(p.connections, label)
+		}
+		p.mu.Unlock()
+		return nil, pooled.err
+	}
+	return pooled.connection, nil
+}
+
+func (p *GatewayPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for label, pooled := range p.connections {
+		if pooled.connection != nil && pooled.connection.Gateway != nil {
+			pooled.connection.Gateway.Close()
+		}
+		delete(p.connections, label)
+	}
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatewayPoolConnectsOncePerIdentity(t *testing.T) {
+	var mu sync.Mutex
+	connects := map[string]int{}
+	pool := NewGatewayPool(func(label string) (*Connection, error) {
+		mu.Lock()
+		connects[label] += 1
+		mu.Unlock()
+		return &Connection{Contract: &gateway.Contract{}}, nil
+	})
+
+	var wg sync.WaitGroup
+	contracts := make([]*gateway.Contract, 10)
+	for i := range contracts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			connection, err := pool.Get("alice")
+			assert.Nil(t, err)
+			contracts[i] = connection.Contract
+		}(i)
+	}
+	wg.Wait()
+	for _, contract := range contracts {
+		assert.Same(t, contracts[0], contract)
+	}
+
+	bob, err := pool.Get("bob")
+	assert.Nil(t, err)
+	assert.NotSame(t, contracts[0], bob.Contract)
+	assert.Equal(t, map[string]int{"alice": 1, "bob": 1}, connects)
+}
+
+func TestGatewayPoolRetriesFailedConnections(t *testing.T) {
+	attempts := 0
+	pool := NewGatewayPool(func(label string) (*Connection, error) {
+		attempts += 1
+		if attempts == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return &Connection{}, nil
+	})
+	_, err := pool.Get("alice")
+	assert.EqualError(t, err, "connection refused")
+	_, err = pool.Get("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// How a submission is retried when the peers invalidate it because a key it read
+// was changed by another transaction in the meantime. Each retry waits up to twice
+// as long as the one before, with jitter so that the transactions that conflicted
+// do not collide again.
+type RetryPolicy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DEFAULT_RETRY_POLICY = RetryPolicy{
+	Attempts:       5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// Replaced by tests so that retries do not wait
+var sleep = time.Sleep
+
+// Reports whether the transaction was invalidated by a read conflict. A
+// transaction with a conflict had no effect on the ledger, so it is safe to submit
+// it again.
+func IsMVCCConflict(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok || s.Group != status.EventServerStatus {
+		return false
+	}
+	code := peer.TxValidationCode(s.Code)
+	return code == peer.TxValidationCode_MVCC_READ_CONFLICT || code == peer.TxValidationCode_PHANTOM_READ_CONFLICT
+}
+
+// Calls submit until it succeeds, fails with anything but a read conflict, or
+// runs out of attempts. Returns the number of attempts made.
+func (p RetryPolicy) Do(submit func() error) (int, error) {
+	backoff := p.InitialBackoff
+	attempt := 1
+	for {
+		err := submit()
+		if err == nil || !IsMVCCConflict(err) || attempt >= p.Attempts {
+			return attempt, err
+		}
+		sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		backoff *= 2
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+		attempt += 1
+	}
+}
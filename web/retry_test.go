@@ -0,0 +1,78 @@
+package web
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/stretchr/testify/assert"
+)
+
+func mvccConflict() error {
+	return status.New(status.EventServerStatus, int32(peer.TxValidationCode_MVCC_READ_CONFLICT), "received invalid transaction", nil)
+}
+
+func noSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+	t.Cleanup(func() {
+		sleep = time.Sleep
+	})
+	return &waits
+}
+
+func TestIsMVCCConflict(t *testing.T) {
+	assert.True(t, IsMVCCConflict(mvccConflict()))
+	phantom := status.New(status.EventServerStatus, int32(peer.TxValidationCode_PHANTOM_READ_CONFLICT), "received invalid transaction", nil)
+	assert.True(t, IsMVCCConflict(phantom))
+	policy := status.New(status.EventServerStatus, int32(peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), "received invalid transaction", nil)
+	assert.False(t, IsMVCCConflict(policy))
+	assert.False(t, IsMVCCConflict(errors.New("chaincode error")))
+}
+
+func TestRetryPolicyRetriesConflicts(t *testing.T) {
+	waits := noSleep(t)
+	calls := 0
+	attempts, err := DEFAULT_RETRY_POLICY.Do(func() error {
+		calls += 1
+		if calls < 3 {
+			return mvccConflict()
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, *waits, 2)
+	for i, wait := range *waits {
+		limit := DEFAULT_RETRY_POLICY.InitialBackoff << uint(i)
+		assert.True(t, wait >= limit/2 && wait <= limit, "wait %d was %s", i, wait)
+	}
+}
+
+func TestRetryPolicyGivesUp(t *testing.T) {
+	waits := noSleep(t)
+	policy := RetryPolicy{Attempts: 4, InitialBackoff: time.Second, MaxBackoff: 2 * time.Second}
+	attempts, err := policy.Do(func() error {
+		return mvccConflict()
+	})
+	assert.True(t, IsMVCCConflict(err))
+	assert.Equal(t, 4, attempts)
+	assert.Len(t, *waits, 3)
+	assert.True(t, (*waits)[2] <= policy.MaxBackoff)
+}
+
+func TestRetryPolicyDoesNotRetryOtherErrors(t *testing.T) {
+	noSleep(t)
+	calls := 0
+	attempts, err := DEFAULT_RETRY_POLICY.Do(func() error {
+		calls += 1
+		return errors.New("chaincode error")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 1, calls)
+}
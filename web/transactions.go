@@ -0,0 +1,171 @@
+package web
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	uuid "github.com/satori/go.uuid"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+const TRANSACTION_STATUS_PENDING string = "pending"
+const TRANSACTION_STATUS_VALID string = "valid"
+const TRANSACTION_STATUS_MVCC_CONFLICT string = "mvcc_conflict"
+const TRANSACTION_STATUS_ENDORSEMENT_FAILURE string = "endorsement_failure"
+const TRANSACTION_STATUS_INVALID string = "invalid"
+const TRANSACTION_STATUS_TIMEOUT string = "timeout"
+const TRANSACTION_STATUS_FAILED string = "failed"
+
+// Number of completed transactions kept for polling before the oldest are dropped
+const DEFAULT_TRANSACTION_HISTORY int = 10000
+
+// The state of a transaction submitted in the background. ID is assigned by the
+// server when the transaction is accepted, since Fabric only assigns its own id once
+// the proposal is endorsed. Subject is the caller that submitted it, and only that
+// caller can read the record.
+type TransactionRecord struct {
+	ID             string           `json:"txId"`
+	Transaction    string           `json:"transaction"`
+	Subject        string           `json:"subject,omitempty"`
+	LedgerId       string           `json:"ledgerId,omitempty"`
+	Status         string           `json:"status"`
+	Attempts       int              `json:"attempts,omitempty"`
+	FabricTxId     string           `json:"fabricTxId,omitempty"`
+	BlockNumber    uint64           `json:"blockNumber,omitempty"`
+	ValidationCode string           `json:"validationCode,omitempty"`
+	Result         string           `json:"result,omitempty"`
+	Error          *pkgErrors.Error `json:"error,omitempty"`
+	SubmittedAt    time.Time        `json:"submittedAt"`
+	CompletedAt    *time.Time       `json:"completedAt,omitempty"`
+}
+
+func (r *TransactionRecord) Pending() bool {
+	return r.Status == TRANSACTION_STATUS_PENDING
+}
+
+// Keeps the records of background transactions in memory. Records are lost when
+// the server restarts, so clients that need certainty after a restart should
+// read the document the transaction created.
+type TransactionTracker struct {
+	mu        sync.Mutex
+	records   map[string]*TransactionRecord
+	pending   map[string]string
+	completed []string
+	history   int
+}
+
+func NewTransactionTracker(history int) *TransactionTracker {
+	return &TransactionTracker{
+		records: map[string]*TransactionRecord{},
+		pending: map[string]string{},
+		history: history,
+	}
+}
+
+// Records a new pending transaction. A transaction that writes a ledger id which
+// a pending transaction is already writing, e.g. a retried request with the same
+// Idempotency-Key, is not started again and the pending record is returned.
+func (t *TransactionTracker) Start(subject string, transaction string, ledgerId string) (TransactionRecord, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ledgerId != "" {
+		if id, ok := t.pending[ledgerId]; ok {
+			return *t.records[id], false
+		}
+	}
+	record := &TransactionRecord{
+		ID:          uuid.NewV4().String(),
+		Transaction: transaction,
+		Subject:     subject,
+		LedgerId:    ledgerId,
+		Status:      TRANSACTION_STATUS_PENDING,
+		SubmittedAt: time.Now().UTC(),
+	}
+	t.records[record.ID] = record
+	if ledgerId != "" {
+		t.pending[ledgerId] = record.ID
+	}
+	return *record, true
+}
+
+// Records the outcome of the last attempt at a transaction. The commit event is
+// nil when that attempt never reached the orderer.
+func (t *TransactionTracker) Complete(id string, attempts int, result []byte, event *fab.TxStatusEvent, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	record, ok := t.records[id]
+	if !ok {
+		return
+	}
+	record.Status = transactionStatus(event, err)
+	record.Attempts = attempts
+	record.Result = string(result)
+	if event != nil {
+		record.FabricTxId = event.TxID
+		record.BlockNumber = event.BlockNumber
+		record.ValidationCode = event.TxValidationCode.String()
+	}
+	if err != nil {
+		coded, ok := pkgErrors.From(err)
+		if !ok {
+			coded = pkgErrors.New(pkgErrors.CODE_INTERNAL, err.Error())
+		}
+		record.Error = coded
+	}
+	completedAt := time.Now().UTC()
+	record.CompletedAt = &completedAt
+	if record.LedgerId != "" && t.pending[record.LedgerId] == id {
+		delete(t.pending, record.LedgerId)
+	}
+	t.completed = append(t.completed, id)
+	for len(t.completed) > t.history {
+		delete(t.records, t.completed[0])
+		t.completed = t.completed[1:]
+	}
+}
+
+func (t *TransactionTracker) Get(id string) (TransactionRecord, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	record, ok := t.records[id]
+	if !ok {
+		return TransactionRecord{}, false
+	}
+	return *record, true
+}
+
+// Classifies the outcome of a submission. A commit event means the transaction
+// was ordered, and its validation code says whether the peers accepted it.
+// Without one the transaction either failed endorsement, failed to reach the
+// orderer, or timed out waiting for the block, in which case it may still commit.
+func transactionStatus(event *fab.TxStatusEvent, err error) string {
+	if event != nil {
+		switch event.TxValidationCode {
+		case peer.TxValidationCode_VALID:
+			return TRANSACTION_STATUS_VALID
+		case peer.TxValidationCode_MVCC_READ_CONFLICT, peer.TxValidationCode_PHANTOM_READ_CONFLICT:
+			return TRANSACTION_STATUS_MVCC_CONFLICT
+		case peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE:
+			return TRANSACTION_STATUS_ENDORSEMENT_FAILURE
+		default:
+			return TRANSACTION_STATUS_INVALID
+		}
+	}
+	if err == nil {
+		return TRANSACTION_STATUS_VALID
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return TRANSACTION_STATUS_ENDORSEMENT_FAILURE
+	}
+	switch {
+	case s.Group == status.ClientStatus && s.Code == status.Timeout.ToInt32():
+		return TRANSACTION_STATUS_TIMEOUT
+	case s.Group == status.OrdererClientStatus || s.Group == status.OrdererServerStatus:
+		return TRANSACTION_STATUS_FAILED
+	}
+	return TRANSACTION_STATUS_ENDORSEMENT_FAILURE
+}
@@ -0,0 +1,111 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/stretchr/testify/assert"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
+)
+
+func TestTransactionStatus(t *testing.T) {
+	timeout := status.New(status.ClientStatus, status.Timeout.ToInt32(), "Execute didn't receive block event", nil)
+	orderer := status.New(status.OrdererClientStatus, status.ConnectionFailed.ToInt32(), "orderer unavailable", nil)
+	chaincode := fmt.Errorf("Failed to submit: %s", pkgErrors.NegativeCapitalAccountBalanceError)
+	for _, test := range []struct {
+		code     *peer.TxValidationCode
+		err      error
+		expected string
+	}{
+		{validationCode(peer.TxValidationCode_VALID), nil, TRANSACTION_STATUS_VALID},
+		{validationCode(peer.TxValidationCode_MVCC_READ_CONFLICT), errors.New("received invalid transaction"), TRANSACTION_STATUS_MVCC_CONFLICT},
+		{validationCode(peer.TxValidationCode_PHANTOM_READ_CONFLICT), errors.New("received invalid transaction"), TRANSACTION_STATUS_MVCC_CONFLICT},
+		{validationCode(peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), errors.New("received invalid transaction"), TRANSACTION_STATUS_ENDORSEMENT_FAILURE},
+		{validationCode(peer.TxValidationCode_DUPLICATE_TXID), errors.New("received invalid transaction"), TRANSACTION_STATUS_INVALID},
+		{nil, chaincode, TRANSACTION_STATUS_ENDORSEMENT_FAILURE},
+		{nil, timeout, TRANSACTION_STATUS_TIMEOUT},
+		{nil, orderer, TRANSACTION_STATUS_FAILED},
+	} {
+		var event *fab.TxStatusEvent
+		if test.code != nil {
+			event = &fab.TxStatusEvent{TxID: "fabricTxId", TxValidationCode: *test.code}
+		}
+		assert.Equal(t, test.expected, transactionStatus(event, test.err))
+	}
+}
+
+func validationCode(code peer.TxValidationCode) *peer.TxValidationCode {
+	return &code
+}
+
+func TestTransactionTrackerRecordsOutcome(t *testing.T) {
+	tracker := NewTransactionTracker(DEFAULT_TRANSACTION_HISTORY)
+	record, started := tracker.Start("alice", "CreateCapitalAccountAction", "testActionId")
+	assert.True(t, started)
+	assert.True(t, record.Pending())
+
+	event := &fab.TxStatusEvent{TxID: "fabricTxId", TxValidationCode: peer.TxValidationCode_VALID, BlockNumber: 7}
+	tracker.Complete(record.ID, 2, []byte("result"), event, nil)
+
+	completed, ok := tracker.Get(record.ID)
+	assert.True(t, ok)
+	assert.Equal(t, TRANSACTION_STATUS_VALID, completed.Status)
+	assert.Equal(t, "fabricTxId", completed.FabricTxId)
+	assert.Equal(t, uint64(7), completed.BlockNumber)
+	assert.Equal(t, "VALID", completed.ValidationCode)
+	assert.Equal(t, "result", completed.Result)
+	assert.Equal(t, 2, completed.Attempts)
+	assert.Nil(t, completed.Error)
+	assert.NotNil(t, completed.CompletedAt)
+}
+
+func TestTransactionTrackerKeepsChaincodeErrorCode(t *testing.T) {
+	tracker := NewTransactionTracker(DEFAULT_TRANSACTION_HISTORY)
+	record, _ := tracker.Start("alice", "CreateCapitalAccountAction", "testActionId")
+	chaincodeErr := pkgErrors.NegativeCapitalAccountBalanceError.WithDetail("capitalAccount", "testAccountId")
+	tracker.Complete(record.ID, 1, nil, nil, fmt.Errorf("Failed to submit: %s", chaincodeErr))
+
+	completed, _ := tracker.Get(record.ID)
+	assert.Equal(t, TRANSACTION_STATUS_ENDORSEMENT_FAILURE, completed.Status)
+	assert.Equal(t, pkgErrors.CODE_NEGATIVE_BALANCE, completed.Error.Code)
+	assert.Equal(t, "testAccountId", completed.Error.Details["capitalAccount"])
+}
+
+func TestTransactionTrackerDeduplicatesPendingLedgerIds(t *testing.T) {
+	tracker := NewTransactionTracker(DEFAULT_TRANSACTION_HISTORY)
+	first, started := tracker.Start("alice", "CreateFund", "testFundId")
+	assert.True(t, started)
+	retry, started := tracker.Start("alice", "CreateFund", "testFundId")
+	assert.False(t, started)
+	assert.Equal(t, first.ID, retry.ID)
+
+	tracker.Complete(first.ID, 1, nil, nil, nil)
+	again, started := tracker.Start("alice", "CreateFund", "testFundId")
+	assert.True(t, started)
+	assert.NotEqual(t, first.ID, again.ID)
+
+	// transactions without a ledger id are never deduplicated
+	a, _ := tracker.Start("alice", "UpdatePortfolioValuation", "")
+	b, _ := tracker.Start("alice", "UpdatePortfolioValuation", "")
+	assert.NotEqual(t, a.ID, b.ID)
+}
+
+func TestTransactionTrackerDropsOldestCompleted(t *testing.T) {
+	tracker := NewTransactionTracker(2)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		record, _ := tracker.Start("alice", "CreateInvestor", fmt.Sprintf("investor%d", i))
+		tracker.Complete(record.ID, 1, nil, nil, nil)
+		ids = append(ids, record.ID)
+	}
+	_, ok := tracker.Get(ids[0])
+	assert.False(t, ok)
+	for _, id := range ids[1:] {
+		_, ok := tracker.Get(id)
+		assert.True(t, ok)
+	}
+}
@@ -7,115 +7,297 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/zacharyfrederick/admin/local"
+	pkgErrors "github.com/zacharyfrederick/admin/types/errors"
 )
 
+// The chaincode transactions the endpoints call, implemented by the gateway's
+// *gateway.Contract and by the in-process local.Backend
+type Contract interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+}
+
 type AdminServer struct {
+	// Nil for the local backend, which has no identities
 	Wallet   *gateway.Wallet
 	Gw       *gateway.Gateway
 	Network  *gateway.Network
-	Contract *gateway.Contract
+	Contract Contract
+	// The wallet label of the server's own identity, which submits the requests
+	// of callers that were not authenticated
+	Identity string
+	// Connections for the identities of authenticated callers
+	Gateways *GatewayPool
+	// Tried in order on every request. Requests are not authenticated when there
+	// are none.
+	Authenticators []Authenticator
+	AuditLog       *AuditLog
+	// The wallet label of each token subject that is not enrolled under its own
+	// subject
+	Identities map[string]string
+	// Transactions submitted in the background by requests with ?async=true
+	Transactions *TransactionTracker
+	Retry        RetryPolicy
+}
+
+// Returns the wallet label the requests of an authenticated subject are signed
+// with. The identity must already be enrolled in the wallet.
+func (a *AdminServer) IdentityFor(subject string) (string, error) {
+	label, ok := a.Identities[subject]
+	if !ok {
+		label = subject
+	}
+	//labels name files in the wallet directory
+	if label == "" || strings.ContainsAny(label, `/\`) || (a.Wallet != nil && !a.Wallet.Exists(label)) {
+		return "", pkgErrors.NoFabricIdentityError.WithDetail("subject", subject)
+	}
+	return label, nil
 }
 
-func ConnectToNetwork() (*AdminServer, error) {
-	err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
+// Returns the contract as seen by the identity, or by the server's own identity
+// when identity is empty. The local backend has a single contract for everyone.
+func (a *AdminServer) contract(identity string) (Contract, error) {
+	if identity == "" || identity == a.Identity || a.Gateways == nil {
+		return a.Contract, nil
+	}
+	connection, err := a.Gateways.Get(identity)
 	if err != nil {
 		return nil, err
 	}
+	return connection.Contract, nil
+}
 
-	wallet, err := gateway.NewFileSystemWallet("wallet")
+// Evaluates the query on a peer as the identity without submitting it for ordering
+func (a *AdminServer) Evaluate(identity string, name string, args ...string) ([]byte, error) {
+	contract, err := a.contract(identity)
 	if err != nil {
 		return nil, err
 	}
+	return contract.EvaluateTransaction(name, args...)
+}
 
-	if !wallet.Exists("appUser") {
-		err = populateWallet(wallet)
+// Submits the transaction as the identity and waits for it to commit, submitting
+// it again when it is invalidated by a read conflict
+func (a *AdminServer) Submit(identity string, name string, args ...string) ([]byte, error) {
+	contract, err := a.contract(identity)
+	if err != nil {
+		return nil, err
+	}
+	var result []byte
+	_, err = a.Retry.Do(func() error {
+		var err error
+		result, err = contract.SubmitTransaction(name, args...)
+		return err
+	})
+	return result, err
+}
+
+// Submits the transaction in the background and returns its pending record
+// without waiting for the endorsement or the commit. Read conflicts are retried
+// as they are by Submit.
+func (a *AdminServer) SubmitAsync(identity string, subject string, ledgerId string, name string, args ...string) (TransactionRecord, error) {
+	contract, err := a.contract(identity)
+	if err != nil {
+		return TransactionRecord{}, err
+	}
+	record, started := a.Transactions.Start(subject, name, ledgerId)
+	if !started {
+		return record, nil
+	}
+	go func() {
+		var result []byte
+		var event *fab.TxStatusEvent
+		attempts, err := a.Retry.Do(func() error {
+			var err error
+			result, event, err = submitWithEvent(contract, name, args)
+			return err
+		})
+		a.Transactions.Complete(record.ID, attempts, result, event, err)
+	}()
+	return record, nil
+}
+
+// Submits the transaction and returns its commit event, which only a contract on
+// a Fabric network has
+func submitWithEvent(contract Contract, name string, args []string) ([]byte, *fab.TxStatusEvent, error) {
+	gatewayContract, ok := contract.(*gateway.Contract)
+	if !ok {
+		result, err := contract.SubmitTransaction(name, args...)
+		return result, nil, err
+	}
+	//a transaction can only be submitted once, so every attempt creates a new one
+	txn, err := gatewayContract.CreateTransaction(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit := txn.RegisterCommitEvent()
+	result, err := txn.Submit(args...)
+	var event *fab.TxStatusEvent
+	select {
+	case event = <-commit:
+	default:
+	}
+	return result, event, err
+}
+
+// Closes the gateway connections of a server on a Fabric network
+func (a *AdminServer) Close() {
+	if a.Gateways != nil {
+		a.Gateways.Close()
+	}
+}
+
+func ConnectToNetwork(cfg Config) (*AdminServer, error) {
+	if cfg.Network.DiscoveryAsLocalhost {
+		err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"..",
-		"..",
-		"..",
-		"fabric-samples",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"connection-org1.yaml",
-	)
+	wallet, err := gateway.NewFileSystemWallet(cfg.Identity.WalletDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !wallet.Exists(cfg.Identity.Label) {
+		err = populateWallet(wallet, cfg.Identity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	adminApp, err := newAdminServer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	gateways := NewGatewayPool(func(label string) (*Connection, error) {
+		return connect(cfg.Network, wallet, label)
+	})
+	connection, err := gateways.Get(cfg.Identity.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	adminApp.Wallet = wallet
+	adminApp.Gw = connection.Gateway
+	adminApp.Contract = connection.Contract
+	adminApp.Network = connection.Network
+	adminApp.Identity = cfg.Identity.Label
+	adminApp.Gateways = gateways
+	return adminApp, nil
+}
+
+// Runs the chaincode in-process instead of connecting to a network, see the
+// local package
+func OpenLocal(cfg Config) (*AdminServer, error) {
+	backend, err := local.Open(cfg.Local.DataFile)
+	if err != nil {
+		return nil, err
+	}
+	adminApp, err := newAdminServer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	adminApp.Contract = backend
+	return adminApp, nil
+}
+
+// Returns a server with the authentication, audit log and transaction tracking of
+// the configuration and no contract yet
+func newAdminServer(cfg Config) (*AdminServer, error) {
+	authenticators, err := NewAuthenticators(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
 
+	auditLog, err := OpenAuditLog(cfg.Audit.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminServer{
+		Authenticators: authenticators,
+		AuditLog:       auditLog,
+		Identities:     cfg.Auth.Identities,
+		Transactions:   NewTransactionTracker(DEFAULT_TRANSACTION_HISTORY),
+		Retry:          DEFAULT_RETRY_POLICY,
+	}, nil
+}
+
+// Connects to the channel as the wallet identity
+func connect(cfg NetworkConfig, wallet *gateway.Wallet, label string) (*Connection, error) {
 	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
+		gateway.WithConfig(config.FromFile(filepath.Clean(cfg.ConnectionProfile))),
+		gateway.WithIdentity(wallet, label),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	network, err := gw.GetNetwork("mychannel")
+	network, err := gw.GetNetwork(cfg.Channel)
 	if err != nil {
+		gw.Close()
 		return nil, err
 	}
 
-	contract := network.GetContract("admin")
+	contract := network.GetContract(cfg.Chaincode)
 
 	if contract == nil {
+		gw.Close()
 		return nil, errors.New("contract is nil")
 	}
 
-	adminApp := &AdminServer{Wallet: wallet, Gw: gw, Contract: contract, Network: network}
-
-	return adminApp, nil
+	return &Connection{Gateway: gw, Network: network, Contract: contract}, nil
 }
 
-func populateWallet(wallet *gateway.Wallet) error {
-	log.Println("============ Populating wallet ============")
-	credPath := filepath.Join(
-		"..",
-		"..",
-		"..",
-		"..",
-		"..",
-		"fabric-samples",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"users",
-		"User1@org1.example.com",
-		"msp",
-	)
+func populateWallet(wallet *gateway.Wallet, identity IdentityConfig) error {
+	log.Printf("============ Populating wallet with %s ============", identity.Label)
+	if identity.Cert == "" || identity.Key == "" {
+		return fmt.Errorf("the wallet has no identity %s and identity.cert and identity.key are not set", identity.Label)
+	}
 
-	certPath := filepath.Join(credPath, "signcerts", "cert.pem")
 	// read the certificate pem
-	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
+	cert, err := ioutil.ReadFile(filepath.Clean(identity.Cert))
 	if err != nil {
 		return err
 	}
 
-	keyDir := filepath.Join(credPath, "keystore")
-	// there's a single file in this dir containing the private key
-	files, err := ioutil.ReadDir(keyDir)
+	keyPath, err := findKey(identity.Key)
 	if err != nil {
 		return err
 	}
-	if len(files) != 1 {
-		return fmt.Errorf("keystore folder should have contain one file")
-	}
-	keyPath := filepath.Join(keyDir, files[0].Name())
 	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
 	if err != nil {
 		return err
 	}
 
-	identity := gateway.NewX509Identity("Org1MSP", string(cert), string(key))
+	return wallet.Put(identity.Label, gateway.NewX509Identity(identity.MSPID, string(cert), string(key)))
+}
 
-	return wallet.Put("appUser", identity)
+// Returns the key file at path, or the single file in path when it is a keystore
+// directory as generated by cryptogen and the Fabric CA client
+func findKey(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("keystore folder %s should contain one file", path)
+	}
+	return filepath.Join(path, files[0].Name()), nil
 }